@@ -10,6 +10,8 @@
 package gvar
 
 import (
+    "bytes"
+    "encoding/gob"
     "gitee.com/johng/gf/g/container/gtype"
     "gitee.com/johng/gf/g/os/gtime"
     "gitee.com/johng/gf/g/util/gconv"
@@ -105,4 +107,34 @@ func (v *Var) GTime(format...string) *gtime.Time {
 // 将变量转换为对象，注意 objPointer 参数必须为struct指针
 func (v *Var) Struct(objPointer interface{}, attrMapping...map[string]string) error {
     return gconv.Struct(v.Val(), objPointer, attrMapping...)
-}
\ No newline at end of file
+}
+
+func init() {
+    // 注册变量类型，以便变量作为interface{}类型的值时能够被gob序列化
+    gob.Register(&Var{})
+}
+
+// 变量序列化时使用的包装对象(gob不支持直接对nil的interface{}进行编码)
+type gobValue struct {
+    Value interface{}
+}
+
+// 实现gob.GobEncoder接口，用于变量的序列化(例如缓存到Redis中)，
+// 注意变量值为自定义类型时需要先通过gob.Register注册该类型
+func (v *Var) GobEncode() ([]byte, error) {
+    buffer := bytes.NewBuffer(nil)
+    if err := gob.NewEncoder(buffer).Encode(&gobValue{v.Val()}); err != nil {
+        return nil, err
+    }
+    return buffer.Bytes(), nil
+}
+
+// 实现gob.GobDecoder接口，用于变量的反序列化
+func (v *Var) GobDecode(data []byte) error {
+    value := gobValue{}
+    if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+        return err
+    }
+    v.Set(value.Value)
+    return nil
+}
@@ -61,6 +61,16 @@ func Decrypt(cipherText []byte, key []byte, iv...[]byte) ([]byte, error) {
     blockModel := cipher.NewCBCDecrypter(block, ivValue)
     plainText  := make([]byte, len(cipherText))
     blockModel.CryptBlocks(plainText, cipherText)
+    // 密钥错误时填充数据无效，检查所有的填充字节，避免去除填充时越界
+    padding := int(plainText[len(plainText) - 1])
+    if padding == 0 || padding > blockSize {
+        return nil, errors.New("invalid padding")
+    }
+    for _, b := range plainText[len(plainText) - padding:] {
+        if int(b) != padding {
+            return nil, errors.New("invalid padding")
+        }
+    }
     plainText = PKCS5UnPadding(plainText)
 
     return plainText, nil
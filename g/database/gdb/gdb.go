@@ -14,10 +14,13 @@ import (
     "database/sql"
     "errors"
     "fmt"
+    "gitee.com/johng/gf/g/container/garray"
+    "gitee.com/johng/gf/g/container/gmap"
     "gitee.com/johng/gf/g/container/gring"
     "gitee.com/johng/gf/g/container/gtype"
     "gitee.com/johng/gf/g/container/gvar"
     "gitee.com/johng/gf/g/os/gcache"
+    "gitee.com/johng/gf/g/os/glog"
     "gitee.com/johng/gf/g/util/grand"
     _ "gitee.com/johng/gf/third/github.com/go-sql-driver/mysql"
     "time"
@@ -35,10 +38,12 @@ type DB interface {
 
     // 内部实现API的方法(不同数据库可覆盖这些方法实现自定义的操作)
     doQuery(link dbLink, query string, args ...interface{}) (rows *sql.Rows, err error)
+    doGetAll(link dbLink, query string, args ...interface{}) (result Result, err error)
     doExec(link dbLink, query string, args ...interface{}) (result sql.Result, err error)
     doPrepare(link dbLink, query string) (*sql.Stmt, error)
-    doInsert(link dbLink, table string, data Map, option int) (result sql.Result, err error)
-    doBatchInsert(link dbLink, table string, list List, batch int, option int) (result sql.Result, err error)
+    doInsert(link dbLink, table string, data Map, option int, conflictKeys []string) (result sql.Result, err error)
+    doBatchInsert(link dbLink, table string, list List, batch int, option int, conflictKeys []string) (result sql.Result, err error)
+    doBatchUpdate(link dbLink, table string, list List, batch int, keys []string) (result sql.Result, err error)
     doUpdate(link dbLink, table string, data interface{}, condition interface{}, args ...interface{}) (result sql.Result, err error)
     doDelete(link dbLink, table string, condition interface{}, args ...interface{}) (result sql.Result, err error)
 
@@ -53,6 +58,9 @@ type DB interface {
     Master() (*sql.DB, error)
     Slave() (*sql.DB, error)
 
+    // 关闭底层所有的数据库连接池
+    Close() error
+
     // Ping
 	PingMaster() error
 	PingSlave() error
@@ -63,12 +71,13 @@ type DB interface {
 	// 数据表插入/更新/保存操作
 	Insert(table string, data Map) (sql.Result, error)
 	Replace(table string, data Map) (sql.Result, error)
-	Save(table string, data Map) (sql.Result, error)
+	Save(table string, data Map, conflictKeys...string) (sql.Result, error)
 
 	// 数据表插入/更新/保存操作(批量)
 	BatchInsert(table string, list List, batch int) (sql.Result, error)
 	BatchReplace(table string, list List, batch int) (sql.Result, error)
-	BatchSave(table string, list List, batch int) (sql.Result, error)
+	BatchSave(table string, list List, batch int, conflictKeys...string) (sql.Result, error)
+	BatchUpdate(table string, list List, batch int, keys...string) (sql.Result, error)
 
 	// 数据修改/删除
 	Update(table string, data interface{}, condition interface{}, args ...interface{}) (sql.Result, error)
@@ -78,6 +87,9 @@ type DB interface {
 	Table(tables string) *Model
 	From(tables string) *Model
 
+	// 创建写后读的会话对象
+	Session() *Session
+
 	// 设置管理
     SetDebug(debug bool)
     SetSchema(schema string)
@@ -86,14 +98,38 @@ type DB interface {
     SetMaxIdleConns(n int)
     SetMaxOpenConns(n int)
     SetConnMaxLifetime(n int)
+    SetQueryCache(cache *gcache.Cache)
+
+    // 集群管理
+    SetHealthCheckInterval(interval time.Duration)
+    SetSlavePolicy(policy int)
+    SetStickyWindow(window time.Duration)
+    GetNodeStatus() []NodeStatus
+
+    // 日志及钩子管理
+    AddHook(hook Hook)
+    RemoveHook(hook Hook)
+    SetLogger(logger *glog.Logger)
+    SetSlowThreshold(threshold time.Duration)
+
+    // 数据表结构
+    Tables() ([]string, error)
+    TableFields(table string) ([]*TableField, error)
 
 	// 内部方法接口
 	getCache() (*gcache.Cache)
+	getQueryCache() (*gcache.Cache)
 	getChars() (charLeft string, charRight string)
 	getDebug() bool
+	getGroup() string
+	getStickyWindow() time.Duration
     filterFields(table string, data map[string]interface{}) map[string]interface{}
     convertValue(fieldValue interface{}, fieldType string) interface{}
     getTableFields(table string) (map[string]string, error)
+    getTables() ([]string, error)
+    getTableFieldList(table string) ([]*TableField, error)
+    getPrimaryKeys(table string) ([]string, error)
+    formatInsertSql(table string, fields []string, rows int, option int, conflictKeys []string) (string, error)
     rowsToResult(rows *sql.Rows) (Result, error)
     handleSqlBeforeExec(sql string) string
 }
@@ -112,21 +148,44 @@ type dbBase struct {
 	debug            *gtype.Bool                  // (默认关闭)是否开启调试模式，当开启时会启用一些调试特性
 	sqls             *gring.Ring                  // (debug=true时有效)已执行的SQL列表
 	cache            *gcache.Cache                // 数据库缓存，包括底层连接池对象缓存及查询缓存；需要注意的是，事务查询不支持查询缓存
+    queryCache       *gtype.Interface             // 自定义的查询缓存对象(*gcache.Cache)，例如使用Redis缓存适配器实现多进程共享的查询缓存
     schema           *gtype.String                // 手动切换的数据库名称
     tables           map[string]map[string]string // 数据库表结构
 	maxIdleConnCount *gtype.Int                   // 连接池最大限制的连接数
     maxOpenConnCount *gtype.Int                   // 连接池最大打开的连接数
     maxConnLifetime  *gtype.Int                   // (单位秒)连接对象可重复使用的时间长度
+    health           *gmap.StringInterfaceMap     // 节点健康状态，键名为节点配置字符串，键值为*nodeHealth
+    healthEntry      *gtype.Interface             // 节点健康检查的定时任务(*gtimer.Entry)
+    slavePolicy      *gtype.Int                   // slave节点的选择策略
+    stickyWindow     *gtype.Int64                 // (单位纳秒)会话写后读的粘滞时间窗口，窗口内会话的查询操作将在master节点上执行
+    hooks            *garray.Array                // SQL执行钩子列表
+    logger           *gtype.Interface             // 自定义的日志对象(*glog.Logger)
+    slowThreshold    *gtype.Int64                 // (单位纳秒)慢查询阈值
 }
 
 // 执行的SQL对象
 type Sql struct {
-	Sql   string        // SQL语句(可能带有预处理占位符)
-	Args  []interface{} // 预处理参数值列表
-	Error error         // 执行结果(nil为成功)
-	Start int64         // 执行开始时间(毫秒)
-	End   int64         // 执行结束时间(毫秒)
-	Func  string        // 执行方法
+	Sql          string        // SQL语句(可能带有预处理占位符)
+	Args         []interface{} // 预处理参数值列表
+	Error        error         // 执行结果(nil为成功)
+	Start        int64         // 执行开始时间(毫秒)
+	End          int64         // 执行结束时间(毫秒)
+	Func         string        // 执行方法(Query/Exec/Prepare)
+	Duration     time.Duration // 执行耗时
+	RowsAffected int64         // 影响的记录数(仅对非查询操作有效)
+	Caller       string        // 调用位置(文件:行号)，仅在调试模式或者设置了钩子时有效
+}
+
+// 数据表字段结构
+type TableField struct {
+    Index   int    // 字段在表中的位置(从0开始)
+    Name    string // 字段名称
+    Type    string // 字段类型(小写)，例如：int(10) unsigned, varchar(45)
+    Null    bool   // 是否允许为NULL
+    Key     string // 索引类型：PRI(主键), UNI(唯一索引), MUL(普通索引)，不同数据库的支持程度不同
+    Default Value  // 默认值
+    Extra   string // 扩展信息，例如：auto_increment
+    Comment string // 字段注释
 }
 
 // 返回数据表记录值
@@ -171,10 +230,18 @@ func New(groupName ...string) (db DB, err error) {
                 group            : group,
                 debug            : gtype.NewBool(),
                 cache            : gcache.New(),
+                queryCache       : gtype.NewInterface(),
                 schema           : gtype.NewString(),
                 maxIdleConnCount : gtype.NewInt(),
                 maxOpenConnCount : gtype.NewInt(),
                 maxConnLifetime  : gtype.NewInt(gDEFAULT_CONN_MAX_LIFE_TIME),
+                health           : gmap.NewStringInterfaceMap(),
+                healthEntry      : gtype.NewInterface(),
+                slavePolicy      : gtype.NewInt(SLAVE_POLICY_PRIORITY),
+                stickyWindow     : gtype.NewInt64(),
+                hooks            : garray.NewArray(0, 0),
+                logger           : gtype.NewInterface(),
+                slowThreshold    : gtype.NewInt64(),
             }
             switch node.Type {
                 case "mysql":
@@ -187,6 +254,8 @@ func New(groupName ...string) (db DB, err error) {
                     base.db = &dbSqlite{dbBase : base}
                 case "oracle":
                     base.db = &dbOracle{dbBase : base}
+                case "mock":
+                    base.db = &dbMock{dbMysql : &dbMysql{dbBase : base}}
                 default:
                     return nil, errors.New(fmt.Sprintf(`unsupported database type "%s"`, node.Type))
             }
@@ -201,10 +270,25 @@ func New(groupName ...string) (db DB, err error) {
 
 // 获取指定数据库角色的一个配置项，内部根据权重计算负载均衡
 func getConfigNodeByGroup(group string, master bool) (*ConfigNode, error) {
+    masterList, slaveList, err := getConfigGroupNodes(group)
+    if err != nil {
+        return nil, err
+    }
+    if len(slaveList) < 1 {
+        slaveList = masterList
+    }
+    if master {
+        return getConfigNodeByPriority(masterList), nil
+    } else {
+        return getConfigNodeByPriority(slaveList), nil
+    }
+}
+
+// 将指定分组的master, slave集群列表拆分出来，slave列表可能为空
+func getConfigGroupNodes(group string) (masterList ConfigGroup, slaveList ConfigGroup, err error) {
     if list, ok := config.c[group]; ok {
-        // 将master, slave集群列表拆分出来
-        masterList := make(ConfigGroup, 0)
-        slaveList  := make(ConfigGroup, 0)
+        masterList = make(ConfigGroup, 0)
+        slaveList  = make(ConfigGroup, 0)
         for i := 0; i < len(list); i++ {
             if list[i].Role == "slave" {
                 slaveList = append(slaveList, list[i])
@@ -213,18 +297,11 @@ func getConfigNodeByGroup(group string, master bool) (*ConfigNode, error) {
             }
         }
         if len(masterList) < 1 {
-            return nil, errors.New("at least one master node configuration's need to make sense")
-        }
-        if len(slaveList) < 1 {
-            slaveList = masterList
-        }
-        if master {
-            return getConfigNodeByPriority(masterList), nil
-        } else {
-            return getConfigNodeByPriority(slaveList), nil
+            return nil, nil, errors.New("at least one master node configuration's need to make sense")
         }
+        return masterList, slaveList, nil
     } else {
-        return nil, errors.New(fmt.Sprintf("empty database configuration for item name '%s'", group))
+        return nil, nil, errors.New(fmt.Sprintf("empty database configuration for item name '%s'", group))
     }
 }
 
@@ -270,11 +347,16 @@ func getConfigNodeByPriority(cg ConfigGroup) *ConfigNode {
 
 // 获得底层数据库链接对象
 func (bs *dbBase) getSqlDb(master bool) (sqlDb *sql.DB, err error) {
-    // 负载均衡
-    node, err := getConfigNodeByGroup(bs.group, master)
+    // 负载均衡及故障转移
+    node, err := bs.selectConfigNode(master)
     if err != nil {
         return nil, err
     }
+    return bs.getSqlDbByNode(node)
+}
+
+// 获得指定配置节点的底层数据库链接对象
+func (bs *dbBase) getSqlDbByNode(node *ConfigNode) (sqlDb *sql.DB, err error) {
     // 默认值设定
     if node.Charset == "" {
         node.Charset = "utf8"
@@ -307,6 +389,12 @@ func (bs *dbBase) getSqlDb(master bool) (sqlDb *sql.DB, err error) {
     if v != nil && sqlDb == nil {
         sqlDb = v.(*sql.DB)
     }
+    if sqlDb == nil {
+        if err == nil {
+            err = errors.New(fmt.Sprintf(`cannot open database connection for node "%s"`, node.String()))
+        }
+        return nil, err
+    }
     // 是否手动选择数据库
     if v := bs.schema.Val(); v != "" {
         sqlDb.Exec("USE " + v)
@@ -328,3 +416,17 @@ func (bs *dbBase) Master() (*sql.DB, error) {
 func (bs *dbBase) Slave() (*sql.DB, error) {
     return bs.getSqlDb(false)
 }
+
+// 关闭数据库对象，关闭底层所有节点的连接池并停止节点健康检查，关闭后不能再使用该对象
+func (bs *dbBase) Close() (err error) {
+    bs.SetHealthCheckInterval(0)
+    for _, v := range bs.cache.Values() {
+        if sqlDb, ok := v.(*sql.DB); ok {
+            if e := sqlDb.Close(); e != nil && err == nil {
+                err = e
+            }
+        }
+    }
+    bs.cache.Close()
+    return
+}
@@ -8,15 +8,17 @@
 package gdb
 
 import (
+    "bytes"
     "database/sql"
+    "encoding/gob"
     "errors"
     "fmt"
-    "gitee.com/johng/gf/g/container/gvar"
     "gitee.com/johng/gf/g/os/gcache"
     "gitee.com/johng/gf/g/os/gtime"
     "gitee.com/johng/gf/g/util/gconv"
     "gitee.com/johng/gf/g/util/gregex"
     "reflect"
+    "sort"
     "strings"
 )
 
@@ -52,6 +54,8 @@ func (bs *dbBase) PrintQueriedSqls() {
         fmt.Println("    Start:", gtime.NewFromTimeStamp(v.Start).Format("Y-m-d H:i:s.u"))
         fmt.Println("    End  :", gtime.NewFromTimeStamp(v.End).Format("Y-m-d H:i:s.u"))
         fmt.Println("    Cost :", v.End - v.Start, "ms")
+        fmt.Println("    Func :", v.Func)
+        fmt.Println("    Call :", v.Caller)
     }
 }
 
@@ -66,27 +70,14 @@ func (bs *dbBase) Query(query string, args ...interface{}) (rows *sql.Rows, err
 
 // 数据库sql查询操作，主要执行查询
 func (bs *dbBase) doQuery(link dbLink, query string, args ...interface{}) (rows *sql.Rows, err error) {
-    query = bs.db.handleSqlBeforeExec(query)
-    if bs.db.getDebug() {
-        mTime1    := gtime.Millisecond()
-        rows, err  = link.Query(query, args...)
-        mTime2    := gtime.Millisecond()
-        s         := &Sql {
-            Sql   : query,
-            Args  : args,
-            Error : err,
-            Start : mTime1,
-            End   : mTime2,
-        }
-        bs.sqls.Put(s)
-        printSql(s)
-    } else {
-        rows, err = link.Query(query, args ...)
-    }
+    s, err := bs.handleSql("Query", query, args, func(s *Sql) error {
+        rows, s.Error = link.Query(s.Sql, s.Args ...)
+        return s.Error
+    })
     if err == nil {
         return rows, nil
     } else {
-        err = formatError(err, query, args...)
+        err = formatError(err, s.Sql, s.Args...)
     }
     return nil, err
 }
@@ -102,24 +93,14 @@ func (bs *dbBase) Exec(query string, args ...interface{}) (result sql.Result, er
 
 // 执行一条sql，并返回执行情况，主要用于非查询操作
 func (bs *dbBase) doExec(link dbLink, query string, args ...interface{}) (result sql.Result, err error) {
-    query = bs.db.handleSqlBeforeExec(query)
-    if bs.db.getDebug() {
-        mTime1     := gtime.Millisecond()
-        result, err = link.Exec(query, args ...)
-        mTime2     := gtime.Millisecond()
-        s := &Sql{
-            Sql   : query,
-            Args  : args,
-            Error : err,
-            Start : mTime1,
-            End   : mTime2,
+    s, err := bs.handleSql("Exec", query, args, func(s *Sql) error {
+        result, s.Error = link.Exec(s.Sql, s.Args ...)
+        if s.Error == nil && bs.needSqlRecord() {
+            s.RowsAffected, _ = result.RowsAffected()
         }
-        bs.sqls.Put(s)
-        printSql(s)
-    } else {
-        result, err = link.Exec(query, args ...)
-    }
-    return result, formatError(err, query, args...)
+        return s.Error
+    })
+    return result, formatError(err, s.Sql, s.Args...)
 }
 
 // SQL预处理，执行完成后调用返回值sql.Stmt.Exec完成sql操作; 默认执行在Slave上, 通过第二个参数指定执行在Master上
@@ -139,13 +120,29 @@ func (bs *dbBase) Prepare(query string, execOnMaster...bool) (*sql.Stmt, error)
 }
 
 // SQL预处理，执行完成后调用返回值sql.Stmt.Exec完成sql操作
-func (bs *dbBase) doPrepare(link dbLink, query string) (*sql.Stmt, error) {
-    return link.Prepare(query)
+func (bs *dbBase) doPrepare(link dbLink, query string) (stmt *sql.Stmt, err error) {
+    s, err := bs.handleSql("Prepare", query, nil, func(s *Sql) error {
+        stmt, s.Error = link.Prepare(s.Sql)
+        return s.Error
+    })
+    if err != nil {
+        return nil, formatError(err, s.Sql)
+    }
+    return stmt, nil
 }
 
 // 数据库查询，获取查询结果集，以列表结构返回
 func (bs *dbBase) GetAll(query string, args ...interface{}) (Result, error) {
-    rows, err := bs.Query(query, args ...)
+    link, err := bs.db.Slave()
+    if err != nil {
+        return nil, err
+    }
+    return bs.db.doGetAll(link, query, args ...)
+}
+
+// 数据库查询，获取查询结果集，以列表结构返回
+func (bs *dbBase) doGetAll(link dbLink, query string, args ...interface{}) (result Result, err error) {
+    rows, err := bs.db.doQuery(link, query, args ...)
     if err != nil || rows == nil {
         return nil, err
     }
@@ -236,17 +233,18 @@ func (bs *dbBase) Begin() (*TX, error) {
 
 // CURD操作:单条数据写入, 仅仅执行写入操作，如果存在冲突的主键或者唯一索引，那么报错返回
 func (bs *dbBase) Insert(table string, data Map) (sql.Result, error) {
-    return bs.db.doInsert(nil, table, data, OPTION_INSERT)
+    return bs.db.doInsert(nil, table, data, OPTION_INSERT, nil)
 }
 
 // CURD操作:单条数据写入, 如果数据存在(主键或者唯一索引)，那么删除后重新写入一条
 func (bs *dbBase) Replace(table string, data Map) (sql.Result, error) {
-    return bs.db.doInsert(nil, table, data, OPTION_REPLACE)
+    return bs.db.doInsert(nil, table, data, OPTION_REPLACE, nil)
 }
 
-// CURD操作:单条数据写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据
-func (bs *dbBase) Save(table string, data Map) (sql.Result, error) {
-    return bs.db.doInsert(nil, table, data, OPTION_SAVE)
+// CURD操作:单条数据写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据，
+// conflictKeys为判断数据是否存在的冲突字段(默认为主键)，MySQL会根据所有的唯一索引判断，因此会忽略该参数
+func (bs *dbBase) Save(table string, data Map, conflictKeys...string) (sql.Result, error) {
+    return bs.db.doInsert(nil, table, data, OPTION_SAVE, conflictKeys)
 }
 
 // insert、replace, save， ignore操作
@@ -254,21 +252,34 @@ func (bs *dbBase) Save(table string, data Map) (sql.Result, error) {
 // 1: replace: 如果数据存在(主键或者唯一索引)，那么删除后重新写入一条
 // 2: save:    如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据
 // 3: ignore:  如果数据存在(主键或者唯一索引)，那么什么也不做
-func (bs *dbBase) doInsert(link dbLink, table string, data Map, option int) (result sql.Result, err error) {
+func (bs *dbBase) doInsert(link dbLink, table string, data Map, option int, conflictKeys []string) (result sql.Result, err error) {
     var fields []string
-    var values []string
     var params []interface{}
-    charl, charr := bs.db.getChars()
     for k, v := range data {
-        fields = append(fields,   charl + k + charr)
-        values = append(values, "?")
+        fields = append(fields, k)
         params = append(params, v)
     }
-    operation := getInsertOperationByOption(option)
-    updatestr := ""
+    query, err := bs.db.formatInsertSql(table, fields, 1, option, conflictKeys)
+    if err != nil {
+        return nil, err
+    }
+    if link == nil {
+        if link, err = bs.db.Master(); err != nil {
+            return nil, err
+        }
+    }
+    return bs.db.doExec(link, query, params...)
+}
+
+// 生成insert、replace, save， ignore操作的SQL语句(MySQL语法)，rows为写入的记录数，
+// 预处理参数按照记录顺序排列，不同的数据库可覆盖该方法实现各自的语法。
+// MySQL的save操作使用 ON DUPLICATE KEY UPDATE 语法，根据所有的唯一索引判断冲突，因此忽略conflictKeys参数。
+func (bs *dbBase) formatInsertSql(table string, fields []string, rows int, option int, conflictKeys []string) (string, error) {
+    charl, charr := bs.db.getChars()
+    updatestr    := ""
     if option == OPTION_SAVE {
         var updates []string
-        for k, _ := range data {
+        for _, k := range fields {
             updates = append(updates,
                 fmt.Sprintf("%s%s%s=VALUES(%s%s%s)",
                     charl, k, charr,
@@ -276,40 +287,32 @@ func (bs *dbBase) doInsert(link dbLink, table string, data Map, option int) (res
                 ),
             )
         }
-        updatestr = fmt.Sprintf("ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ","))
-    }
-    if link == nil {
-        if link, err = bs.db.Master(); err != nil {
-            return nil, err
-        }
+        updatestr = fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ","))
     }
-    return bs.db.doExec(link, fmt.Sprintf("%s INTO %s(%s) VALUES(%s) %s",
-        operation, table, strings.Join(fields, ","),
-        strings.Join(values, ","), updatestr),
-        params...)
+    return fmt.Sprintf("%s INTO %s(%s) VALUES%s%s",
+        getInsertOperationByOption(option), table, strings.Join(quoteFields(fields, charl, charr), ","),
+        getValuePlaceholders(len(fields), rows), updatestr), nil
 }
 
 // CURD操作:批量数据指定批次量写入
 func (bs *dbBase) BatchInsert(table string, list List, batch int) (sql.Result, error) {
-    return bs.db.doBatchInsert(nil, table, list, batch, OPTION_INSERT)
+    return bs.db.doBatchInsert(nil, table, list, batch, OPTION_INSERT, nil)
 }
 
 // CURD操作:批量数据指定批次量写入, 如果数据存在(主键或者唯一索引)，那么删除后重新写入一条
 func (bs *dbBase) BatchReplace(table string, list List, batch int) (sql.Result, error) {
-    return bs.db.doBatchInsert(nil, table, list, batch, OPTION_REPLACE)
+    return bs.db.doBatchInsert(nil, table, list, batch, OPTION_REPLACE, nil)
 }
 
-// CURD操作:批量数据指定批次量写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据
-func (bs *dbBase) BatchSave(table string, list List, batch int) (sql.Result, error) {
-    return bs.db.doBatchInsert(nil, table, list, batch, OPTION_SAVE)
+// CURD操作:批量数据指定批次量写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据，conflictKeys同Save方法
+func (bs *dbBase) BatchSave(table string, list List, batch int, conflictKeys...string) (sql.Result, error) {
+    return bs.db.doBatchInsert(nil, table, list, batch, OPTION_SAVE, conflictKeys)
 }
 
 // 批量写入数据
-func (bs *dbBase) doBatchInsert(link dbLink, table string, list List, batch int, option int) (result sql.Result, err error) {
-    var keys    []string
-    var values  []string
-    var bvalues []string
-    var params  []interface{}
+func (bs *dbBase) doBatchInsert(link dbLink, table string, list List, batch int, option int, conflictKeys []string) (result sql.Result, err error) {
+    var keys   []string
+    var params []interface{}
     // 判断长度
     if len(list) < 1 {
         return result, errors.New("empty data list")
@@ -321,52 +324,69 @@ func (bs *dbBase) doBatchInsert(link dbLink, table string, list List, batch int,
     }
     // 首先获取字段名称及记录长度
     for k, _ := range list[0] {
-        keys   = append(keys,   k)
-        values = append(values, "?")
-    }
-    charl, charr   := bs.db.getChars()
-    keyStr         := charl + strings.Join(keys, charl + "," + charr) + charr
-    valueHolderStr := "(" + strings.Join(values, ",") + ")"
-    // 操作判断
-    operation := getInsertOperationByOption(option)
-    updatestr := ""
-    if option == OPTION_SAVE {
-        var updates []string
-        for _, k := range keys {
-            updates = append(updates,
-                fmt.Sprintf("%s%s%s=VALUES(%s%s%s)",
-                    charl, k, charr,
-                    charl, k, charr,
-                ),
-            )
-        }
-        updatestr = fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ","))
+        keys = append(keys, k)
     }
     // 构造批量写入数据格式(注意map的遍历是无序的)
+    rows := 0
     for i := 0; i < len(list); i++ {
         for _, k := range keys {
             params = append(params, list[i][k])
         }
-        bvalues = append(bvalues, valueHolderStr)
-        if len(bvalues) == batch {
-            r, err := bs.db.doExec(link, fmt.Sprintf("%s INTO %s(%s) VALUES%s %s",
-                operation, table, keyStr, strings.Join(bvalues, ","),
-                updatestr),
-                params...)
+        rows++
+        if rows == batch || i == len(list) - 1 {
+            query, err := bs.db.formatInsertSql(table, keys, rows, option, conflictKeys)
+            if err != nil {
+                return result, err
+            }
+            r, err := bs.db.doExec(link, query, params...)
             if err != nil {
                 return result, err
             }
-            result  = r
-            params  = params[:0]
-            bvalues = bvalues[:0]
+            result = r
+            params = params[:0]
+            rows   = 0
         }
     }
-    // 处理最后不构成指定批量的数据
-    if len(bvalues) > 0 {
-        r, err := bs.db.doExec(link, fmt.Sprintf("%s INTO %s(%s) VALUES%s %s",
-            operation, table, keyStr, strings.Join(bvalues, ","),
-            updatestr),
-            params...)
+    return result, nil
+}
+
+// CURD操作:批量数据更新，按照keys字段(默认为主键)匹配记录，每一批次数据只需要一次数据库操作，
+// 每条记录中除keys字段外的其他字段将会被更新，记录中不存在的字段保持原值不变。
+func (bs *dbBase) BatchUpdate(table string, list List, batch int, keys...string) (sql.Result, error) {
+    return bs.db.doBatchUpdate(nil, table, list, batch, keys)
+}
+
+// 批量更新数据，使用CASE WHEN语法实现，兼容所有的数据库类型
+func (bs *dbBase) doBatchUpdate(link dbLink, table string, list List, batch int, keys []string) (result sql.Result, err error) {
+    if len(list) < 1 {
+        return result, errors.New("empty data list")
+    }
+    if len(keys) == 0 {
+        if keys, err = bs.db.getPrimaryKeys(table); err != nil {
+            return nil, err
+        }
+        if len(keys) == 0 {
+            return nil, errors.New(fmt.Sprintf(`no keys specified and no primary key found for table "%s"`, table))
+        }
+    }
+    if link == nil {
+        if link, err = bs.db.Master(); err != nil {
+            return
+        }
+    }
+    if batch <= 0 {
+        batch = len(list)
+    }
+    for start := 0; start < len(list); start += batch {
+        end := start + batch
+        if end > len(list) {
+            end = len(list)
+        }
+        query, params, err := bs.formatBatchUpdateSql(table, list[start : end], keys)
+        if err != nil {
+            return result, err
+        }
+        r, err := bs.db.doExec(link, query, params...)
         if err != nil {
             return result, err
         }
@@ -375,6 +395,65 @@ func (bs *dbBase) doBatchInsert(link dbLink, table string, list List, batch int,
     return result, nil
 }
 
+// 生成批量更新的SQL语句及预处理参数，例如：
+// UPDATE user SET nickname=CASE WHEN (id=?) THEN ? WHEN (id=?) THEN ? ELSE nickname END WHERE (id=?) OR (id=?)
+func (bs *dbBase) formatBatchUpdateSql(table string, list List, keys []string) (string, []interface{}, error) {
+    charl, charr := bs.db.getChars()
+    keyMap       := make(map[string]struct{}, len(keys))
+    conditions   := make([]string, len(keys))
+    for i, k := range keys {
+        keyMap[k]     = struct{}{}
+        conditions[i] = fmt.Sprintf("%s%s%s=?", charl, k, charr)
+    }
+    condition := "(" + strings.Join(conditions, " AND ") + ")"
+    // 需要更新的字段(按照名称排序，保证生成的SQL一致)
+    fieldMap  := make(map[string]struct{})
+    for _, m := range list {
+        for _, k := range keys {
+            if _, ok := m[k]; !ok {
+                return "", nil, errors.New(fmt.Sprintf(`key "%s" not found in batch update data`, k))
+            }
+        }
+        for k, _ := range m {
+            if _, ok := keyMap[k]; !ok {
+                fieldMap[k] = struct{}{}
+            }
+        }
+    }
+    fields := make([]string, 0, len(fieldMap))
+    for k, _ := range fieldMap {
+        fields = append(fields, k)
+    }
+    if len(fields) == 0 {
+        return "", nil, errors.New("no fields to update in batch update data")
+    }
+    sort.Strings(fields)
+    params  := make([]interface{}, 0)
+    updates := make([]string, 0, len(fields))
+    for _, field := range fields {
+        buffer := bytes.NewBufferString(fmt.Sprintf("%s%s%s=CASE", charl, field, charr))
+        for _, m := range list {
+            if v, ok := m[field]; ok {
+                buffer.WriteString(" WHEN " + condition + " THEN ?")
+                for _, k := range keys {
+                    params = append(params, m[k])
+                }
+                params = append(params, v)
+            }
+        }
+        buffer.WriteString(fmt.Sprintf(" ELSE %s%s%s END", charl, field, charr))
+        updates = append(updates, buffer.String())
+    }
+    wheres := make([]string, len(list))
+    for i, m := range list {
+        wheres[i] = condition
+        for _, k := range keys {
+            params = append(params, m[k])
+        }
+    }
+    return fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(updates, ","), strings.Join(wheres, " OR ")), params, nil
+}
+
 // CURD操作:数据更新，统一采用sql预处理
 // data参数支持字符串或者关联数组类型，内部会自行做判断处理
 func (bs *dbBase) Update(table string, data interface{}, condition interface{}, args ...interface{}) (sql.Result, error) {
@@ -430,45 +509,36 @@ func (bs *dbBase) doDelete(link dbLink, table string, condition interface{}, arg
     return bs.db.doExec(link, fmt.Sprintf("DELETE FROM %s WHERE %s", table, newWhere), newArgs...)
 }
 
+func init() {
+    // 注册查询结果类型，以便查询结果能够被序列化后缓存(例如使用Redis缓存适配器的查询缓存)
+    gob.Register(Result{})
+}
+
 // 获得缓存对象
 func (bs *dbBase) getCache() *gcache.Cache {
     return bs.cache
 }
 
+// 设置查询缓存对象(Model.Cache使用)，默认使用数据库对象内部的内存缓存。
+// 当使用Redis等需要序列化的缓存适配器时，查询结果将会以gob方式序列化(Result类型已注册)。
+func (bs *dbBase) SetQueryCache(cache *gcache.Cache) {
+    bs.queryCache.Set(cache)
+}
+
+// 获得查询缓存对象
+func (bs *dbBase) getQueryCache() *gcache.Cache {
+    if v := bs.queryCache.Val(); v != nil {
+        return v.(*gcache.Cache)
+    }
+    return bs.cache
+}
+
 // 将数据查询的列表数据*sql.Rows转换为Result类型
 func (bs *dbBase) rowsToResult(rows *sql.Rows) (Result, error) {
-    // 列信息列表, 名称与类型
-    types          := make([]string, 0)
-    columns        := make([]string, 0)
-    columnTypes, _ := rows.ColumnTypes()
-    for _, t := range columnTypes {
-        types   = append(types, t.DatabaseTypeName())
-        columns = append(columns, t.Name())
-    }
-    // 返回结构组装
-    values   := make([]sql.RawBytes, len(columns))
-    scanArgs := make([]interface{}, len(values))
+    iterator := newIterator(bs.db, rows)
     records  := make(Result, 0)
-    for i := range values {
-        scanArgs[i] = &values[i]
-    }
-    for rows.Next() {
-        if err := rows.Scan(scanArgs...); err != nil {
-            return records, err
-        }
-        row := make(Record)
-        // 注意col字段是一个[]byte类型(slice类型本身是一个指针)，多个记录循环时该变量指向的是同一个内存地址
-        for i, col := range values {
-            if col == nil {
-                row[columns[i]] = gvar.New(nil, true)
-            } else {
-                // 由于 sql.RawBytes 是slice类型, 这里必须使用值复制
-                v := make([]byte, len(col))
-                copy(v, col)
-                row[columns[i]] = gvar.New(bs.db.convertValue(v, types[i]), true)
-            }
-        }
-        records = append(records, row)
+    for iterator.Next() {
+        records = append(records, iterator.Record())
     }
-    return records, nil
+    return records, iterator.Err()
 }
@@ -0,0 +1,201 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/gtimer"
+    "sync"
+    "time"
+)
+
+const (
+    SLAVE_POLICY_PRIORITY = 0 // (默认)按照节点权重负载均衡选择slave节点
+    SLAVE_POLICY_LATENCY  = 1 // 选择健康检查延迟最低的slave节点
+    // 节点连续健康检查失败多少次后被剔除
+    gHEALTH_CHECK_MAX_FAILURES = 2
+)
+
+// 节点健康状态
+type nodeHealth struct {
+    alive    *gtype.Bool  // 是否可用
+    latency  *gtype.Int64 // (单位纳秒)最近一次健康检查的延迟
+    failures *gtype.Int   // 连续失败次数
+}
+
+// 数据库节点状态
+type NodeStatus struct {
+    Node     ConfigNode    // 节点配置
+    Alive    bool          // 是否可用(未开启健康检查时始终为true)
+    Latency  time.Duration // 最近一次健康检查的延迟
+    Failures int           // 连续健康检查失败次数
+}
+
+// 设置节点健康检查的时间间隔，开启后将会定时ping集群中的所有节点，
+// 连续失败的节点将会被剔除(不再被选择)，节点恢复后将会被重新加入；
+// 当所有slave节点都不可用时，查询操作将会自动转移到master节点上执行。
+// 当interval <= 0时表示关闭健康检查。
+func (bs *dbBase) SetHealthCheckInterval(interval time.Duration) {
+    if v := bs.healthEntry.Val(); v != nil {
+        v.(*gtimer.Entry).Close()
+    }
+    if interval <= 0 {
+        bs.health.Clear()
+        return
+    }
+    bs.healthEntry.Set(gtimer.AddSingleton(interval, bs.checkNodesHealth))
+}
+
+// 设置slave节点的选择策略，参数值：SLAVE_POLICY_PRIORITY, SLAVE_POLICY_LATENCY，
+// 需要注意的是SLAVE_POLICY_LATENCY策略需要开启健康检查才能获得节点延迟数据，否则按照权重选择。
+func (bs *dbBase) SetSlavePolicy(policy int) {
+    bs.slavePolicy.Set(policy)
+}
+
+// 设置会话(Session)写后读(read-your-writes)的粘滞时间窗口，
+// 会话执行写操作(事务中的写操作在事务提交成功后)后的window时间内，通过该会话执行的查询操作都将在master节点上执行，
+// 以避免主从同步延迟导致读取不到刚写入的数据，不影响其他会话及数据库对象本身的查询操作。当window <= 0时表示关闭该特性。
+func (bs *dbBase) SetStickyWindow(window time.Duration) {
+    bs.stickyWindow.Set(int64(window))
+}
+
+// 获取会话写后读的粘滞时间窗口
+func (bs *dbBase) getStickyWindow() time.Duration {
+    return time.Duration(bs.stickyWindow.Val())
+}
+
+// 获取当前数据库集群所有节点的状态
+func (bs *dbBase) GetNodeStatus() []NodeStatus {
+    config.RLock()
+    list := make(ConfigGroup, len(config.c[bs.group]))
+    copy(list, config.c[bs.group])
+    config.RUnlock()
+
+    array := make([]NodeStatus, len(list))
+    for i, node := range list {
+        array[i] = NodeStatus {
+            Node  : node,
+            Alive : true,
+        }
+        if health := bs.getNodeHealth(&node, false); health != nil {
+            array[i].Alive    = health.alive.Val()
+            array[i].Latency  = time.Duration(health.latency.Val())
+            array[i].Failures = health.failures.Val()
+        }
+    }
+    return array
+}
+
+// 根据节点健康状态及选择策略从集群中选择一个配置节点
+func (bs *dbBase) selectConfigNode(master bool) (*ConfigNode, error) {
+    config.RLock()
+    masterList, slaveList, err := getConfigGroupNodes(bs.group)
+    config.RUnlock()
+    if err != nil {
+        return nil, err
+    }
+    // 当所有master节点都不可用时，仍然按照权重选择(没有更好的选择)
+    if list := bs.filterAliveNodes(masterList); len(list) > 0 {
+        masterList = list
+    }
+    if master {
+        return getConfigNodeByPriority(masterList), nil
+    }
+    // 所有slave节点都不可用(或者没有配置slave节点)时，查询转移到master节点执行
+    if slaveList = bs.filterAliveNodes(slaveList); len(slaveList) < 1 {
+        slaveList = masterList
+    }
+    if bs.slavePolicy.Val() == SLAVE_POLICY_LATENCY {
+        if node := bs.getConfigNodeByLatency(slaveList); node != nil {
+            return node, nil
+        }
+    }
+    return getConfigNodeByPriority(slaveList), nil
+}
+
+// 过滤掉集群中已被剔除的节点
+func (bs *dbBase) filterAliveNodes(cg ConfigGroup) ConfigGroup {
+    list := make(ConfigGroup, 0, len(cg))
+    for i := 0; i < len(cg); i++ {
+        if health := bs.getNodeHealth(&cg[i], false); health == nil || health.alive.Val() {
+            list = append(list, cg[i])
+        }
+    }
+    return list
+}
+
+// 选择健康检查延迟最低的节点，当没有任何节点延迟数据时返回nil
+func (bs *dbBase) getConfigNodeByLatency(cg ConfigGroup) *ConfigNode {
+    index   := -1
+    minimum := int64(0)
+    for i := 0; i < len(cg); i++ {
+        if health := bs.getNodeHealth(&cg[i], false); health != nil {
+            if latency := health.latency.Val(); latency > 0 && (index < 0 || latency < minimum) {
+                index   = i
+                minimum = latency
+            }
+        }
+    }
+    if index < 0 {
+        return nil
+    }
+    return &cg[index]
+}
+
+// 获取节点的健康状态对象，create为true时如果不存在则创建
+func (bs *dbBase) getNodeHealth(node *ConfigNode, create bool) *nodeHealth {
+    if !create {
+        if v := bs.health.Get(node.String()); v != nil {
+            return v.(*nodeHealth)
+        }
+        return nil
+    }
+    return bs.health.GetOrSetFuncLock(node.String(), func() interface{} {
+        return &nodeHealth {
+            alive    : gtype.NewBool(true),
+            latency  : gtype.NewInt64(),
+            failures : gtype.NewInt(),
+        }
+    }).(*nodeHealth)
+}
+
+// 对集群中的所有节点执行健康检查(并发执行)
+func (bs *dbBase) checkNodesHealth() {
+    config.RLock()
+    list := make(ConfigGroup, len(config.c[bs.group]))
+    copy(list, config.c[bs.group])
+    config.RUnlock()
+
+    wg := sync.WaitGroup{}
+    for i := 0; i < len(list); i++ {
+        wg.Add(1)
+        go func(node *ConfigNode) {
+            defer wg.Done()
+            bs.checkNodeHealth(node)
+        }(&list[i])
+    }
+    wg.Wait()
+}
+
+// 对指定节点执行健康检查，并更新节点的健康状态
+func (bs *dbBase) checkNodeHealth(node *ConfigNode) {
+    health      := bs.getNodeHealth(node, true)
+    sqlDb, err  := bs.getSqlDbByNode(node)
+    start       := time.Now()
+    if err == nil {
+        err = sqlDb.Ping()
+    }
+    if err != nil {
+        if health.failures.Add(1) >= gHEALTH_CHECK_MAX_FAILURES {
+            health.alive.Set(false)
+        }
+        return
+    }
+    health.latency.Set(int64(time.Since(start)))
+    health.failures.Set(0)
+    health.alive.Set(true)
+}
@@ -37,7 +37,7 @@ type ConfigNode  struct {
     User             string   // 账号
     Pass             string   // 密码
     Name             string   // 数据库名称
-    Type             string   // 数据库类型：mysql, sqlite, mssql, pgsql, oracle(目前仅支持mysql)
+    Type             string   // 数据库类型：mysql, sqlite, mssql, pgsql, oracle, mock(用于单元测试，不需要真实的数据库)
     Role             string   // (可选，默认为master)数据库的角色，用于主从操作分离，至少需要有一个master，参数值：master, slave
     Charset          string   // (可选，默认为 utf8)编码，默认为 utf8
     Priority         int      // (可选)用于负载均衡的权重计算，当集群中只有一个节点时，权重没有任何意义
@@ -105,6 +105,13 @@ func AddConfigNode (group string, node ConfigNode) {
     config.Unlock()
 }
 
+// 删除数据库服务器集群配置，已创建的数据库对象不受影响
+func RemoveConfigGroup (group string) {
+    config.Lock()
+    delete(config.c, group)
+    config.Unlock()
+}
+
 // 添加默认链接的一台数据库服务器配置
 func AddDefaultConfigNode (node ConfigNode) {
     AddConfigNode(DEFAULT_GROUP_NAME, node)
@@ -167,4 +174,9 @@ func (bs *dbBase) SetDebug(debug bool) {
 // 获取是否开启调试服务
 func (bs *dbBase) getDebug() bool {
     return bs.debug.Val()
-}
\ No newline at end of file
+}
+
+// 获取数据库对象的配置分组名称
+func (bs *dbBase) getGroup() string {
+    return bs.group
+}
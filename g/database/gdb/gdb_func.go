@@ -49,7 +49,17 @@ func formatCondition(where interface{}, args []interface{}) (string, []interface
     newWhere := buffer.String()
     newArgs  := make([]interface{}, 0)
     if len(args) > 0 {
+        // offset为前面的参数展开后新增的占位符数量，用于准确定位当前参数对应的占位符
+        offset := 0
         for index, arg := range args {
+            // 子查询，使用子查询语句替换对应的占位符
+            if model, ok := arg.(*Model); ok {
+                subSql, subArgs := model.getFormattedSql()
+                newWhere = replacePlaceholder(newWhere, index + offset, subSql)
+                newArgs  = append(newArgs, subArgs...)
+                offset  += len(subArgs) - 1
+                continue
+            }
             rv   := reflect.ValueOf(arg)
             kind := rv.Kind()
             if kind == reflect.Ptr {
@@ -62,14 +72,13 @@ func formatCondition(where interface{}, args []interface{}) (string, []interface
                     for i := 0; i < rv.Len(); i++ {
                         newArgs = append(newArgs, rv.Index(i).Interface())
                     }
-                    counter    := 0
-                    newWhere, _ = gregex.ReplaceStringFunc(`\?`, newWhere, func(s string) string {
-                        counter++
-                        if counter == index + 1 {
-                            return "?" + strings.Repeat(",?", rv.Len() - 1)
-                        }
-                        return s
-                    })
+                    // 空数组使用NULL替换，避免产生错误的SQL语法
+                    if rv.Len() == 0 {
+                        newWhere = replacePlaceholder(newWhere, index + offset, "NULL")
+                    } else {
+                        newWhere = replacePlaceholder(newWhere, index + offset, "?" + strings.Repeat(",?", rv.Len() - 1))
+                    }
+                    offset += rv.Len() - 1
                 default:
                     newArgs = append(newArgs, arg)
             }
@@ -78,19 +87,55 @@ func formatCondition(where interface{}, args []interface{}) (string, []interface
     return newWhere, newArgs
 }
 
+// 将SQL语句中第index(从0开始)个预处理占位符替换为给定的字符串
+func replacePlaceholder(query string, index int, replacement string) string {
+    counter   := 0
+    result, _ := gregex.ReplaceStringFunc(`\?`, query, func(s string) string {
+        counter++
+        if counter == index + 1 {
+            return replacement
+        }
+        return s
+    })
+    return result
+}
+
+// 使用数据库关键字操作符对字段名称进行转义，例如：user.id => `user`.`id`，
+// 只有由字母、数字、下划线及"."组成的字段名称才会被转义，其他表达式(如函数调用)原样返回
+func quoteWord(word string, charLeft string, charRight string) string {
+    if !gregex.IsMatchString(`^[\w\.]+$`, word) {
+        return word
+    }
+    array := strings.Split(word, ".")
+    for k, v := range array {
+        array[k] = charLeft + v + charRight
+    }
+    return strings.Join(array, ".")
+}
+
 // 打印SQL对象(仅在debug=true时有效)
-func printSql(v *Sql) {
-    s := fmt.Sprintf("%s, %v, %s, %s, %d ms, %s", v.Sql, v.Args,
+func (bs *dbBase) printSql(v *Sql) {
+    s := fmt.Sprintf("%s, %v, %s, %s, %d ms, %s, %s", v.Sql, v.Args,
         gtime.NewFromTimeStamp(v.Start).Format("Y-m-d H:i:s.u"),
         gtime.NewFromTimeStamp(v.End).Format("Y-m-d H:i:s.u"),
         v.End - v.Start,
         v.Func,
+        v.Caller,
     )
+    logger := bs.getLogger()
     if v.Error != nil {
         s += "\nError: " + v.Error.Error()
-        glog.Backtrace(true, 2).Error(s)
+        if logger != nil {
+            logger.Backtrace(true, 2).Error(s)
+        } else {
+            glog.Backtrace(true, 2).Error(s)
+        }
     } else {
-        glog.Debug(s)
+        if logger != nil {
+            logger.Debug(s)
+        } else {
+            glog.Debug(s)
+        }
     }
 }
 
@@ -107,6 +152,75 @@ func formatError(err error, query string, args ...interface{}) error {
     return err
 }
 
+// 使用数据库关键字操作符对字段名称列表进行转义
+func quoteFields(fields []string, charLeft string, charRight string) []string {
+    array := make([]string, len(fields))
+    for i, field := range fields {
+        array[i] = charLeft + field + charRight
+    }
+    return array
+}
+
+// 生成批量写入的预处理占位符，例如：fields=2, rows=2 时返回 (?,?),(?,?)
+func getValuePlaceholders(fields int, rows int) string {
+    holder := "(" + strings.TrimRight(strings.Repeat("?,", fields), ",") + ")"
+    return strings.TrimRight(strings.Repeat(holder + ",", rows), ",")
+}
+
+// 获得save操作的冲突判断字段，当没有指定时使用数据表的主键
+func getConflictKeys(db DB, table string, conflictKeys []string) ([]string, error) {
+    if len(conflictKeys) > 0 {
+        return conflictKeys, nil
+    }
+    keys, err := db.getPrimaryKeys(table)
+    if err != nil {
+        return nil, err
+    }
+    if len(keys) == 0 {
+        return nil, errors.New(fmt.Sprintf(`no conflict keys specified and no primary key found for table "%s"`, table))
+    }
+    return keys, nil
+}
+
+// 获得fields中除去keys以外的字段列表，用于save操作时需要更新的字段
+func getFieldsExceptKeys(fields []string, keys []string) []string {
+    array := make([]string, 0, len(fields))
+    for _, field := range fields {
+        found := false
+        for _, key := range keys {
+            if strings.EqualFold(field, key) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            array = append(array, field)
+        }
+    }
+    return array
+}
+
+// 生成使用 ON CONFLICT(keys) DO UPDATE 语法的save操作SQL语句(SQLite/PostgreSQL)，
+// excluded为引用待写入数据的表名称
+func formatOnConflictInsertSql(db DB, table string, fields []string, rows int, conflictKeys []string, excluded string) (string, error) {
+    keys, err := getConflictKeys(db, table, conflictKeys)
+    if err != nil {
+        return "", err
+    }
+    charl, charr := db.getChars()
+    updates      := make([]string, 0)
+    for _, field := range getFieldsExceptKeys(fields, keys) {
+        updates = append(updates, fmt.Sprintf("%s%s%s=%s.%s%s%s", charl, field, charr, excluded, charl, field, charr))
+    }
+    action := "DO NOTHING"
+    if len(updates) > 0 {
+        action = "DO UPDATE SET " + strings.Join(updates, ",")
+    }
+    return fmt.Sprintf("INSERT INTO %s(%s) VALUES%s ON CONFLICT(%s) %s",
+        table, strings.Join(quoteFields(fields, charl, charr), ","), getValuePlaceholders(len(fields), rows),
+        strings.Join(quoteFields(keys, charl, charr), ","), action), nil
+}
+
 // 根据insert选项获得操作名称
 func getInsertOperationByOption(option int) string {
     oper := "INSERT"
@@ -0,0 +1,137 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "fmt"
+    "gitee.com/johng/gf/g/os/glog"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "time"
+)
+
+// SQL执行钩子接口，用于SQL日志记录、审计及拦截等操作，
+// 预处理操作(Prepare)同样会调用钩子，此时s.Func为"Prepare"且s.Args为空，后续通过sql.Stmt执行的操作不再调用钩子。
+type Hook interface {
+    // SQL执行前调用，此时s.Sql为预处理占位符统一使用"?"的原始SQL语句，
+    // 钩子可以修改s.Sql及s.Args，返回非nil的错误时将会拒绝执行该SQL，并将该错误返回给调用方。
+    BeforeExec(s *Sql) error
+    // SQL执行后调用(无论成功或者失败)，此时s.Sql为最终执行的SQL语句，s.Error为执行结果
+    AfterExec(s *Sql)
+}
+
+var (
+    // gdb包所在的目录，用于获取SQL调用位置时过滤掉包内部的调用
+    gdbPackageDir = ""
+)
+
+func init() {
+    if _, file, _, ok := runtime.Caller(0); ok {
+        gdbPackageDir = filepath.Dir(file)
+    }
+}
+
+// 添加SQL执行钩子，钩子按照添加顺序依次调用
+func (bs *dbBase) AddHook(hook Hook) {
+    bs.hooks.Append(hook)
+}
+
+// 删除SQL执行钩子，hook为AddHook时传入的钩子对象
+func (bs *dbBase) RemoveHook(hook Hook) {
+    if index := bs.hooks.Search(hook); index != -1 {
+        bs.hooks.Remove(index)
+    }
+}
+
+// 设置日志对象，调试信息及慢查询日志将会输出到该日志对象(默认使用glog默认日志对象)
+func (bs *dbBase) SetLogger(logger *glog.Logger) {
+    bs.logger.Set(logger)
+}
+
+// 设置慢查询阈值，执行时间超过该阈值的SQL将会以WARNING等级输出到日志中，当threshold <= 0时表示关闭
+func (bs *dbBase) SetSlowThreshold(threshold time.Duration) {
+    bs.slowThreshold.Set(int64(threshold))
+}
+
+// 执行SQL操作，统一处理执行钩子、调试记录及慢查询日志，
+// 参数f为真正的执行方法，执行时应当使用s.Sql及s.Args，并将执行结果写入到s.Error。
+func (bs *dbBase) handleSql(function string, query string, args []interface{}, f func(s *Sql) error) (*Sql, error) {
+    s := &Sql {
+        Sql  : query,
+        Args : args,
+        Func : function,
+    }
+    hooks := bs.hooks.Slice()
+    if len(hooks) > 0 || bs.db.getDebug() {
+        s.Caller = getCaller()
+    }
+    for _, hook := range hooks {
+        if err := hook.(Hook).BeforeExec(s); err != nil {
+            return s, err
+        }
+    }
+    s.Sql      = bs.db.handleSqlBeforeExec(s.Sql)
+    start     := time.Now()
+    f(s)
+    s.Duration = time.Since(start)
+    s.Start    = start.UnixNano()/1e6
+    s.End      = s.Start + int64(s.Duration/time.Millisecond)
+    if bs.db.getDebug() {
+        bs.sqls.Put(s)
+        bs.printSql(s)
+    }
+    if threshold := bs.slowThreshold.Val(); threshold > 0 && int64(s.Duration) >= threshold {
+        if s.Caller == "" {
+            s.Caller = getCaller()
+        }
+        bs.printSlowSql(s)
+    }
+    for _, hook := range hooks {
+        hook.(Hook).AfterExec(s)
+    }
+    return s, s.Error
+}
+
+// 是否需要记录SQL执行的详细信息(例如影响行数)
+func (bs *dbBase) needSqlRecord() bool {
+    return bs.hooks.Len() > 0 || bs.db.getDebug()
+}
+
+// 输出慢查询日志
+func (bs *dbBase) printSlowSql(v *Sql) {
+    s := fmt.Sprintf("SLOW SQL: %s, %v, %d ms, %s", v.Sql, v.Args, v.Duration/time.Millisecond, v.Caller)
+    if logger := bs.getLogger(); logger != nil {
+        logger.Backtrace(false).Warning(s)
+    } else {
+        glog.Backtrace(false).Warning(s)
+    }
+}
+
+// 获得自定义的日志对象，没有设置时返回nil
+func (bs *dbBase) getLogger() *glog.Logger {
+    if v := bs.logger.Val(); v != nil {
+        return v.(*glog.Logger)
+    }
+    return nil
+}
+
+// 获取gdb包外部的SQL调用位置(文件:行号)
+func getCaller() string {
+    pc     := make([]uintptr, 32)
+    frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+    for {
+        frame, more := frames.Next()
+        if filepath.Dir(frame.File) != gdbPackageDir || strings.HasSuffix(frame.File, "_test.go") {
+            return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+        }
+        if !more {
+            break
+        }
+    }
+    return ""
+}
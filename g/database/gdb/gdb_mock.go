@@ -0,0 +1,451 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gregex"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 用于单元测试的mock数据库，不需要任何真实的数据库即可使用，SQL语法与MySQL一致。
+// mock数据库会记录所有执行的SQL语句，并根据预设的期望(正则匹配SQL语句及参数)返回结果，例如：
+// gdb.AddConfigNode("test", gdb.ConfigNode{Type : "mock", Name : "test"})
+// mock := gdb.GetMock("test")
+// mock.ExpectQuery(`SELECT \* FROM user WHERE id=\?`).WithArgs(1).WillReturnRows(gdb.List{{"id" : 1}})
+// db, _ := gdb.New("test")
+// one, _ := db.Table("user").Where("id=?", 1).One()
+
+const (
+    // mock数据库底层注册的database/sql驱动名称
+    gMOCK_DRIVER_NAME = "gdb-mock"
+)
+
+// mock数据库链接对象(使用MySQL的SQL语法)
+type dbMock struct {
+    *dbMysql
+}
+
+// mock对象，记录执行的SQL语句并管理预设的期望
+type Mock struct {
+    mu           sync.Mutex
+    sqls         []*Sql             // 已执行的SQL列表
+    expectations []*MockExpectation // 预设的期望列表
+}
+
+// mock期望，用于匹配执行的SQL语句并返回预设的结果
+type MockExpectation struct {
+    function     string        // 匹配的执行方法：Query/Exec
+    pattern      string        // SQL语句匹配的正则表达式
+    args         []interface{} // 匹配的预处理参数列表
+    withArgs     bool          // 是否需要匹配预处理参数
+    columns      []string      // (Query)返回结果集的字段列表
+    rows         List          // (Query)返回的结果集
+    lastInsertId int64         // (Exec)返回的最后插入ID
+    rowsAffected int64         // (Exec)返回的影响行数
+    err          error         // 返回的错误
+    times        int           // 最大匹配次数，0表示不限制
+    matched      int           // 已匹配次数
+}
+
+// mock驱动(database/sql/driver)
+type mockDriver struct {}
+
+// mock驱动链接
+type mockConn struct {
+    mock *Mock
+}
+
+// mock驱动预处理对象
+type mockStmt struct {
+    conn  *mockConn
+    query string
+}
+
+// mock驱动事务对象
+type mockTx struct {
+    conn *mockConn
+}
+
+// mock驱动查询结果集
+type mockRows struct {
+    columns []string
+    types   []string
+    values  [][]driver.Value
+    index   int
+}
+
+// mock驱动执行结果
+type mockResult struct {
+    lastInsertId int64
+    rowsAffected int64
+}
+
+var (
+    // mock对象存储器，键名为配置节点的名称
+    mocks = gmap.NewStringInterfaceMap()
+)
+
+func init() {
+    sql.Register(gMOCK_DRIVER_NAME, &mockDriver{})
+}
+
+// 创建mock数据库链接对象，配置节点的Linkinfo或者Name作为mock对象的名称
+func (db *dbMock) Open(config *ConfigNode) (*sql.DB, error) {
+    return sql.Open(gMOCK_DRIVER_NAME, getMockName(config))
+}
+
+// 获取配置节点对应的mock对象名称
+func getMockName(config *ConfigNode) string {
+    if config.Linkinfo != "" {
+        return config.Linkinfo
+    }
+    if config.Name != "" {
+        return config.Name
+    }
+    return DEFAULT_GROUP_NAME
+}
+
+// 获取指定名称的mock对象(与配置节点的Linkinfo或者Name对应)，不存在时自动创建
+func GetMock(name string) *Mock {
+    return mocks.GetOrSetFuncLock(name, func() interface{} {
+        return &Mock{}
+    }).(*Mock)
+}
+
+// 添加查询操作(Query)的期望，pattern为匹配SQL语句的正则表达式，
+// 多个期望同时匹配时使用先添加的期望，匹配次数达到上限的期望将被忽略。
+func (m *Mock) ExpectQuery(pattern string) *MockExpectation {
+    return m.expect("Query", pattern)
+}
+
+// 添加执行操作(Exec)的期望，使用方式同ExpectQuery
+func (m *Mock) ExpectExec(pattern string) *MockExpectation {
+    return m.expect("Exec", pattern)
+}
+
+// 获取已执行的SQL列表(包括事务的BEGIN/COMMIT/ROLLBACK操作)
+func (m *Mock) GetSqls() []*Sql {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    sqls := make([]*Sql, len(m.sqls))
+    copy(sqls, m.sqls)
+    return sqls
+}
+
+// 获取最后执行的SQL，没有时返回nil
+func (m *Mock) GetLastSql() *Sql {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if len(m.sqls) > 0 {
+        return m.sqls[len(m.sqls) - 1]
+    }
+    return nil
+}
+
+// 清空已执行的SQL列表及所有的期望
+func (m *Mock) Reset() {
+    m.mu.Lock()
+    m.sqls         = nil
+    m.expectations = nil
+    m.mu.Unlock()
+}
+
+// 检查所有的期望是否都已被匹配(设置了匹配次数的期望需要匹配指定的次数)，否则返回错误
+func (m *Mock) ExpectationsWereMet() error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for _, e := range m.expectations {
+        if err := gregex.Validate(e.pattern); err != nil {
+            return err
+        }
+        if e.matched == 0 || (e.times > 0 && e.matched < e.times) {
+            return errors.New(fmt.Sprintf(`mock expectation not met: %s "%s", matched %d times`, e.function, e.pattern, e.matched))
+        }
+    }
+    return nil
+}
+
+// 添加期望
+func (m *Mock) expect(function string, pattern string) *MockExpectation {
+    e := &MockExpectation {
+        function : function,
+        pattern  : pattern,
+    }
+    m.mu.Lock()
+    m.expectations = append(m.expectations, e)
+    m.mu.Unlock()
+    return e
+}
+
+// 记录执行的SQL并查找匹配的期望，没有匹配的期望时返回错误
+func (m *Mock) match(function string, query string, args []driver.NamedValue) (*MockExpectation, error) {
+    values := make([]interface{}, len(args))
+    for i, arg := range args {
+        values[i] = arg.Value
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    s := &Sql {
+        Sql   : query,
+        Args  : values,
+        Func  : function,
+        Start : time.Now().UnixNano()/1e6,
+    }
+    s.End  = s.Start
+    m.sqls = append(m.sqls, s)
+    for _, e := range m.expectations {
+        if e.function != function || (e.times > 0 && e.matched >= e.times) {
+            continue
+        }
+        if !gregex.IsMatchString(e.pattern, query) || (e.withArgs && !e.matchArgs(values)) {
+            continue
+        }
+        e.matched++
+        s.Error = e.err
+        return e, e.err
+    }
+    s.Error = errors.New(fmt.Sprintf(`no mock expectation matched for %s: %s, %v`, function, query, values))
+    return nil, s.Error
+}
+
+// 记录事务操作
+func (m *Mock) record(query string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    s := &Sql {
+        Sql   : query,
+        Func  : "Tx",
+        Start : time.Now().UnixNano()/1e6,
+    }
+    s.End  = s.Start
+    m.sqls = append(m.sqls, s)
+}
+
+// 设置期望匹配的预处理参数列表，参数值按照字符串形式进行比较，
+// 参数也可以是一个func(value interface{}) bool类型的自定义匹配方法。
+func (e *MockExpectation) WithArgs(args...interface{}) *MockExpectation {
+    e.args     = args
+    e.withArgs = true
+    return e
+}
+
+// 设置查询操作返回的结果集，columns为结果集的字段列表(默认为rows中的键名按照字母排序)
+func (e *MockExpectation) WillReturnRows(rows List, columns...string) *MockExpectation {
+    e.rows    = rows
+    e.columns = columns
+    return e
+}
+
+// 设置执行操作返回的最后插入ID及影响行数
+func (e *MockExpectation) WillReturnResult(lastInsertId, rowsAffected int64) *MockExpectation {
+    e.lastInsertId = lastInsertId
+    e.rowsAffected = rowsAffected
+    return e
+}
+
+// 设置返回的错误
+func (e *MockExpectation) WillReturnError(err error) *MockExpectation {
+    e.err = err
+    return e
+}
+
+// 设置期望的匹配次数，达到次数后该期望将不再被匹配
+func (e *MockExpectation) Times(n int) *MockExpectation {
+    e.times = n
+    return e
+}
+
+// 判断预处理参数是否匹配
+func (e *MockExpectation) matchArgs(values []interface{}) bool {
+    if len(e.args) != len(values) {
+        return false
+    }
+    for i, arg := range e.args {
+        if f, ok := arg.(func(value interface{}) bool); ok {
+            if !f(values[i]) {
+                return false
+            }
+        } else if gconv.String(arg) != gconv.String(values[i]) {
+            return false
+        }
+    }
+    return true
+}
+
+// 根据期望生成查询结果集
+func (e *MockExpectation) newRows() *mockRows {
+    rows := &mockRows {
+        columns : e.columns,
+        values  : make([][]driver.Value, len(e.rows)),
+    }
+    if len(rows.columns) == 0 {
+        keys := make(map[string]struct{})
+        for _, m := range e.rows {
+            for k, _ := range m {
+                keys[k] = struct{}{}
+            }
+        }
+        for k, _ := range keys {
+            rows.columns = append(rows.columns, k)
+        }
+        sort.Strings(rows.columns)
+    }
+    rows.types = make([]string, len(rows.columns))
+    for i, m := range e.rows {
+        rows.values[i] = make([]driver.Value, len(rows.columns))
+        for j, column := range rows.columns {
+            value, t := getMockDriverValue(m[column])
+            rows.values[i][j] = value
+            if rows.types[j] == "" {
+                rows.types[j] = t
+            }
+        }
+    }
+    for i, t := range rows.types {
+        if t == "" {
+            rows.types[i] = "VARCHAR"
+        }
+    }
+    return rows
+}
+
+// 将结果集的值转换为驱动支持的值，同时返回对应的数据库字段类型
+func getMockDriverValue(value interface{}) (driver.Value, string) {
+    switch v := value.(type) {
+        case nil:
+            return nil, ""
+        case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+            return gconv.Int64(v), "INT"
+        case float32, float64:
+            return gconv.Float64(v), "DOUBLE"
+        case bool:
+            return v, "BOOL"
+        case []byte:
+            return v, "BLOB"
+        default:
+            return gconv.String(v), "VARCHAR"
+    }
+}
+
+// 创建驱动链接，name为mock对象名称
+func (d *mockDriver) Open(name string) (driver.Conn, error) {
+    return &mockConn{mock : GetMock(name)}, nil
+}
+
+func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
+    return &mockStmt{conn : c, query : query}, nil
+}
+
+func (c *mockConn) Close() error {
+    return nil
+}
+
+func (c *mockConn) Begin() (driver.Tx, error) {
+    c.mock.record("BEGIN")
+    return &mockTx{conn : c}, nil
+}
+
+func (c *mockConn) Ping(ctx context.Context) error {
+    return nil
+}
+
+// 预处理参数不做任何转换，以便记录原始的参数值
+func (c *mockConn) CheckNamedValue(value *driver.NamedValue) error {
+    return nil
+}
+
+func (c *mockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    e, err := c.mock.match("Query", query, args)
+    if err != nil {
+        return nil, err
+    }
+    return e.newRows(), nil
+}
+
+func (c *mockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    e, err := c.mock.match("Exec", query, args)
+    if err != nil {
+        return nil, err
+    }
+    return &mockResult{lastInsertId : e.lastInsertId, rowsAffected : e.rowsAffected}, nil
+}
+
+func (s *mockStmt) Close() error {
+    return nil
+}
+
+func (s *mockStmt) NumInput() int {
+    return -1
+}
+
+func (s *mockStmt) Exec(args []driver.Value) (driver.Result, error) {
+    return s.conn.ExecContext(context.Background(), s.query, getMockNamedValues(args))
+}
+
+func (s *mockStmt) Query(args []driver.Value) (driver.Rows, error) {
+    return s.conn.QueryContext(context.Background(), s.query, getMockNamedValues(args))
+}
+
+// 将预处理参数转换为[]driver.NamedValue
+func getMockNamedValues(args []driver.Value) []driver.NamedValue {
+    values := make([]driver.NamedValue, len(args))
+    for i, arg := range args {
+        values[i] = driver.NamedValue{Ordinal : i + 1, Value : arg}
+    }
+    return values
+}
+
+func (tx *mockTx) Commit() error {
+    tx.conn.mock.record("COMMIT")
+    return nil
+}
+
+func (tx *mockTx) Rollback() error {
+    tx.conn.mock.record("ROLLBACK")
+    return nil
+}
+
+func (r *mockRows) Columns() []string {
+    return r.columns
+}
+
+func (r *mockRows) Close() error {
+    return nil
+}
+
+func (r *mockRows) Next(dest []driver.Value) error {
+    if r.index >= len(r.values) {
+        return io.EOF
+    }
+    copy(dest, r.values[r.index])
+    r.index++
+    return nil
+}
+
+// 返回字段的数据库类型，用于查询结果的类型转换
+func (r *mockRows) ColumnTypeDatabaseTypeName(index int) string {
+    return strings.ToUpper(r.types[index])
+}
+
+func (r *mockResult) LastInsertId() (int64, error) {
+    return r.lastInsertId, nil
+}
+
+func (r *mockResult) RowsAffected() (int64, error) {
+    return r.rowsAffected, nil
+}
@@ -35,6 +35,12 @@ type Model struct {
 	cacheEnabled bool          // 当前SQL操作是否开启查询缓存功能
 	cacheTime    int           // 查询缓存时间
 	cacheName    string        // 查询缓存名称
+	unions       []modelUnion  // 联合查询(UNION/UNION ALL)的Model列表
+	master       bool          // 查询操作是否强制在master节点上执行
+	conflictKeys []string      // Save操作的冲突判断字段，同时也是批量Update操作的记录匹配字段(默认均为主键)
+	shardValues  []interface{} // 手动指定的分片字段值
+	shardRouted  bool          // 是否已完成分片路由(已路由的Model不再进行分片路由)
+	session      *Session      // 所属的写后读会话对象
 }
 
 // 链式操作，数据表字段，可支持多个表，以半角逗号连接
@@ -76,6 +82,15 @@ func (md *Model) Clone() *Model {
         newModel = md.db.Table(md.tablesInit)
 	}
     *newModel = *md
+    // 复制slice属性，避免克隆对象之间共享底层数组
+    if len(md.whereArgs) > 0 {
+        newModel.whereArgs = make([]interface{}, len(md.whereArgs))
+        copy(newModel.whereArgs, md.whereArgs)
+    }
+    if len(md.unions) > 0 {
+        newModel.unions = make([]modelUnion, len(md.unions))
+        copy(newModel.unions, md.unions)
+    }
     return newModel
 }
 
@@ -176,6 +191,22 @@ func (md *Model) ForPage(page, limit int) (*Model) {
 	return model
 }
 
+// 链式操作，强制查询操作在master节点上执行(例如写入后需要立即读取的场景)，事务操作本身即在master节点上执行
+func (md *Model) Master() *Model {
+    model       := md.Clone()
+    model.master = true
+    return model
+}
+
+// 链式操作，设置Save操作判断数据是否存在的冲突字段(默认为主键)，
+// 同时也作为批量Update操作(Data为List)时匹配记录的字段。
+// 需要注意的是MySQL的Save操作(ON DUPLICATE KEY UPDATE)根据所有的唯一索引判断冲突，因此会忽略该设置。
+func (md *Model) OnConflict(columns...string) *Model {
+    model             := md.Clone()
+    model.conflictKeys = columns
+    return model
+}
+
 // 设置批处理的大小
 func (md *Model) Batch(batch int) *Model {
     model      := md.Clone()
@@ -245,11 +276,16 @@ func (md *Model) Insert() (result sql.Result, err error) {
 	defer func() {
 		if err == nil {
 			md.checkAndRemoveCache()
+			md.checkAndMarkWrite()
 		}
 	}()
 	if md.data == nil {
 		return nil, errors.New("inserting into table with empty data")
 	}
+	// 分库分表
+	if rule := md.getShardingRule(); rule != nil {
+		return md.shardingWrite(rule, (*Model).Insert)
+	}
 	// 批量操作
 	if list, ok := md.data.(List); ok {
 		batch := 10
@@ -284,11 +320,16 @@ func (md *Model) Replace() (result sql.Result, err error) {
 	defer func() {
 		if err == nil {
 			md.checkAndRemoveCache()
+			md.checkAndMarkWrite()
 		}
 	}()
 	if md.data == nil {
 		return nil, errors.New("replacing into table with empty data")
 	}
+	// 分库分表
+	if rule := md.getShardingRule(); rule != nil {
+		return md.shardingWrite(rule, (*Model).Replace)
+	}
 	// 批量操作
 	if list, ok := md.data.(List); ok {
 		batch := 10
@@ -323,11 +364,16 @@ func (md *Model) Save() (result sql.Result, err error) {
 	defer func() {
 		if err == nil {
 			md.checkAndRemoveCache()
+			md.checkAndMarkWrite()
 		}
 	}()
 	if md.data == nil {
 		return nil, errors.New("replacing into table with empty data")
 	}
+	// 分库分表
+	if rule := md.getShardingRule(); rule != nil {
+		return md.shardingWrite(rule, (*Model).Save)
+	}
 	// 批量操作
 	if list, ok := md.data.(List); ok {
 		batch := 10
@@ -340,33 +386,59 @@ func (md *Model) Save() (result sql.Result, err error) {
             }
         }
 		if md.tx == nil {
-			return md.db.BatchSave(md.tables, list, batch)
+			return md.db.BatchSave(md.tables, list, batch, md.conflictKeys...)
 		} else {
-			return md.tx.BatchSave(md.tables, list, batch)
+			return md.tx.BatchSave(md.tables, list, batch, md.conflictKeys...)
 		}
 	} else if data, ok := md.data.(Map); ok {
         if md.filter {
             data = md.db.filterFields(md.tables, data)
         }
 		if md.tx == nil {
-			return md.db.Save(md.tables, data)
+			return md.db.Save(md.tables, data, md.conflictKeys...)
 		} else {
-			return md.tx.Save(md.tables, data)
+			return md.tx.Save(md.tables, data, md.conflictKeys...)
 		}
 	}
 	return nil, errors.New("saving into table with invalid data type")
 }
 
-// 链式操作， CURD - Update
+// 链式操作， CURD - Update/BatchUpdate，
+// 当Data为List时执行批量更新，按照OnConflict设置的字段(默认为主键)匹配记录，此时Where条件无效
 func (md *Model) Update() (result sql.Result, err error) {
 	defer func() {
 		if err == nil {
 			md.checkAndRemoveCache()
+			md.checkAndMarkWrite()
 		}
 	}()
 	if md.data == nil {
 		return nil, errors.New("updating table with empty data")
 	}
+	// 分库分表，批量更新按照数据中的分片字段值路由，其他按照Where条件路由
+	if rule := md.getShardingRule(); rule != nil {
+		if _, ok := md.data.(List); ok {
+			return md.shardingWrite(rule, (*Model).Update)
+		}
+		return md.shardingExec(rule, (*Model).Update)
+	}
+	// 批量操作
+	if list, ok := md.data.(List); ok {
+		batch := 10
+		if md.batch > 0 {
+			batch = md.batch
+		}
+		if md.filter {
+			for k, m := range list {
+				list[k] = md.db.filterFields(md.tables, m)
+			}
+		}
+		if md.tx == nil {
+			return md.db.BatchUpdate(md.tables, list, batch, md.conflictKeys...)
+		} else {
+			return md.tx.BatchUpdate(md.tables, list, batch, md.conflictKeys...)
+		}
+	}
     if md.filter {
         if data, ok := md.data.(Map); ok {
             if md.filter {
@@ -386,8 +458,13 @@ func (md *Model) Delete() (result sql.Result, err error) {
 	defer func() {
 		if err == nil {
 			md.checkAndRemoveCache()
+			md.checkAndMarkWrite()
 		}
 	}()
+	// 分库分表
+	if rule := md.getShardingRule(); rule != nil {
+		return md.shardingExec(rule, (*Model).Delete)
+	}
 	if md.tx == nil {
 		return md.db.Delete(md.tables, md.where, md.whereArgs...)
 	} else {
@@ -402,7 +479,12 @@ func (md *Model) Select() (Result, error) {
 
 // 链式操作，查询所有记录
 func (md *Model) All() (Result, error) {
-	return md.getAll(md.getFormattedSql(), md.whereArgs...)
+	// 分库分表
+	if rule := md.getShardingRule(); rule != nil {
+		return md.shardingAll(rule)
+	}
+	s, args := md.getFormattedSql()
+	return md.getAll(s, args...)
 }
 
 // 链式操作，查询单条记录
@@ -441,19 +523,26 @@ func (md *Model) Struct(obj interface{}) error {
 // 链式操作，查询数量，fields可以为空，也可以自定义查询字段，
 // 当给定自定义查询字段时，该字段必须为数量结果，否则会引起歧义，使用如：md.Fields("COUNT(id)")
 func (md *Model) Count() (int, error) {
+	// 分库分表
+	if rule := md.getShardingRule(); rule != nil {
+		return md.shardingCount(rule)
+	}
     defer func(fields string) {
         md.fields = fields
     }(md.fields)
-	if md.fields == "" || md.fields == "*" {
-		md.fields = "COUNT(1)"
-	} else {
-        md.fields = fmt.Sprintf(`COUNT(%s)`, md.fields)
-	}
-	s := md.getFormattedSql()
-	if len(md.groupBy) > 0 {
+    // 联合查询时查询字段保持不变，直接对联合查询结果进行统计
+    if len(md.unions) == 0 {
+        if md.fields == "" || md.fields == "*" {
+            md.fields = "COUNT(1)"
+        } else {
+            md.fields = fmt.Sprintf(`COUNT(%s)`, md.fields)
+        }
+    }
+	s, args := md.getFormattedSql()
+	if len(md.groupBy) > 0 || len(md.unions) > 0 {
 		s = fmt.Sprintf("SELECT COUNT(1) FROM (%s) count_alias", s)
 	}
-	list, err := md.getAll(s, md.whereArgs...)
+	list, err := md.getAll(s, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -474,22 +563,28 @@ func (md *Model) getAll(query string, args ...interface{}) (result Result, err e
 		if len(cacheKey) == 0 {
 			cacheKey = query + "/" + gconv.String(args)
 		}
-		if v := md.db.getCache().Get(cacheKey); v != nil {
-			return v.(Result), nil
+		if v, ok := md.db.getQueryCache().Get(cacheKey).(Result); ok {
+			return v, nil
 		}
 	}
 
-	if md.tx == nil {
-		result, err = md.db.GetAll(query, args...)
-	} else {
+	if md.tx != nil {
 		result, err = md.tx.GetAll(query, args...)
+	} else if md.isMaster() {
+		master, e := md.db.Master()
+		if e != nil {
+			return nil, e
+		}
+		result, err = md.db.doGetAll(master, query, args...)
+	} else {
+		result, err = md.db.GetAll(query, args...)
 	}
 	// 查询缓存保存处理
 	if len(cacheKey) > 0 && err == nil {
 		if md.cacheTime < 0 {
-			md.db.getCache().Remove(cacheKey)
+			md.db.getQueryCache().Remove(cacheKey)
 		} else {
-			md.db.getCache().Set(cacheKey, result, md.cacheTime*1000)
+			md.db.getQueryCache().Set(cacheKey, result, md.cacheTime*1000)
 		}
 	}
 	return result, err
@@ -498,29 +593,56 @@ func (md *Model) getAll(query string, args ...interface{}) (result Result, err e
 // 检查是否需要查询查询缓存
 func (md *Model) checkAndRemoveCache() {
 	if md.cacheEnabled && md.cacheTime < 0 && len(md.cacheName) > 0 {
-		md.db.getCache().Remove(md.cacheName)
+		md.db.getQueryCache().Remove(md.cacheName)
+	}
+}
+
+// 写操作成功后记录所属会话的写操作时间，事务中的写操作在事务提交成功后记录
+func (md *Model) checkAndMarkWrite() {
+	if md.session != nil && md.tx == nil {
+		md.session.markWrite()
 	}
 }
 
-// 格式化当前输入参数，返回可执行的SQL语句（不带参数）
-func (md *Model) getFormattedSql() string {
+// 判断查询操作是否需要在master节点上执行(强制master或者处于会话写后读的粘滞时间窗口内)
+func (md *Model) isMaster() bool {
+	return md.master || (md.session != nil && md.session.isSticky())
+}
+
+// 格式化当前输入参数，返回可执行的SQL语句（不带参数）及对应的预处理参数列表
+func (md *Model) getFormattedSql() (string, []interface{}) {
 	if md.fields == "" {
 		md.fields = "*"
 	}
-	s := fmt.Sprintf("SELECT %s FROM %s", md.fields, md.tables)
+	s    := fmt.Sprintf("SELECT %s FROM %s", md.fields, md.tables)
+	args := md.whereArgs
 	if md.where != "" {
 		s += " WHERE " + md.where
 	}
 	if md.groupBy != "" {
 		s += " GROUP BY " + md.groupBy
 	}
+	// 联合查询，排序及分页条件作用于整个联合查询结果
+	if len(md.unions) > 0 {
+		args = make([]interface{}, len(md.whereArgs))
+		copy(args, md.whereArgs)
+		for _, union := range md.unions {
+			subSql, subArgs := union.model.getFormattedSql()
+			// 带有排序或者分页条件的子查询需要使用括号包含
+			if union.model.orderBy != "" || union.model.limit != 0 {
+				subSql = "(" + subSql + ")"
+			}
+			s   += fmt.Sprintf(" %s %s", union.operator, subSql)
+			args = append(args, subArgs...)
+		}
+	}
 	if md.orderBy != "" {
 		s += " ORDER BY " + md.orderBy
 	}
 	if md.limit != 0 {
 		s += fmt.Sprintf(" LIMIT %d, %d", md.start, md.limit)
 	}
-	return s
+	return s, args
 }
 
 // 组块结果集
@@ -529,8 +651,7 @@ func (md *Model) getFormattedSql() string {
 func (md *Model) Chunk(limit int, callback func(result Result, err error) bool) {
 	page := 1
 	for {
-		md.ForPage(page, limit)
-		data, err := md.getAll(md.getFormattedSql(), md.whereArgs...)
+		data, err := md.ForPage(page, limit).All()
 		if err != nil {
 			callback(nil, err)
 			break
@@ -0,0 +1,155 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "fmt"
+)
+
+// 联合查询项
+type modelUnion struct {
+    operator string // 联合操作符：UNION, UNION ALL
+    model    *Model // 联合查询的Model对象
+}
+
+// 链式操作，添加IN条件到Where中，
+// in参数可以是slice/array，也可以是*Model(子查询)，例如：WhereIn("id", g.Slice{1,2,3})
+func (md *Model) WhereIn(column string, in interface{}) *Model {
+    return md.appendCondition(" AND ", fmt.Sprintf("%s IN(?)", md.quoteWord(column)), in)
+}
+
+// 链式操作，添加NOT IN条件到Where中，in参数同WhereIn
+func (md *Model) WhereNotIn(column string, in interface{}) *Model {
+    return md.appendCondition(" AND ", fmt.Sprintf("%s NOT IN(?)", md.quoteWord(column)), in)
+}
+
+// 链式操作，添加BETWEEN条件到Where中
+func (md *Model) WhereBetween(column string, min, max interface{}) *Model {
+    return md.appendCondition(" AND ", fmt.Sprintf("%s BETWEEN ? AND ?", md.quoteWord(column)), min, max)
+}
+
+// 链式操作，添加NOT BETWEEN条件到Where中
+func (md *Model) WhereNotBetween(column string, min, max interface{}) *Model {
+    return md.appendCondition(" AND ", fmt.Sprintf("%s NOT BETWEEN ? AND ?", md.quoteWord(column)), min, max)
+}
+
+// 链式操作，添加IS NULL条件到Where中，支持同时给定多个字段
+func (md *Model) WhereNull(columns ...string) *Model {
+    model := md
+    for _, column := range columns {
+        model = model.appendCondition(" AND ", fmt.Sprintf("%s IS NULL", md.quoteWord(column)))
+    }
+    return model
+}
+
+// 链式操作，添加IS NOT NULL条件到Where中，支持同时给定多个字段
+func (md *Model) WhereNotNull(columns ...string) *Model {
+    model := md
+    for _, column := range columns {
+        model = model.appendCondition(" AND ", fmt.Sprintf("%s IS NOT NULL", md.quoteWord(column)))
+    }
+    return model
+}
+
+// 链式操作，添加EXISTS子查询条件到Where中
+func (md *Model) WhereExists(subQuery *Model) *Model {
+    return md.appendCondition(" AND ", "EXISTS(?)", subQuery)
+}
+
+// 链式操作，添加NOT EXISTS子查询条件到Where中
+func (md *Model) WhereNotExists(subQuery *Model) *Model {
+    return md.appendCondition(" AND ", "NOT EXISTS(?)", subQuery)
+}
+
+// 链式操作，添加使用括号包含的条件组到Where中(AND连接)，条件组通过回调函数构造，例如：
+// WhereGroup(func(m *gdb.Model) *gdb.Model {
+//     return m.Where("status", 1).Or("vip", 1)
+// })
+// 生成的条件为：AND (status=? OR vip=?)
+func (md *Model) WhereGroup(f func(m *Model) *Model) *Model {
+    return md.appendGroup(" AND ", f)
+}
+
+// 链式操作，添加使用括号包含的条件组到Where中(OR连接)，使用方式同WhereGroup
+func (md *Model) OrWhereGroup(f func(m *Model) *Model) *Model {
+    return md.appendGroup(" OR ", f)
+}
+
+// 链式操作，UNION联合查询(结果去重)，当前Model的排序及分页条件作用于整个联合查询结果
+func (md *Model) Union(models ...*Model) *Model {
+    return md.appendUnion("UNION", models)
+}
+
+// 链式操作，UNION ALL联合查询(结果不去重)，当前Model的排序及分页条件作用于整个联合查询结果
+func (md *Model) UnionAll(models ...*Model) *Model {
+    return md.appendUnion("UNION ALL", models)
+}
+
+// 使用给定的连接操作符添加条件到Where中，当Where为空时直接作为Where条件
+func (md *Model) appendCondition(operator string, where string, args ...interface{}) *Model {
+    model             := md.Clone()
+    newWhere, newArgs := formatCondition(where, args)
+    if model.where == "" {
+        model.where = newWhere
+    } else {
+        model.where += operator + newWhere
+    }
+    model.whereArgs = append(model.whereArgs, newArgs...)
+    return model
+}
+
+// 添加键值(keyset)分页条件 column>value (operator为<时倒序)，已有的Where条件作为整体(加括号)与分页条件进行AND连接，
+// 避免已有条件中的OR使分页条件只作用于其中一个分支
+func (md *Model) appendKeyset(column string, operator string, value interface{}) *Model {
+    model := md.Clone()
+    if model.where != "" {
+        model.where = "(" + model.where + ")"
+    }
+    return model.appendCondition(" AND ", fmt.Sprintf("%s%s?", md.quoteWord(column), operator), value)
+}
+
+// 添加条件组到Where中
+func (md *Model) appendGroup(operator string, f func(m *Model) *Model) *Model {
+    group := f(md.newConditionModel())
+    if group == nil || group.where == "" {
+        return md.Clone()
+    }
+    model := md.Clone()
+    if model.where == "" {
+        model.where = "(" + group.where + ")"
+    } else {
+        model.where += operator + "(" + group.where + ")"
+    }
+    model.whereArgs = append(model.whereArgs, group.whereArgs...)
+    return model
+}
+
+// 添加联合查询Model
+func (md *Model) appendUnion(operator string, models []*Model) *Model {
+    model := md.Clone()
+    for _, m := range models {
+        model.unions = append(model.unions, modelUnion{
+            operator : operator,
+            model    : m,
+        })
+    }
+    return model
+}
+
+// 创建一个不带任何条件的Model对象，用于构造条件组
+func (md *Model) newConditionModel() *Model {
+    if md.tx != nil {
+        return md.tx.Table(md.tablesInit)
+    }
+    return md.db.Table(md.tablesInit)
+}
+
+// 使用当前数据库的关键字操作符对字段名称进行转义
+func (md *Model) quoteWord(word string) string {
+    charLeft, charRight := md.db.getChars()
+    return quoteWord(word, charLeft, charRight)
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gvar"
+    "strings"
+)
+
+// 查询结果集迭代器，基于单个*sql.Rows游标逐条读取记录，不会将结果集全部加载到内存中，
+// 使用完毕后需要调用Close关闭游标(Next返回false时游标会被自动关闭)。
+type Iterator struct {
+    db       DB             // 数据库对象(用于字段类型转换)
+    rows     *sql.Rows      // 查询游标
+    columns  []string       // 字段名称列表
+    types    []string       // 字段类型列表
+    values   []sql.RawBytes // 字段值读取缓冲区(每条记录复用)
+    scanArgs []interface{}  // 字段值读取参数
+    record   Record         // 当前记录
+    err      error          // 迭代过程中产生的错误
+}
+
+// 创建查询结果集迭代器
+func newIterator(db DB, rows *sql.Rows) *Iterator {
+    it := &Iterator {
+        db   : db,
+        rows : rows,
+    }
+    columnTypes, err := rows.ColumnTypes()
+    if err != nil {
+        it.err = err
+        return it
+    }
+    for _, t := range columnTypes {
+        it.types   = append(it.types,   t.DatabaseTypeName())
+        it.columns = append(it.columns, t.Name())
+    }
+    it.values   = make([]sql.RawBytes, len(it.columns))
+    it.scanArgs = make([]interface{},  len(it.columns))
+    for i := range it.values {
+        it.scanArgs[i] = &it.values[i]
+    }
+    return it
+}
+
+// 读取下一条记录，没有更多记录或者产生错误时返回false
+func (it *Iterator) Next() bool {
+    it.record = nil
+    if it.err != nil || !it.rows.Next() {
+        return false
+    }
+    if err := it.rows.Scan(it.scanArgs...); err != nil {
+        it.err = err
+        return false
+    }
+    it.record = make(Record, len(it.columns))
+    // 注意col字段是一个[]byte类型(slice类型本身是一个指针)，多个记录循环时该变量指向的是同一个内存地址
+    for i, col := range it.values {
+        if col == nil {
+            it.record[it.columns[i]] = gvar.New(nil, true)
+        } else {
+            // 由于 sql.RawBytes 是slice类型, 这里必须使用值复制
+            v := make([]byte, len(col))
+            copy(v, col)
+            it.record[it.columns[i]] = gvar.New(it.db.convertValue(v, it.types[i]), true)
+        }
+    }
+    return true
+}
+
+// 获取当前记录(Next返回true之后有效)
+func (it *Iterator) Record() Record {
+    return it.record
+}
+
+// 获取迭代过程中产生的错误
+func (it *Iterator) Err() error {
+    if it.err != nil {
+        return it.err
+    }
+    return it.rows.Err()
+}
+
+// 关闭查询游标，可重复调用
+func (it *Iterator) Close() error {
+    return it.rows.Close()
+}
+
+// 链式操作，获取查询结果集迭代器，查询结果以流式的方式逐条读取，适用于大结果集的处理。
+// 需要注意的是迭代器不支持查询缓存，并且在事务中迭代未结束之前，不能在同一事务中执行其他的SQL操作。
+func (md *Model) Iterator() (*Iterator, error) {
+    // 分库分表，迭代器只支持单个分片
+    if rule := md.getShardingRule(); rule != nil {
+        targets, err := md.getShardingTargets(rule)
+        if err != nil {
+            return nil, err
+        }
+        if len(targets) != 1 {
+            return nil, errors.New(fmt.Sprintf(`iterator on sharding table "%s" requires sharding key "%s" value matching a single shard`, rule.Table, rule.Key))
+        }
+        model, err := md.newShardingModel(targets[0])
+        if err != nil {
+            return nil, err
+        }
+        return model.Iterator()
+    }
+    s, args   := md.getFormattedSql()
+    rows, err := (*sql.Rows)(nil), error(nil)
+    if md.tx != nil {
+        rows, err = md.tx.Query(s, args...)
+    } else if md.isMaster() {
+        master, e := md.db.Master()
+        if e != nil {
+            return nil, e
+        }
+        rows, err = md.db.doQuery(master, s, args...)
+    } else {
+        rows, err = md.db.Query(s, args...)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return newIterator(md.db, rows), nil
+}
+
+// 链式操作，流式遍历查询结果集，每读取一条记录调用一次f，f返回false时停止遍历。
+// 整个遍历过程只执行一次查询，内存占用与结果集大小无关。
+func (md *Model) Each(f func(record Record) bool) error {
+    iterator, err := md.Iterator()
+    if err != nil {
+        return err
+    }
+    defer iterator.Close()
+    for iterator.Next() {
+        if !f(iterator.Record()) {
+            break
+        }
+    }
+    return iterator.Err()
+}
+
+// 基于键值(keyset)的组块结果集，column为有序且唯一的字段(例如自增主键)，
+// 每次查询使用 column > 上一组最后一条记录的column值 的条件替代OFFSET，查询效率与组块位置无关，
+// 并且在回调函数中修改(例如删除)已处理的记录不会导致记录被跳过或者重复处理。
+// 需要注意的是当前Model的排序及分页条件会被覆盖。
+func (md *Model) ChunkById(column string, limit int, callback func(result Result, err error) bool) {
+    key  := getColumnKey(column)
+    last := interface{}(nil)
+    for {
+        model := md
+        if last != nil {
+            model = model.appendKeyset(column, ">", last)
+        }
+        data, err := model.OrderBy(md.quoteWord(column) + " ASC").Limit(0, limit).All()
+        if err != nil {
+            callback(nil, err)
+            break
+        }
+        if len(data) == 0 {
+            break
+        }
+        if callback(data, err) == false {
+            break
+        }
+        if len(data) < limit {
+            break
+        }
+        if v := data[len(data) - 1][key]; v == nil || v.IsNil() {
+            break
+        } else {
+            last = v.Val()
+        }
+    }
+}
+
+// 获取字段在结果集中的键名(结果集中的键名不包含表名前缀)
+func getColumnKey(column string) string {
+    if pos := strings.LastIndex(column, "."); pos != -1 {
+        return column[pos + 1:]
+    }
+    return column
+}
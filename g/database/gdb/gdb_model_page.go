@@ -0,0 +1,132 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "errors"
+)
+
+// 分页查询结果
+type Pagination struct {
+    Result    Result // 当前页的记录列表
+    Total     int    // 总记录数
+    TotalPage int    // 总页数
+    Page      int    // 当前页码(从1开始)
+    Size      int    // 每页记录数
+}
+
+// 游标(keyset)分页查询结果
+type CursorPagination struct {
+    Result     Result // 当前页的记录列表
+    Size       int    // 每页记录数
+    HasMore    bool   // 是否还有下一页
+    NextCursor Value  // 下一页的游标值(当前页最后一条记录的游标字段值)，没有下一页时为nil
+}
+
+// 获取总记录数(实现gpage.Paginator接口)
+func (p *Pagination) GetTotal() int {
+    return p.Total
+}
+
+// 获取每页记录数(实现gpage.Paginator接口)
+func (p *Pagination) GetSize() int {
+    return p.Size
+}
+
+// 获取当前页码(实现gpage.Paginator接口)
+func (p *Pagination) GetPage() int {
+    return p.Page
+}
+
+// 链式操作，分页查询，同时返回当前页的记录列表及总记录数、总页数等分页信息，
+// 统计查询会忽略排序条件；当页码超出总页数或者第一页记录数不足一页时，将会省略不必要的查询。
+// 返回结果可通过 gpage.NewFromPagination 直接生成分页对象。
+func (md *Model) Paginate(page, size int) (*Pagination, error) {
+    if size <= 0 {
+        return nil, errors.New("invalid page size, it should be greater than 0")
+    }
+    if page < 1 {
+        page = 1
+    }
+    pagination := &Pagination {
+        Page   : page,
+        Size   : size,
+        Result : make(Result, 0),
+    }
+    // 第一页时先执行分页查询，当记录数不足一页时即为总记录数，不需要执行统计查询
+    if page == 1 {
+        result, err := md.ForPage(page, size).All()
+        if err != nil {
+            return nil, err
+        }
+        pagination.Result = result
+        if len(result) < size {
+            pagination.Total = len(result)
+            if len(result) > 0 {
+                pagination.TotalPage = 1
+            }
+            return pagination, nil
+        }
+    }
+    countModel        := md.Clone()
+    countModel.orderBy = ""
+    countModel.start   = 0
+    countModel.limit   = 0
+    total, err        := countModel.Count()
+    if err != nil {
+        return nil, err
+    }
+    pagination.Total     = total
+    pagination.TotalPage = (total + size - 1) / size
+    if page > 1 && page <= pagination.TotalPage {
+        if pagination.Result, err = md.ForPage(page, size).All(); err != nil {
+            return nil, err
+        }
+    }
+    return pagination, nil
+}
+
+// 链式操作，游标(keyset)分页查询，适用于大数据表的翻页：
+// column为有序且唯一的字段(例如自增主键)，cursor为上一页返回的NextCursor(第一页时为nil)，
+// desc为true时按照column倒序翻页。查询使用 column > cursor (倒序时为 <) 的条件替代OFFSET，
+// 翻页效率与页码无关；需要注意的是当前Model的排序条件会被column排序覆盖。
+func (md *Model) CursorPaginate(column string, cursor interface{}, size int, desc...bool) (*CursorPagination, error) {
+    if size <= 0 {
+        return nil, errors.New("invalid page size, it should be greater than 0")
+    }
+    operator, order := ">", "ASC"
+    if len(desc) > 0 && desc[0] {
+        operator, order = "<", "DESC"
+    }
+    // 游标值可以直接使用上一页返回的NextCursor
+    if v, ok := cursor.(Value); ok {
+        if v != nil {
+            cursor = v.Val()
+        } else {
+            cursor = nil
+        }
+    }
+    model := md
+    if cursor != nil {
+        model = model.appendKeyset(column, operator, cursor)
+    }
+    // 多查询一条记录用于判断是否还有下一页
+    result, err := model.OrderBy(md.quoteWord(column) + " " + order).Limit(0, size + 1).All()
+    if err != nil {
+        return nil, err
+    }
+    pagination := &CursorPagination {
+        Result : result,
+        Size   : size,
+    }
+    if len(result) > size {
+        pagination.Result     = result[: size]
+        pagination.HasMore    = true
+        pagination.NextCursor = pagination.Result[size - 1][getColumnKey(column)]
+    }
+    return pagination, nil
+}
@@ -0,0 +1,424 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gregex"
+    "gitee.com/johng/gf/g/util/gstr"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// 分片操作合并后的执行结果
+type shardingResult struct {
+    lastInsertId int64
+    rowsAffected int64
+}
+
+func (r *shardingResult) LastInsertId() (int64, error) {
+    return r.lastInsertId, nil
+}
+
+func (r *shardingResult) RowsAffected() (int64, error) {
+    return r.rowsAffected, nil
+}
+
+// 链式操作，手动指定分片字段值，用于Where条件及写入数据中都不包含分片字段值的场景，
+// 给定多个值时，查询/更新/删除操作将会在这些值对应的多个分片上执行。
+func (md *Model) Shard(values...interface{}) *Model {
+    model            := md.Clone()
+    model.shardValues = values
+    return model
+}
+
+// 获取当前Model操作的逻辑表对应的分库分表规则，已路由的Model或者没有规则时返回nil
+func (md *Model) getShardingRule() *ShardingRule {
+    if md.shardRouted {
+        return nil
+    }
+    if name := md.getShardingTable(); name != "" {
+        return getShardingRule(strings.Trim(name, "`\""))
+    }
+    return nil
+}
+
+// 获取当前Model操作的逻辑表名称(可能带有转义符号)
+func (md *Model) getShardingTable() string {
+    if array := strings.Fields(md.tablesInit); len(array) > 0 {
+        return array[0]
+    }
+    return ""
+}
+
+// 根据Where条件(或者Shard方法给定的值)获取路由目标列表，没有分片字段值时返回所有的分片
+func (md *Model) getShardingTargets(rule *ShardingRule) ([]*shardTarget, error) {
+    values := md.shardValues
+    if len(values) == 0 {
+        values = getShardingValuesFromWhere(rule.Key, md.where, md.whereArgs)
+    }
+    if len(values) == 0 {
+        return rule.getAllTargets(), nil
+    }
+    return rule.getTargets(values)
+}
+
+// 创建路由到指定分片的Model对象
+func (md *Model) newShardingModel(target *shardTarget) (*Model, error) {
+    db, err := getShardingDb(md.db, target.group)
+    if err != nil {
+        return nil, err
+    }
+    if md.tx != nil && db != md.db {
+        return nil, errors.New(fmt.Sprintf(`cross-database sharding to group "%s" is not supported in transaction`, target.group))
+    }
+    model            := md.Clone()
+    model.db          = db
+    model.shardRouted = true
+    model.tables      = strings.Replace(md.tables, md.getShardingTable(), target.table, 1)
+    return model, nil
+}
+
+// 在多个分片上执行操作，非事务操作时并发执行，返回第一个产生的错误
+func (md *Model) doShardingTasks(targets []*shardTarget, f func(index int, model *Model) error) error {
+    models := make([]*Model, len(targets))
+    for i, target := range targets {
+        model, err := md.newShardingModel(target)
+        if err != nil {
+            return err
+        }
+        models[i] = model
+    }
+    // 事务操作只能在同一个链接上顺序执行
+    if md.tx != nil || len(models) == 1 {
+        for i, model := range models {
+            if err := f(i, model); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    errs := make([]error, len(models))
+    wg   := sync.WaitGroup{}
+    for i, model := range models {
+        wg.Add(1)
+        go func(i int, model *Model) {
+            defer wg.Done()
+            errs[i] = f(i, model)
+        }(i, model)
+    }
+    wg.Wait()
+    for _, err := range errs {
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// 分片查询，多个分片的查询结果将会合并，并按照排序及分页条件重新排序及截取
+func (md *Model) shardingAll(rule *ShardingRule) (Result, error) {
+    targets, err := md.getShardingTargets(rule)
+    if err != nil {
+        return nil, err
+    }
+    if len(targets) > 1 {
+        if err := md.checkShardingScatter(rule); err != nil {
+            return nil, err
+        }
+    }
+    model := md
+    // 每个分片都需要查询出前start+limit条记录，合并排序后再截取
+    if len(targets) > 1 && md.limit > 0 {
+        model       = md.Clone()
+        model.start = 0
+        model.limit = md.start + md.limit
+    }
+    results := make([]Result, len(targets))
+    err      = model.doShardingTasks(targets, func(index int, m *Model) (err error) {
+        results[index], err = m.All()
+        return
+    })
+    if err != nil {
+        return nil, err
+    }
+    if len(targets) == 1 {
+        return results[0], nil
+    }
+    result := make(Result, 0)
+    for _, r := range results {
+        result = append(result, r...)
+    }
+    if md.orderBy != "" {
+        sortResultByOrder(result, md.orderBy)
+    }
+    if md.limit > 0 {
+        if md.start >= len(result) {
+            return make(Result, 0), nil
+        }
+        if end := md.start + md.limit; end < len(result) {
+            result = result[md.start : end]
+        } else {
+            result = result[md.start :]
+        }
+    }
+    return result, nil
+}
+
+// 分片统计查询，多个分片的统计结果将会累加
+func (md *Model) shardingCount(rule *ShardingRule) (int, error) {
+    targets, err := md.getShardingTargets(rule)
+    if err != nil {
+        return 0, err
+    }
+    if len(targets) > 1 && md.groupBy != "" {
+        if err := md.checkShardingScatter(rule); err != nil {
+            return 0, err
+        }
+    }
+    counts := make([]int, len(targets))
+    err     = md.doShardingTasks(targets, func(index int, m *Model) (err error) {
+        counts[index], err = m.Count()
+        return
+    })
+    total := 0
+    for _, n := range counts {
+        total += n
+    }
+    return total, err
+}
+
+// 检查查询是否可以在多个分片上执行后直接合并结果，分组、去重及聚合查询的结果需要跨分片重新聚合，
+// 直接合并将会得到错误的结果，因此不支持，需要通过Where条件或者Shard方法路由到单个分片执行。
+func (md *Model) checkShardingScatter(rule *ShardingRule) error {
+    if md.groupBy != "" || gregex.IsMatchString(`(?i)(^|[^\w])(DISTINCT\s|(COUNT|SUM|AVG|MAX|MIN|GROUP_CONCAT)\s*\()`, md.fields) {
+        return errors.New(fmt.Sprintf(`aggregate, distinct or group by query on multiple shards is not supported for table "%s"`, rule.Table))
+    }
+    return nil
+}
+
+// 分片更新/删除操作，根据Where条件路由，没有分片字段值时在所有的分片上执行，影响行数将会累加
+func (md *Model) shardingExec(rule *ShardingRule, f func(m *Model) (sql.Result, error)) (sql.Result, error) {
+    targets, err := md.getShardingTargets(rule)
+    if err != nil {
+        return nil, err
+    }
+    results := make([]sql.Result, len(targets))
+    err      = md.doShardingTasks(targets, func(index int, m *Model) (err error) {
+        results[index], err = f(m)
+        return
+    })
+    if err != nil {
+        return nil, err
+    }
+    return mergeShardingResults(results), nil
+}
+
+// 分片写入操作，根据写入数据中的分片字段值(或者Shard方法给定的值)路由，批量数据将会按照分片分组后写入
+func (md *Model) shardingWrite(rule *ShardingRule, f func(m *Model) (sql.Result, error)) (sql.Result, error) {
+    list := List(nil)
+    switch v := md.data.(type) {
+        case List:
+            list = v
+        case Map:
+            list = List{v}
+        default:
+            // 其他类型的数据只能通过Shard方法指定分片
+            if len(md.shardValues) != 1 {
+                return nil, errors.New(fmt.Sprintf(`sharding key "%s" value is required for table "%s"`, rule.Key, rule.Table))
+            }
+            index, err := rule.getIndex(md.shardValues[0])
+            if err != nil {
+                return nil, err
+            }
+            model, err := md.newShardingModel(rule.getTarget(index))
+            if err != nil {
+                return nil, err
+            }
+            return f(model)
+    }
+    groups := make(map[int]List)
+    for _, m := range list {
+        value, ok := m[rule.Key]
+        if !ok {
+            if len(md.shardValues) != 1 {
+                return nil, errors.New(fmt.Sprintf(`sharding key "%s" not found in data for table "%s"`, rule.Key, rule.Table))
+            }
+            value = md.shardValues[0]
+        }
+        index, err := rule.getIndex(value)
+        if err != nil {
+            return nil, err
+        }
+        groups[index] = append(groups[index], m)
+    }
+    results := make([]sql.Result, 0, len(groups))
+    for index := 0; index < rule.getTotal(); index++ {
+        group, ok := groups[index]
+        if !ok {
+            continue
+        }
+        model, err := md.newShardingModel(rule.getTarget(index))
+        if err != nil {
+            return nil, err
+        }
+        if _, ok := md.data.(Map); ok {
+            model.data = group[0]
+        } else {
+            model.data = group
+        }
+        result, err := f(model)
+        if err != nil {
+            return nil, err
+        }
+        results = append(results, result)
+    }
+    return mergeShardingResults(results), nil
+}
+
+// 合并多个分片的执行结果，影响行数累加，最后插入ID为最后一个非0的值
+func mergeShardingResults(results []sql.Result) sql.Result {
+    if len(results) == 1 {
+        return results[0]
+    }
+    merged := &shardingResult{}
+    for _, result := range results {
+        if result == nil {
+            continue
+        }
+        if n, err := result.RowsAffected(); err == nil {
+            merged.rowsAffected += n
+        }
+        if id, err := result.LastInsertId(); err == nil && id > 0 {
+            merged.lastInsertId = id
+        }
+    }
+    return merged
+}
+
+// 从Where条件中解析分片字段的值，支持 key=? 及 key IN(?,?) 形式的条件(包括字面量值)，
+// 当条件中包含OR操作符或者没有分片字段的等值条件时返回nil(需要在所有分片上执行)。
+func getShardingValuesFromWhere(key string, where string, args []interface{}) []interface{} {
+    if where == "" || regexp.MustCompile(`(?i)\sOR\s`).MatchString(where) {
+        return nil
+    }
+    pattern := fmt.Sprintf("(?i)(?:^|[^\\w\\.`\"])(?:[\\w`\"]+\\.)?[`\"]?%s[`\"]?\\s*(=|IN\\s*\\()\\s*", regexp.QuoteMeta(key))
+    match   := regexp.MustCompile(pattern).FindStringSubmatchIndex(where)
+    if match == nil {
+        return nil
+    }
+    argIndex := strings.Count(where[: match[1]], "?")
+    rest     := where[match[1] :]
+    values   := make([]interface{}, 0)
+    for {
+        rest = strings.TrimSpace(rest)
+        if rest == "" {
+            return nil
+        }
+        value := interface{}(nil)
+        switch {
+            case rest[0] == '?':
+                if argIndex >= len(args) {
+                    return nil
+                }
+                value = args[argIndex]
+                argIndex++
+                rest  = rest[1 :]
+            case rest[0] == '\'':
+                pos := strings.Index(rest[1 :], "'")
+                if pos < 0 {
+                    return nil
+                }
+                value = rest[1 : pos + 1]
+                rest  = rest[pos + 2 :]
+            default:
+                pos := strings.IndexAny(rest, " ,)")
+                if pos < 0 {
+                    pos = len(rest)
+                }
+                if !gstr.IsNumeric(rest[: pos]) {
+                    return nil
+                }
+                value = rest[: pos]
+                rest  = rest[pos :]
+        }
+        values = append(values, value)
+        // 等值条件只有一个值，IN条件需要解析到右括号为止
+        if where[match[2] : match[3]] == "=" {
+            return values
+        }
+        rest = strings.TrimSpace(rest)
+        if strings.HasPrefix(rest, ",") {
+            rest = rest[1 :]
+        } else if strings.HasPrefix(rest, ")") {
+            return values
+        } else {
+            return nil
+        }
+    }
+}
+
+// 按照排序语句对合并后的结果集重新排序，例如：id DESC, name ASC
+func sortResultByOrder(result Result, orderBy string) {
+    type orderItem struct {
+        key  string
+        desc bool
+    }
+    items    := make([]orderItem, 0)
+    replacer := strings.NewReplacer("`", "", "\"", "")
+    for _, v := range strings.Split(orderBy, ",") {
+        array := strings.Fields(v)
+        if len(array) == 0 {
+            continue
+        }
+        items = append(items, orderItem {
+            key  : getColumnKey(replacer.Replace(array[0])),
+            desc : len(array) > 1 && strings.EqualFold(array[1], "DESC"),
+        })
+    }
+    sort.SliceStable(result, func(i, j int) bool {
+        for _, item := range items {
+            if c := compareValue(result[i][item.key], result[j][item.key]); c != 0 {
+                if item.desc {
+                    return c > 0
+                }
+                return c < 0
+            }
+        }
+        return false
+    })
+}
+
+// 比较两个记录值的大小，数值类型按照数值比较，其他类型按照字符串比较，nil值最小
+func compareValue(a, b Value) int {
+    aNil, bNil := a == nil || a.IsNil(), b == nil || b.IsNil()
+    switch {
+        case aNil && bNil:
+            return 0
+        case aNil:
+            return -1
+        case bNil:
+            return 1
+    }
+    as, bs := a.String(), b.String()
+    if gstr.IsNumeric(as) && gstr.IsNumeric(bs) {
+        af, bf := gconv.Float64(as), gconv.Float64(bs)
+        switch {
+            case af < bf:
+                return -1
+            case af > bf:
+                return 1
+        }
+        return 0
+    }
+    return strings.Compare(as, bs)
+}
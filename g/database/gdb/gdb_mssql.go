@@ -8,7 +8,7 @@
 @date 20181109
 说明：
     1.需要导入sqlserver驱动： github.com/denisenkom/go-mssqldb
-    2.不支持save/replace方法
+    2.save/replace方法使用MERGE语法实现
     3.不支持LastInsertId方法
 */
 package gdb
@@ -175,3 +175,101 @@ func (db *dbMssql) getTableFields(table string) (fields map[string]string, err e
 	}
 	return
 }
+
+// 生成insert、replace, save， ignore操作的SQL语句，
+// SQL Server没有replace语法，replace、save及ignore操作均使用MERGE语法实现，例如：
+// MERGE INTO user AS T USING (VALUES (?,?)) AS S(id,name) ON (T.id=S.id)
+// WHEN MATCHED THEN UPDATE SET T.name=S.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (S.id,S.name);
+func (db *dbMssql) formatInsertSql(table string, fields []string, rows int, option int, conflictKeys []string) (string, error) {
+	if option == OPTION_INSERT {
+		return db.dbBase.formatInsertSql(table, fields, rows, option, conflictKeys)
+	}
+	keys, err := getConflictKeys(db, table, conflictKeys)
+	if err != nil {
+		return "", err
+	}
+	charl, charr := db.getChars()
+	quoted := quoteFields(fields, charl, charr)
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = fmt.Sprintf("T.%s%s%s=S.%s%s%s", charl, key, charr, charl, key, charr)
+	}
+	values := make([]string, len(fields))
+	for i, field := range quoted {
+		values[i] = "S." + field
+	}
+	query := fmt.Sprintf("MERGE INTO %s AS T USING (VALUES %s) AS S(%s) ON (%s)",
+		table, getValuePlaceholders(len(fields), rows), strings.Join(quoted, ","), strings.Join(conditions, " AND "))
+	if option != OPTION_IGNORE {
+		updates := make([]string, 0)
+		for _, field := range getFieldsExceptKeys(fields, keys) {
+			updates = append(updates, fmt.Sprintf("T.%s%s%s=S.%s%s%s", charl, field, charr, charl, field, charr))
+		}
+		if len(updates) > 0 {
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ",")
+		}
+	}
+	query += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);", strings.Join(quoted, ","), strings.Join(values, ","))
+	return query, nil
+}
+
+// 获得指定表的主键字段列表
+func (db *dbMssql) getPrimaryKeys(table string) (keys []string, err error) {
+	v := db.cache.GetOrSetFunc("table_primary_keys_"+table, func() interface{} {
+		result := (Result)(nil)
+		result, err = db.GetAll(fmt.Sprintf(`
+		SELECT c.COLUMN_NAME AS FIELD FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS t, INFORMATION_SCHEMA.KEY_COLUMN_USAGE c
+		WHERE t.CONSTRAINT_NAME=c.CONSTRAINT_NAME AND t.CONSTRAINT_TYPE='PRIMARY KEY' AND t.TABLE_NAME='%s'
+		ORDER BY c.ORDINAL_POSITION`, table))
+		if err != nil {
+			return nil
+		}
+		keys = make([]string, len(result))
+		for i, m := range result {
+			keys[i] = strings.ToLower(m["FIELD"].String())
+		}
+		return keys
+	}, 0)
+	if err == nil {
+		keys = v.([]string)
+	}
+	return
+}
+
+// 获取当前数据库所有的数据表名称
+func (db *dbMssql) getTables() ([]string, error) {
+	result, err := db.GetAll(`SELECT NAME FROM SYSOBJECTS WHERE XTYPE='U' ORDER BY NAME`)
+	if err != nil {
+		return nil, err
+	}
+	array := make([]string, len(result))
+	for i, m := range result {
+		array[i] = strings.ToLower(m["NAME"].String())
+	}
+	return array, nil
+}
+
+// 获取指定数据表的字段结构列表
+func (db *dbMssql) getTableFieldList(table string) ([]*TableField, error) {
+	result, err := db.GetAll(fmt.Sprintf(`
+	SELECT COLUMN_NAME AS FIELD, DATA_TYPE AS TYPE, IS_NULLABLE AS NULLABLE, COLUMN_DEFAULT AS DEFAULT_VALUE,
+	COLUMNPROPERTY(OBJECT_ID(TABLE_NAME), COLUMN_NAME, 'IsIdentity') AS IS_IDENTITY
+	FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME='%s' ORDER BY ORDINAL_POSITION`, table))
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]*TableField, len(result))
+	for i, m := range result {
+		fields[i] = &TableField{
+			Index:   i,
+			Name:    strings.ToLower(m["FIELD"].String()),
+			Type:    strings.ToLower(m["TYPE"].String()),
+			Null:    strings.EqualFold(m["NULLABLE"].String(), "YES"),
+			Default: m["DEFAULT_VALUE"],
+		}
+		if m["IS_IDENTITY"].Int() == 1 {
+			fields[i].Extra = "auto_increment"
+		}
+	}
+	return fields, markPrimaryKeys(db, table, fields)
+}
@@ -8,7 +8,7 @@
 @date 20181026
 说明：
     1.需要导入oracle驱动： github.com/mattn/go-oci8
-    2.不支持save/replace方法，可以调用这2个方法估计会报错，还没测试过,(应该是可以通过oracle的merge来实现这2个功能的，还没仔细研究)
+    2.save/replace方法使用MERGE语法实现
     3.不支持LastInsertId方法
 */
 package gdb
@@ -61,6 +61,10 @@ func (db *dbOracle) handleSqlBeforeExec(query string) string {
 
 //由于ORACLE中对LIMIT和批量插入的语法与MYSQL不一致，所以这里需要对LIMIT和批量插入做语法上的转换
 func (db *dbOracle) parseSql(sql string) string {
+	//MERGE语句(save/replace操作)不需要做转换
+	if gregex.IsMatchString(`^\s*(?i)MERGE`, sql) {
+		return sql
+	}
 	//下面的正则表达式匹配出SELECT和INSERT的关键字后分别做不同的处理，如有LIMIT则将LIMIT的关键字也匹配出
 	patten := `^\s*(?i)(SELECT)|(INSERT)|(LIMIT\s*(\d+)\s*,\s*(\d+))`
 	if gregex.IsMatchString(patten, sql) == false {
@@ -168,3 +172,104 @@ func (db *dbOracle) getTableFields(table string) (fields map[string]string, err
 	}
 	return
 }
+
+// 生成insert、replace, save， ignore操作的SQL语句，
+// ORACLE没有replace语法，replace、save及ignore操作均使用MERGE语法实现，例如：
+// MERGE INTO user T USING (SELECT ? id, ? name FROM DUAL UNION ALL SELECT ? id, ? name FROM DUAL) S ON (T.id=S.id)
+// WHEN MATCHED THEN UPDATE SET T.name=S.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (S.id,S.name)
+func (db *dbOracle) formatInsertSql(table string, fields []string, rows int, option int, conflictKeys []string) (string, error) {
+	if option == OPTION_INSERT {
+		return db.dbBase.formatInsertSql(table, fields, rows, option, conflictKeys)
+	}
+	keys, err := getConflictKeys(db, table, conflictKeys)
+	if err != nil {
+		return "", err
+	}
+	charl, charr := db.getChars()
+	quoted := quoteFields(fields, charl, charr)
+	columns := make([]string, len(fields))
+	values := make([]string, len(fields))
+	for i, field := range quoted {
+		columns[i] = "? " + field
+		values[i] = "S." + field
+	}
+	selects := make([]string, rows)
+	for i := 0; i < rows; i++ {
+		selects[i] = fmt.Sprintf("SELECT %s FROM DUAL", strings.Join(columns, ","))
+	}
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = fmt.Sprintf("T.%s%s%s=S.%s%s%s", charl, key, charr, charl, key, charr)
+	}
+	query := fmt.Sprintf("MERGE INTO %s T USING (%s) S ON (%s)",
+		table, strings.Join(selects, " UNION ALL "), strings.Join(conditions, " AND "))
+	if option != OPTION_IGNORE {
+		updates := make([]string, 0)
+		for _, field := range getFieldsExceptKeys(fields, keys) {
+			updates = append(updates, fmt.Sprintf("T.%s%s%s=S.%s%s%s", charl, field, charr, charl, field, charr))
+		}
+		if len(updates) > 0 {
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ",")
+		}
+	}
+	query += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(quoted, ","), strings.Join(values, ","))
+	return query, nil
+}
+
+// 获得指定表的主键字段列表
+func (db *dbOracle) getPrimaryKeys(table string) (keys []string, err error) {
+	v := db.cache.GetOrSetFunc("table_primary_keys_"+table, func() interface{} {
+		result := (Result)(nil)
+		result, err = db.GetAll(fmt.Sprintf(`
+		SELECT cols.COLUMN_NAME AS FIELD FROM USER_CONSTRAINTS cons, USER_CONS_COLUMNS cols
+		WHERE cons.CONSTRAINT_NAME = cols.CONSTRAINT_NAME AND cons.CONSTRAINT_TYPE = 'P' AND cons.TABLE_NAME = '%s'
+		ORDER BY cols.POSITION`, strings.ToUpper(table)))
+		if err != nil {
+			return nil
+		}
+		keys = make([]string, len(result))
+		for i, m := range result {
+			keys[i] = strings.ToLower(m["FIELD"].String())
+		}
+		return keys
+	}, 0)
+	if err == nil {
+		keys = v.([]string)
+	}
+	return
+}
+
+// 获取当前用户所有的数据表名称
+func (db *dbOracle) getTables() ([]string, error) {
+	result, err := db.GetAll(`SELECT TABLE_NAME FROM USER_TABLES ORDER BY TABLE_NAME`)
+	if err != nil {
+		return nil, err
+	}
+	array := make([]string, len(result))
+	for i, m := range result {
+		array[i] = strings.ToLower(m["TABLE_NAME"].String()) //ORACLE返回的值默认都是大写的，需要转为小写
+	}
+	return array, nil
+}
+
+// 获取指定数据表的字段结构列表
+func (db *dbOracle) getTableFieldList(table string) ([]*TableField, error) {
+	result, err := db.GetAll(fmt.Sprintf(`
+	SELECT c.COLUMN_NAME AS FIELD, c.DATA_TYPE AS TYPE, c.NULLABLE AS NULLABLE, m.COMMENTS AS COMMENTS
+	FROM USER_TAB_COLUMNS c LEFT JOIN USER_COL_COMMENTS m ON m.TABLE_NAME = c.TABLE_NAME AND m.COLUMN_NAME = c.COLUMN_NAME
+	WHERE c.TABLE_NAME = '%s' ORDER BY c.COLUMN_ID`, strings.ToUpper(table)))
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]*TableField, len(result))
+	for i, m := range result {
+		fields[i] = &TableField{
+			Index:   i,
+			Name:    strings.ToLower(m["FIELD"].String()),
+			Type:    strings.ToLower(m["TYPE"].String()),
+			Null:    m["NULLABLE"].String() == "Y",
+			Comment: m["COMMENTS"].String(),
+		}
+	}
+	return fields, markPrimaryKeys(db, table, fields)
+}
@@ -11,12 +11,12 @@ import (
     "fmt"
     "regexp"
     "database/sql"
+    "strings"
 )
 
 // PostgreSQL的适配.
 // 使用时需要import:
 // _ "gitee.com/johng/gf/third/github.com/lib/pq"
-// @todo 需要完善replace和save的操作覆盖
 
 // 数据库链接对象
 type dbPgsql struct {
@@ -52,4 +52,81 @@ func (db *dbPgsql) handleSqlBeforeExec(query string) string {
         return fmt.Sprintf("$%d", index)
     })
     return str
-}
\ No newline at end of file
+}
+
+// 生成insert、replace, save， ignore操作的SQL语句，
+// PostgreSQL没有replace语法，replace及save操作均使用 ON CONFLICT DO UPDATE 语法实现(需要PostgreSQL 9.5及以上版本)
+func (db *dbPgsql) formatInsertSql(table string, fields []string, rows int, option int, conflictKeys []string) (string, error) {
+    switch option {
+        case OPTION_IGNORE:
+            charl, charr := db.getChars()
+            return fmt.Sprintf("INSERT INTO %s(%s) VALUES%s ON CONFLICT DO NOTHING",
+                table, strings.Join(quoteFields(fields, charl, charr), ","), getValuePlaceholders(len(fields), rows)), nil
+        case OPTION_REPLACE, OPTION_SAVE:
+            return formatOnConflictInsertSql(db, table, fields, rows, conflictKeys, "EXCLUDED")
+    }
+    return db.dbBase.formatInsertSql(table, fields, rows, option, conflictKeys)
+}
+
+// 获得指定表的主键字段列表
+func (db *dbPgsql) getPrimaryKeys(table string) (keys []string, err error) {
+    v := db.cache.GetOrSetFunc("table_primary_keys_" + table, func() interface{} {
+        result := (Result)(nil)
+        result, err = db.GetAll(`
+        SELECT a.attname AS field FROM pg_index i
+        JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+        WHERE i.indrelid = ?::regclass AND i.indisprimary`, table)
+        if err != nil {
+            return nil
+        }
+        keys = make([]string, len(result))
+        for i, m := range result {
+            keys[i] = m["field"].String()
+        }
+        return keys
+    }, 0)
+    if err == nil {
+        keys = v.([]string)
+    }
+    return
+}
+
+// 获取当前数据库(当前schema)所有的数据表名称
+func (db *dbPgsql) getTables() ([]string, error) {
+    result, err := db.GetAll(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema() ORDER BY tablename`)
+    if err != nil {
+        return nil, err
+    }
+    array := make([]string, len(result))
+    for i, m := range result {
+        array[i] = m["tablename"].String()
+    }
+    return array, nil
+}
+
+// 获取指定数据表的字段结构列表
+func (db *dbPgsql) getTableFieldList(table string) ([]*TableField, error) {
+    result, err := db.GetAll(`
+    SELECT column_name, data_type, is_nullable, column_default, col_description(?::regclass, ordinal_position) AS column_comment
+    FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?
+    ORDER BY ordinal_position`, table, table)
+    if err != nil {
+        return nil, err
+    }
+    fields := make([]*TableField, len(result))
+    for i, m := range result {
+        fields[i] = &TableField {
+            Index   : i,
+            Name    : m["column_name"].String(),
+            Type    : strings.ToLower(m["data_type"].String()),
+            Null    : strings.EqualFold(m["is_nullable"].String(), "YES"),
+            Default : m["column_default"],
+            Comment : m["column_comment"].String(),
+        }
+        // 自增字段的默认值为序列值
+        if strings.HasPrefix(fields[i].Default.String(), "nextval(") {
+            fields[i].Extra = "auto_increment"
+        }
+    }
+    return fields, markPrimaryKeys(db, table, fields)
+}
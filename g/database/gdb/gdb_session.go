@@ -0,0 +1,68 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "time"
+)
+
+// 写后读(read-your-writes)的会话对象，通常对应一次请求，不同会话之间互不影响。
+// 通过会话执行写操作(事务中的写操作在事务提交成功后)后的粘滞时间窗口内(SetStickyWindow)，
+// 通过该会话执行的查询操作都将在master节点上执行，例如：
+// session := db.Session()
+// session.Table("user").Data(g.Map{"name" : "john"}).Insert()
+// one, _ := session.Table("user").Where("name=?", "john").One()
+type Session struct {
+    db        DB           // 数据库对象
+    lastWrite *gtype.Int64 // (单位纳秒)会话最近一次写操作成功的时间
+}
+
+// 创建写后读的会话对象
+func (bs *dbBase) Session() *Session {
+    return &Session {
+        db        : bs.db,
+        lastWrite : gtype.NewInt64(),
+    }
+}
+
+// 链式操作，数据表字段，可支持多个表，以半角逗号连接
+func (s *Session) Table(tables string) *Model {
+    model        := s.db.Table(tables)
+    model.session = s
+    return model
+}
+
+// 链式操作，数据表字段，可支持多个表，以半角逗号连接
+func (s *Session) From(tables string) *Model {
+    return s.Table(tables)
+}
+
+// 开启事务操作，事务提交成功后记录会话的写操作时间
+func (s *Session) Begin() (*TX, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return nil, err
+    }
+    tx.session = s
+    return tx, nil
+}
+
+// 记录会话的写操作时间
+func (s *Session) markWrite() {
+    s.lastWrite.Set(time.Now().UnixNano())
+}
+
+// 判断会话当前是否处于写后读的粘滞时间窗口内
+func (s *Session) isSticky() bool {
+    if window := s.db.getStickyWindow(); window > 0 {
+        if last := s.lastWrite.Val(); last > 0 {
+            return time.Now().UnixNano() - last < int64(window)
+        }
+    }
+    return false
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gdb
+
+import (
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gstr"
+    "hash/crc32"
+    "strings"
+)
+
+const (
+    SHARDING_MODULO = 0 // (默认)取模分片，分片字段值必须为整数：分片索引 = 字段值 % 分片总数
+    SHARDING_RANGE  = 1 // 范围分片，按照Ranges给定的区间上限(不包含)确定分片索引
+    SHARDING_HASH   = 2 // 哈希分片，分片索引 = crc32(字段值) % 分片总数，适用于字符串类型的分片字段
+)
+
+// 分库分表规则，分片总数为DbCount*TableCount，分片索引(index)从0开始，
+// 数据库索引(db) = index / TableCount，数据表索引(table) = index % TableCount。
+// 数据库配置分组名称及数据表名称模板中可以使用以下变量：
+// {db}    数据库索引；
+// {table} 数据库内的数据表索引；
+// {index} 全局的分片索引；
+// 例如：DbCount=2, TableCount=4, GroupTemplate="order_{db}", TableTemplate="order_{index}"，
+// 那么 user_id=13 的记录分片索引为13%8=5，将会被路由到配置分组order_1的数据表order_5上。
+type ShardingRule struct {
+    Table         string                                          // 逻辑表名，即Model中使用的表名
+    Key           string                                          // 分片字段名称
+    Algorithm     int                                             // 分片算法：SHARDING_MODULO, SHARDING_RANGE, SHARDING_HASH
+    DbCount       int                                             // 分库数量(默认为1，即只分表)
+    TableCount    int                                             // 每个数据库中的分表数量(默认为1，即只分库)
+    GroupTemplate string                                          // 数据库配置分组名称模板，为空时表示使用当前数据库对象(只分表)
+    TableTemplate string                                          // 数据表名称模板，为空时表示使用逻辑表名(只分库)
+    Ranges        []int64                                         // (SHARDING_RANGE)每个分片的区间上限(不包含)，按照从小到大顺序，数量必须等于分片总数
+    Func          func(value interface{}, total int) (int, error) // 自定义分片算法，给定时Algorithm无效，返回值为分片索引
+}
+
+// 分片路由目标
+type shardTarget struct {
+    index int    // 分片索引
+    group string // 数据库配置分组名称
+    table string // 数据表名称
+}
+
+var (
+    // 分库分表规则，键名为逻辑表名
+    shardingRules = gmap.NewStringInterfaceMap()
+    // 分片使用的数据库对象，键名为配置分组名称
+    shardingDbs   = gmap.NewStringInterfaceMap()
+)
+
+// 添加分库分表规则(全局有效)，同一逻辑表名的规则将会被覆盖。
+// 添加规则后，Model操作该逻辑表时将会根据Where条件或者写入数据中的分片字段值自动路由到对应的数据库及数据表，
+// 没有给定分片字段值的查询/更新/删除操作将会在所有的分片上执行并合并结果(分组、去重及聚合查询除外，将会返回错误)。
+func AddShardingRule(rule ShardingRule) error {
+    if rule.Table == "" || rule.Key == "" {
+        return errors.New("table and key are required for sharding rule")
+    }
+    if rule.DbCount < 1 {
+        rule.DbCount = 1
+    }
+    if rule.TableCount < 1 {
+        rule.TableCount = 1
+    }
+    if rule.Func == nil {
+        switch rule.Algorithm {
+            case SHARDING_MODULO, SHARDING_HASH:
+            case SHARDING_RANGE:
+                if len(rule.Ranges) != rule.getTotal() {
+                    return errors.New(fmt.Sprintf("sharding ranges count %d does not match shards count %d", len(rule.Ranges), rule.getTotal()))
+                }
+            default:
+                return errors.New(fmt.Sprintf("unsupported sharding algorithm: %d", rule.Algorithm))
+        }
+    }
+    shardingRules.Set(rule.Table, &rule)
+    return nil
+}
+
+// 删除指定逻辑表的分库分表规则
+func RemoveShardingRule(table string) {
+    shardingRules.Remove(table)
+}
+
+// 获取指定逻辑表的分库分表规则，不存在时返回nil
+func getShardingRule(table string) *ShardingRule {
+    if v := shardingRules.Get(table); v != nil {
+        return v.(*ShardingRule)
+    }
+    return nil
+}
+
+// 分片总数
+func (r *ShardingRule) getTotal() int {
+    return r.DbCount * r.TableCount
+}
+
+// 根据分片字段值计算分片索引
+func (r *ShardingRule) getIndex(value interface{}) (int, error) {
+    total := r.getTotal()
+    if r.Func != nil {
+        index, err := r.Func(value, total)
+        if err == nil && (index < 0 || index >= total) {
+            err = errors.New(fmt.Sprintf("sharding index %d out of range [0, %d)", index, total))
+        }
+        return index, err
+    }
+    switch r.Algorithm {
+        case SHARDING_RANGE:
+            v := gconv.Int64(value)
+            for i, max := range r.Ranges {
+                if v < max {
+                    return i, nil
+                }
+            }
+            return 0, errors.New(fmt.Sprintf(`sharding key value "%v" out of ranges for table "%s"`, value, r.Table))
+
+        case SHARDING_HASH:
+            return int(crc32.ChecksumIEEE([]byte(gconv.String(value))) % uint32(total)), nil
+
+        default:
+            s := gconv.String(value)
+            if !gstr.IsNumeric(s) || strings.Contains(s, ".") {
+                return 0, errors.New(fmt.Sprintf(`invalid sharding key value "%v" for modulo algorithm, integer required`, value))
+            }
+            index := gconv.Int64(s) % int64(total)
+            if index < 0 {
+                index = -index
+            }
+            return int(index), nil
+    }
+}
+
+// 根据分片索引生成路由目标
+func (r *ShardingRule) getTarget(index int) *shardTarget {
+    replaces := map[string]string {
+        "{db}"    : gconv.String(index / r.TableCount),
+        "{table}" : gconv.String(index % r.TableCount),
+        "{index}" : gconv.String(index),
+    }
+    target := &shardTarget {
+        index : index,
+        table : r.Table,
+    }
+    if r.GroupTemplate != "" {
+        target.group = gstr.ReplaceByMap(r.GroupTemplate, replaces)
+    }
+    if r.TableTemplate != "" {
+        target.table = gstr.ReplaceByMap(r.TableTemplate, replaces)
+    }
+    return target
+}
+
+// 根据分片字段值列表生成去重后的路由目标列表(按照分片索引排序)
+func (r *ShardingRule) getTargets(values []interface{}) ([]*shardTarget, error) {
+    exists  := make(map[int]bool)
+    targets := make([]*shardTarget, 0)
+    for _, value := range values {
+        index, err := r.getIndex(value)
+        if err != nil {
+            return nil, err
+        }
+        exists[index] = true
+    }
+    for i := 0; i < r.getTotal(); i++ {
+        if exists[i] {
+            targets = append(targets, r.getTarget(i))
+        }
+    }
+    return targets, nil
+}
+
+// 获取所有分片的路由目标
+func (r *ShardingRule) getAllTargets() []*shardTarget {
+    targets := make([]*shardTarget, r.getTotal())
+    for i := 0; i < len(targets); i++ {
+        targets[i] = r.getTarget(i)
+    }
+    return targets
+}
+
+// 获取分片使用的数据库对象，配置分组为空或者与当前数据库对象相同时直接返回当前数据库对象
+func getShardingDb(db DB, group string) (DB, error) {
+    if group == "" || group == db.getGroup() {
+        return db, nil
+    }
+    err := error(nil)
+    v   := shardingDbs.GetOrSetFuncLock(group, func() interface{} {
+        r, e := New(group)
+        if e != nil {
+            err = e
+            return nil
+        }
+        return r
+    })
+    if v == nil {
+        shardingDbs.Remove(group)
+        if err == nil {
+            err = errors.New(fmt.Sprintf(`database initialization failed for sharding group "%s"`, group))
+        }
+        return nil, err
+    }
+    return v.(DB), nil
+}
@@ -9,6 +9,8 @@ package gdb
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 )
 
 // 使用时需要import:
@@ -42,8 +44,102 @@ func (db *dbSqlite) getChars () (charLeft string, charRight string) {
 }
 
 // 在执行sql之前对sql进行进一步处理
-// @todo 需要增加对Save方法的支持，可使用正则来实现替换，
-// @todo 将ON DUPLICATE KEY UPDATE触发器修改为两条SQL语句(INSERT OR IGNORE & UPDATE)
 func (db *dbSqlite) handleSqlBeforeExec(query string) string {
 	return query
-}
\ No newline at end of file
+}
+
+// 生成insert、replace, save， ignore操作的SQL语句，
+// save操作使用 ON CONFLICT DO UPDATE 语法实现(需要SQLite 3.24.0及以上版本)
+func (db *dbSqlite) formatInsertSql(table string, fields []string, rows int, option int, conflictKeys []string) (string, error) {
+	switch option {
+	case OPTION_IGNORE:
+		charl, charr := db.getChars()
+		return fmt.Sprintf("INSERT OR IGNORE INTO %s(%s) VALUES%s",
+			table, strings.Join(quoteFields(fields, charl, charr), ","), getValuePlaceholders(len(fields), rows)), nil
+	case OPTION_SAVE:
+		return formatOnConflictInsertSql(db, table, fields, rows, conflictKeys, "excluded")
+	}
+	return db.dbBase.formatInsertSql(table, fields, rows, option, conflictKeys)
+}
+
+// 获得指定表的主键字段列表
+func (db *dbSqlite) getPrimaryKeys(table string) (keys []string, err error) {
+	v := db.cache.GetOrSetFunc("table_primary_keys_"+table, func() interface{} {
+		result := (Result)(nil)
+		result, err = db.GetAll(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+		if err != nil {
+			return nil
+		}
+		// pk字段为主键中的顺序(从1开始)，非主键字段为0
+		positions := make(map[int]string)
+		for _, m := range result {
+			if pk := m["pk"].Int(); pk > 0 {
+				positions[pk] = m["name"].String()
+			}
+		}
+		keys = make([]string, 0, len(positions))
+		for i := 1; i <= len(positions); i++ {
+			keys = append(keys, positions[i])
+		}
+		return keys
+	}, 0)
+	if err == nil {
+		keys = v.([]string)
+	}
+	return
+}
+
+// 获得指定表表的数据结构，构造成map哈希表返回，其中键名为表字段名称，键值为字段数据类型.
+func (db *dbSqlite) getTableFields(table string) (fields map[string]string, err error) {
+	v := db.cache.GetOrSetFunc("table_fields_"+table, func() interface{} {
+		result := (Result)(nil)
+		result, err = db.GetAll(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+		if err != nil {
+			return nil
+		}
+		fields = make(map[string]string)
+		for _, m := range result {
+			fields[m["name"].String()] = strings.ToLower(m["type"].String())
+		}
+		return fields
+	}, 0)
+	if err == nil {
+		fields = v.(map[string]string)
+	}
+	return
+}
+
+// 获取当前数据库所有的数据表名称
+func (db *dbSqlite) getTables() ([]string, error) {
+	result, err := db.GetAll(`SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	array := make([]string, len(result))
+	for i, m := range result {
+		array[i] = m["name"].String()
+	}
+	return array, nil
+}
+
+// 获取指定数据表的字段结构列表
+func (db *dbSqlite) getTableFieldList(table string) ([]*TableField, error) {
+	result, err := db.GetAll(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]*TableField, len(result))
+	for i, m := range result {
+		fields[i] = &TableField{
+			Index:   i,
+			Name:    m["name"].String(),
+			Type:    strings.ToLower(m["type"].String()),
+			Null:    m["notnull"].Int() == 0,
+			Default: m["dflt_value"],
+		}
+		if m["pk"].Int() > 0 {
+			fields[i].Key = "PRI"
+		}
+	}
+	return fields, nil
+}
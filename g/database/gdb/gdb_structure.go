@@ -102,19 +102,90 @@ func (bs *dbBase) getTableFields(table string) (fields map[string]string, err er
     return
 }
 
-/*
-// 获取当前数据库所有的表结构
-func (bs *dbBase) getTables() []string {
-    if result, _ := bs.GetAll(`SHOW TABLES`); result != nil {
-        array := make([]string, len(result))
+// 获得指定表的主键字段列表(按照索引中的顺序)，联合主键时返回多个字段
+func (bs *dbBase) getPrimaryKeys(table string) (keys []string, err error) {
+    // 缓存不存在时会查询数据表结构，缓存后不过期，直至程序重启(重新部署)
+    v := bs.cache.GetOrSetFunc("table_primary_keys_" + table, func() interface{} {
+        result       := (Result)(nil)
+        charl, charr := bs.db.getChars()
+        result, err   = bs.GetAll(fmt.Sprintf(`SHOW KEYS FROM %s%s%s WHERE Key_name='PRIMARY'`, charl, table, charr))
+        if err != nil {
+            return nil
+        }
+        keys = make([]string, len(result))
         for i, m := range result {
-            for _, v := range m {
-                array[i] = v.String()
+            keys[i] = m["Column_name"].String()
+        }
+        return keys
+    }, 0)
+    if err == nil {
+        keys = v.([]string)
+    }
+    return
+}
+
+// 获取当前数据库所有的数据表名称
+func (bs *dbBase) Tables() ([]string, error) {
+    return bs.db.getTables()
+}
+
+// 获取指定数据表的字段结构列表(按照字段在表中的顺序)，常用于代码生成等工具，结果不会被缓存
+func (bs *dbBase) TableFields(table string) ([]*TableField, error) {
+    return bs.db.getTableFieldList(table)
+}
+
+// 获取当前数据库所有的数据表名称
+func (bs *dbBase) getTables() ([]string, error) {
+    result, err := bs.GetAll(`SHOW TABLES`)
+    if err != nil {
+        return nil, err
+    }
+    array := make([]string, len(result))
+    for i, m := range result {
+        for _, v := range m {
+            array[i] = v.String()
+            break
+        }
+    }
+    return array, nil
+}
+
+// 获取指定数据表的字段结构列表
+func (bs *dbBase) getTableFieldList(table string) ([]*TableField, error) {
+    charl, charr := bs.db.getChars()
+    result, err  := bs.GetAll(fmt.Sprintf(`SHOW FULL COLUMNS FROM %s%s%s`, charl, table, charr))
+    if err != nil {
+        return nil, err
+    }
+    fields := make([]*TableField, len(result))
+    for i, m := range result {
+        fields[i] = &TableField {
+            Index   : i,
+            Name    : m["Field"].String(),
+            Type    : strings.ToLower(m["Type"].String()),
+            Null    : strings.EqualFold(m["Null"].String(), "YES"),
+            Key     : m["Key"].String(),
+            Default : m["Default"],
+            Extra   : m["Extra"].String(),
+            Comment : m["Comment"].String(),
+        }
+    }
+    return fields, nil
+}
+
+// 根据数据表的主键字段列表设置字段结构的索引类型，用于不能直接查询到字段索引类型的数据库
+func markPrimaryKeys(db DB, table string, fields []*TableField) error {
+    keys, err := db.getPrimaryKeys(table)
+    if err != nil {
+        return err
+    }
+    for _, field := range fields {
+        for _, key := range keys {
+            if strings.EqualFold(field.Name, key) {
+                field.Key = "PRI"
                 break
             }
         }
-        return array
     }
     return nil
 }
-*/
\ No newline at end of file
@@ -14,14 +14,19 @@ import (
 
 // 数据库事务对象
 type TX struct {
-    db     DB
-    tx     *sql.Tx
-    master *sql.DB
+    db      DB
+    tx      *sql.Tx
+    master  *sql.DB
+    session *Session // 所属的写后读会话对象(通过Session.Begin开启的事务)
 }
 
-// 事务操作，提交
+// 事务操作，提交，提交成功后记录所属会话的写操作时间
 func (tx *TX) Commit() error {
-    return tx.tx.Commit()
+    err := tx.tx.Commit()
+    if err == nil && tx.session != nil {
+        tx.session.markWrite()
+    }
+    return err
 }
 
 // 事务操作，回滚
@@ -46,12 +51,7 @@ func (tx *TX) Prepare(query string) (*sql.Stmt, error) {
 
 // 数据库查询，获取查询结果集，以列表结构返回
 func (tx *TX) GetAll(query string, args ...interface{}) (Result, error) {
-    rows, err := tx.Query(query, args ...)
-    if err != nil || rows == nil {
-        return nil, err
-    }
-    defer rows.Close()
-    return tx.db.rowsToResult(rows)
+    return tx.db.doGetAll(tx.tx, query, args ...)
 }
 
 // 数据库查询，获取查询结果记录，以关联数组结构返回
@@ -101,32 +101,37 @@ func (tx *TX) GetCount(query string, args ...interface{}) (int, error) {
 
 // CURD操作:单条数据写入, 仅仅执行写入操作，如果存在冲突的主键或者唯一索引，那么报错返回
 func (tx *TX) Insert(table string, data Map) (sql.Result, error) {
-    return tx.db.doInsert(tx.tx, table, data, OPTION_INSERT)
+    return tx.db.doInsert(tx.tx, table, data, OPTION_INSERT, nil)
 }
 
 // CURD操作:单条数据写入, 如果数据存在(主键或者唯一索引)，那么删除后重新写入一条
 func (tx *TX) Replace(table string, data Map) (sql.Result, error) {
-    return tx.db.doInsert(tx.tx, table, data, OPTION_REPLACE)
+    return tx.db.doInsert(tx.tx, table, data, OPTION_REPLACE, nil)
 }
 
-// CURD操作:单条数据写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据
-func (tx *TX) Save(table string, data Map) (sql.Result, error) {
-    return tx.db.doInsert(tx.tx, table, data, OPTION_SAVE)
+// CURD操作:单条数据写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据，conflictKeys为冲突判断字段(默认为主键)
+func (tx *TX) Save(table string, data Map, conflictKeys...string) (sql.Result, error) {
+    return tx.db.doInsert(tx.tx, table, data, OPTION_SAVE, conflictKeys)
 }
 
 // CURD操作:批量数据指定批次量写入
 func (tx *TX) BatchInsert(table string, list List, batch int) (sql.Result, error) {
-    return tx.db.doBatchInsert(tx.tx, table, list, batch, OPTION_INSERT)
+    return tx.db.doBatchInsert(tx.tx, table, list, batch, OPTION_INSERT, nil)
 }
 
 // CURD操作:批量数据指定批次量写入, 如果数据存在(主键或者唯一索引)，那么删除后重新写入一条
 func (tx *TX) BatchReplace(table string, list List, batch int) (sql.Result, error) {
-    return tx.db.doBatchInsert(tx.tx, table, list, batch, OPTION_REPLACE)
+    return tx.db.doBatchInsert(tx.tx, table, list, batch, OPTION_REPLACE, nil)
+}
+
+// CURD操作:批量数据指定批次量写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据，conflictKeys同Save方法
+func (tx *TX) BatchSave(table string, list List, batch int, conflictKeys...string) (sql.Result, error) {
+    return tx.db.doBatchInsert(tx.tx, table, list, batch, OPTION_SAVE, conflictKeys)
 }
 
-// CURD操作:批量数据指定批次量写入, 如果数据存在(主键或者唯一索引)，那么更新，否则写入一条新数据
-func (tx *TX) BatchSave(table string, list List, batch int) (sql.Result, error) {
-    return tx.db.doBatchInsert(tx.tx, table, list, batch, OPTION_SAVE)
+// CURD操作:批量数据更新，按照keys字段(默认为主键)匹配记录，每一批次数据只需要一次数据库操作
+func (tx *TX) BatchUpdate(table string, list List, batch int, keys...string) (sql.Result, error) {
+    return tx.db.doBatchUpdate(tx.tx, table, list, batch, keys)
 }
 
 // CURD操作:数据更新，统一采用sql预处理
@@ -1,3 +1,9 @@
+// 数据库操作测试需要本地MySQL数据库(root账号，无密码)，
+// 不依赖MySQL的测试(mock数据库、分库分表及SQL语句生成等)可以通过 go test -tags nomysql 单独执行。
+
+//go:build !nomysql
+// +build !nomysql
+
 package gdb_test
 
 import (
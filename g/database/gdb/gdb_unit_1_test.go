@@ -1,10 +1,17 @@
+//go:build !nomysql
+// +build !nomysql
+
 package gdb_test
 
 import (
     "gitee.com/johng/gf/g"
+    "gitee.com/johng/gf/g/database/gdb"
     "gitee.com/johng/gf/g/os/gtime"
     "gitee.com/johng/gf/g/util/gtest"
+    "errors"
+    "strings"
     "testing"
+    "time"
 )
 
 func TestDbBase_Query(t *testing.T) {
@@ -161,6 +168,104 @@ func TestDbBase_GetStruct(t *testing.T) {
     }
 }
 
+func TestDbBase_HealthCheck(t *testing.T) {
+    db.SetHealthCheckInterval(100*time.Millisecond)
+    defer db.SetHealthCheckInterval(0)
+    time.Sleep(300*time.Millisecond)
+    list := db.GetNodeStatus()
+    gtest.Assert(len(list), 1)
+    gtest.Assert(list[0].Alive, true)
+    gtest.AssertGT(int64(list[0].Latency), 0)
+
+    db.SetSlavePolicy(gdb.SLAVE_POLICY_LATENCY)
+    defer db.SetSlavePolicy(gdb.SLAVE_POLICY_PRIORITY)
+    if count, err := db.GetCount("SELECT * FROM user"); err != nil {
+        gtest.Fatal(err)
+    } else {
+        gtest.Assert(count, 3)
+    }
+}
+
+// 用于测试的SQL执行钩子
+type testHook struct {
+    before int
+    after  int
+    last   *gdb.Sql
+}
+
+func (h *testHook) BeforeExec(s *gdb.Sql) error {
+    h.before++
+    if strings.HasPrefix(s.Sql, "TRUNCATE") {
+        return errors.New("TRUNCATE is not allowed")
+    }
+    return nil
+}
+
+func (h *testHook) AfterExec(s *gdb.Sql) {
+    h.after++
+    h.last = s
+}
+
+func TestDbBase_Hook(t *testing.T) {
+    hook := &testHook{}
+    db.AddHook(hook)
+    defer db.RemoveHook(hook)
+    if _, err := db.Update("user", "nickname='T1'", "id=?", 1); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(hook.before, 1)
+    gtest.Assert(hook.after, 1)
+    gtest.Assert(hook.last.Func, "Exec")
+    gtest.Assert(hook.last.RowsAffected, 1)
+    gtest.Assert(strings.Contains(hook.last.Caller, "gdb_unit_1_test.go"), true)
+
+    if _, err := db.Exec("TRUNCATE TABLE user"); err == nil {
+        gtest.Fatal("TRUNCATE should be rejected by hook")
+    }
+    gtest.Assert(hook.after, 1)
+    if count, err := db.GetCount("SELECT * FROM user"); err != nil {
+        gtest.Fatal(err)
+    } else {
+        gtest.Assert(count, 3)
+    }
+}
+
+func TestDbBase_BatchUpdate(t *testing.T) {
+    if result, err := db.BatchUpdate("user", g.List {
+        {"id" : 2, "nickname" : "T22"},
+        {"id" : 3, "nickname" : "T33"},
+    }, 10); err != nil {
+        gtest.Fatal(err)
+    } else {
+        n, _ := result.RowsAffected()
+        gtest.Assert(n, 2)
+    }
+    if value, err := db.GetValue("SELECT nickname FROM user WHERE id=?", 3); err != nil {
+        gtest.Fatal(err)
+    } else {
+        gtest.Assert(value.String(), "T33")
+    }
+}
+
+func TestDbBase_TableFields(t *testing.T) {
+    if tables, err := db.Tables(); err != nil {
+        gtest.Fatal(err)
+    } else {
+        gtest.Assert(len(tables) > 0, true)
+    }
+    fields, err := db.TableFields("user")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(fields), 5)
+    gtest.Assert(fields[0].Name, "id")
+    gtest.Assert(fields[0].Key,  "PRI")
+    gtest.Assert(fields[0].Extra, "auto_increment")
+    gtest.Assert(fields[0].Comment, "用户ID")
+    gtest.Assert(fields[1].Name, "passport")
+    gtest.Assert(fields[4].Name, "create_time")
+}
+
 func TestDbBase_Delete(t *testing.T) {
     if result, err := db.Delete("user", nil); err != nil {
         gtest.Fatal(err)
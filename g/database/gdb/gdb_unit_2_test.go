@@ -1,7 +1,11 @@
+//go:build !nomysql
+// +build !nomysql
+
 package gdb_test
 
 import (
     "gitee.com/johng/gf/g"
+    "gitee.com/johng/gf/g/database/gdb"
     "gitee.com/johng/gf/g/os/gtime"
     "gitee.com/johng/gf/g/util/gtest"
     "testing"
@@ -88,6 +92,46 @@ func TestModel_Update(t *testing.T) {
     gtest.Assert(n, 1)
 }
 
+func TestModel_OnConflict(t *testing.T) {
+    save := func(nickname string) int64 {
+        result, err := db.Table("user").OnConflict("id").Data(g.Map{
+            "id"          : 3,
+            "passport"    : "t3",
+            "password"    : "25d55ad283aa400af464c76d713c07ad",
+            "nickname"    : nickname,
+            "create_time" : "2010-10-10 00:00:01",
+        }).Save()
+        if err != nil {
+            gtest.Fatal(err)
+        }
+        n, _ := result.RowsAffected()
+        return n
+    }
+    // MySQL的ON DUPLICATE KEY UPDATE在记录有更新时影响行数为2，记录没有变化时为0
+    gtest.Assert(save("T3_SAVE"), 2)
+    gtest.Assert(save("T3_SAVE"), 0)
+    gtest.Assert(save("T3"), 2)
+}
+
+func TestModel_BatchUpdate(t *testing.T) {
+    result, err := db.Table("user").Data(g.List{
+        {"id" : 2, "passport" : "t22", "nickname" : "T22"},
+        {"id" : 3, "passport" : "t3",  "nickname" : "T33"},
+    }).Update()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    n, _ := result.RowsAffected()
+    gtest.Assert(n, 2)
+    // 恢复测试数据
+    if _, err := db.Table("user").OnConflict("id").Data(g.List{
+        {"id" : 2, "nickname" : "T2"},
+        {"id" : 3, "nickname" : "T3"},
+    }).Update(); err != nil {
+        gtest.Fatal(err)
+    }
+}
+
 func TestModel_Clone(t *testing.T) {
     md := db.Table("user").Where("id IN(?)", g.Slice{1,3})
     count, err := md.Count()
@@ -208,6 +252,195 @@ func TestModel_Where2(t *testing.T) {
     gtest.Assert(result[0]["id"].Int(), 3)
 }
 
+func TestModel_Master(t *testing.T) {
+    record, err := db.Table("user").Master().Where("id", 1).One()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(record["nickname"].String(), "T111")
+}
+
+func TestModel_Paginate(t *testing.T) {
+    pagination, err := db.Table("user").OrderBy("id ASC").Paginate(1, 2)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(pagination.Total, 3)
+    gtest.Assert(pagination.TotalPage, 2)
+    gtest.Assert(len(pagination.Result), 2)
+
+    pagination, err = db.Table("user").OrderBy("id ASC").Paginate(2, 2)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(pagination.Result), 1)
+    gtest.Assert(pagination.Result[0]["id"].Int(), 3)
+
+    pagination, err = db.Table("user").Paginate(3, 2)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(pagination.Result), 0)
+}
+
+func TestModel_CursorPaginate(t *testing.T) {
+    pagination, err := db.Table("user").CursorPaginate("id", nil, 2)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(pagination.Result), 2)
+    gtest.Assert(pagination.HasMore, true)
+    gtest.Assert(pagination.NextCursor.Int(), 2)
+
+    pagination, err = db.Table("user").CursorPaginate("id", pagination.NextCursor, 2)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(pagination.Result), 1)
+    gtest.Assert(pagination.HasMore, false)
+    gtest.Assert(pagination.Result[0]["id"].Int(), 3)
+
+    // 已有条件中包含OR
+    pagination, err = db.Table("user").Where("id=? OR id>?", 1, 1).CursorPaginate("id", 1, 1)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(pagination.Result), 1)
+    gtest.Assert(pagination.Result[0]["id"].Int(), 2)
+    gtest.Assert(pagination.NextCursor.Int(), 2)
+}
+
+func TestModel_WhereIn(t *testing.T) {
+    result, err := db.Table("user").WhereIn("id", g.Slice{1,3}).WhereNotIn("id", g.Slice{3}).All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 1)
+    gtest.Assert(result[0]["id"].Int(), 1)
+}
+
+func TestModel_WhereBetween(t *testing.T) {
+    result, err := db.Table("user").WhereBetween("id", 2, 3).WhereNotNull("nickname").OrderBy("id ASC").All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 2)
+    gtest.Assert(result[0]["id"].Int(), 2)
+    gtest.Assert(result[1]["id"].Int(), 3)
+}
+
+func TestModel_WhereGroup(t *testing.T) {
+    result, err := db.Table("user").Where("id>?", 1).WhereGroup(func(m *gdb.Model) *gdb.Model {
+        return m.Where("nickname=?", "T2").Or("nickname=?", "T111")
+    }).All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 1)
+    gtest.Assert(result[0]["id"].Int(), 2)
+}
+
+func TestModel_SubQuery(t *testing.T) {
+    sub        := db.Table("user").Fields("id").Where("id>?", 2)
+    count, err := db.Table("user").WhereIn("id", sub).Count()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(count, 1)
+
+    result, err := db.Table("user u").WhereExists(db.Table("user").Where("id=u.id AND id=?", 1)).All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 1)
+    gtest.Assert(result[0]["id"].Int(), 1)
+}
+
+func TestModel_Union(t *testing.T) {
+    md := db.Table("user").Fields("id").Where("id=?", 1).Union(
+        db.Table("user").Fields("id").Where("id<?", 3),
+    )
+    count, err := md.Count()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(count, 2)
+
+    result, err := db.Table("user").Fields("id").Where("id=?", 1).UnionAll(
+        db.Table("user").Fields("id").Where("id<?", 3),
+    ).OrderBy("id DESC").All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 3)
+    gtest.Assert(result[0]["id"].Int(), 2)
+}
+
+func TestModel_Each(t *testing.T) {
+    ids := make([]int, 0)
+    err := db.Table("user").OrderBy("id ASC").Each(func(record gdb.Record) bool {
+        ids = append(ids, record["id"].Int())
+        return true
+    })
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(ids, []int{1, 2, 3})
+
+    count := 0
+    err    = db.Table("user").Each(func(record gdb.Record) bool {
+        count++
+        return false
+    })
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(count, 1)
+}
+
+func TestModel_Iterator(t *testing.T) {
+    iterator, err := db.Table("user").Where("id>?", 1).OrderBy("id DESC").Iterator()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer iterator.Close()
+    ids := make([]int, 0)
+    for iterator.Next() {
+        ids = append(ids, iterator.Record()["id"].Int())
+    }
+    if err := iterator.Err(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(ids, []int{3, 2})
+}
+
+func TestModel_ChunkById(t *testing.T) {
+    ids := make([]int, 0)
+    db.Table("user").ChunkById("id", 2, func(result gdb.Result, err error) bool {
+        if err != nil {
+            gtest.Fatal(err)
+        }
+        for _, record := range result {
+            ids = append(ids, record["id"].Int())
+        }
+        return true
+    })
+    gtest.Assert(ids, []int{1, 2, 3})
+
+    // 已有条件中包含OR
+    ids = make([]int, 0)
+    db.Table("user").Where("id=? OR id>?", 1, 1).ChunkById("id", 1, func(result gdb.Result, err error) bool {
+        if err != nil {
+            gtest.Fatal(err)
+        }
+        for _, record := range result {
+            ids = append(ids, record["id"].Int())
+        }
+        return len(ids) < 10
+    })
+    gtest.Assert(ids, []int{1, 2, 3})
+}
+
 func TestModel_Delete(t *testing.T) {
     result, err := db.Table("user").Delete()
     if err != nil {
@@ -1,3 +1,6 @@
+//go:build !nomysql
+// +build !nomysql
+
 package gdb_test
 
 import (
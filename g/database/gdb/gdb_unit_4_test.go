@@ -0,0 +1,415 @@
+package gdb_test
+
+import (
+    "errors"
+    "gitee.com/johng/gf/g"
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/util/gtest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func init() {
+    gdb.AddConfigNode("mock", gdb.ConfigNode{
+        Type : "mock",
+        Name : "mock",
+        Role : "master",
+    })
+    gdb.AddConfigNode("mock_cluster", gdb.ConfigNode{
+        Type : "mock",
+        Name : "mock_master",
+        Role : "master",
+    })
+    gdb.AddConfigNode("mock_cluster", gdb.ConfigNode{
+        Type : "mock",
+        Name : "mock_slave",
+        Role : "slave",
+    })
+}
+
+func TestMock_Query(t *testing.T) {
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    mock.ExpectQuery(`SELECT \* FROM user WHERE id=\?`).WithArgs(1).WillReturnRows(g.List{
+        {"id" : 1, "nickname" : "john", "score" : 99.5},
+    })
+    mock.ExpectQuery(`SELECT COUNT\(1\) FROM user`).WillReturnRows(g.List{{"count" : 10}})
+
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    record, err := mdb.Table("user").Where("id=?", 1).One()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(record["id"].Int(), 1)
+    gtest.Assert(record["nickname"].String(), "john")
+    gtest.Assert(record["score"].Float64(), 99.5)
+
+    count, err := mdb.Table("user").Count()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(count, 10)
+
+    // 参数不匹配
+    if _, err := mdb.Table("user").Where("id=?", 2).One(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    gtest.Assert(mock.GetLastSql().Args, g.Slice{2})
+    gtest.Assert(len(mock.GetSqls()), 3)
+    gtest.Assert(mock.ExpectationsWereMet(), nil)
+}
+
+func TestMock_Exec(t *testing.T) {
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    mock.ExpectExec(`^INSERT INTO user`).WillReturnResult(10, 1).Times(1)
+    mock.ExpectExec(`^DELETE FROM user`).WillReturnError(errors.New("delete denied"))
+
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    result, err := mdb.Table("user").Data(g.Map{"nickname" : "john"}).Insert()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    id, _ := result.LastInsertId()
+    gtest.Assert(id, 10)
+    gtest.Assert(mock.GetLastSql().Sql, "INSERT INTO user(`nickname`) VALUES(?)")
+    gtest.Assert(mock.GetLastSql().Args, g.Slice{"john"})
+
+    // 超过匹配次数
+    if _, err := mdb.Table("user").Data(g.Map{"nickname" : "smith"}).Insert(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    if _, err := mdb.Table("user").Where("id", 1).Delete(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+
+    tx, err := mdb.Begin()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    tx.Rollback()
+    gtest.Assert(mock.GetLastSql().Sql, "ROLLBACK")
+    gtest.Assert(mock.ExpectationsWereMet(), nil)
+}
+
+func TestMock_CursorPaginate(t *testing.T) {
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    // 已有条件中包含OR时，游标条件作用于整个条件
+    mock.ExpectQuery(`^SELECT \* FROM user WHERE \(uid=\? OR uid=\?\) AND .id.<\? ORDER BY .id. DESC LIMIT`).WithArgs(1, 2, 10).WillReturnRows(g.List{
+        {"id" : 9}, {"id" : 8}, {"id" : 7},
+    }).Times(1)
+    pagination, err := mdb.Table("user").Where("uid=? OR uid=?", 1, 2).CursorPaginate("id", 10, 2, true)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(pagination.Result), 2)
+    gtest.Assert(pagination.HasMore, true)
+    gtest.Assert(pagination.NextCursor.Int(), 8)
+    gtest.Assert(mock.ExpectationsWereMet(), nil)
+}
+
+func TestMock_ChunkById(t *testing.T) {
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    // 已有条件中包含OR时，键值条件作用于整个条件，否则将会重复返回满足第一个分支的记录
+    mock.ExpectQuery(`^SELECT \* FROM user WHERE uid=\? OR uid=\? ORDER BY .id. ASC LIMIT`).WithArgs(1, 2).WillReturnRows(g.List{
+        {"id" : 1}, {"id" : 2},
+    }).Times(1)
+    mock.ExpectQuery(`^SELECT \* FROM user WHERE \(uid=\? OR uid=\?\) AND .id.>\? ORDER BY .id. ASC LIMIT`).WithArgs(1, 2, 2).WillReturnRows(g.List{
+        {"id" : 3},
+    }).Times(1)
+    ids := make([]int, 0)
+    mdb.Table("user").Where("uid=? OR uid=?", 1, 2).ChunkById("id", 2, func(result gdb.Result, err error) bool {
+        if err != nil {
+            gtest.Fatal(err)
+        }
+        for _, record := range result {
+            ids = append(ids, record["id"].Int())
+        }
+        return true
+    })
+    gtest.Assert(ids, []int{1, 2, 3})
+    gtest.Assert(mock.ExpectationsWereMet(), nil)
+}
+
+// 用于测试的SQL执行钩子
+type mockHook struct {
+    sqls []*gdb.Sql
+}
+
+func (h *mockHook) BeforeExec(s *gdb.Sql) error {
+    if strings.HasPrefix(s.Sql, "DROP") {
+        return errors.New("DROP is not allowed")
+    }
+    return nil
+}
+
+func (h *mockHook) AfterExec(s *gdb.Sql) {
+    h.sqls = append(h.sqls, s)
+}
+
+func TestMock_Hook(t *testing.T) {
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    mock.ExpectExec(`^UPDATE user`).WillReturnResult(0, 1)
+
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    hook := &mockHook{}
+    mdb.AddHook(hook)
+    if _, err := mdb.Exec("UPDATE user SET nickname='john'"); err != nil {
+        gtest.Fatal(err)
+    }
+    // 预处理操作同样调用钩子
+    if _, err := mdb.Prepare("DROP TABLE user", true); err == nil {
+        gtest.Fatal("DROP should be rejected by hook")
+    }
+    stmt, err := mdb.Prepare("UPDATE user SET nickname=?", true)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    if _, err := stmt.Exec("smith"); err != nil {
+        gtest.Fatal(err)
+    }
+    stmt.Close()
+    gtest.Assert(len(hook.sqls), 2)
+    gtest.Assert(hook.sqls[0].Func, "Exec")
+    gtest.Assert(hook.sqls[1].Func, "Prepare")
+    gtest.Assert(hook.sqls[1].Sql, "UPDATE user SET nickname=?")
+
+    // 删除钩子后不再调用
+    mdb.RemoveHook(hook)
+    mdb.RemoveHook(hook)
+    if _, err := mdb.Exec("UPDATE user SET nickname='john'"); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(hook.sqls), 2)
+}
+
+func TestSession_Sticky(t *testing.T) {
+    master := gdb.GetMock("mock_master")
+    slave  := gdb.GetMock("mock_slave")
+    master.Reset()
+    slave.Reset()
+    master.ExpectExec(`^INSERT INTO user`).WillReturnResult(1, 1)
+    master.ExpectQuery(`^SELECT`).WillReturnRows(g.List{{"id" : 1}})
+    slave.ExpectQuery(`^SELECT`).WillReturnRows(g.List{{"id" : 1}})
+
+    mdb, err := gdb.New("mock_cluster")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    mdb.SetStickyWindow(time.Minute)
+    session := mdb.Session()
+    // 会话写操作之前
+    if _, err := session.Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(slave.GetSqls()), 1)
+
+    if _, err := session.Table("user").Data(g.Map{"nickname" : "john"}).Insert(); err != nil {
+        gtest.Fatal(err)
+    }
+    if _, err := session.Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(master.GetSqls()), 2)
+    // 其他会话及数据库对象本身不受影响
+    if _, err := mdb.Session().Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    if _, err := mdb.Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(slave.GetSqls()), 3)
+    gtest.Assert(len(master.GetSqls()), 2)
+
+    // 事务中的写操作在提交成功后生效
+    session = mdb.Session()
+    tx, err := session.Begin()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    if _, err := tx.Table("user").Data(g.Map{"nickname" : "john"}).Insert(); err != nil {
+        gtest.Fatal(err)
+    }
+    if _, err := session.Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(slave.GetSqls()), 4)
+    gtest.Assert(tx.Commit(), nil)
+    if _, err := session.Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(slave.GetLastSql().Sql, "SELECT * FROM user")
+    gtest.Assert(len(slave.GetSqls()), 4)
+    gtest.Assert(master.GetLastSql().Sql, "SELECT * FROM user")
+
+    // 关闭粘滞时间窗口
+    mdb.SetStickyWindow(0)
+    if _, err := session.Table("user").One(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(slave.GetSqls()), 5)
+}
+
+func TestSharding_Route(t *testing.T) {
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    mock.ExpectQuery(`FROM orders_1 WHERE user_id=\?`).WithArgs(13).WillReturnRows(g.List{
+        {"id" : 1, "user_id" : 13},
+    })
+    mock.ExpectExec(`^INSERT INTO orders_`).WillReturnResult(0, 1)
+    mock.ExpectExec(`^UPDATE orders_0 SET`).WillReturnResult(0, 2)
+
+    err := gdb.AddShardingRule(gdb.ShardingRule {
+        Table         : "orders",
+        Key           : "user_id",
+        TableCount    : 4,
+        TableTemplate : "orders_{index}",
+    })
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer gdb.RemoveShardingRule("orders")
+
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    result, err := mdb.Table("orders").Where("user_id=?", 13).All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 1)
+    gtest.Assert(mock.GetLastSql().Sql, "SELECT * FROM orders_1 WHERE user_id=?")
+
+    // 批量写入按照分片分组
+    r, err := mdb.Table("orders").Data(g.List{
+        {"id" : 1, "user_id" : 1},
+        {"id" : 2, "user_id" : 2},
+        {"id" : 3, "user_id" : 5},
+    }).Insert()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    n, _ := r.RowsAffected()
+    gtest.Assert(n, 2)
+    gtest.Assert(strings.HasPrefix(mock.GetLastSql().Sql, "INSERT INTO orders_2("), true)
+
+    // 缺少分片字段
+    if _, err := mdb.Table("orders").Data(g.Map{"id" : 4}).Insert(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+
+    // 通过Shard指定分片
+    r, err = mdb.Table("orders").Shard(8).Data(g.Map{"status" : 1}).Where("id=?", 4).Update()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    n, _ = r.RowsAffected()
+    gtest.Assert(n, 2)
+    gtest.Assert(mock.GetLastSql().Sql, "UPDATE orders_0 SET `status`=? WHERE id=?")
+    gtest.Assert(mock.ExpectationsWereMet(), nil)
+}
+
+func TestSharding_Scatter(t *testing.T) {
+    mock := gdb.GetMock("mock")
+    mock.Reset()
+    mock.ExpectQuery(`FROM orders_0 `).WillReturnRows(g.List{
+        {"id" : 4, "user_id" : 2},
+        {"id" : 1, "user_id" : 0},
+    })
+    mock.ExpectQuery(`FROM orders_1 `).WillReturnRows(g.List{
+        {"id" : 5, "user_id" : 3},
+        {"id" : 2, "user_id" : 1},
+    })
+    mock.ExpectQuery(`SELECT COUNT\(1\) FROM orders_`).WillReturnRows(g.List{{"count" : 3}})
+
+    err := gdb.AddShardingRule(gdb.ShardingRule {
+        Table         : "orders",
+        Key           : "user_id",
+        TableCount    : 2,
+        TableTemplate : "orders_{index}",
+    })
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer gdb.RemoveShardingRule("orders")
+
+    mdb, err := gdb.New("mock")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    // 没有分片字段条件时在所有分片上查询，合并后重新排序及分页
+    result, err := mdb.Table("orders").Where("id>?", 0).OrderBy("id DESC").Limit(1, 2).All()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(result), 2)
+    gtest.Assert(result[0]["id"].Int(), 4)
+    gtest.Assert(result[1]["id"].Int(), 2)
+    gtest.Assert(len(mock.GetSqls()), 2)
+    for _, s := range mock.GetSqls() {
+        gtest.Assert(strings.HasSuffix(s.Sql, "ORDER BY id DESC LIMIT 0, 3"), true)
+    }
+
+    // IN条件只查询对应的分片
+    if _, err := mdb.Table("orders").Where("user_id IN(?)", g.Slice{1, 3}).All(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(mock.GetLastSql().Sql, "SELECT * FROM orders_1 WHERE user_id IN(?,?)")
+
+    count, err := mdb.Table("orders").Count()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(count, 6)
+
+    // 分组、去重及聚合查询不支持跨分片合并
+    sqls := len(mock.GetSqls())
+    if _, err := mdb.Table("orders").Fields("SUM(amount) total").All(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    if _, err := mdb.Table("orders").Fields("MAX(id)").Value(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    if _, err := mdb.Table("orders").Fields("DISTINCT user_id").All(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    if _, err := mdb.Table("orders").Fields("user_id").GroupBy("user_id").All(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    if _, err := mdb.Table("orders").GroupBy("user_id").Count(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+    gtest.Assert(len(mock.GetSqls()), sqls)
+    // 路由到单个分片时不受限制
+    if _, err := mdb.Table("orders").Fields("SUM(amount) total").Where("user_id", 1).All(); err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(mock.GetLastSql().Sql, "SELECT SUM(amount) total FROM orders_1 WHERE user_id=?")
+
+    // 迭代器只支持单个分片
+    if _, err := mdb.Table("orders").Iterator(); err == nil {
+        gtest.Fatal("FAIL")
+    }
+}
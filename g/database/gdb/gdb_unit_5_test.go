@@ -0,0 +1,72 @@
+// 各数据库insert、replace, save， ignore操作的SQL语句生成测试(不需要数据库链接)
+
+package gdb
+
+import (
+    "gitee.com/johng/gf/g/util/gtest"
+    "testing"
+)
+
+// 创建指定类型的数据库对象(不创建数据库链接)
+func newFormatTestDb(dbType string) DB {
+    base := &dbBase{}
+    switch dbType {
+        case "mysql":
+            base.db = &dbMysql{dbBase  : base}
+        case "pgsql":
+            base.db = &dbPgsql{dbBase  : base}
+        case "mssql":
+            base.db = &dbMssql{dbBase  : base}
+        case "sqlite":
+            base.db = &dbSqlite{dbBase : base}
+        case "oracle":
+            base.db = &dbOracle{dbBase : base}
+    }
+    return base.db
+}
+
+func TestFormatInsertSql(t *testing.T) {
+    fields := []string{"id", "name"}
+    keys   := []string{"id"}
+    cases  := []struct {
+        dbType string
+        option int
+        rows   int
+        keys   []string
+        sql    string
+    }{
+        {"mysql", OPTION_INSERT,  1, nil,  "INSERT INTO user(`id`,`name`) VALUES(?,?)"},
+        {"mysql", OPTION_REPLACE, 1, nil,  "REPLACE INTO user(`id`,`name`) VALUES(?,?)"},
+        {"mysql", OPTION_IGNORE,  2, nil,  "INSERT IGNORE INTO user(`id`,`name`) VALUES(?,?),(?,?)"},
+        {"mysql", OPTION_SAVE,    1, nil,  "INSERT INTO user(`id`,`name`) VALUES(?,?) ON DUPLICATE KEY UPDATE `id`=VALUES(`id`),`name`=VALUES(`name`)"},
+        // MySQL根据唯一索引判断冲突，忽略冲突字段
+        {"mysql", OPTION_SAVE,    1, keys, "INSERT INTO user(`id`,`name`) VALUES(?,?) ON DUPLICATE KEY UPDATE `id`=VALUES(`id`),`name`=VALUES(`name`)"},
+
+        {"sqlite", OPTION_INSERT,  1, nil,  "INSERT INTO user(`id`,`name`) VALUES(?,?)"},
+        {"sqlite", OPTION_REPLACE, 1, nil,  "REPLACE INTO user(`id`,`name`) VALUES(?,?)"},
+        {"sqlite", OPTION_IGNORE,  2, nil,  "INSERT OR IGNORE INTO user(`id`,`name`) VALUES(?,?),(?,?)"},
+        {"sqlite", OPTION_SAVE,    2, keys, "INSERT INTO user(`id`,`name`) VALUES(?,?),(?,?) ON CONFLICT(`id`) DO UPDATE SET `name`=excluded.`name`"},
+
+        {"pgsql", OPTION_INSERT,  1, nil,  `INSERT INTO user("id","name") VALUES(?,?)`},
+        {"pgsql", OPTION_IGNORE,  2, nil,  `INSERT INTO user("id","name") VALUES(?,?),(?,?) ON CONFLICT DO NOTHING`},
+        {"pgsql", OPTION_REPLACE, 1, keys, `INSERT INTO user("id","name") VALUES(?,?) ON CONFLICT("id") DO UPDATE SET "name"=EXCLUDED."name"`},
+        {"pgsql", OPTION_SAVE,    2, keys, `INSERT INTO user("id","name") VALUES(?,?),(?,?) ON CONFLICT("id") DO UPDATE SET "name"=EXCLUDED."name"`},
+        // 只有冲突字段时不需要更新
+        {"pgsql", OPTION_SAVE,    1, fields, `INSERT INTO user("id","name") VALUES(?,?) ON CONFLICT("id","name") DO NOTHING`},
+
+        {"mssql", OPTION_INSERT,  1, nil,  `INSERT INTO user("id","name") VALUES(?,?)`},
+        {"mssql", OPTION_SAVE,    2, keys, `MERGE INTO user AS T USING (VALUES (?,?),(?,?)) AS S("id","name") ON (T."id"=S."id") WHEN MATCHED THEN UPDATE SET T."name"=S."name" WHEN NOT MATCHED THEN INSERT ("id","name") VALUES (S."id",S."name");`},
+        {"mssql", OPTION_IGNORE,  1, keys, `MERGE INTO user AS T USING (VALUES (?,?)) AS S("id","name") ON (T."id"=S."id") WHEN NOT MATCHED THEN INSERT ("id","name") VALUES (S."id",S."name");`},
+
+        {"oracle", OPTION_INSERT,  1, nil,  `INSERT INTO user("id","name") VALUES(?,?)`},
+        {"oracle", OPTION_SAVE,    2, keys, `MERGE INTO user T USING (SELECT ? "id",? "name" FROM DUAL UNION ALL SELECT ? "id",? "name" FROM DUAL) S ON (T."id"=S."id") WHEN MATCHED THEN UPDATE SET T."name"=S."name" WHEN NOT MATCHED THEN INSERT ("id","name") VALUES (S."id",S."name")`},
+        {"oracle", OPTION_IGNORE,  1, keys, `MERGE INTO user T USING (SELECT ? "id",? "name" FROM DUAL) S ON (T."id"=S."id") WHEN NOT MATCHED THEN INSERT ("id","name") VALUES (S."id",S."name")`},
+    }
+    gtest.Case(t, func() {
+        for _, c := range cases {
+            sql, err := newFormatTestDb(c.dbType).formatInsertSql("user", fields, c.rows, c.option, c.keys)
+            gtest.Assert(err, nil)
+            gtest.Assert(c.dbType + ": " + sql, c.dbType + ": " + c.sql)
+        }
+    })
+}
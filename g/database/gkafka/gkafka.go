@@ -160,15 +160,7 @@ func (client *Client) Receive() (*Message, error) {
                 if client.Config.AutoMarkOffset {
                     client.consumer.MarkOffset(msg, "")
                 }
-                return &Message {
-                    Value       : msg.Value,
-                    Key         : msg.Key,
-                    Topic       : msg.Topic,
-                    Partition   : int(msg.Partition),
-                    Offset      : int(msg.Offset),
-                    client      : client,
-                    consumerMsg : msg,
-                }, nil
+                return newMessage(client, msg), nil
 
             case err := <-errorsChan:
                 if err != nil {
@@ -182,12 +174,8 @@ func (client *Client) Receive() (*Message, error) {
 
 // Send data to kafka in synchronized way.
 func (client *Client) SyncSend(message *Message) error {
-    if client.syncProducer == nil {
-        if p, err := sarama.NewSyncProducer(strings.Split(client.Config.Servers, ","), &client.Config.Config); err != nil {
-            return err
-        } else {
-            client.syncProducer = p
-        }
+    if err := client.initSyncProducer(); err != nil {
+        return err
     }
     for _, topic := range strings.Split(client.Config.Topics, ",") {
         msg := messageToProducerMessage(message)
@@ -199,6 +187,18 @@ func (client *Client) SyncSend(message *Message) error {
     return nil
 }
 
+// 初始化内部同步生产客户端
+func (client *Client) initSyncProducer() error {
+    if client.syncProducer == nil {
+        if p, err := sarama.NewSyncProducer(strings.Split(client.Config.Servers, ","), &client.Config.Config); err != nil {
+            return err
+        } else {
+            client.syncProducer = p
+        }
+    }
+    return nil
+}
+
 // Send data to kafka in asynchronized way(concurrent safe).
 func (client *Client) AsyncSend(message *Message) error {
     if client.asyncProducer == nil {
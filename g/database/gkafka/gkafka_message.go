@@ -6,6 +6,21 @@
 
 package gkafka
 
+import "gitee.com/johng/gf/third/github.com/Shopify/sarama"
+
+// Convert *sarama.ConsumerMessage to *gkafka.Message
+func newMessage(client *Client, msg *sarama.ConsumerMessage) *Message {
+    return &Message {
+        Value       : msg.Value,
+        Key         : msg.Key,
+        Topic       : msg.Topic,
+        Partition   : int(msg.Partition),
+        Offset      : int(msg.Offset),
+        client      : client,
+        consumerMsg : msg,
+    }
+}
+
 // 自动标记已读取
 func (msg *Message) MarkOffset() {
     if msg.consumerMsg != nil && msg.client != nil && msg.client.consumer != nil {
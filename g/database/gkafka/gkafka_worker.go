@@ -0,0 +1,372 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gkafka
+
+import (
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/glog"
+    "gitee.com/johng/gf/third/github.com/Shopify/sarama"
+    "gitee.com/johng/gf/third/github.com/johng-cn/sarama-cluster"
+    "hash/fnv"
+    "strconv"
+    "sync"
+    "time"
+)
+
+const (
+    gDEFAULT_WORKER_CONCURRENCY      = 1
+    gDEFAULT_WORKER_MAX_RETRIES      = 3
+    gDEFAULT_WORKER_RETRY_BACKOFF    = 100 * time.Millisecond
+    gDEFAULT_WORKER_MAX_BACKOFF      = 10 * time.Second
+    gDEFAULT_WORKER_QUEUE_SIZE       = 100
+    gDEFAULT_WORKER_SHUTDOWN_TIMEOUT = 30 * time.Second
+)
+
+// 消息处理方法，返回error时将会按照重试策略重新处理该消息
+type Handler func(message *Message) error
+
+// 消费Worker配置
+type WorkerConfig struct {
+    Concurrency     int           // 并发处理的协程数量，相同Key(没有Key时为相同分区)的消息总是由同一个协程按顺序处理
+    MaxRetries      int           // 处理失败后的最大重试次数，为0时使用默认值，小于0时表示不重试
+    RetryBackoff    time.Duration // 首次重试的等待时间，之后每次重试等待时间翻倍
+    MaxRetryBackoff time.Duration // 重试等待时间的上限
+    DeadLetterTopic string        // 超过重试次数的消息投递的死信topic，为空时仅记录日志并跳过该消息
+    QueueSize       int           // 每个处理协程的消息队列大小
+    ShutdownTimeout time.Duration // 关闭时等待正在处理的消息完成的超时时间
+}
+
+// 消费Worker，并发处理消费到的消息，只有在消息处理完成(成功或者投递到死信topic)后才会提交该消息的偏移量，
+// 并且同一分区的偏移量只会按照顺序连续提交，因此服务异常退出后未处理完成的消息将会被重新消费。
+type Worker struct {
+    client   *Client
+    consumer *cluster.Consumer
+    handler  Handler
+    config   WorkerConfig
+    queues   []chan *sarama.ConsumerMessage
+    offsets  *offsetTracker
+    closing  chan struct{}
+    wg       sync.WaitGroup
+    running  *gtype.Bool
+}
+
+// 分区偏移量跟踪对象，记录各分区已处理完成的连续偏移量
+type offsetTracker struct {
+    mu         sync.Mutex
+    partitions map[string]map[int32]*partitionOffsets
+}
+
+// 单个分区的偏移量记录
+type partitionOffsets struct {
+    queue   []int64        // 按照消费顺序排列的未提交偏移量
+    pending map[int64]bool // 未提交的偏移量及其是否已处理完成
+    marked  int64          // 已处理完成的下一条消息偏移量(-1表示没有)
+}
+
+// 默认的Worker配置
+func NewWorkerConfig() WorkerConfig {
+    return WorkerConfig {
+        Concurrency     : gDEFAULT_WORKER_CONCURRENCY,
+        MaxRetries      : gDEFAULT_WORKER_MAX_RETRIES,
+        RetryBackoff    : gDEFAULT_WORKER_RETRY_BACKOFF,
+        MaxRetryBackoff : gDEFAULT_WORKER_MAX_BACKOFF,
+        QueueSize       : gDEFAULT_WORKER_QUEUE_SIZE,
+        ShutdownTimeout : gDEFAULT_WORKER_SHUTDOWN_TIMEOUT,
+    }
+}
+
+// 创建消费Worker，config为可选的Worker配置，未设置的配置项使用默认值。
+// Worker使用客户端的消费配置(GroupId/Topics)，并忽略AutoMarkOffset配置，同一客户端同时只能运行一个Worker。
+func (client *Client) NewWorker(handler Handler, config...WorkerConfig) *Worker {
+    c := NewWorkerConfig()
+    if len(config) > 0 {
+        c = config[0]
+        if c.Concurrency <= 0 {
+            c.Concurrency = gDEFAULT_WORKER_CONCURRENCY
+        }
+        if c.MaxRetries == 0 {
+            c.MaxRetries = gDEFAULT_WORKER_MAX_RETRIES
+        }
+        if c.RetryBackoff <= 0 {
+            c.RetryBackoff = gDEFAULT_WORKER_RETRY_BACKOFF
+        }
+        if c.MaxRetryBackoff <= 0 {
+            c.MaxRetryBackoff = gDEFAULT_WORKER_MAX_BACKOFF
+        }
+        if c.QueueSize <= 0 {
+            c.QueueSize = gDEFAULT_WORKER_QUEUE_SIZE
+        }
+        if c.ShutdownTimeout <= 0 {
+            c.ShutdownTimeout = gDEFAULT_WORKER_SHUTDOWN_TIMEOUT
+        }
+    }
+    return &Worker {
+        client  : client,
+        handler : handler,
+        config  : c,
+        offsets : &offsetTracker{partitions : make(map[string]map[int32]*partitionOffsets)},
+        running : gtype.NewBool(),
+    }
+}
+
+// 启动Worker，开始异步消费并处理消息
+func (w *Worker) Start() error {
+    if !w.running.Set(true) {
+        if err := w.client.initConsumer(); err != nil {
+            w.running.Set(false)
+            return err
+        }
+        if w.config.DeadLetterTopic != "" {
+            if err := w.client.initSyncProducer(); err != nil {
+                w.running.Set(false)
+                return err
+            }
+        }
+        w.consumer = w.client.consumer
+        w.closing  = make(chan struct{})
+        w.queues   = make([]chan *sarama.ConsumerMessage, w.config.Concurrency)
+        for i := 0; i < len(w.queues); i++ {
+            w.queues[i] = make(chan *sarama.ConsumerMessage, w.config.QueueSize)
+            w.wg.Add(1)
+            go w.work(w.queues[i])
+        }
+        w.wg.Add(1)
+        go w.dispatch()
+        return nil
+    }
+    return errors.New("worker is already running")
+}
+
+// 优雅关闭Worker，停止消费新的消息，等待正在处理的消息完成(最长等待ShutdownTimeout)，
+// 提交已处理完成的偏移量后关闭消费客户端。队列中尚未处理的消息偏移量不会被提交，将会在下次启动后重新消费。
+func (w *Worker) Stop() error {
+    if !w.running.Set(false) {
+        return nil
+    }
+    close(w.closing)
+    done := make(chan struct{})
+    go func() {
+        w.wg.Wait()
+        close(done)
+    }()
+    var err error
+    select {
+        case <- done:
+        case <- time.After(w.config.ShutdownTimeout):
+            err = errors.New(fmt.Sprintf("worker shutdown timeout after %s", w.config.ShutdownTimeout))
+    }
+    if e := w.consumer.CommitOffsets(); e != nil && err == nil {
+        err = e
+    }
+    if e := w.consumer.Close(); e != nil && err == nil {
+        err = e
+    }
+    w.client.consumer = nil
+    return err
+}
+
+// 获得各分区已处理完成的下一条消息偏移量(即将会被提交的偏移量)，格式为：topic => partition => offset
+func (w *Worker) Offsets() map[string]map[int]int64 {
+    w.offsets.mu.Lock()
+    defer w.offsets.mu.Unlock()
+    m := make(map[string]map[int]int64, len(w.offsets.partitions))
+    for topic, partitions := range w.offsets.partitions {
+        for partition, p := range partitions {
+            if p.marked < 0 {
+                continue
+            }
+            if _, ok := m[topic]; !ok {
+                m[topic] = make(map[int]int64)
+            }
+            m[topic][int(partition)] = p.marked
+        }
+    }
+    return m
+}
+
+// 从消费客户端读取消息，按照消息Key分发到对应的处理协程
+func (w *Worker) dispatch() {
+    defer w.wg.Done()
+    consumer := w.consumer
+    for {
+        select {
+            case <- w.closing:
+                return
+
+            case err, ok := <- consumer.Errors():
+                if ok {
+                    glog.Error("kafka worker consume error:", err)
+                }
+
+            case msg, ok := <- consumer.Messages():
+                if !ok {
+                    return
+                }
+                w.offsets.add(msg.Topic, msg.Partition, msg.Offset)
+                select {
+                    case w.queues[w.getQueueIndex(msg)] <- msg:
+                    case <- w.closing:
+                        return
+                }
+        }
+    }
+}
+
+// 计算消息对应的处理协程索引，没有Key的消息按照分区分配，以保证同一Key/分区的消息按顺序处理
+func (w *Worker) getQueueIndex(msg *sarama.ConsumerMessage) int {
+    if len(w.queues) == 1 {
+        return 0
+    }
+    h := fnv.New32a()
+    if len(msg.Key) > 0 {
+        h.Write(msg.Key)
+    } else {
+        h.Write([]byte(msg.Topic + "/" + strconv.Itoa(int(msg.Partition))))
+    }
+    return int(h.Sum32() % uint32(len(w.queues)))
+}
+
+// 处理协程，依次处理队列中的消息
+func (w *Worker) work(queue chan *sarama.ConsumerMessage) {
+    defer w.wg.Done()
+    for {
+        select {
+            case <- w.closing:
+                return
+            case msg := <- queue:
+                // 关闭过程中中断的消息不标记偏移量，等待重新消费
+                if w.process(msg) {
+                    w.mark(w.offsets.done(msg.Topic, msg.Partition, msg.Offset))
+                }
+        }
+    }
+}
+
+// 处理单条消息，失败时按照退避策略重试，超过重试次数后投递到死信topic，
+// 返回该消息是否已处理完成(Worker关闭导致处理中断时返回false)。
+func (w *Worker) process(msg *sarama.ConsumerMessage) bool {
+    backoff := w.config.RetryBackoff
+    for retries := 0; ; retries++ {
+        err := w.handle(msg)
+        if err == nil {
+            return true
+        }
+        if retries >= w.config.MaxRetries {
+            return w.deadLetter(msg, err)
+        }
+        select {
+            case <- w.closing:
+                return false
+            case <- time.After(backoff):
+        }
+        if backoff *= 2; backoff > w.config.MaxRetryBackoff {
+            backoff = w.config.MaxRetryBackoff
+        }
+    }
+}
+
+// 执行消息处理方法，并将处理方法产生的panic转换为error
+func (w *Worker) handle(msg *sarama.ConsumerMessage) (err error) {
+    defer func() {
+        if e := recover(); e != nil {
+            err = errors.New(fmt.Sprintf("%v", e))
+        }
+    }()
+    // Worker消息的偏移量由Worker管理，因此这里不关联客户端，消息的MarkOffset方法无效
+    return w.handler(newMessage(nil, msg))
+}
+
+// 将超过重试次数的消息投递到死信topic，投递失败时持续重试直到成功或者Worker关闭
+func (w *Worker) deadLetter(msg *sarama.ConsumerMessage, err error) bool {
+    if w.config.DeadLetterTopic == "" {
+        glog.Errorf("kafka worker dropped message %s/%d/%d: %s", msg.Topic, msg.Partition, msg.Offset, err.Error())
+        return true
+    }
+    message := &sarama.ProducerMessage {
+        Topic : w.config.DeadLetterTopic,
+        Key   : sarama.ByteEncoder(msg.Key),
+        Value : sarama.ByteEncoder(msg.Value),
+    }
+    // 消息头需要Kafka 0.11及以上版本的支持
+    if w.client.Config.Version.IsAtLeast(sarama.V0_11_0_0) {
+        message.Headers = []sarama.RecordHeader {
+            {Key : []byte("x-original-topic"),     Value : []byte(msg.Topic)},
+            {Key : []byte("x-original-partition"), Value : []byte(strconv.Itoa(int(msg.Partition)))},
+            {Key : []byte("x-original-offset"),    Value : []byte(strconv.FormatInt(msg.Offset, 10))},
+            {Key : []byte("x-error"),              Value : []byte(err.Error())},
+        }
+    }
+    backoff := w.config.RetryBackoff
+    for {
+        _, _, e := w.client.syncProducer.SendMessage(message)
+        if e == nil {
+            return true
+        }
+        glog.Errorf("kafka worker send message %s/%d/%d to dead letter topic error: %s", msg.Topic, msg.Partition, msg.Offset, e.Error())
+        select {
+            case <- w.closing:
+                return false
+            case <- time.After(backoff):
+        }
+        if backoff *= 2; backoff > w.config.MaxRetryBackoff {
+            backoff = w.config.MaxRetryBackoff
+        }
+    }
+}
+
+// 标记分区已处理完成的连续偏移量，由消费客户端按照CommitInterval定时提交
+func (w *Worker) mark(topic string, partition int32, offset int64) {
+    if offset >= 0 {
+        // MarkPartitionOffset标记的是已处理的消息偏移量，提交时会自动加1
+        w.consumer.MarkPartitionOffset(topic, partition, offset - 1, "")
+    }
+}
+
+// 记录消费到的消息偏移量，分区重新分配后偏移量可能回退，此时重置该分区的记录
+func (t *offsetTracker) add(topic string, partition int32, offset int64) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if _, ok := t.partitions[topic]; !ok {
+        t.partitions[topic] = make(map[int32]*partitionOffsets)
+    }
+    p, ok := t.partitions[topic][partition]
+    if !ok || (len(p.queue) > 0 && offset <= p.queue[len(p.queue) - 1]) {
+        p = &partitionOffsets {
+            pending : make(map[int64]bool),
+            marked  : -1,
+        }
+        t.partitions[topic][partition] = p
+    }
+    p.queue = append(p.queue, offset)
+    p.pending[offset] = false
+}
+
+// 标记消息已处理完成，返回该分区新的已完成的下一条消息偏移量，偏移量没有变化时返回-1
+func (t *offsetTracker) done(topic string, partition int32, offset int64) (string, int32, int64) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    p, ok := t.partitions[topic][partition]
+    if !ok {
+        return topic, partition, -1
+    }
+    if _, ok := p.pending[offset]; !ok {
+        return topic, partition, -1
+    }
+    p.pending[offset] = true
+    marked := int64(-1)
+    for len(p.queue) > 0 && p.pending[p.queue[0]] {
+        marked = p.queue[0] + 1
+        delete(p.pending, p.queue[0])
+        p.queue = p.queue[1:]
+    }
+    if marked >= 0 {
+        p.marked = marked
+    }
+    return topic, partition, marked
+}
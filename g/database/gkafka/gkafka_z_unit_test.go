@@ -0,0 +1,211 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gkafka_test
+
+import (
+    "encoding/binary"
+    "errors"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/database/gkafka"
+    "gitee.com/johng/gf/g/util/gtest"
+    "gitee.com/johng/gf/third/github.com/Shopify/sarama"
+    "sync"
+    "testing"
+    "time"
+)
+
+// 编码消费分组的分区分配数据(版本0，单个topic)
+func encodeAssignment(topic string, partitions...int32) []byte {
+    b := make([]byte, 0, 64)
+    b  = append(b, 0, 0)
+    b  = binary.BigEndian.AppendUint32(b, 1)
+    b  = binary.BigEndian.AppendUint16(b, uint16(len(topic)))
+    b  = append(b, topic...)
+    b  = binary.BigEndian.AppendUint32(b, uint32(len(partitions)))
+    for _, p := range partitions {
+        b = binary.BigEndian.AppendUint32(b, uint32(p))
+    }
+    return binary.BigEndian.AppendUint32(b, 0xffffffff)
+}
+
+// 创建模拟的Kafka节点，topic "test"的0号分区包含3条消息
+func newMockBroker(t *testing.T) *sarama.MockBroker {
+    broker := sarama.NewMockBroker(t, 1)
+    broker.SetHandlerByMap(map[string]sarama.MockResponse {
+        "MetadataRequest" : sarama.NewMockMetadataResponse(t).
+            SetBroker(broker.Addr(), broker.BrokerID()).
+            SetController(broker.BrokerID()).
+            SetLeader("test", 0, broker.BrokerID()).
+            SetLeader("test-dlq", 0, broker.BrokerID()),
+        "FindCoordinatorRequest" : sarama.NewMockFindCoordinatorResponse(t).
+            SetCoordinator(sarama.CoordinatorGroup, "group", broker),
+        "JoinGroupRequest" : sarama.NewMockWrapper(&sarama.JoinGroupResponse {
+            GenerationId  : 1,
+            GroupProtocol : "range",
+            LeaderId      : "leader",
+            MemberId      : "member",
+        }),
+        "SyncGroupRequest" : sarama.NewMockWrapper(&sarama.SyncGroupResponse {
+            MemberAssignment : encodeAssignment("test", 0),
+        }),
+        "HeartbeatRequest"   : sarama.NewMockWrapper(&sarama.HeartbeatResponse{}),
+        "LeaveGroupRequest"  : sarama.NewMockWrapper(&sarama.LeaveGroupResponse{}),
+        "OffsetFetchRequest" : sarama.NewMockOffsetFetchResponse(t).
+            SetOffset("group", "test", 0, -1, "", sarama.ErrNoError),
+        "OffsetRequest" : sarama.NewMockOffsetResponse(t).
+            SetOffset("test", 0, sarama.OffsetOldest, 0).
+            SetOffset("test", 0, sarama.OffsetNewest, 3),
+        "FetchRequest" : sarama.NewMockFetchResponse(t, 3).
+            SetVersion(2).
+            SetMessage("test", 0, 0, sarama.StringEncoder("a")).
+            SetMessage("test", 0, 1, sarama.StringEncoder("fail")).
+            SetMessage("test", 0, 2, sarama.StringEncoder("c")).
+            SetHighWaterMark("test", 0, 3),
+        "OffsetCommitRequest" : sarama.NewMockOffsetCommitResponse(t),
+        "ProduceRequest"      : sarama.NewMockProduceResponse(t).SetVersion(2),
+    })
+    return broker
+}
+
+func newClient(broker *sarama.MockBroker) *gkafka.Client {
+    config        := gkafka.NewConfig()
+    config.Servers = broker.Addr()
+    config.GroupId = "group"
+    config.Topics  = "test"
+    config.Version = sarama.V0_10_0_0
+    config.Metadata.Retry.Backoff = 10 * time.Millisecond
+    return gkafka.NewClient(config)
+}
+
+// 统计模拟节点接收到的指定类型请求数量
+func countRequests(broker *sarama.MockBroker, match func(r interface{}) bool) int {
+    count := 0
+    for _, item := range broker.History() {
+        if match(item.Request) {
+            count++
+        }
+    }
+    return count
+}
+
+func TestWorker_RetryAndDeadLetter(t *testing.T) {
+    broker := newMockBroker(t)
+    defer broker.Close()
+    client := newClient(broker)
+    defer client.Close()
+
+    mu      := sync.Mutex{}
+    handled := make([]string, 0)
+    calls   := gtype.NewInt()
+    worker  := client.NewWorker(func(message *gkafka.Message) error {
+        calls.Add(1)
+        if string(message.Value) == "fail" {
+            return errors.New("handle failed")
+        }
+        mu.Lock()
+        handled = append(handled, string(message.Value))
+        mu.Unlock()
+        return nil
+    }, gkafka.WorkerConfig {
+        Concurrency     : 2,
+        MaxRetries      : 2,
+        RetryBackoff    : 10 * time.Millisecond,
+        DeadLetterTopic : "test-dlq",
+    })
+    gtest.Case(t, func() {
+        gtest.Assert(worker.Start(), nil)
+        gtest.AssertNE(worker.Start(), nil)
+        for i := 0; i < 100; i++ {
+            if len(worker.Offsets()["test"]) > 0 && worker.Offsets()["test"][0] == 3 {
+                break
+            }
+            time.Sleep(50 * time.Millisecond)
+        }
+        // 失败的消息共执行3次(1次处理+2次重试)，随后投递到死信topic
+        gtest.Assert(calls.Val(), 5)
+        mu.Lock()
+        gtest.Assert(handled, []string{"a", "c"})
+        mu.Unlock()
+        gtest.Assert(worker.Offsets()["test"][0], 3)
+        gtest.Assert(countRequests(broker, func(r interface{}) bool {
+            _, ok := r.(*sarama.ProduceRequest)
+            return ok
+        }), 1)
+
+        gtest.Assert(worker.Stop(), nil)
+        gtest.Assert(worker.Stop(), nil)
+        gtest.AssertNE(countRequests(broker, func(r interface{}) bool {
+            _, ok := r.(*sarama.OffsetCommitRequest)
+            return ok
+        }), 0)
+    })
+}
+
+func TestWorker_Shutdown(t *testing.T) {
+    broker := newMockBroker(t)
+    defer broker.Close()
+    client := newClient(broker)
+    defer client.Close()
+
+    calls  := gtype.NewInt()
+    worker := client.NewWorker(func(message *gkafka.Message) error {
+        calls.Add(1)
+        panic("handle panic")
+    }, gkafka.WorkerConfig {
+        MaxRetries   : 100,
+        RetryBackoff : 10 * time.Millisecond,
+    })
+    gtest.Case(t, func() {
+        gtest.Assert(worker.Start(), nil)
+        for i := 0; i < 100 && calls.Val() < 3; i++ {
+            time.Sleep(20 * time.Millisecond)
+        }
+        gtest.Assert(calls.Val() >= 3, true)
+        // 关闭时中断重试，未处理完成的消息不提交偏移量
+        gtest.Assert(worker.Stop(), nil)
+        gtest.Assert(len(worker.Offsets()), 0)
+    })
+}
+
+func TestWorker_PartialConfig(t *testing.T) {
+    // 处理所有消息，返回处理方法的执行次数
+    run := func(config gkafka.WorkerConfig) int {
+        broker := newMockBroker(t)
+        defer broker.Close()
+        client := newClient(broker)
+        defer client.Close()
+        calls  := gtype.NewInt()
+        worker := client.NewWorker(func(message *gkafka.Message) error {
+            calls.Add(1)
+            if string(message.Value) == "fail" {
+                return errors.New("handle failed")
+            }
+            return nil
+        }, config)
+        gtest.Assert(worker.Start(), nil)
+        for i := 0; i < 100; i++ {
+            if len(worker.Offsets()["test"]) > 0 && worker.Offsets()["test"][0] == 3 {
+                break
+            }
+            time.Sleep(50 * time.Millisecond)
+        }
+        gtest.Assert(worker.Stop(), nil)
+        return calls.Val()
+    }
+    gtest.Case(t, func() {
+        // 未设置MaxRetries时使用默认的重试次数(3次)
+        gtest.Assert(run(gkafka.WorkerConfig {
+            RetryBackoff    : 10 * time.Millisecond,
+            DeadLetterTopic : "test-dlq",
+        }), 6)
+        // MaxRetries小于0时不重试
+        gtest.Assert(run(gkafka.WorkerConfig {
+            MaxRetries      : -1,
+            DeadLetterTopic : "test-dlq",
+        }), 3)
+    })
+}
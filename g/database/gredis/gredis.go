@@ -11,10 +11,12 @@
 package gredis
 
 import (
-    "time"
-    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
-    "gitee.com/johng/gf/g/container/gmap"
+    "crypto/tls"
     "fmt"
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "strings"
+    "time"
 )
 
 const (
@@ -24,17 +26,28 @@ const (
     gDEFAULT_POOL_MAX_LIFE_TIME = 60  * time.Second
 )
 
-// Redis客户端
+// Redis客户端，支持单节点、Sentinel及Cluster三种模式
 type Redis struct {
-    pool *redis.Pool
+    pool     *redis.Pool // 单节点及Sentinel模式下的链接池
+    sentinel *sentinel   // Sentinel模式下的主节点发现对象
+    cluster  *cluster    // Cluster模式下的集群节点管理对象
 }
 
-// Redis服务端但节点连接配置信息
+// Redis服务端连接配置信息，根据配置项的不同分为以下三种模式：
+// 1、单节点模式：使用Host/Port连接指定的Redis节点；
+// 2、Sentinel模式：MasterName不为空时，Addrs为Sentinel节点地址列表，通过Sentinel发现主节点，并自动感知主从切换；
+// 3、Cluster模式：Cluster为true时，Addrs为集群的种子节点地址列表，命令按照键名的槽位路由到对应的节点(此时Db无效)；
 type Config struct {
-    Host string // IP/域名
-    Port int    // 端口
-    Db   int    // db
-    Pass string // 密码
+    Host          string      // IP/域名
+    Port          int         // 端口
+    Db            int         // db
+    Pass          string      // 密码
+    MasterName    string      // (Sentinel)主节点名称
+    Addrs         []string    // (Sentinel/Cluster)节点地址列表，格式为host:port
+    Cluster       bool        // 是否为Cluster模式
+    TLS           bool        // 是否使用TLS链接
+    TLSSkipVerify bool        // TLS链接时是否跳过服务端证书校验
+    TLSConfig     *tls.Config // 自定义TLS配置(给定时TLSSkipVerify无效)
 }
 
 // Redis链接池统计信息
@@ -42,86 +55,150 @@ type PoolStats struct {
     redis.PoolStats
 }
 
-// 连接池map
+// 客户端对象map，键名为配置信息
 var pools = gmap.NewStringInterfaceMap()
 
-// 创建redis操作对象.
+// 创建redis操作对象，相同配置的对象共享同一个链接池.
 func New(config Config) *Redis {
-    r       := &Redis{}
-    poolKey := fmt.Sprintf("%s:%d,%d", config.Host, config.Port, config.Db)
-    if v := pools.Get(poolKey); v == nil {
-        pool := &redis.Pool {
-            MaxIdle         : gDEFAULT_POOL_MAX_IDLE,
-            MaxActive       : gDEFAULT_POOL_MAX_ACTIVE,
-            IdleTimeout     : gDEFAULT_POOL_IDLE_TIMEOUT,
-            MaxConnLifetime : gDEFAULT_POOL_MAX_LIFE_TIME,
-            Dial            : func() (redis.Conn, error) {
-                c, err := redis.Dial("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port))
-                if err != nil {
-                    return nil, err
-                }
-                if len(config.Pass) > 0 {
-                    if _, err := c.Do("AUTH", config.Pass); err != nil {
-                        return nil, err
-                    }
-                }
-                if _, err := c.Do("SELECT", config.Db); err != nil {
-                    return nil, err
+    return pools.GetOrSetFuncLock(config.getKey(), func() interface{} {
+        r := &Redis{}
+        switch {
+            case config.Cluster:
+                r.cluster  = newCluster(config)
+            case config.MasterName != "":
+                r.sentinel = newSentinel(config)
+                r.pool     = newPool(r.sentinel.dial, r.sentinel.testOnBorrow)
+            default:
+                addr  := fmt.Sprintf("%s:%d", config.Host, config.Port)
+                r.pool = newPool(func() (redis.Conn, error) {
+                    return redis.Dial("tcp", addr, config.getDialOptions(true)...)
+                }, nil)
+        }
+        return r
+    }).(*Redis)
+}
+
+// 创建链接池，test为可选的额外链接检测方法
+func newPool(dial func() (redis.Conn, error), test func(c redis.Conn, t time.Time) error) *redis.Pool {
+    return &redis.Pool {
+        MaxIdle         : gDEFAULT_POOL_MAX_IDLE,
+        MaxActive       : gDEFAULT_POOL_MAX_ACTIVE,
+        IdleTimeout     : gDEFAULT_POOL_IDLE_TIMEOUT,
+        MaxConnLifetime : gDEFAULT_POOL_MAX_LIFE_TIME,
+        Dial            : dial,
+        // 用来测试连接是否可用
+        TestOnBorrow    : func(c redis.Conn, t time.Time) error {
+            if test != nil {
+                if err := test(c, t); err != nil {
+                    return err
                 }
-                return c, nil
-            },
-            // 用来测试连接是否可用
-            TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			    _, err := c.Do("PING")
-			    return err
-		    },
+            }
+            _, err := c.Do("PING")
+            return err
+        },
+    }
+}
+
+// 配置信息对应的唯一键名
+func (c *Config) getKey() string {
+    switch {
+        case c.Cluster:
+            return fmt.Sprintf("cluster:%s,%s,%v,%v,%p", strings.Join(c.Addrs, ";"), c.Pass, c.TLS, c.TLSSkipVerify, c.TLSConfig)
+        case c.MasterName != "":
+            return fmt.Sprintf("sentinel:%s@%s,%d,%s,%v,%v,%p", c.MasterName, strings.Join(c.Addrs, ";"), c.Db, c.Pass, c.TLS, c.TLSSkipVerify, c.TLSConfig)
+    }
+    return fmt.Sprintf("%s:%d,%d,%s,%v,%v,%p", c.Host, c.Port, c.Db, c.Pass, c.TLS, c.TLSSkipVerify, c.TLSConfig)
+}
+
+// 获得链接Redis节点的选项，auth为false时不进行密码认证及数据库选择(用于链接Sentinel节点)
+func (c *Config) getDialOptions(auth bool) []redis.DialOption {
+    options := make([]redis.DialOption, 0)
+    if auth {
+        options = append(options, redis.DialPassword(c.Pass))
+        if !c.Cluster {
+            options = append(options, redis.DialDatabase(c.Db))
+        }
+    }
+    if c.TLS {
+        options = append(options, redis.DialUseTLS(true), redis.DialTLSSkipVerify(c.TLSSkipVerify))
+        if c.TLSConfig != nil {
+            options = append(options, redis.DialTLSConfig(c.TLSConfig))
         }
-        pools.Set(poolKey, pool)
-        r.pool = pool
-    } else {
-        r.pool = v.(*redis.Pool)
     }
-    return r
+    return options
 }
 
-// 关闭redis管理对象，将会关闭底层的
+// 关闭redis管理对象，将会关闭底层的链接池
 func (r *Redis) Close() error {
+    if r.cluster != nil {
+        return r.cluster.close()
+    }
+    if r.sentinel != nil {
+        r.sentinel.close()
+    }
     return r.pool.Close()
 }
 
 // 获得一个原生的redis连接对象，用于自定义连接操作，
 // 但是需要注意的是如果不再使用该连接对象时，需要手动Close连接，否则会造成连接数超限。
+// Cluster模式下返回的是任意一个主节点的链接，链接上的命令不会自动路由。
 func (r *Redis) GetConn() redis.Conn {
+    if r.cluster != nil {
+        return r.cluster.getConn()
+    }
     return r.pool.Get()
 }
 
+// 设置链接池属性，Cluster模式下对所有节点的链接池有效
+func (r *Redis) setPool(f func(pool *redis.Pool)) {
+    if r.cluster != nil {
+        r.cluster.setPool(f)
+    } else {
+        f(r.pool)
+    }
+}
+
 // 设置属性 - MaxIdle
 func (r *Redis) SetMaxIdle(value int) {
-    r.pool.MaxIdle = value
+    r.setPool(func(pool *redis.Pool) {
+        pool.MaxIdle = value
+    })
 }
 
 // 设置属性 - MaxActive
 func (r *Redis) SetMaxActive(value int) {
-    r.pool.MaxActive = value
+    r.setPool(func(pool *redis.Pool) {
+        pool.MaxActive = value
+    })
 }
 
 // 设置属性 - IdleTimeout
 func (r *Redis) SetIdleTimeout(value time.Duration) {
-    r.pool.IdleTimeout = value
+    r.setPool(func(pool *redis.Pool) {
+        pool.IdleTimeout = value
+    })
 }
 
 // 设置属性 - MaxConnLifetime
 func (r *Redis) SetMaxConnLifetime(value time.Duration) {
-    r.pool.MaxConnLifetime = value
+    r.setPool(func(pool *redis.Pool) {
+        pool.MaxConnLifetime = value
+    })
 }
 
-// 获取当前连接池统计信息
+// 获取当前连接池统计信息，Cluster模式下为所有节点链接池的汇总
 func (r *Redis) Stats() *PoolStats {
+    if r.cluster != nil {
+        return &PoolStats{r.cluster.stats()}
+    }
     return &PoolStats{r.pool.Stats()}
 }
 
-// 执行同步命令 - Do
+// 执行同步命令 - Do，Cluster模式下根据键名路由，并自动处理MOVED/ASK重定向
 func (r *Redis) Do(command string, args ...interface{}) (interface{}, error) {
+    if r.cluster != nil {
+        return r.cluster.do(command, args...)
+    }
     conn := r.pool.Get()
     defer conn.Close()
     return conn.Do(command, args...)
@@ -129,6 +206,9 @@ func (r *Redis) Do(command string, args ...interface{}) (interface{}, error) {
 
 // 执行异步命令 - Send
 func (r *Redis) Send(command string, args ...interface{}) error {
+    if r.cluster != nil {
+        return r.cluster.send(command, args...)
+    }
     conn := r.pool.Get()
     defer conn.Close()
     return conn.Send(command, args...)
@@ -0,0 +1,654 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "net"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+const (
+    gCLUSTER_SLOTS         = 16384 // 集群槽位数量
+    gCLUSTER_MAX_REDIRECTS = 16    // 单条命令最大的重定向次数
+)
+
+// 不包含键名的命令，Cluster模式下发送到任意节点执行
+var keylessCommands = make(map[string]bool)
+
+// 作用于整个数据库的命令，Cluster模式下发送到所有主节点执行并合并结果
+var fanoutCommands = map[string]bool {
+    "KEYS"     : true,
+    "DBSIZE"   : true,
+    "FLUSHDB"  : true,
+    "FLUSHALL" : true,
+}
+
+// 包含多个键名的命令及每个键名占用的参数个数，Cluster模式下按照槽位拆分后分别执行并合并结果
+var multiKeyCommands = map[string]int {
+    "DEL"    : 1,
+    "UNLINK" : 1,
+    "EXISTS" : 1,
+    "TOUCH"  : 1,
+    "MGET"   : 1,
+    "MSET"   : 2,
+}
+
+func init() {
+    commands := `PING ECHO AUTH SELECT QUIT INFO TIME DBSIZE KEYS SCAN FLUSHDB FLUSHALL MULTI EXEC DISCARD
+                 PUBLISH SUBSCRIBE PSUBSCRIBE UNSUBSCRIBE PUNSUBSCRIBE CLUSTER SENTINEL ROLE ASKING READONLY
+                 SCRIPT CONFIG CLIENT`
+    for _, command := range strings.Fields(commands) {
+        keylessCommands[command] = true
+    }
+    // 空命令用于刷新缓冲区并读取所有回复
+    keylessCommands[""] = true
+}
+
+// Cluster集群节点管理对象，维护槽位与主节点的映射关系及各个节点的链接池。
+// 命令根据键名的槽位路由到对应的主节点，收到MOVED重定向时更新槽位映射并刷新集群拓扑，
+// 收到ASK重定向时在目标节点上先执行ASKING再执行命令(不更新槽位映射)。
+type cluster struct {
+    config     Config
+    mu         sync.RWMutex
+    pools      map[string]*redis.Pool // 节点地址对应的链接池
+    slots      []string               // 槽位对应的主节点地址
+    options    []func(*redis.Pool)    // 链接池属性设置，对后续创建的链接池同样有效
+    refreshMu  sync.Mutex             // 保证同一时间只有一个拓扑刷新过程
+    refreshing int32                  // 是否正在后台刷新拓扑(原子操作)
+}
+
+// 创建集群节点管理对象，集群拓扑在第一次执行命令时获取
+func newCluster(config Config) *cluster {
+    return &cluster {
+        config : config,
+        pools  : make(map[string]*redis.Pool),
+        slots  : make([]string, gCLUSTER_SLOTS),
+    }
+}
+
+// 获得指定节点的链接池，不存在时创建
+func (c *cluster) getPool(addr string) *redis.Pool {
+    c.mu.RLock()
+    pool, ok := c.pools[addr]
+    c.mu.RUnlock()
+    if ok {
+        return pool
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if pool, ok = c.pools[addr]; !ok {
+        pool = newPool(func() (redis.Conn, error) {
+            return redis.Dial("tcp", addr, c.config.getDialOptions(true)...)
+        }, nil)
+        for _, f := range c.options {
+            f(pool)
+        }
+        c.pools[addr] = pool
+    }
+    return pool
+}
+
+// 设置所有链接池的属性
+func (c *cluster) setPool(f func(pool *redis.Pool)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.options = append(c.options, f)
+    for _, pool := range c.pools {
+        f(pool)
+    }
+}
+
+// 所有节点链接池的汇总统计信息
+func (c *cluster) stats() redis.PoolStats {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    stats := redis.PoolStats{}
+    for _, pool := range c.pools {
+        s := pool.Stats()
+        stats.ActiveCount += s.ActiveCount
+        stats.IdleCount   += s.IdleCount
+    }
+    return stats
+}
+
+// 关闭所有节点的链接池
+func (c *cluster) close() error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    err := error(nil)
+    for addr, pool := range c.pools {
+        if e := pool.Close(); e != nil && err == nil {
+            err = e
+        }
+        delete(c.pools, addr)
+    }
+    return err
+}
+
+// 获取所有已知的节点地址(种子节点在前)
+func (c *cluster) getAddrs() []string {
+    addrs  := append([]string(nil), c.config.Addrs...)
+    exists := make(map[string]bool)
+    for _, addr := range addrs {
+        exists[addr] = true
+    }
+    c.mu.RLock()
+    others := make([]string, 0)
+    for addr := range c.pools {
+        if !exists[addr] {
+            others = append(others, addr)
+        }
+    }
+    c.mu.RUnlock()
+    sort.Strings(others)
+    return append(addrs, others...)
+}
+
+// 通过CLUSTER SLOTS命令刷新集群拓扑(槽位与主节点的映射关系)
+func (c *cluster) refresh() error {
+    c.refreshMu.Lock()
+    defer c.refreshMu.Unlock()
+    lastErr := errors.New("no cluster address configured")
+    for _, addr := range c.getAddrs() {
+        slots, err := c.querySlots(addr)
+        if err != nil {
+            lastErr = err
+            continue
+        }
+        c.mu.Lock()
+        c.slots = slots
+        c.mu.Unlock()
+        return nil
+    }
+    return errors.New(fmt.Sprintf("redis cluster slots refresh failed: %s", lastErr.Error()))
+}
+
+// 后台异步刷新集群拓扑，同一时间只有一个刷新过程
+func (c *cluster) refreshAsync() {
+    if atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+        go func() {
+            c.refresh()
+            atomic.StoreInt32(&c.refreshing, 0)
+        }()
+    }
+}
+
+// 从指定节点查询槽位映射，回复格式为：[[start, end, [ip, port, id], [replica]...]...]
+func (c *cluster) querySlots(addr string) ([]string, error) {
+    conn := c.getPool(addr).Get()
+    defer conn.Close()
+    values, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+    if err != nil {
+        return nil, err
+    }
+    slots := make([]string, gCLUSTER_SLOTS)
+    for _, value := range values {
+        item, err := redis.Values(value, nil)
+        if err != nil || len(item) < 3 {
+            return nil, errors.New("invalid cluster slots reply")
+        }
+        node, err := redis.Values(item[2], nil)
+        if err != nil || len(node) < 2 {
+            return nil, errors.New("invalid cluster slots reply")
+        }
+        start, end := gconv.Int(item[0]), gconv.Int(item[1])
+        if start < 0 || end >= gCLUSTER_SLOTS || start > end {
+            return nil, errors.New(fmt.Sprintf("invalid cluster slots range: %d-%d", start, end))
+        }
+        master := net.JoinHostPort(gconv.String(node[0]), gconv.String(node[1]))
+        for i := start; i <= end; i++ {
+            slots[i] = master
+        }
+    }
+    return slots, nil
+}
+
+// 获得槽位对应的主节点地址，槽位映射未知时同步刷新集群拓扑
+func (c *cluster) getAddrBySlot(slot int) (string, error) {
+    c.mu.RLock()
+    addr := c.slots[slot]
+    c.mu.RUnlock()
+    if addr != "" {
+        return addr, nil
+    }
+    if err := c.refresh(); err != nil {
+        return "", err
+    }
+    c.mu.RLock()
+    addr = c.slots[slot]
+    c.mu.RUnlock()
+    if addr == "" {
+        return "", errors.New(fmt.Sprintf("redis cluster slot %d is not covered", slot))
+    }
+    return addr, nil
+}
+
+// 获得命令应当发送到的节点地址，不包含键名的命令发送到任意节点
+func (c *cluster) getAddr(command string, args []interface{}) (string, error) {
+    if key, ok := getCommandKey(command, args); ok {
+        return c.getAddrBySlot(GetSlot(key))
+    }
+    return c.getAnyAddr()
+}
+
+// 获得任意一个可用的节点地址(优先使用槽位映射中的主节点)
+func (c *cluster) getAnyAddr() (string, error) {
+    c.mu.RLock()
+    for _, addr := range c.slots {
+        if addr != "" {
+            c.mu.RUnlock()
+            return addr, nil
+        }
+    }
+    c.mu.RUnlock()
+    if addrs := c.getAddrs(); len(addrs) > 0 {
+        return addrs[0], nil
+    }
+    return "", errors.New("no cluster address configured")
+}
+
+// 获得任意一个节点的链接
+func (c *cluster) getConn() redis.Conn {
+    addr, err := c.getAnyAddr()
+    if err != nil {
+        return errorConn{err}
+    }
+    return c.getPool(addr).Get()
+}
+
+// 获得所有主节点的地址，槽位映射未知时同步刷新集群拓扑
+func (c *cluster) getMasters() ([]string, error) {
+    masters := c.getSlotMasters()
+    if len(masters) > 0 {
+        return masters, nil
+    }
+    if err := c.refresh(); err != nil {
+        return nil, err
+    }
+    if masters = c.getSlotMasters(); len(masters) == 0 {
+        return nil, errors.New("redis cluster has no master node")
+    }
+    return masters, nil
+}
+
+// 获得槽位映射中的所有主节点地址(已排序)
+func (c *cluster) getSlotMasters() []string {
+    c.mu.RLock()
+    exists := make(map[string]bool)
+    for _, addr := range c.slots {
+        if addr != "" {
+            exists[addr] = true
+        }
+    }
+    c.mu.RUnlock()
+    masters := make([]string, 0, len(exists))
+    for addr := range exists {
+        masters = append(masters, addr)
+    }
+    sort.Strings(masters)
+    return masters
+}
+
+// 执行命令：
+// 1、作用于整个数据库的命令(KEYS/DBSIZE/FLUSHDB/FLUSHALL)在所有主节点上执行并合并结果；
+// 2、键名位于不同槽位的多键命令(DEL/EXISTS/MGET/MSET等)按照槽位拆分后分别执行并合并结果(不保证原子性)；
+// 3、其他命令根据键名路由到对应的节点，并处理MOVED/ASK重定向；
+// SCAN命令无法在多个节点间使用同一个游标，需要使用Redis.Scan遍历所有节点。
+func (c *cluster) do(command string, args...interface{}) (interface{}, error) {
+    name := strings.ToUpper(command)
+    switch {
+        case name == "SCAN":
+            return nil, errors.New("SCAN is not supported in cluster mode, use Redis.Scan instead")
+        case fanoutCommands[name]:
+            return c.doAll(name, args)
+        case multiKeyCommands[name] > 0:
+            if groups := splitKeysBySlot(name, args); len(groups) > 1 {
+                return c.doSplit(name, args, groups)
+            }
+    }
+    return c.doRoute(command, args)
+}
+
+// 在所有主节点上执行命令并合并结果：KEYS合并键名列表，DBSIZE累加数量，其他命令返回最后一个节点的回复
+func (c *cluster) doAll(command string, args []interface{}) (interface{}, error) {
+    masters, err := c.getMasters()
+    if err != nil {
+        return nil, err
+    }
+    keys  := make([]interface{}, 0)
+    size  := int64(0)
+    reply := interface{}(nil)
+    for _, addr := range masters {
+        conn := c.getPool(addr).Get()
+        reply, err = conn.Do(command, args...)
+        conn.Close()
+        if err != nil {
+            return nil, err
+        }
+        switch command {
+            case "KEYS":
+                values, err := redis.Values(reply, nil)
+                if err != nil {
+                    return nil, err
+                }
+                keys = append(keys, values...)
+            case "DBSIZE":
+                n, err := redis.Int64(reply, nil)
+                if err != nil {
+                    return nil, err
+                }
+                size += n
+        }
+    }
+    switch command {
+        case "KEYS":
+            return keys, nil
+        case "DBSIZE":
+            return size, nil
+    }
+    return reply, nil
+}
+
+// 按照槽位拆分执行多键命令并合并结果：MGET按照键名顺序合并键值，MSET返回OK，其他命令累加数量
+func (c *cluster) doSplit(command string, args []interface{}, groups [][]int) (interface{}, error) {
+    step   := multiKeyCommands[command]
+    values := make([]interface{}, len(args))
+    count  := int64(0)
+    for _, group := range groups {
+        params := make([]interface{}, 0, len(group) * step)
+        for _, index := range group {
+            params = append(params, args[index : index + step]...)
+        }
+        reply, err := c.doRoute(command, params)
+        if err != nil {
+            return nil, err
+        }
+        switch command {
+            case "MGET":
+                array, err := redis.Values(reply, nil)
+                if err != nil {
+                    return nil, err
+                }
+                for i, index := range group {
+                    if i < len(array) {
+                        values[index] = array[i]
+                    }
+                }
+            case "MSET":
+                // 回复均为OK
+            default:
+                n, err := redis.Int64(reply, nil)
+                if err != nil {
+                    return nil, err
+                }
+                count += n
+        }
+    }
+    switch command {
+        case "MGET":
+            return values, nil
+        case "MSET":
+            return "OK", nil
+    }
+    return count, nil
+}
+
+// 执行命令，根据键名路由到对应的节点，并处理MOVED/ASK重定向
+func (c *cluster) doRoute(command string, args []interface{}) (interface{}, error) {
+    addr, err := c.getAddr(command, args)
+    if err != nil {
+        return nil, err
+    }
+    asking    := false
+    refreshed := false
+    for i := 0; i < gCLUSTER_MAX_REDIRECTS; i++ {
+        conn := c.getPool(addr).Get()
+        if asking {
+            conn.Send("ASKING")
+        }
+        reply, err := conn.Do(command, args...)
+        conn.Close()
+        switch e := err.(type) {
+            case nil:
+                return reply, nil
+            case redis.Error:
+                kind, slot, target := parseRedirect(e)
+                switch kind {
+                    case "MOVED":
+                        c.mu.Lock()
+                        c.slots[slot] = target
+                        c.mu.Unlock()
+                        c.refreshAsync()
+                        addr, asking = target, false
+                        continue
+                    case "ASK":
+                        addr, asking = target, true
+                        continue
+                }
+                return reply, err
+            default:
+                // 节点不可用时刷新一次集群拓扑后重试(可能发生了故障转移)
+                if refreshed || c.refresh() != nil {
+                    return reply, err
+                }
+                refreshed = true
+                if addr, err = c.getAddr(command, args); err != nil {
+                    return nil, err
+                }
+                asking = false
+        }
+    }
+    return nil, errors.New(fmt.Sprintf("too many cluster redirections for command: %s", command))
+}
+
+// 批量执行命令，非事务命令按照节点分组后在各节点上批量执行，返回重定向错误的命令将会单独重试；
+// 事务命令的键名必须位于同一个槽位。
+func (c *cluster) exec(commands []pipelineCommand, multi bool) ([]interface{}, error) {
+    if multi {
+        slot := -1
+        for _, command := range commands {
+            for _, key := range getCommandKeys(command.name, command.args) {
+                if s := GetSlot(key); slot == -1 {
+                    slot = s
+                } else if s != slot {
+                    return nil, errors.New("keys in cluster transaction must hash to the same slot")
+                }
+            }
+        }
+        addr, err := c.getAnyAddr()
+        if slot >= 0 {
+            addr, err = c.getAddrBySlot(slot)
+        }
+        if err != nil {
+            return nil, err
+        }
+        conn := c.getPool(addr).Get()
+        defer conn.Close()
+        return execPipeline(conn, commands, true)
+    }
+    // 按照节点分组，需要在多个节点上执行的命令单独执行
+    groups   := make(map[string][]int)
+    separate := make([]int, 0)
+    for i, command := range commands {
+        if isMultiNodeCommand(command.name, command.args) {
+            separate = append(separate, i)
+            continue
+        }
+        addr, err := c.getAddr(command.name, command.args)
+        if err != nil {
+            return nil, err
+        }
+        groups[addr] = append(groups[addr], i)
+    }
+    replies := make([]interface{}, len(commands))
+    for addr, indexes := range groups {
+        group := make([]pipelineCommand, len(indexes))
+        for i, index := range indexes {
+            group[i] = commands[index]
+        }
+        conn        := c.getPool(addr).Get()
+        values, err := execPipeline(conn, group, false)
+        conn.Close()
+        if err != nil {
+            return nil, err
+        }
+        for i, index := range indexes {
+            replies[index] = values[i]
+        }
+    }
+    for _, index := range separate {
+        if reply, err := c.do(commands[index].name, commands[index].args...); err != nil {
+            replies[index] = err
+        } else {
+            replies[index] = reply
+        }
+    }
+    // 重定向的命令单独执行
+    for i, reply := range replies {
+        if e, ok := reply.(redis.Error); ok {
+            if kind, _, _ := parseRedirect(e); kind != "" {
+                if reply, err := c.do(commands[i].name, commands[i].args...); err != nil {
+                    replies[i] = err
+                } else {
+                    replies[i] = reply
+                }
+            }
+        }
+    }
+    return replies, nil
+}
+
+// 执行异步命令，根据键名路由到对应的节点(不处理重定向)
+func (c *cluster) send(command string, args...interface{}) error {
+    addr, err := c.getAddr(command, args)
+    if err != nil {
+        return err
+    }
+    conn := c.getPool(addr).Get()
+    defer conn.Close()
+    return conn.Send(command, args...)
+}
+
+// 解析重定向错误，格式为：MOVED <slot> <host:port> 或者 ASK <slot> <host:port>
+func parseRedirect(err redis.Error) (kind string, slot int, addr string) {
+    array := strings.Fields(string(err))
+    if len(array) != 3 || (array[0] != "MOVED" && array[0] != "ASK") {
+        return "", 0, ""
+    }
+    slot = gconv.Int(array[1])
+    if slot < 0 || slot >= gCLUSTER_SLOTS {
+        return "", 0, ""
+    }
+    return array[0], slot, array[2]
+}
+
+// 获得命令中的键名，不包含键名的命令返回false
+func getCommandKey(command string, args []interface{}) (string, bool) {
+    command = strings.ToUpper(command)
+    if keylessCommands[command] || len(args) == 0 {
+        return "", false
+    }
+    switch command {
+        case "EVAL", "EVALSHA":
+            // EVAL script numkeys key [key ...] arg [arg ...]
+            if len(args) < 3 || gconv.Int(args[1]) < 1 {
+                return "", false
+            }
+            return gconv.String(args[2]), true
+    }
+    return gconv.String(args[0]), true
+}
+
+// 获得命令中的所有键名，多键命令返回所有键名，其他命令最多返回一个键名
+func getCommandKeys(command string, args []interface{}) []string {
+    command = strings.ToUpper(command)
+    if step := multiKeyCommands[command]; step > 0 {
+        keys := make([]string, 0, len(args) / step)
+        for i := 0; i < len(args); i += step {
+            keys = append(keys, gconv.String(args[i]))
+        }
+        return keys
+    }
+    if key, ok := getCommandKey(command, args); ok {
+        return []string{key}
+    }
+    return nil
+}
+
+// 将多键命令的键名按照槽位分组，返回每组键名在参数中的位置(按照槽位首次出现的顺序)
+func splitKeysBySlot(command string, args []interface{}) [][]int {
+    step    := multiKeyCommands[command]
+    groups  := make([][]int, 0)
+    indexes := make(map[int]int)
+    for i := 0; i + step <= len(args); i += step {
+        slot := GetSlot(gconv.String(args[i]))
+        if n, ok := indexes[slot]; ok {
+            groups[n] = append(groups[n], i)
+        } else {
+            indexes[slot] = len(groups)
+            groups        = append(groups, []int{i})
+        }
+    }
+    return groups
+}
+
+// 命令是否需要在多个节点上执行(作用于整个数据库的命令、SCAN及键名位于不同槽位的多键命令)
+func isMultiNodeCommand(command string, args []interface{}) bool {
+    command = strings.ToUpper(command)
+    switch {
+        case command == "SCAN", fanoutCommands[command]:
+            return true
+        case multiKeyCommands[command] > 0:
+            return len(splitKeysBySlot(command, args)) > 1
+    }
+    return false
+}
+
+// 计算键名在集群中所在的槽位，键名中包含{hashtag}时只使用hashtag计算
+func GetSlot(key string) int {
+    if start := strings.IndexByte(key, '{'); start >= 0 {
+        if end := strings.IndexByte(key[start + 1:], '}'); end > 0 {
+            key = key[start + 1 : start + 1 + end]
+        }
+    }
+    return int(crc16(key) % gCLUSTER_SLOTS)
+}
+
+// CRC16(XMODEM)校验，Redis Cluster使用该算法计算槽位
+func crc16(s string) uint16 {
+    crc := uint16(0)
+    for i := 0; i < len(s); i++ {
+        crc ^= uint16(s[i]) << 8
+        for j := 0; j < 8; j++ {
+            if crc & 0x8000 != 0 {
+                crc = crc << 1 ^ 0x1021
+            } else {
+                crc = crc << 1
+            }
+        }
+    }
+    return crc
+}
+
+// 无法获取链接时返回的链接对象，所有操作均返回错误
+type errorConn struct {
+    err error
+}
+
+func (c errorConn) Close() error                                        { return nil }
+func (c errorConn) Err() error                                          { return c.err }
+func (c errorConn) Do(string, ...interface{}) (interface{}, error)      { return nil, c.err }
+func (c errorConn) Send(string, ...interface{}) error                   { return c.err }
+func (c errorConn) Flush() error                                        { return c.err }
+func (c errorConn) Receive() (interface{}, error)                       { return nil, c.err }
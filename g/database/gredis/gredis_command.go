@@ -0,0 +1,301 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "errors"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "time"
+)
+
+// 有序集合成员
+type ZMember struct {
+    Member *gvar.Var // 成员
+    Score  float64   // 分数
+}
+
+// 执行同步命令，并将回复数据转换为*gvar.Var返回(返回值不会为nil)，
+// 其中字符串回复转换为string，数组回复转换为[]interface{}，不存在的键值转换为nil。
+func (r *Redis) DoVar(command string, args ...interface{}) (*gvar.Var, error) {
+    reply, err := r.Do(command, args...)
+    if err != nil {
+        return gvar.New(nil, true), err
+    }
+    return gvar.New(convertReply(reply), true), nil
+}
+
+// 将Redis回复数据中的[]byte转换为string
+func convertReply(reply interface{}) interface{} {
+    switch v := reply.(type) {
+        case []byte:
+            return string(v)
+        case []interface{}:
+            for i := range v {
+                v[i] = convertReply(v[i])
+            }
+            return v
+    }
+    return reply
+}
+
+// 获得过期时间的命令参数
+func getExpireArgs(expire []time.Duration) []interface{} {
+    if len(expire) > 0 && expire[0] > 0 {
+        return []interface{}{"PX", int64(expire[0] / time.Millisecond)}
+    }
+    return nil
+}
+
+// 获取字符串键值，键名不存在时返回的变量值为nil
+func (r *Redis) Get(key string) (*gvar.Var, error) {
+    return r.DoVar("GET", key)
+}
+
+// 批量获取字符串键值，返回的变量值为[]interface{}
+func (r *Redis) MGet(keys...string) (*gvar.Var, error) {
+    return r.DoVar("MGET", gconv.Interfaces(keys)...)
+}
+
+// 设置字符串键值，expire为可选的过期时间
+func (r *Redis) Set(key string, value interface{}, expire...time.Duration) error {
+    _, err := r.Do("SET", append([]interface{}{key, value}, getExpireArgs(expire)...)...)
+    return err
+}
+
+// 当键名不存在时设置字符串键值，设置成功时返回true
+func (r *Redis) SetNX(key string, value interface{}, expire...time.Duration) (bool, error) {
+    args := append([]interface{}{key, value}, getExpireArgs(expire)...)
+    reply, err := r.Do("SET", append(args, "NX")...)
+    return reply != nil, err
+}
+
+// 键值自增，delta默认为1，返回自增后的值
+func (r *Redis) Incr(key string, delta...int64) (int64, error) {
+    if len(delta) > 0 {
+        return redis.Int64(r.Do("INCRBY", key, delta[0]))
+    }
+    return redis.Int64(r.Do("INCR", key))
+}
+
+// 删除键名，返回删除的数量
+func (r *Redis) Del(keys...string) (int, error) {
+    return redis.Int(r.Do("DEL", gconv.Interfaces(keys)...))
+}
+
+// 判断键名是否存在
+func (r *Redis) Exists(key string) (bool, error) {
+    return redis.Bool(r.Do("EXISTS", key))
+}
+
+// 设置键名的过期时间，键名不存在时返回false
+func (r *Redis) Expire(key string, expire time.Duration) (bool, error) {
+    return redis.Bool(r.Do("PEXPIRE", key, int64(expire / time.Millisecond)))
+}
+
+// 获得键名的剩余过期时间，键名不存在时返回-2，不过期时返回-1(单位均为纳秒)
+func (r *Redis) TTL(key string) (time.Duration, error) {
+    ttl, err := redis.Int64(r.Do("PTTL", key))
+    if err != nil || ttl < 0 {
+        return time.Duration(ttl), err
+    }
+    return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// 遍历匹配指定模式的所有键名(通过SCAN命令分批获取，count为每批获取数量的参考值)，
+// Cluster模式下将会遍历所有的主节点。
+func (r *Redis) Scan(match string, count int) ([]string, error) {
+    if r.cluster == nil {
+        conn := r.pool.Get()
+        defer conn.Close()
+        return scanKeys(conn, match, count)
+    }
+    masters, err := r.cluster.getMasters()
+    if err != nil {
+        return nil, err
+    }
+    keys := make([]string, 0)
+    for _, addr := range masters {
+        conn       := r.cluster.getPool(addr).Get()
+        array, err := scanKeys(conn, match, count)
+        conn.Close()
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, array...)
+    }
+    return keys, nil
+}
+
+// 在指定链接上通过SCAN命令遍历匹配的所有键名
+func scanKeys(conn redis.Conn, match string, count int) ([]string, error) {
+    keys   := make([]string, 0)
+    cursor := "0"
+    for {
+        values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", match, "COUNT", count))
+        if err != nil {
+            return nil, err
+        }
+        if len(values) != 2 {
+            return nil, errors.New("invalid scan reply")
+        }
+        array, err := redis.Strings(values[1], nil)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, array...)
+        if cursor, _ = redis.String(values[0], nil); cursor == "0" || cursor == "" {
+            return keys, nil
+        }
+    }
+}
+
+// 获取哈希表字段值
+func (r *Redis) HGet(key string, field string) (*gvar.Var, error) {
+    return r.DoVar("HGET", key, field)
+}
+
+// 设置哈希表字段值
+func (r *Redis) HSet(key string, field string, value interface{}) error {
+    _, err := r.Do("HSET", key, field, value)
+    return err
+}
+
+// 批量设置哈希表字段值，data可以为map或者struct(通过gconv.Map转换)
+func (r *Redis) HMSet(key string, data interface{}) error {
+    m := gconv.Map(data)
+    if len(m) == 0 {
+        return nil
+    }
+    args := make([]interface{}, 0, len(m)*2 + 1)
+    args  = append(args, key)
+    for k, v := range m {
+        args = append(args, k, v)
+    }
+    _, err := r.Do("HMSET", args...)
+    return err
+}
+
+// 获取哈希表所有字段，返回的变量值为map[string]interface{}，可以通过Struct方法转换为struct对象
+func (r *Redis) HGetAll(key string) (*gvar.Var, error) {
+    values, err := redis.Values(r.Do("HGETALL", key))
+    if err != nil {
+        return gvar.New(nil, true), err
+    }
+    m := make(map[string]interface{}, len(values)/2)
+    for i := 0; i < len(values) - 1; i += 2 {
+        m[gconv.String(values[i])] = convertReply(values[i + 1])
+    }
+    return gvar.New(m, true), nil
+}
+
+// 删除哈希表字段，返回删除的数量
+func (r *Redis) HDel(key string, fields...string) (int, error) {
+    return redis.Int(r.Do("HDEL", append([]interface{}{key}, gconv.Interfaces(fields)...)...))
+}
+
+// 从列表头部写入数据，返回写入后的列表长度
+func (r *Redis) LPush(key string, values...interface{}) (int, error) {
+    return redis.Int(r.Do("LPUSH", append([]interface{}{key}, values...)...))
+}
+
+// 从列表尾部写入数据，返回写入后的列表长度
+func (r *Redis) RPush(key string, values...interface{}) (int, error) {
+    return redis.Int(r.Do("RPUSH", append([]interface{}{key}, values...)...))
+}
+
+// 从列表头部弹出数据，列表为空时返回的变量值为nil
+func (r *Redis) LPop(key string) (*gvar.Var, error) {
+    return r.DoVar("LPOP", key)
+}
+
+// 从列表尾部弹出数据，列表为空时返回的变量值为nil
+func (r *Redis) RPop(key string) (*gvar.Var, error) {
+    return r.DoVar("RPOP", key)
+}
+
+// 获取列表指定范围的数据(支持负数索引)，返回的变量值为[]interface{}
+func (r *Redis) LRange(key string, start, stop int) (*gvar.Var, error) {
+    return r.DoVar("LRANGE", key, start, stop)
+}
+
+// 获取列表长度
+func (r *Redis) LLen(key string) (int, error) {
+    return redis.Int(r.Do("LLEN", key))
+}
+
+// 向集合添加成员，返回新增的成员数量
+func (r *Redis) SAdd(key string, members...interface{}) (int, error) {
+    return redis.Int(r.Do("SADD", append([]interface{}{key}, members...)...))
+}
+
+// 删除集合成员，返回删除的成员数量
+func (r *Redis) SRem(key string, members...interface{}) (int, error) {
+    return redis.Int(r.Do("SREM", append([]interface{}{key}, members...)...))
+}
+
+// 获取集合所有成员，返回的变量值为[]interface{}
+func (r *Redis) SMembers(key string) (*gvar.Var, error) {
+    return r.DoVar("SMEMBERS", key)
+}
+
+// 判断是否为集合成员
+func (r *Redis) SIsMember(key string, member interface{}) (bool, error) {
+    return redis.Bool(r.Do("SISMEMBER", key, member))
+}
+
+// 向有序集合添加成员(成员已存在时更新分数)，返回新增的成员数量
+func (r *Redis) ZAdd(key string, score float64, member interface{}) (int, error) {
+    return redis.Int(r.Do("ZADD", key, score, member))
+}
+
+// 删除有序集合成员，返回删除的成员数量
+func (r *Redis) ZRem(key string, members...interface{}) (int, error) {
+    return redis.Int(r.Do("ZREM", append([]interface{}{key}, members...)...))
+}
+
+// 获取有序集合成员的分数，成员不存在时返回的变量值为nil
+func (r *Redis) ZScore(key string, member interface{}) (*gvar.Var, error) {
+    return r.DoVar("ZSCORE", key, member)
+}
+
+// 获取有序集合的成员数量
+func (r *Redis) ZCard(key string) (int, error) {
+    return redis.Int(r.Do("ZCARD", key))
+}
+
+// 按照分数从小到大获取有序集合指定范围的成员，返回的变量值为[]interface{}
+func (r *Redis) ZRange(key string, start, stop int) (*gvar.Var, error) {
+    return r.DoVar("ZRANGE", key, start, stop)
+}
+
+// 按照分数从小到大获取有序集合指定范围的成员及分数
+func (r *Redis) ZRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+    return r.zMembers(r.Do("ZRANGE", key, start, stop, "WITHSCORES"))
+}
+
+// 获取有序集合分数在[min, max]范围内的成员及分数，min/max可以为"-inf"/"+inf"
+func (r *Redis) ZRangeByScore(key string, min, max interface{}) ([]ZMember, error) {
+    return r.zMembers(r.Do("ZRANGEBYSCORE", key, min, max, "WITHSCORES"))
+}
+
+// 将WITHSCORES形式的回复数据转换为成员列表
+func (r *Redis) zMembers(reply interface{}, err error) ([]ZMember, error) {
+    values, err := redis.Values(reply, err)
+    if err != nil {
+        return nil, err
+    }
+    members := make([]ZMember, 0, len(values)/2)
+    for i := 0; i < len(values) - 1; i += 2 {
+        members = append(members, ZMember {
+            Member : gvar.New(convertReply(values[i]), true),
+            Score  : gconv.Float64(convertReply(values[i + 1])),
+        })
+    }
+    return members, nil
+}
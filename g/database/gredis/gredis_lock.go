@@ -0,0 +1,315 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/gtimer"
+    "gitee.com/johng/gf/g/util/grand"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "sync"
+    "time"
+)
+
+const (
+    gDEFAULT_LOCK_PREFIX         = "gredis:lock:"   // 锁键名的默认前缀
+    gDEFAULT_LOCK_LEASE          = 30 * time.Second // 默认的租约时间
+    gDEFAULT_LOCK_RETRY_INTERVAL = 50               // 阻塞加锁时的重试间隔(毫秒)
+    gLOCK_WRITE_FIELD            = "w"              // 写锁在哈希表中的字段名
+    gLOCK_READ_FIELD_PREFIX      = "r:"             // 读锁在哈希表中的字段名前缀
+)
+
+var (
+    // 加写锁：锁不存在时写入持有者令牌并设置租约，返回递增的fencing token，锁已存在时返回0
+    lockScriptAcquire = NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+    return 0
+end
+redis.call('HSET', KEYS[1], 'w', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return redis.call('INCR', KEYS[2])
+`)
+    // 加读锁：不存在写锁时写入持有者令牌并延长租约，成功返回1，存在写锁时返回0
+    lockScriptAcquireRead = NewScript(`
+if redis.call('HEXISTS', KEYS[1], 'w') == 1 then
+    return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[3]) then
+    redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`)
+    // 解锁：只有令牌匹配时才删除，所有持有者都解锁后删除锁，成功返回1
+    lockScriptRelease = NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) ~= ARGV[2] then
+    return 0
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+if redis.call('HLEN', KEYS[1]) == 0 then
+    redis.call('DEL', KEYS[1])
+end
+return 1
+`)
+    // 续约：只有令牌匹配时才延长租约，成功返回1
+    lockScriptRenew = NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) ~= ARGV[2] then
+    return 0
+end
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[3]) then
+    redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`)
+)
+
+// 基于Redis的分布式锁管理对象，接口与gmlock保持一致，用于多个进程/服务器之间的互斥：
+// 1、每次加锁都会生成唯一的持有者令牌，只有持有者才能解锁；
+// 2、加锁时未指定过期时间时，锁的租约(默认30秒)将会在持有期间自动续约，持有进程崩溃时锁将会在租约到期后自动释放；
+//    指定过期时间时不会自动续约，锁在过期后自动释放(与gmlock的过期时间语义一致)；
+// 3、每次成功加写锁都会获得一个单调递增的fencing token(通过Fence方法获取)，
+//    可以在写入外部存储时携带该值，由存储端拒绝较旧的token，防止锁过期后旧持有者的延迟写入；
+// 4、锁不可重入，同一个Locker对象对同一键名重复加写锁同样会被阻塞；
+// 需要注意的是Redis操作失败时TryLock返回false，Lock将会持续重试直到成功，Unlock/RUnlock的失败将会被忽略(锁将在租约到期后释放)。
+type Locker struct {
+    redis  *Redis
+    prefix string
+    lease  *gtype.Int64              // 租约时间(纳秒)
+    mu     sync.Mutex
+    writes map[string]*lockEntry     // 当前持有的写锁，键名为锁名称
+    reads  map[string][]*lockEntry   // 当前持有的读锁，键名为锁名称
+}
+
+// 当前持有的锁
+type lockEntry struct {
+    field string        // 锁在哈希表中的字段名
+    token string        // 持有者令牌
+    fence int64         // fencing token(仅写锁)
+    renew *gtimer.Entry // 自动续约的定时任务
+}
+
+// 创建分布式锁管理对象，prefix为锁键名的前缀，默认为gredis:lock:
+func NewLocker(redis *Redis, prefix...string) *Locker {
+    l := &Locker {
+        redis  : redis,
+        prefix : gDEFAULT_LOCK_PREFIX,
+        lease  : gtype.NewInt64(int64(gDEFAULT_LOCK_LEASE)),
+        writes : make(map[string]*lockEntry),
+        reads  : make(map[string][]*lockEntry),
+    }
+    if len(prefix) > 0 {
+        l.prefix = prefix[0]
+    }
+    return l
+}
+
+// 设置自动续约的锁的租约时间，续约间隔为租约时间的1/3
+func (l *Locker) SetLease(lease time.Duration) {
+    l.lease.Set(int64(lease))
+}
+
+// 分布式写锁，如果锁成功返回true，失败则返回false；过期时间默认为0表示持有期间自动续约
+func (l *Locker) TryLock(key string, expire...time.Duration) bool {
+    return l.doLock(key, l.getExpire(expire...))
+}
+
+// 分布式写锁，锁成功时立即返回，否则阻塞等待；过期时间默认为0表示持有期间自动续约
+func (l *Locker) Lock(key string, expire...time.Duration) {
+    e := l.getExpire(expire...)
+    for !l.doLock(key, e) {
+        time.Sleep(time.Duration(gDEFAULT_LOCK_RETRY_INTERVAL + grand.N(0, gDEFAULT_LOCK_RETRY_INTERVAL)) * time.Millisecond)
+    }
+}
+
+// 解除分布式写锁，只能解除当前Locker对象持有的锁
+func (l *Locker) Unlock(key string) {
+    l.mu.Lock()
+    entry, ok := l.writes[key]
+    delete(l.writes, key)
+    l.mu.Unlock()
+    if ok {
+        l.release(key, entry)
+    }
+}
+
+// 分布式读锁，如果锁成功返回true，失败(存在写锁)则返回false，读锁在持有期间自动续约
+func (l *Locker) TryRLock(key string) bool {
+    return l.doRLock(key)
+}
+
+// 分布式读锁，锁成功时立即返回，存在写锁时阻塞等待，读锁在持有期间自动续约
+func (l *Locker) RLock(key string) {
+    for !l.doRLock(key) {
+        time.Sleep(time.Duration(gDEFAULT_LOCK_RETRY_INTERVAL + grand.N(0, gDEFAULT_LOCK_RETRY_INTERVAL)) * time.Millisecond)
+    }
+}
+
+// 解除当前Locker对象持有的一个分布式读锁
+func (l *Locker) RUnlock(key string) {
+    l.mu.Lock()
+    entries := l.reads[key]
+    entry   := (*lockEntry)(nil)
+    if len(entries) > 0 {
+        entry = entries[len(entries) - 1]
+        if len(entries) == 1 {
+            delete(l.reads, key)
+        } else {
+            l.reads[key] = entries[: len(entries) - 1]
+        }
+    }
+    l.mu.Unlock()
+    if entry != nil {
+        l.release(key, entry)
+    }
+}
+
+// 获得当前Locker对象持有的写锁的fencing token，未持有该写锁时返回0
+func (l *Locker) Fence(key string) int64 {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if entry, ok := l.writes[key]; ok {
+        return entry.fence
+    }
+    return 0
+}
+
+// 判断当前Locker对象是否持有指定的写锁(自动续约失败后将不再持有)
+func (l *Locker) IsLocked(key string) bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    _, ok := l.writes[key]
+    return ok
+}
+
+// 获得过期时间，没有设置时默认为0(自动续约)
+func (l *Locker) getExpire(expire...time.Duration) time.Duration {
+    if len(expire) > 0 && expire[0] > 0 {
+        return expire[0]
+    }
+    return 0
+}
+
+// 锁在Redis中的键名，使用hashtag保证Cluster模式下锁键名与fencing token键名位于同一槽位
+func (l *Locker) getLockKey(key string) string {
+    return l.prefix + "{" + key + "}"
+}
+
+// fencing token计数器的键名，该键名不会过期，以保证fencing token单调递增
+func (l *Locker) getFenceKey(key string) string {
+    return l.prefix + "{" + key + "}:fence"
+}
+
+// 生成持有者令牌
+func newLockToken() string {
+    return grand.RandStr(32)
+}
+
+// 执行加写锁操作
+func (l *Locker) doLock(key string, expire time.Duration) bool {
+    lease := expire
+    if lease == 0 {
+        lease = time.Duration(l.lease.Val())
+    }
+    token := newLockToken()
+    fence, err := redis.Int64(l.redis.EvalScript(
+        lockScriptAcquire, []string{l.getLockKey(key), l.getFenceKey(key)}, token, int64(lease/time.Millisecond),
+    ))
+    if err != nil || fence == 0 {
+        return false
+    }
+    entry := &lockEntry {
+        field : gLOCK_WRITE_FIELD,
+        token : token,
+        fence : fence,
+    }
+    l.mu.Lock()
+    if expire == 0 {
+        entry.renew = l.startRenew(key, entry, lease)
+    }
+    if old, ok := l.writes[key]; ok && old.renew != nil {
+        // 已过期的旧锁
+        old.renew.Close()
+    }
+    l.writes[key] = entry
+    l.mu.Unlock()
+    return true
+}
+
+// 执行加读锁操作
+func (l *Locker) doRLock(key string) bool {
+    lease := time.Duration(l.lease.Val())
+    token := newLockToken()
+    field := gLOCK_READ_FIELD_PREFIX + token
+    ok, err := redis.Bool(l.redis.EvalScript(
+        lockScriptAcquireRead, []string{l.getLockKey(key)}, field, token, int64(lease/time.Millisecond),
+    ))
+    if err != nil || !ok {
+        return false
+    }
+    entry := &lockEntry {
+        field : field,
+        token : token,
+    }
+    l.mu.Lock()
+    entry.renew = l.startRenew(key, entry, lease)
+    l.reads[key] = append(l.reads[key], entry)
+    l.mu.Unlock()
+    return true
+}
+
+// 开始自动续约，续约间隔为租约时间的1/3，锁已经不再由当前持有者持有时停止续约
+func (l *Locker) startRenew(key string, entry *lockEntry, lease time.Duration) *gtimer.Entry {
+    interval := lease / 3
+    if interval < time.Millisecond {
+        interval = time.Millisecond
+    }
+    return gtimer.AddSingleton(interval, func() {
+        ok, err := redis.Bool(l.redis.EvalScript(
+            lockScriptRenew, []string{l.getLockKey(key)}, entry.field, entry.token, int64(lease/time.Millisecond),
+        ))
+        // 网络错误时等待下一次续约
+        if err != nil || ok {
+            return
+        }
+        l.mu.Lock()
+        if entry.renew != nil {
+            entry.renew.Close()
+        }
+        l.removeEntry(key, entry)
+        l.mu.Unlock()
+    })
+}
+
+// 删除本地记录的锁(需要在加锁状态下调用)
+func (l *Locker) removeEntry(key string, entry *lockEntry) {
+    if l.writes[key] == entry {
+        delete(l.writes, key)
+        return
+    }
+    entries := l.reads[key]
+    for i, e := range entries {
+        if e == entry {
+            entries = append(entries[: i], entries[i + 1 :]...)
+            break
+        }
+    }
+    if len(entries) == 0 {
+        delete(l.reads, key)
+    } else {
+        l.reads[key] = entries
+    }
+}
+
+// 停止自动续约并释放锁
+func (l *Locker) release(key string, entry *lockEntry) {
+    l.mu.Lock()
+    if entry.renew != nil {
+        entry.renew.Close()
+    }
+    l.mu.Unlock()
+    l.redis.EvalScript(lockScriptRelease, []string{l.getLockKey(key)}, entry.field, entry.token)
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "errors"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+)
+
+// 批量命令构建对象，所有命令在同一个链接上一次性发送，减少网络往返次数，使用示例：
+//     results, err := redis.Pipeline().Add("SET", "k", "v").Add("INCR", "n").Exec()
+// 通过Multi创建时，命令将会包含在MULTI/EXEC事务中执行。
+type Pipeline struct {
+    redis    *Redis
+    multi    bool
+    commands []pipelineCommand
+}
+
+// 批量命令中的单条命令
+type pipelineCommand struct {
+    name string
+    args []interface{}
+}
+
+// 创建批量命令构建对象
+func (r *Redis) Pipeline() *Pipeline {
+    return &Pipeline {
+        redis : r,
+    }
+}
+
+// 创建事务批量命令构建对象，所有命令在MULTI/EXEC事务中原子执行
+func (r *Redis) Multi() *Pipeline {
+    return &Pipeline {
+        redis : r,
+        multi : true,
+    }
+}
+
+// 添加命令，返回当前对象以便链式操作
+func (p *Pipeline) Add(command string, args...interface{}) *Pipeline {
+    p.commands = append(p.commands, pipelineCommand{command, args})
+    return p
+}
+
+// 当前添加的命令数量
+func (p *Pipeline) Len() int {
+    return len(p.commands)
+}
+
+// 执行所有命令并清空命令列表，按照添加顺序返回每条命令的执行结果(结果转换规则与DoVar相同)。
+// 当某条命令执行失败时，该命令对应的结果值为nil，返回的error为第一条失败命令的错误，其他命令的结果仍然有效；
+// 事务中的命令存在语法错误时整个事务不会执行，此时返回nil结果列表。
+// Cluster模式下批量命令按照节点分组发送，事务中所有命令的键名必须位于同一个槽位。
+func (p *Pipeline) Exec() ([]*gvar.Var, error) {
+    commands  := p.commands
+    p.commands = nil
+    if len(commands) == 0 {
+        return []*gvar.Var{}, nil
+    }
+    replies := []interface{}(nil)
+    err     := error(nil)
+    if p.redis.cluster != nil {
+        replies, err = p.redis.cluster.exec(commands, p.multi)
+    } else {
+        conn := p.redis.GetConn()
+        replies, err = execPipeline(conn, commands, p.multi)
+        conn.Close()
+    }
+    if err != nil {
+        return nil, err
+    }
+    results := make([]*gvar.Var, len(replies))
+    for i, reply := range replies {
+        if e, ok := reply.(error); ok {
+            if err == nil {
+                err = e
+            }
+            reply = nil
+        }
+        results[i] = gvar.New(convertReply(reply), true)
+    }
+    return results, err
+}
+
+// 在指定链接上批量执行命令，返回每条命令的原始回复数据(命令的执行错误作为回复数据返回)
+func execPipeline(conn redis.Conn, commands []pipelineCommand, multi bool) ([]interface{}, error) {
+    if multi {
+        if err := conn.Send("MULTI"); err != nil {
+            return nil, err
+        }
+    }
+    for _, command := range commands {
+        if err := conn.Send(command.name, command.args...); err != nil {
+            return nil, err
+        }
+    }
+    if multi {
+        reply, err := conn.Do("EXEC")
+        if err != nil {
+            return nil, err
+        }
+        if reply == nil {
+            return nil, errors.New("transaction aborted")
+        }
+        return redis.Values(reply, nil)
+    }
+    reply, err := conn.Do("")
+    if err != nil {
+        return nil, err
+    }
+    return reply.([]interface{}), nil
+}
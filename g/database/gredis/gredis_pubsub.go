@@ -0,0 +1,177 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "sync"
+    "time"
+)
+
+const (
+    gDEFAULT_SUBSCRIBE_BUFFER    = 100         // 订阅消息通道的缓冲大小
+    gDEFAULT_RECONNECT_INTERVAL  = time.Second // 订阅链接断开后的重连间隔
+)
+
+// 订阅消息
+type Message struct {
+    Channel string // 消息所在频道
+    Pattern string // 匹配的订阅模式(仅PSubscribe时有效)
+    Data    []byte // 消息内容
+}
+
+// 订阅对象，订阅链接断开时将会自动重连并重新订阅，直到调用Close关闭，
+// 接收到的消息通过C通道传递，Close之后C通道将会被关闭。
+// 需要注意的是断线期间发布的消息将会丢失，可以通过OnReconnect设置重连成功后的回调函数进行补偿处理。
+type Subscriber struct {
+    C           <-chan *Message   // 消息通道
+    c           chan *Message
+    redis       *Redis
+    channels    []interface{}     // 订阅的频道
+    patterns    []interface{}     // 订阅的模式
+    mu          sync.Mutex
+    psc         *redis.PubSubConn // 当前订阅链接
+    done        chan struct{}     // 关闭通知
+    closeOnce   sync.Once
+    onReconnect *gtype.Interface  // 重连成功后的回调函数
+}
+
+// 订阅指定的频道，首次订阅失败时返回错误
+func (r *Redis) Subscribe(channels...string) (*Subscriber, error) {
+    return r.newSubscriber(channels, nil)
+}
+
+// 按照模式订阅频道(例如：news.*)，首次订阅失败时返回错误
+func (r *Redis) PSubscribe(patterns...string) (*Subscriber, error) {
+    return r.newSubscriber(nil, patterns)
+}
+
+// 创建订阅对象并执行首次订阅
+func (r *Redis) newSubscriber(channels []string, patterns []string) (*Subscriber, error) {
+    c := make(chan *Message, gDEFAULT_SUBSCRIBE_BUFFER)
+    s := &Subscriber {
+        C           : c,
+        c           : c,
+        redis       : r,
+        done        : make(chan struct{}),
+        onReconnect : gtype.NewInterface(),
+    }
+    for _, v := range channels {
+        s.channels = append(s.channels, v)
+    }
+    for _, v := range patterns {
+        s.patterns = append(s.patterns, v)
+    }
+    psc, err := s.subscribe()
+    if err != nil {
+        return nil, err
+    }
+    go s.loop(psc)
+    return s, nil
+}
+
+// 设置重连成功后的回调函数
+func (s *Subscriber) OnReconnect(f func()) {
+    s.onReconnect.Set(f)
+}
+
+// 关闭订阅对象，可重复调用
+func (s *Subscriber) Close() {
+    s.closeOnce.Do(func() {
+        close(s.done)
+        s.mu.Lock()
+        if s.psc != nil {
+            s.psc.Unsubscribe()
+            s.psc.PUnsubscribe()
+        }
+        s.mu.Unlock()
+    })
+}
+
+// 创建订阅链接并订阅所有的频道及模式
+func (s *Subscriber) subscribe() (*redis.PubSubConn, error) {
+    psc := &redis.PubSubConn{Conn : s.redis.GetConn()}
+    err := error(nil)
+    if len(s.channels) > 0 {
+        err = psc.Subscribe(s.channels...)
+    }
+    if err == nil && len(s.patterns) > 0 {
+        err = psc.PSubscribe(s.patterns...)
+    }
+    if err != nil {
+        psc.Close()
+        return nil, err
+    }
+    s.mu.Lock()
+    s.psc = psc
+    s.mu.Unlock()
+    return psc, nil
+}
+
+// 判断订阅对象是否已关闭
+func (s *Subscriber) isClosed() bool {
+    select {
+        case <-s.done:
+            return true
+        default:
+            return false
+    }
+}
+
+// 接收消息，链接断开时自动重连
+func (s *Subscriber) loop(psc *redis.PubSubConn) {
+    defer close(s.c)
+    for {
+        s.receive(psc)
+        s.mu.Lock()
+        s.psc = nil
+        s.mu.Unlock()
+        psc.Close()
+        // 重连直到成功或者订阅对象关闭
+        for psc = nil; psc == nil; {
+            select {
+                case <-s.done:
+                    return
+                case <-time.After(gDEFAULT_RECONNECT_INTERVAL):
+            }
+            psc, _ = s.subscribe()
+        }
+        if s.isClosed() {
+            psc.Close()
+            return
+        }
+        if f, ok := s.onReconnect.Val().(func()); ok {
+            f()
+        }
+    }
+}
+
+// 从订阅链接接收消息，直到链接出错或者取消了所有订阅
+func (s *Subscriber) receive(psc *redis.PubSubConn) {
+    for {
+        message := (*Message)(nil)
+        switch v := psc.Receive().(type) {
+            case redis.Message:
+                message = &Message{Channel : v.Channel, Pattern : v.Pattern, Data : v.Data}
+            case redis.Subscription:
+                if v.Count == 0 {
+                    return
+                }
+                continue
+            case error:
+                return
+            default:
+                continue
+        }
+        select {
+            case s.c <- message:
+            case <-s.done:
+                return
+        }
+    }
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "strings"
+)
+
+// Lua脚本，执行时优先使用EVALSHA，服务端未缓存该脚本时使用EVAL
+type Script struct {
+    src  string // 脚本内容
+    hash string // 脚本的SHA1值
+}
+
+// 创建Lua脚本对象，通常作为包级变量创建一次，通过EvalScript执行
+func NewScript(src string) *Script {
+    sum := sha1.Sum([]byte(src))
+    return &Script {
+        src  : src,
+        hash : hex.EncodeToString(sum[:]),
+    }
+}
+
+// 获取脚本内容
+func (s *Script) Src() string {
+    return s.src
+}
+
+// 获取脚本的SHA1值
+func (s *Script) Hash() string {
+    return s.hash
+}
+
+// 执行Lua脚本，Cluster模式下根据第一个键名路由(所有键名需要位于同一个槽位)
+func (r *Redis) EvalScript(s *Script, keys []string, args...interface{}) (interface{}, error) {
+    params := make([]interface{}, 0, len(keys) + len(args) + 2)
+    params  = append(params, s.hash, len(keys))
+    for _, key := range keys {
+        params = append(params, key)
+    }
+    params = append(params, args...)
+    reply, err := r.Do("EVALSHA", params...)
+    if e, ok := err.(redis.Error); ok && strings.HasPrefix(string(e), "NOSCRIPT") {
+        params[0] = s.src
+        reply, err = r.Do("EVAL", params...)
+    }
+    return reply, err
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis
+
+import (
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "net"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    gSENTINEL_CONNECT_TIMEOUT = 3 * time.Second // 链接Sentinel节点的超时时间
+    gSENTINEL_SWITCH_CHANNEL  = "+switch-master" // Sentinel主从切换事件频道
+)
+
+// Sentinel主节点发现对象，通过Sentinel节点获取主节点地址，并订阅主从切换事件，
+// 主节点变更后，链接池中指向旧主节点的链接将会在取出时被丢弃。
+type sentinel struct {
+    config Config
+    mu     sync.Mutex        // 保证同一时间只有一个主节点发现过程
+    addrs  []string          // Sentinel节点地址列表(最近可用的节点排在最前面)
+    master *gtype.String     // 当前的主节点地址，为空时表示需要重新发现
+    psc    *redis.PubSubConn // 当前订阅主从切换事件的链接
+    pscMu  sync.Mutex
+    closed *gtype.Bool
+}
+
+// Sentinel模式下创建的链接，记录链接的主节点地址
+type sentinelConn struct {
+    redis.Conn
+    addr string
+}
+
+// 创建Sentinel主节点发现对象，并开始监听主从切换事件
+func newSentinel(config Config) *sentinel {
+    s := &sentinel {
+        config : config,
+        addrs  : append([]string(nil), config.Addrs...),
+        master : gtype.NewString(),
+        closed : gtype.NewBool(),
+    }
+    go s.watch()
+    return s
+}
+
+// 获得当前的主节点地址，没有缓存时通过Sentinel节点发现
+func (s *sentinel) getMaster() (string, error) {
+    if addr := s.master.Val(); addr != "" {
+        return addr, nil
+    }
+    return s.discover()
+}
+
+// 依次询问Sentinel节点获取主节点地址，成功的Sentinel节点将会被移动到列表最前面
+func (s *sentinel) discover() (string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    lastErr := errors.New("no sentinel address configured")
+    for i, addr := range s.addrs {
+        master, err := s.queryMaster(addr)
+        if err != nil {
+            lastErr = err
+            continue
+        }
+        s.addrs[0], s.addrs[i] = s.addrs[i], s.addrs[0]
+        s.master.Set(master)
+        return master, nil
+    }
+    return "", errors.New(fmt.Sprintf(`redis master "%s" discovery failed: %s`, s.config.MasterName, lastErr.Error()))
+}
+
+// 从指定的Sentinel节点查询主节点地址
+func (s *sentinel) queryMaster(addr string) (string, error) {
+    conn, err := s.dialSentinel(addr)
+    if err != nil {
+        return "", err
+    }
+    defer conn.Close()
+    array, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.config.MasterName))
+    if err != nil {
+        return "", err
+    }
+    if len(array) != 2 {
+        return "", errors.New(fmt.Sprintf(`redis master "%s" not found on sentinel "%s"`, s.config.MasterName, addr))
+    }
+    return net.JoinHostPort(array[0], array[1]), nil
+}
+
+// 链接Sentinel节点
+func (s *sentinel) dialSentinel(addr string) (redis.Conn, error) {
+    options := append(s.config.getDialOptions(false), redis.DialConnectTimeout(gSENTINEL_CONNECT_TIMEOUT))
+    return redis.Dial("tcp", addr, options...)
+}
+
+// 链接池的链接创建方法，链接到当前的主节点，并校验节点角色(防止链接到故障转移过程中已降级的节点)
+func (s *sentinel) dial() (redis.Conn, error) {
+    addr, err := s.getMaster()
+    if err != nil {
+        return nil, err
+    }
+    conn, err := redis.Dial("tcp", addr, s.config.getDialOptions(true)...)
+    if err != nil {
+        // 主节点不可用时，下次创建链接重新发现主节点
+        s.master.Set("")
+        return nil, err
+    }
+    if values, err := redis.Values(conn.Do("ROLE")); err != nil || len(values) == 0 || gconv.String(values[0]) != "master" {
+        conn.Close()
+        s.master.Set("")
+        return nil, errors.New(fmt.Sprintf(`redis node "%s" is not master of "%s"`, addr, s.config.MasterName))
+    }
+    return &sentinelConn{Conn : conn, addr : addr}, nil
+}
+
+// 链接池取出链接时的检测方法，主节点变更后丢弃指向旧主节点的链接
+func (s *sentinel) testOnBorrow(c redis.Conn, t time.Time) error {
+    if conn, ok := c.(*sentinelConn); ok && conn.addr != s.master.Val() {
+        return errors.New("redis master changed")
+    }
+    return nil
+}
+
+// 订阅Sentinel节点的主从切换事件，链接断开时切换Sentinel节点重连，直到关闭
+func (s *sentinel) watch() {
+    for !s.closed.Val() {
+        s.mu.Lock()
+        addrs := append([]string(nil), s.addrs...)
+        s.mu.Unlock()
+        for _, addr := range addrs {
+            if s.closed.Val() {
+                return
+            }
+            conn, err := s.dialSentinel(addr)
+            if err != nil {
+                continue
+            }
+            psc := &redis.PubSubConn{Conn : conn}
+            if err := psc.Subscribe(gSENTINEL_SWITCH_CHANNEL); err != nil {
+                conn.Close()
+                continue
+            }
+            s.pscMu.Lock()
+            s.psc = psc
+            if s.closed.Val() {
+                psc.Unsubscribe()
+            }
+            s.pscMu.Unlock()
+            // 订阅中断期间可能错过了主从切换事件，订阅成功后重新发现主节点
+            s.discover()
+            s.receive(psc)
+            s.pscMu.Lock()
+            s.psc = nil
+            s.pscMu.Unlock()
+            conn.Close()
+            break
+        }
+        if !s.closed.Val() {
+            time.Sleep(gDEFAULT_RECONNECT_INTERVAL)
+        }
+    }
+}
+
+// 接收主从切换事件，事件内容格式为：<master name> <old ip> <old port> <new ip> <new port>
+func (s *sentinel) receive(psc *redis.PubSubConn) {
+    for {
+        switch v := psc.Receive().(type) {
+            case redis.Message:
+                array := strings.Fields(string(v.Data))
+                if len(array) == 5 && array[0] == s.config.MasterName {
+                    s.master.Set(net.JoinHostPort(array[3], array[4]))
+                }
+            case redis.Subscription:
+                if v.Count == 0 {
+                    return
+                }
+            case error:
+                return
+        }
+    }
+}
+
+// 关闭主从切换事件的订阅
+func (s *sentinel) close() {
+    s.pscMu.Lock()
+    s.closed.Set(true)
+    if s.psc != nil {
+        s.psc.Unsubscribe()
+    }
+    s.pscMu.Unlock()
+}
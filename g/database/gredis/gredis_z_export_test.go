@@ -0,0 +1,20 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// 导出内部变量用于外部测试包
+
+package gredis
+
+// 分布式锁使用的Lua脚本内容
+var (
+    LockScriptAcquire     = lockScriptAcquire.Src()
+    LockScriptAcquireRead = lockScriptAcquireRead.Src()
+    LockScriptRelease     = lockScriptRelease.Src()
+    LockScriptRenew       = lockScriptRenew.Src()
+)
+
+// 写锁在哈希表中的字段名
+const LockWriteField = gLOCK_WRITE_FIELD
@@ -0,0 +1,74 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// 使用真实的Redis服务执行分布式锁的Lua脚本，通过环境变量指定Redis服务地址(未指定时跳过)：
+//     GF_REDIS_ADDR=127.0.0.1:6379 GF_REDIS_PASS=xxx go test -run Real
+
+package gredis_test
+
+import (
+    "fmt"
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gtest"
+    "net"
+    "os"
+    "testing"
+    "time"
+)
+
+// 根据环境变量创建连接真实Redis服务的客户端，未设置GF_REDIS_ADDR时跳过测试
+func newRealRedis(t *testing.T) *gredis.Redis {
+    addr := os.Getenv("GF_REDIS_ADDR")
+    if addr == "" {
+        t.Skip("GF_REDIS_ADDR is not set, skip tests against real redis server")
+    }
+    host, port, err := net.SplitHostPort(addr)
+    if err != nil {
+        t.Fatal(err)
+    }
+    redis := gredis.New(gredis.Config {
+        Host : host,
+        Port : gconv.Int(port),
+        Pass : os.Getenv("GF_REDIS_PASS"),
+    })
+    if _, err := redis.Do("PING"); err != nil {
+        redis.Close()
+        t.Fatal(err)
+    }
+    return redis
+}
+
+func TestLocker_Real(t *testing.T) {
+    redis := newRealRedis(t)
+    defer redis.Close()
+    // 每次测试使用不同的前缀，测试结束后删除所有相关键名(包括不过期的fencing token计数器)
+    prefix := fmt.Sprintf("gredis:test:%d:", time.Now().UnixNano())
+    defer func() {
+        if keys, err := redis.Scan(prefix + "*", 100); err == nil && len(keys) > 0 {
+            redis.Del(keys...)
+        }
+    }()
+    tests := []struct {
+        name string
+        f    func(redis *gredis.Redis, prefix string)
+    }{
+        {"TryLock_Unlock", testLockerTryLockUnlock},
+        {"Lock",           testLockerLock},
+        {"Expire",         testLockerExpire},
+        {"Renew",          testLockerRenew},
+        {"RLock",          testLockerRLock},
+    }
+    for i, test := range tests {
+        f := test.f
+        p := fmt.Sprintf("%s%d:", prefix, i)
+        t.Run(test.name, func(t *testing.T) {
+            gtest.Case(t, func() {
+                f(redis, p)
+            })
+        })
+    }
+}
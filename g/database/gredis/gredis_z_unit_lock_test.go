@@ -0,0 +1,181 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis_test
+
+import (
+    "gitee.com/johng/gf/g/container/garray"
+    "fmt"
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/database/gredis/gredistest"
+    "gitee.com/johng/gf/g/util/gtest"
+    "strconv"
+    "testing"
+    "time"
+)
+
+// 模拟服务端不包含Lua解释器，这里通过Go语言实现与分布式锁Lua脚本等价的操作，
+// 真实Lua脚本的测试见TestLocker_Real(需要通过GF_REDIS_ADDR指定Redis服务地址)。
+func registerLockScripts(server *gredistest.FakeServer) {
+    type call = func(command string, args...string) interface{}
+    // 当键名的剩余过期时间小于ttl(毫秒)时延长过期时间
+    extendExpire := func(call call, key string, ttl string) {
+        pttl, _ := strconv.ParseInt(fmt.Sprintf("%v", call("PTTL", key)), 10, 64)
+        n, err  := strconv.ParseInt(ttl, 10, 64)
+        if err == nil && pttl < n {
+            call("PEXPIRE", key, ttl)
+        }
+    }
+    server.RegisterScript(gredis.LockScriptAcquire, func(call call, keys []string, args []string) interface{} {
+        if call("EXISTS", keys[0]) == 1 {
+            return 0
+        }
+        call("HSET", keys[0], gredis.LockWriteField, args[0])
+        call("PEXPIRE", keys[0], args[1])
+        return call("INCR", keys[1])
+    })
+    server.RegisterScript(gredis.LockScriptAcquireRead, func(call call, keys []string, args []string) interface{} {
+        if call("HEXISTS", keys[0], gredis.LockWriteField) == 1 {
+            return 0
+        }
+        call("HSET", keys[0], args[0], args[1])
+        extendExpire(call, keys[0], args[2])
+        return 1
+    })
+    server.RegisterScript(gredis.LockScriptRelease, func(call call, keys []string, args []string) interface{} {
+        if call("HGET", keys[0], args[0]) != args[1] {
+            return 0
+        }
+        // 哈希表为空时将会自动删除
+        call("HDEL", keys[0], args[0])
+        return 1
+    })
+    server.RegisterScript(gredis.LockScriptRenew, func(call call, keys []string, args []string) interface{} {
+        if call("HGET", keys[0], args[0]) != args[1] {
+            return 0
+        }
+        extendExpire(call, keys[0], args[2])
+        return 1
+    })
+}
+
+// 使用模拟服务端执行测试方法
+func runFakeLockerTest(t *testing.T, f func(redis *gredis.Redis, prefix string)) {
+    server, redis := newFakeRedis()
+    defer server.Close()
+    defer redis.Close()
+    gtest.Case(t, func() {
+        f(redis, "gredis:lock:")
+    })
+}
+
+func TestLocker_TryLock_Unlock(t *testing.T) {
+    runFakeLockerTest(t, testLockerTryLockUnlock)
+}
+
+func TestLocker_Lock(t *testing.T) {
+    runFakeLockerTest(t, testLockerLock)
+}
+
+func TestLocker_Expire(t *testing.T) {
+    runFakeLockerTest(t, testLockerExpire)
+}
+
+func TestLocker_Renew(t *testing.T) {
+    runFakeLockerTest(t, testLockerRenew)
+}
+
+func TestLocker_RLock(t *testing.T) {
+    runFakeLockerTest(t, testLockerRLock)
+}
+
+func testLockerTryLockUnlock(redis *gredis.Redis, prefix string) {
+    l1 := gredis.NewLocker(redis, prefix)
+    l2 := gredis.NewLocker(redis, prefix)
+    gtest.Assert(l1.TryLock("test"), true)
+    gtest.Assert(l1.IsLocked("test"), true)
+    gtest.Assert(l1.Fence("test"), 1)
+    gtest.Assert(l2.TryLock("test"), false)
+    // 不是锁的持有者，不能解锁
+    l2.Unlock("test")
+    gtest.Assert(l2.TryLock("test"), false)
+
+    l1.Unlock("test")
+    gtest.Assert(l1.IsLocked("test"), false)
+    gtest.Assert(l1.Fence("test"), 0)
+    gtest.Assert(l2.TryLock("test"), true)
+    gtest.Assert(l2.Fence("test"), 2)
+    l2.Unlock("test")
+}
+
+func testLockerLock(redis *gredis.Redis, prefix string) {
+    array := garray.New(0, 0)
+    go func() {
+        l := gredis.NewLocker(redis, prefix)
+        l.Lock("test")
+        array.Append(1)
+        time.Sleep(300*time.Millisecond)
+        l.Unlock("test")
+    }()
+    go func() {
+        time.Sleep(100*time.Millisecond)
+        l := gredis.NewLocker(redis, prefix)
+        l.Lock("test")
+        array.Append(1)
+        l.Unlock("test")
+    }()
+    time.Sleep(200*time.Millisecond)
+    gtest.Assert(array.Len(), 1)
+    time.Sleep(300*time.Millisecond)
+    gtest.Assert(array.Len(), 2)
+}
+
+func testLockerExpire(redis *gredis.Redis, prefix string) {
+    l1 := gredis.NewLocker(redis, prefix)
+    l2 := gredis.NewLocker(redis, prefix)
+    gtest.Assert(l1.TryLock("test", 100*time.Millisecond), true)
+    gtest.Assert(l2.TryLock("test"), false)
+    time.Sleep(200*time.Millisecond)
+    gtest.Assert(l2.TryLock("test"), true)
+    // 过期后旧的持有者解锁无效
+    l1.Unlock("test")
+    gtest.Assert(l1.TryLock("test"), false)
+    l2.Unlock("test")
+}
+
+func testLockerRenew(redis *gredis.Redis, prefix string) {
+    l1 := gredis.NewLocker(redis, prefix)
+    l2 := gredis.NewLocker(redis, prefix)
+    l1.SetLease(150*time.Millisecond)
+    gtest.Assert(l1.TryLock("test"), true)
+    // 持有期间自动续约
+    time.Sleep(500*time.Millisecond)
+    gtest.Assert(l2.TryLock("test"), false)
+    gtest.Assert(l1.IsLocked("test"), true)
+
+    // 锁被删除后续约失败，不再持有该锁
+    redis.Del(prefix + "{test}")
+    time.Sleep(200*time.Millisecond)
+    gtest.Assert(l1.IsLocked("test"), false)
+    gtest.Assert(l2.TryLock("test"), true)
+    l2.Unlock("test")
+}
+
+func testLockerRLock(redis *gredis.Redis, prefix string) {
+    l1 := gredis.NewLocker(redis, prefix)
+    l2 := gredis.NewLocker(redis, prefix)
+    gtest.Assert(l1.TryRLock("test"), true)
+    gtest.Assert(l2.TryRLock("test"), true)
+    gtest.Assert(l2.TryLock("test"), false)
+    l1.RUnlock("test")
+    gtest.Assert(l2.TryLock("test"), false)
+    l2.RUnlock("test")
+    gtest.Assert(l2.TryLock("test"), true)
+    gtest.Assert(l1.TryRLock("test"), false)
+    l2.Unlock("test")
+    gtest.Assert(l1.TryRLock("test"), true)
+    l1.RUnlock("test")
+}
@@ -0,0 +1,336 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredis_test
+
+import (
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/database/gredis/gredistest"
+    "gitee.com/johng/gf/g/util/gtest"
+    "testing"
+    "time"
+)
+
+func newFakeRedis() (*gredistest.FakeServer, *gredis.Redis) {
+    server, err := gredistest.NewFakeServer()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    registerLockScripts(server)
+    return server, gredis.New(server.Config())
+}
+
+func TestRedis_Command(t *testing.T) {
+    server, redis := newFakeRedis()
+    defer server.Close()
+    defer redis.Close()
+    gtest.Case(t, func() {
+        v, err := redis.Get("k")
+        gtest.Assert(err, nil)
+        gtest.Assert(v.IsNil(), true)
+
+        gtest.Assert(redis.Set("k", 100, time.Minute), nil)
+        v, _ = redis.Get("k")
+        gtest.Assert(v.Int(), 100)
+        ttl, _ := redis.TTL("k")
+        gtest.Assert(ttl > 0 && ttl <= time.Minute, true)
+
+        ok, _ := redis.SetNX("k", 200)
+        gtest.Assert(ok, false)
+        n, _ := redis.Incr("k", 5)
+        gtest.Assert(n, 105)
+
+        v, _ = redis.MGet("k", "none")
+        gtest.Assert(v.Interfaces(), []interface{}{"105", nil})
+
+        count, _ := redis.Del("k", "none")
+        gtest.Assert(count, 1)
+        ok, _ = redis.Exists("k")
+        gtest.Assert(ok, false)
+    })
+}
+
+func TestRedis_Hash(t *testing.T) {
+    type User struct {
+        Id   int
+        Name string
+    }
+    server, redis := newFakeRedis()
+    defer server.Close()
+    defer redis.Close()
+    gtest.Case(t, func() {
+        gtest.Assert(redis.HMSet("user", User{Id : 1, Name : "john"}), nil)
+        v, _ := redis.HGet("user", "Name")
+        gtest.Assert(v.String(), "john")
+
+        v, err := redis.HGetAll("user")
+        gtest.Assert(err, nil)
+        user := new(User)
+        gtest.Assert(v.Struct(user), nil)
+        gtest.Assert(user.Id, 1)
+        gtest.Assert(user.Name, "john")
+
+        n, _ := redis.HDel("user", "Name", "None")
+        gtest.Assert(n, 1)
+    })
+}
+
+func TestRedis_ListAndSortedSet(t *testing.T) {
+    server, redis := newFakeRedis()
+    defer server.Close()
+    defer redis.Close()
+    gtest.Case(t, func() {
+        redis.RPush("list", 1, 2, 3)
+        redis.LPush("list", 0)
+        v, _ := redis.LRange("list", 0, -1)
+        gtest.Assert(v.Ints(), []int{0, 1, 2, 3})
+        v, _ = redis.RPop("list")
+        gtest.Assert(v.Int(), 3)
+        n, _ := redis.LLen("list")
+        gtest.Assert(n, 3)
+
+        redis.ZAdd("rank", 3, "c")
+        redis.ZAdd("rank", 1, "a")
+        redis.ZAdd("rank", 2.5, "b")
+        v, _ = redis.ZRange("rank", 0, -1)
+        gtest.Assert(v.Strings(), []string{"a", "b", "c"})
+        members, err := redis.ZRangeWithScores("rank", 0, 1)
+        gtest.Assert(err, nil)
+        gtest.Assert(len(members), 2)
+        gtest.Assert(members[1].Member.String(), "b")
+        gtest.Assert(members[1].Score, 2.5)
+        members, _ = redis.ZRangeByScore("rank", 2, "+inf")
+        gtest.Assert(len(members), 2)
+        gtest.Assert(members[0].Member.String(), "b")
+    })
+}
+
+func TestRedis_Pipeline(t *testing.T) {
+    server, redis := newFakeRedis()
+    defer server.Close()
+    defer redis.Close()
+    gtest.Case(t, func() {
+        results, err := redis.Pipeline().Add("SET", "n", 1).Add("INCR", "n").Add("GET", "n").Exec()
+        gtest.Assert(err, nil)
+        gtest.Assert(len(results), 3)
+        gtest.Assert(results[0].String(), "OK")
+        gtest.Assert(results[1].Int(), 2)
+        gtest.Assert(results[2].Int(), 2)
+
+        // 单条命令出错不影响其他命令的结果
+        results, err = redis.Pipeline().Add("HGET", "n", "field").Add("INCR", "n").Exec()
+        gtest.AssertNE(err, nil)
+        gtest.Assert(results[0].IsNil(), true)
+        gtest.Assert(results[1].Int(), 3)
+
+        multi := redis.Multi().Add("INCR", "n").Add("INCR", "n")
+        gtest.Assert(multi.Len(), 2)
+        results, err = multi.Exec()
+        gtest.Assert(err, nil)
+        gtest.Assert(results[0].Int(), 4)
+        gtest.Assert(results[1].Int(), 5)
+        gtest.Assert(multi.Len(), 0)
+    })
+}
+
+func TestRedis_Subscribe(t *testing.T) {
+    server, redis := newFakeRedis()
+    defer server.Close()
+    defer redis.Close()
+    gtest.Case(t, func() {
+        s, err := redis.Subscribe("news")
+        gtest.Assert(err, nil)
+        p, err := redis.PSubscribe("news.*")
+        gtest.Assert(err, nil)
+
+        redis.Do("PUBLISH", "news", "hello")
+        redis.Do("PUBLISH", "news.sport", "goal")
+        message := <-s.C
+        gtest.Assert(message.Channel, "news")
+        gtest.Assert(string(message.Data), "hello")
+        message = <-p.C
+        gtest.Assert(message.Channel, "news.sport")
+        gtest.Assert(message.Pattern, "news.*")
+        gtest.Assert(string(message.Data), "goal")
+
+        // 链接断开后自动重连并重新订阅
+        reconnected := make(chan struct{}, 1)
+        s.OnReconnect(func() {
+            reconnected <- struct{}{}
+        })
+        server.CloseClients()
+        select {
+            case <-reconnected:
+            case <-time.After(5*time.Second):
+                gtest.Fatal("reconnect timeout")
+        }
+        redis.Do("PUBLISH", "news", "again")
+        message = <-s.C
+        gtest.Assert(string(message.Data), "again")
+
+        s.Close()
+        p.Close()
+        _, ok := <-s.C
+        gtest.Assert(ok, false)
+    })
+}
+
+func TestRedis_Sentinel(t *testing.T) {
+    master, _   := newFakeRedis()
+    slave, _    := newFakeRedis()
+    sentinel, _ := newFakeRedis()
+    defer master.Close()
+    defer slave.Close()
+    defer sentinel.Close()
+    sentinel.SetSentinelMaster("mymaster", master.Addr())
+    gtest.Case(t, func() {
+        redis := gredis.New(gredis.Config {
+            MasterName : "mymaster",
+            Addrs      : []string{"127.0.0.1:1", sentinel.Addr()},
+        })
+        defer redis.Close()
+        gtest.Assert(redis.Set("k", "v1"), nil)
+        v, _ := gredis.New(master.Config()).Get("k")
+        gtest.Assert(v.String(), "v1")
+
+        // 主从切换后写入新的主节点
+        master.SetRole("slave")
+        sentinel.SetSentinelMaster("mymaster", slave.Addr())
+        newMaster := gredis.New(slave.Config())
+        for i := 0; i < 50; i++ {
+            gtest.Assert(redis.Set("k", "v2"), nil)
+            if v, _ := newMaster.Get("k"); v.String() == "v2" {
+                break
+            }
+            time.Sleep(100*time.Millisecond)
+        }
+        v, _ = newMaster.Get("k")
+        gtest.Assert(v.String(), "v2")
+    })
+}
+
+func TestRedis_Cluster(t *testing.T) {
+    // 键名foo的槽位为12182，bar的槽位为5061
+    a, _ := newFakeRedis()
+    b, _ := newFakeRedis()
+    defer a.Close()
+    defer b.Close()
+    split := []gredistest.FakeSlotRange {
+        {Start : 0,    End : 8191,  Addr : a.Addr()},
+        {Start : 8192, End : 16383, Addr : b.Addr()},
+    }
+    a.SetClusterSlots(split...)
+    b.SetClusterSlots(split...)
+    gtest.Case(t, func() {
+        redis := gredis.New(gredis.Config {
+            Cluster : true,
+            Addrs   : []string{a.Addr()},
+        })
+        defer redis.Close()
+        nodeA := gredis.New(a.Config())
+        nodeB := gredis.New(b.Config())
+
+        // 按照槽位路由
+        gtest.Assert(redis.Set("foo", 1), nil)
+        gtest.Assert(redis.Set("bar", 2), nil)
+        v, _ := nodeB.Get("foo")
+        gtest.Assert(v.Int(), 1)
+        v, _ = nodeA.Get("bar")
+        gtest.Assert(v.Int(), 2)
+        _, err := nodeA.Get("foo")
+        gtest.AssertNE(err, nil)
+
+        // MOVED重定向
+        all := gredistest.FakeSlotRange{Start : 0, End : 16383, Addr : a.Addr()}
+        a.SetClusterSlots(all)
+        b.SetClusterSlots(all)
+        gtest.Assert(redis.Set("foo", 3), nil)
+        v, _ = nodeA.Get("foo")
+        gtest.Assert(v.Int(), 3)
+
+        // ASK重定向
+        a.SetClusterAsk(12182, b.Addr())
+        gtest.Assert(redis.Set("{foo}.new", 4), nil)
+        results, _ := nodeB.Pipeline().Add("ASKING").Add("GET", "{foo}.new").Exec()
+        gtest.Assert(results[1].Int(), 4)
+        v, _ = redis.Get("foo")
+        gtest.Assert(v.Int(), 3)
+        a.SetClusterAsk(12182, "")
+
+        // 批量命令按照节点分组，重定向的命令单独重试
+        a.SetClusterSlots(split...)
+        b.SetClusterSlots(split...)
+        results, err = redis.Pipeline().Add("SET", "foo", 5).Add("GET", "bar").Add("GET", "foo").Exec()
+        gtest.Assert(err, nil)
+        gtest.Assert(results[1].Int(), 2)
+        gtest.Assert(results[2].Int(), 5)
+
+        // 事务中的键名必须位于同一个槽位
+        _, err = redis.Multi().Add("SET", "foo", 1).Add("SET", "bar", 1).Exec()
+        gtest.AssertNE(err, nil)
+        results, err = redis.Multi().Add("SET", "{foo}.a", 1).Add("INCR", "{foo}.a").Exec()
+        gtest.Assert(err, nil)
+        gtest.Assert(results[1].Int(), 2)
+    })
+}
+
+func TestRedis_ClusterMultiNode(t *testing.T) {
+    // 键名foo的槽位为12182，bar的槽位为5061
+    a, _ := newFakeRedis()
+    b, _ := newFakeRedis()
+    defer a.Close()
+    defer b.Close()
+    split := []gredistest.FakeSlotRange {
+        {Start : 0,    End : 8191,  Addr : a.Addr()},
+        {Start : 8192, End : 16383, Addr : b.Addr()},
+    }
+    a.SetClusterSlots(split...)
+    b.SetClusterSlots(split...)
+    gtest.Case(t, func() {
+        redis := gredis.New(gredis.Config {
+            Cluster : true,
+            Addrs   : []string{a.Addr()},
+        })
+        defer redis.Close()
+
+        // 多键命令按照槽位拆分执行
+        _, err := redis.Do("MSET", "foo", 1, "bar", 2, "{foo}.a", 3)
+        gtest.Assert(err, nil)
+        v, err := redis.MGet("bar", "foo", "none", "{foo}.a")
+        gtest.Assert(err, nil)
+        gtest.Assert(v.Strings(), []string{"2", "1", "", "3"})
+        n, err := redis.DoVar("EXISTS", "foo", "bar", "none")
+        gtest.Assert(err, nil)
+        gtest.Assert(n.Int(), 2)
+
+        // 作用于整个数据库的命令在所有主节点上执行并合并结果
+        size, err := redis.DoVar("DBSIZE")
+        gtest.Assert(err, nil)
+        gtest.Assert(size.Int(), 3)
+        keys, err := redis.DoVar("KEYS", "*")
+        gtest.Assert(err, nil)
+        gtest.Assert(len(keys.Strings()), 3)
+        array, err := redis.Scan("*", 10)
+        gtest.Assert(err, nil)
+        gtest.Assert(len(array), 3)
+        _, err = redis.Do("SCAN", 0)
+        gtest.AssertNE(err, nil)
+
+        // 批量命令中的多键命令同样按照槽位拆分执行
+        results, err := redis.Pipeline().Add("MGET", "foo", "bar").Add("DBSIZE").Exec()
+        gtest.Assert(err, nil)
+        gtest.Assert(results[0].Strings(), []string{"1", "2"})
+        gtest.Assert(results[1].Int(), 3)
+
+        deleted, err := redis.Del("foo", "bar")
+        gtest.Assert(err, nil)
+        gtest.Assert(deleted, 2)
+        _, err = redis.Do("FLUSHDB")
+        gtest.Assert(err, nil)
+        size, _ = redis.DoVar("DBSIZE")
+        gtest.Assert(size.Int(), 0)
+    })
+}
@@ -0,0 +1,1189 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// Package gredistest provides an in-process fake Redis server for unit testing.
+//
+// 用于单元测试的进程内Redis模拟服务端。
+package gredistest
+
+import (
+    "bufio"
+    "crypto/sha1"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/database/gredis"
+    "io"
+    "net"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 进程内的Redis模拟服务端，实现了RESP协议及常用的Redis命令(字符串、哈希、列表、集合、有序集合、
+// 过期时间、事务、发布/订阅)，并且可以模拟Sentinel节点及Cluster节点，用于在没有Redis服务的环境下进行单元测试，使用示例：
+//     server, _ := gredistest.NewFakeServer()
+//     defer server.Close()
+//     redis := gredis.New(server.Config())
+// 需要注意的是该模拟服务端只用于测试，数据只保存在内存中，不包含Lua解释器，
+// 需要执行的Lua脚本需要通过RegisterScript注册等价的Go语言实现。
+type FakeServer struct {
+    mu       sync.Mutex
+    listener net.Listener
+    dbs      map[int]map[string]*fakeItem // 数据库索引对应的数据
+    conns    map[*fakeConn]struct{}       // 当前的客户端链接
+    closed   bool                         // 是否已关闭
+    role     string                       // 节点角色(ROLE命令)，默认为master
+    masters  map[string]string            // (Sentinel)主节点名称对应的主节点地址
+    slots    []FakeSlotRange              // (Cluster)槽位分配，为空时表示非集群模式
+    asks     map[int]string               // (Cluster)正在迁移的槽位对应的目标节点地址
+    scripts  map[string]ScriptFunc        // 脚本SHA1值对应的Go语言实现
+    loaded   map[string]struct{}          // 通过EVAL缓存的脚本SHA1值
+}
+
+// Lua脚本的Go语言实现，call用于执行Redis命令(对应redis.call)，keys及args对应脚本中的KEYS及ARGV，
+// 方法在服务端加锁状态下执行，因此与Lua脚本一样是原子操作。
+type ScriptFunc func(call func(command string, args...string) interface{}, keys []string, args []string) interface{}
+
+// 模拟服务端中的数据项
+type fakeItem struct {
+    value  interface{} // string, map[string]string, []string, map[string]struct{}, map[string]float64
+    expire time.Time   // 过期时间，零值表示不过期
+}
+
+// 模拟服务端的客户端链接
+type fakeConn struct {
+    server   *FakeServer
+    conn     net.Conn
+    writer   *bufio.Writer
+    writeMu  sync.Mutex
+    db       int
+    asking   bool                // (Cluster)上一条命令是否为ASKING
+    multi    [][]string          // MULTI事务中缓存的命令，nil表示不在事务中
+    channels map[string]struct{} // 订阅的频道
+    patterns map[string]struct{} // 订阅的模式
+}
+
+// Redis简单字符串类型回复
+type fakeStatus string
+
+// Redis错误类型回复
+type fakeError string
+
+const (
+    gFAKE_WRONG_TYPE = fakeError("WRONGTYPE Operation against a key holding the wrong kind of value")
+    gFAKE_NOT_INT    = fakeError("ERR value is not an integer or out of range")
+    gFAKE_SYNTAX     = fakeError("ERR syntax error")
+)
+
+// 创建并启动Redis模拟服务端，监听本地随机端口
+func NewFakeServer() (*FakeServer, error) {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        return nil, err
+    }
+    s := &FakeServer {
+        listener : listener,
+        dbs      : make(map[int]map[string]*fakeItem),
+        conns    : make(map[*fakeConn]struct{}),
+        role     : "master",
+        masters  : make(map[string]string),
+        asks     : make(map[int]string),
+        scripts  : make(map[string]ScriptFunc),
+        loaded   : make(map[string]struct{}),
+    }
+    go s.serve()
+    return s, nil
+}
+
+// 模拟服务端的监听地址
+func (s *FakeServer) Addr() string {
+    return s.listener.Addr().String()
+}
+
+// 获得连接模拟服务端的客户端配置
+func (s *FakeServer) Config() gredis.Config {
+    addr := s.listener.Addr().(*net.TCPAddr)
+    return gredis.Config {
+        Host : addr.IP.String(),
+        Port : addr.Port,
+    }
+}
+
+// 注册Lua脚本src对应的Go语言实现，注册后可通过EVAL及EVALSHA执行该脚本
+func (s *FakeServer) RegisterScript(src string, f ScriptFunc) {
+    sum := sha1.Sum([]byte(src))
+    s.mu.Lock()
+    s.scripts[hex.EncodeToString(sum[:])] = f
+    s.mu.Unlock()
+}
+
+// 关闭模拟服务端，并断开所有的客户端链接
+func (s *FakeServer) Close() error {
+    s.mu.Lock()
+    s.closed = true
+    for c := range s.conns {
+        c.conn.Close()
+    }
+    s.mu.Unlock()
+    return s.listener.Close()
+}
+
+// 断开所有的客户端链接(服务端继续运行)，用于测试客户端的断线重连
+func (s *FakeServer) CloseClients() {
+    s.mu.Lock()
+    for c := range s.conns {
+        c.conn.Close()
+    }
+    s.mu.Unlock()
+}
+
+// 清空所有数据
+func (s *FakeServer) FlushAll() {
+    s.mu.Lock()
+    s.dbs = make(map[int]map[string]*fakeItem)
+    s.mu.Unlock()
+}
+
+// 接收客户端链接
+func (s *FakeServer) serve() {
+    for {
+        conn, err := s.listener.Accept()
+        if err != nil {
+            return
+        }
+        c := &fakeConn {
+            server   : s,
+            conn     : conn,
+            writer   : bufio.NewWriter(conn),
+            channels : make(map[string]struct{}),
+            patterns : make(map[string]struct{}),
+        }
+        s.mu.Lock()
+        if s.closed {
+            s.mu.Unlock()
+            conn.Close()
+            return
+        }
+        s.conns[c] = struct{}{}
+        s.mu.Unlock()
+        go c.serve()
+    }
+}
+
+// 处理客户端链接的命令请求
+func (c *fakeConn) serve() {
+    defer func() {
+        c.server.mu.Lock()
+        delete(c.server.conns, c)
+        c.server.mu.Unlock()
+        c.conn.Close()
+    }()
+    reader := bufio.NewReader(c.conn)
+    for {
+        args, err := readFakeCommand(reader)
+        if err != nil {
+            return
+        }
+        if len(args) == 0 {
+            continue
+        }
+        c.handle(args)
+    }
+}
+
+// 读取一条RESP协议的命令请求(数组形式或者内联形式)
+func readFakeCommand(reader *bufio.Reader) ([]string, error) {
+    line, err := reader.ReadString('\n')
+    if err != nil {
+        return nil, err
+    }
+    line = strings.TrimRight(line, "\r\n")
+    if !strings.HasPrefix(line, "*") {
+        return strings.Fields(line), nil
+    }
+    n, err := strconv.Atoi(line[1:])
+    if err != nil {
+        return nil, err
+    }
+    args := make([]string, n)
+    for i := 0; i < n; i++ {
+        line, err = reader.ReadString('\n')
+        if err != nil {
+            return nil, err
+        }
+        if !strings.HasPrefix(line, "$") {
+            return nil, errors.New("invalid bulk string")
+        }
+        size, err := strconv.Atoi(strings.TrimRight(line[1:], "\r\n"))
+        if err != nil {
+            return nil, err
+        }
+        buffer := make([]byte, size + 2)
+        if _, err = io.ReadFull(reader, buffer); err != nil {
+            return nil, err
+        }
+        args[i] = string(buffer[:size])
+    }
+    return args, nil
+}
+
+// 写入回复数据
+func (c *fakeConn) write(reply interface{}) {
+    c.writeMu.Lock()
+    writeFakeReply(c.writer, reply)
+    c.writer.Flush()
+    c.writeMu.Unlock()
+}
+
+// 按照RESP协议编码回复数据
+func writeFakeReply(w *bufio.Writer, reply interface{}) {
+    switch v := reply.(type) {
+        case nil:
+            w.WriteString("$-1\r\n")
+        case fakeStatus:
+            w.WriteString("+" + string(v) + "\r\n")
+        case fakeError:
+            w.WriteString("-" + string(v) + "\r\n")
+        case int:
+            w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+        case int64:
+            w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+        case string:
+            w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+        case []string:
+            w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+            for _, item := range v {
+                writeFakeReply(w, item)
+            }
+        case []interface{}:
+            if v == nil {
+                w.WriteString("*-1\r\n")
+                return
+            }
+            w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+            for _, item := range v {
+                writeFakeReply(w, item)
+            }
+        default:
+            writeFakeReply(w, fmt.Sprintf("%v", v))
+    }
+}
+
+// 处理一条命令
+func (c *fakeConn) handle(args []string) {
+    command := strings.ToUpper(args[0])
+    // 订阅模式下只能执行订阅相关的命令
+    switch command {
+        case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+            c.handleSubscribe(command, args[1:])
+            return
+        case "ROLE", "SENTINEL", "CLUSTER", "ASKING":
+            c.write(c.handleCluster(command, args[1:]))
+            return
+    }
+    // 集群模式下检查键名所在的槽位
+    if reply := c.checkSlot(command, args[1:]); reply != nil {
+        c.write(reply)
+        return
+    }
+    if c.multi != nil {
+        switch command {
+            case "EXEC":
+                commands := c.multi
+                c.multi   = nil
+                replies  := make([]interface{}, len(commands))
+                c.server.mu.Lock()
+                for i, cmd := range commands {
+                    replies[i] = c.execute(strings.ToUpper(cmd[0]), cmd[1:])
+                }
+                c.server.mu.Unlock()
+                c.write(replies)
+            case "DISCARD":
+                c.multi = nil
+                c.write(fakeStatus("OK"))
+            case "MULTI":
+                c.write(fakeError("ERR MULTI calls can not be nested"))
+            default:
+                c.multi = append(c.multi, args)
+                c.write(fakeStatus("QUEUED"))
+        }
+        return
+    }
+    switch command {
+        case "MULTI":
+            c.multi = make([][]string, 0)
+            c.write(fakeStatus("OK"))
+        case "EXEC", "DISCARD":
+            c.write(fakeError("ERR " + command + " without MULTI"))
+        case "PUBLISH":
+            if len(args) != 3 {
+                c.write(fakeError("ERR wrong number of arguments for 'publish' command"))
+                return
+            }
+            c.write(c.server.publish(args[1], args[2]))
+        default:
+            c.server.mu.Lock()
+            reply := c.execute(command, args[1:])
+            c.server.mu.Unlock()
+            c.write(reply)
+    }
+}
+
+// 处理订阅相关命令
+func (c *fakeConn) handleSubscribe(command string, names []string) {
+    c.server.mu.Lock()
+    defer c.server.mu.Unlock()
+    set, kind := c.channels, strings.ToLower(command)
+    if command == "PSUBSCRIBE" || command == "PUNSUBSCRIBE" {
+        set = c.patterns
+    }
+    if len(names) == 0 && strings.HasSuffix(command, "UNSUBSCRIBE") {
+        for name := range set {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        if len(names) == 0 {
+            c.write([]interface{}{kind, nil, len(c.channels) + len(c.patterns)})
+            return
+        }
+    }
+    for _, name := range names {
+        if strings.HasSuffix(command, "UNSUBSCRIBE") {
+            delete(set, name)
+        } else {
+            set[name] = struct{}{}
+        }
+        c.write([]interface{}{kind, name, len(c.channels) + len(c.patterns)})
+    }
+}
+
+// 发布消息，返回接收到消息的客户端数量
+func (s *FakeServer) publish(channel string, message string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    count := 0
+    for c := range s.conns {
+        if _, ok := c.channels[channel]; ok {
+            c.write([]interface{}{"message", channel, message})
+            count++
+        }
+        for pattern := range c.patterns {
+            if fakeMatch(pattern, channel) {
+                c.write([]interface{}{"pmessage", pattern, channel, message})
+                count++
+            }
+        }
+    }
+    return count
+}
+
+// 获得当前链接的数据库(需要在加锁状态下调用)
+func (c *fakeConn) getDb() map[string]*fakeItem {
+    db, ok := c.server.dbs[c.db]
+    if !ok {
+        db = make(map[string]*fakeItem)
+        c.server.dbs[c.db] = db
+    }
+    return db
+}
+
+// 获得未过期的数据项，不存在时返回nil
+func (c *fakeConn) getItem(key string) *fakeItem {
+    db := c.getDb()
+    if item, ok := db[key]; ok {
+        if item.expire.IsZero() || time.Now().Before(item.expire) {
+            return item
+        }
+        delete(db, key)
+    }
+    return nil
+}
+
+// 获得字符串类型的值
+func (c *fakeConn) getString(key string) (string, bool, interface{}) {
+    item := c.getItem(key)
+    if item == nil {
+        return "", false, nil
+    }
+    if v, ok := item.value.(string); ok {
+        return v, true, nil
+    }
+    return "", false, gFAKE_WRONG_TYPE
+}
+
+// 获得数据项，不存在时使用create创建
+func (c *fakeConn) getOrCreate(key string, create func() interface{}) *fakeItem {
+    item := c.getItem(key)
+    if item == nil {
+        item = &fakeItem{value : create()}
+        c.getDb()[key] = item
+    }
+    return item
+}
+
+// 执行数据操作命令(需要在加锁状态下调用)
+func (c *fakeConn) execute(command string, args []string) interface{} {
+    argc := map[string]int {
+        "GET" : 1, "SET" : 2, "SETNX" : 2, "GETSET" : 2, "INCR" : 1, "DECR" : 1, "INCRBY" : 2, "DECRBY" : 2,
+        "APPEND" : 2, "STRLEN" : 1, "EXPIRE" : 2, "PEXPIRE" : 2, "TTL" : 1, "PTTL" : 1, "PERSIST" : 1,
+        "TYPE" : 1, "SELECT" : 1, "AUTH" : 1, "ECHO" : 1, "KEYS" : 1, "SCAN" : 1, "RENAME" : 2,
+        "HGET" : 2, "HSET" : 3, "HSETNX" : 3, "HMSET" : 3, "HDEL" : 2, "HGETALL" : 1, "HEXISTS" : 2,
+        "HLEN" : 1, "HKEYS" : 1, "HVALS" : 1, "HINCRBY" : 3, "HMGET" : 2,
+        "LPUSH" : 2, "RPUSH" : 2, "LPOP" : 1, "RPOP" : 1, "LRANGE" : 3, "LLEN" : 1, "LINDEX" : 2, "LREM" : 3,
+        "SADD" : 2, "SREM" : 2, "SMEMBERS" : 1, "SISMEMBER" : 2, "SCARD" : 1,
+        "ZADD" : 3, "ZREM" : 2, "ZSCORE" : 2, "ZCARD" : 1, "ZRANGE" : 3, "ZREVRANGE" : 3,
+        "ZRANGEBYSCORE" : 3, "ZINCRBY" : 3, "ZRANK" : 2,
+        "DEL" : 1, "EXISTS" : 1, "MGET" : 1, "MSET" : 2, "EVAL" : 2, "EVALSHA" : 2,
+    }
+    if n, ok := argc[command]; ok && len(args) < n {
+        return fakeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+    }
+    db := c.getDb()
+    switch command {
+        case "EVAL", "EVALSHA":
+            return c.eval(command, args)
+        case "PING":
+            if len(args) > 0 {
+                return args[0]
+            }
+            return fakeStatus("PONG")
+        case "ECHO":
+            return args[0]
+        case "AUTH", "QUIT":
+            return fakeStatus("OK")
+        case "SELECT":
+            n, err := strconv.Atoi(args[0])
+            if err != nil {
+                return gFAKE_NOT_INT
+            }
+            c.db = n
+            return fakeStatus("OK")
+        case "FLUSHDB":
+            c.server.dbs[c.db] = make(map[string]*fakeItem)
+            return fakeStatus("OK")
+        case "FLUSHALL":
+            c.server.dbs = make(map[int]map[string]*fakeItem)
+            return fakeStatus("OK")
+        case "DBSIZE":
+            count := 0
+            for key := range db {
+                if c.getItem(key) != nil {
+                    count++
+                }
+            }
+            return count
+        case "KEYS":
+            return c.matchKeys(args[0])
+        case "SCAN":
+            // 一次返回所有匹配的键名
+            pattern := "*"
+            for i := 1; i < len(args) - 1; i++ {
+                if strings.ToUpper(args[i]) == "MATCH" {
+                    pattern = args[i + 1]
+                }
+            }
+            return []interface{}{"0", c.matchKeys(pattern)}
+        case "DEL", "UNLINK":
+            count := 0
+            for _, key := range args {
+                if c.getItem(key) != nil {
+                    delete(db, key)
+                    count++
+                }
+            }
+            return count
+        case "EXISTS":
+            count := 0
+            for _, key := range args {
+                if c.getItem(key) != nil {
+                    count++
+                }
+            }
+            return count
+        case "TYPE":
+            item := c.getItem(args[0])
+            if item == nil {
+                return fakeStatus("none")
+            }
+            switch item.value.(type) {
+                case string:
+                    return fakeStatus("string")
+                case map[string]string:
+                    return fakeStatus("hash")
+                case []string:
+                    return fakeStatus("list")
+                case map[string]struct{}:
+                    return fakeStatus("set")
+                default:
+                    return fakeStatus("zset")
+            }
+        case "RENAME":
+            item := c.getItem(args[0])
+            if item == nil {
+                return fakeError("ERR no such key")
+            }
+            delete(db, args[0])
+            db[args[1]] = item
+            return fakeStatus("OK")
+        case "EXPIRE", "PEXPIRE":
+            n, err := strconv.ParseInt(args[1], 10, 64)
+            if err != nil {
+                return gFAKE_NOT_INT
+            }
+            item := c.getItem(args[0])
+            if item == nil {
+                return 0
+            }
+            unit := time.Millisecond
+            if command == "EXPIRE" {
+                unit = time.Second
+            }
+            item.expire = time.Now().Add(time.Duration(n) * unit)
+            return 1
+        case "TTL", "PTTL":
+            item := c.getItem(args[0])
+            if item == nil {
+                return -2
+            }
+            if item.expire.IsZero() {
+                return -1
+            }
+            ttl := time.Until(item.expire)
+            if command == "TTL" {
+                return int64((ttl + time.Second - 1) / time.Second)
+            }
+            return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+        case "PERSIST":
+            item := c.getItem(args[0])
+            if item == nil || item.expire.IsZero() {
+                return 0
+            }
+            item.expire = time.Time{}
+            return 1
+
+        // 字符串
+        case "GET":
+            v, ok, err := c.getString(args[0])
+            if err != nil {
+                return err
+            }
+            if !ok {
+                return nil
+            }
+            return v
+        case "MGET":
+            replies := make([]interface{}, len(args))
+            for i, key := range args {
+                if v, ok, _ := c.getString(key); ok {
+                    replies[i] = v
+                }
+            }
+            return replies
+        case "SET":
+            return c.set(args)
+        case "SETNX":
+            if c.getItem(args[0]) != nil {
+                return 0
+            }
+            db[args[0]] = &fakeItem{value : args[1]}
+            return 1
+        case "MSET":
+            if len(args) % 2 != 0 {
+                return fakeError("ERR wrong number of arguments for 'mset' command")
+            }
+            for i := 0; i < len(args); i += 2 {
+                db[args[i]] = &fakeItem{value : args[i + 1]}
+            }
+            return fakeStatus("OK")
+        case "GETSET":
+            v, ok, err := c.getString(args[0])
+            if err != nil {
+                return err
+            }
+            db[args[0]] = &fakeItem{value : args[1]}
+            if !ok {
+                return nil
+            }
+            return v
+        case "APPEND":
+            v, _, err := c.getString(args[0])
+            if err != nil {
+                return err
+            }
+            item := c.getOrCreate(args[0], func() interface{} { return "" })
+            item.value = v + args[1]
+            return len(v) + len(args[1])
+        case "STRLEN":
+            v, _, err := c.getString(args[0])
+            if err != nil {
+                return err
+            }
+            return len(v)
+        case "INCR", "DECR", "INCRBY", "DECRBY":
+            delta := int64(1)
+            if len(args) > 1 {
+                n, err := strconv.ParseInt(args[1], 10, 64)
+                if err != nil {
+                    return gFAKE_NOT_INT
+                }
+                delta = n
+            }
+            if strings.HasPrefix(command, "DECR") {
+                delta = -delta
+            }
+            v, ok, err := c.getString(args[0])
+            if err != nil {
+                return err
+            }
+            n := int64(0)
+            if ok {
+                if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+                    return gFAKE_NOT_INT
+                }
+            }
+            n += delta
+            item := c.getOrCreate(args[0], func() interface{} { return "" })
+            item.value = strconv.FormatInt(n, 10)
+            return n
+
+        // 哈希表
+        case "HSET", "HMSET", "HSETNX":
+            if len(args) % 2 != 1 {
+                return fakeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+            }
+            item := c.getOrCreate(args[0], func() interface{} { return make(map[string]string) })
+            hash, ok := item.value.(map[string]string)
+            if !ok {
+                return gFAKE_WRONG_TYPE
+            }
+            count := 0
+            for i := 1; i < len(args); i += 2 {
+                if _, exists := hash[args[i]]; !exists {
+                    count++
+                } else if command == "HSETNX" {
+                    continue
+                }
+                hash[args[i]] = args[i + 1]
+            }
+            if command == "HMSET" {
+                return fakeStatus("OK")
+            }
+            return count
+        case "HGET", "HEXISTS", "HMGET", "HGETALL", "HKEYS", "HVALS", "HLEN", "HDEL", "HINCRBY":
+            hash := map[string]string(nil)
+            if item := c.getItem(args[0]); item != nil {
+                v, ok := item.value.(map[string]string)
+                if !ok {
+                    return gFAKE_WRONG_TYPE
+                }
+                hash = v
+            }
+            return c.executeHash(command, args, hash)
+
+        // 列表
+        case "LPUSH", "RPUSH":
+            item := c.getOrCreate(args[0], func() interface{} { return make([]string, 0) })
+            list, ok := item.value.([]string)
+            if !ok {
+                return gFAKE_WRONG_TYPE
+            }
+            for _, v := range args[1:] {
+                if command == "LPUSH" {
+                    list = append([]string{v}, list...)
+                } else {
+                    list = append(list, v)
+                }
+            }
+            item.value = list
+            return len(list)
+        case "LPOP", "RPOP", "LRANGE", "LLEN", "LINDEX", "LREM":
+            item := c.getItem(args[0])
+            list := []string(nil)
+            if item != nil {
+                v, ok := item.value.([]string)
+                if !ok {
+                    return gFAKE_WRONG_TYPE
+                }
+                list = v
+            }
+            return c.executeList(command, args, item, list)
+
+        // 集合
+        case "SADD":
+            item := c.getOrCreate(args[0], func() interface{} { return make(map[string]struct{}) })
+            set, ok := item.value.(map[string]struct{})
+            if !ok {
+                return gFAKE_WRONG_TYPE
+            }
+            count := 0
+            for _, v := range args[1:] {
+                if _, exists := set[v]; !exists {
+                    set[v] = struct{}{}
+                    count++
+                }
+            }
+            return count
+        case "SREM", "SMEMBERS", "SISMEMBER", "SCARD":
+            set := map[string]struct{}(nil)
+            if item := c.getItem(args[0]); item != nil {
+                v, ok := item.value.(map[string]struct{})
+                if !ok {
+                    return gFAKE_WRONG_TYPE
+                }
+                set = v
+            }
+            switch command {
+                case "SREM":
+                    count := 0
+                    for _, v := range args[1:] {
+                        if _, exists := set[v]; exists {
+                            delete(set, v)
+                            count++
+                        }
+                    }
+                    if set != nil && len(set) == 0 {
+                        delete(db, args[0])
+                    }
+                    return count
+                case "SMEMBERS":
+                    members := make([]string, 0, len(set))
+                    for v := range set {
+                        members = append(members, v)
+                    }
+                    sort.Strings(members)
+                    return members
+                case "SISMEMBER":
+                    if _, ok := set[args[1]]; ok {
+                        return 1
+                    }
+                    return 0
+                default:
+                    return len(set)
+            }
+
+        // 有序集合
+        case "ZADD", "ZINCRBY":
+            if len(args) % 2 != 1 {
+                return gFAKE_SYNTAX
+            }
+            item := c.getOrCreate(args[0], func() interface{} { return make(map[string]float64) })
+            zset, ok := item.value.(map[string]float64)
+            if !ok {
+                return gFAKE_WRONG_TYPE
+            }
+            count := 0
+            for i := 1; i < len(args); i += 2 {
+                score, err := strconv.ParseFloat(args[i], 64)
+                if err != nil {
+                    return fakeError("ERR value is not a valid float")
+                }
+                if _, exists := zset[args[i + 1]]; !exists {
+                    count++
+                }
+                if command == "ZINCRBY" {
+                    zset[args[i + 1]] += score
+                    return formatFakeFloat(zset[args[i + 1]])
+                }
+                zset[args[i + 1]] = score
+            }
+            return count
+        case "ZREM", "ZSCORE", "ZCARD", "ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE", "ZRANK":
+            zset := map[string]float64(nil)
+            if item := c.getItem(args[0]); item != nil {
+                v, ok := item.value.(map[string]float64)
+                if !ok {
+                    return gFAKE_WRONG_TYPE
+                }
+                zset = v
+            }
+            return c.executeSortedSet(command, args, zset)
+    }
+    return fakeError(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(command)))
+}
+
+// 执行SET命令，支持EX/PX/NX/XX选项
+func (c *fakeConn) set(args []string) interface{} {
+    key, value := args[0], args[1]
+    expire     := time.Time{}
+    nx, xx     := false, false
+    for i := 2; i < len(args); i++ {
+        switch strings.ToUpper(args[i]) {
+            case "NX":
+                nx = true
+            case "XX":
+                xx = true
+            case "EX", "PX":
+                if i + 1 >= len(args) {
+                    return gFAKE_SYNTAX
+                }
+                n, err := strconv.ParseInt(args[i + 1], 10, 64)
+                if err != nil || n <= 0 {
+                    return fakeError("ERR invalid expire time in set")
+                }
+                unit := time.Millisecond
+                if strings.ToUpper(args[i]) == "EX" {
+                    unit = time.Second
+                }
+                expire = time.Now().Add(time.Duration(n) * unit)
+                i++
+            default:
+                return gFAKE_SYNTAX
+        }
+    }
+    exists := c.getItem(key) != nil
+    if (nx && exists) || (xx && !exists) {
+        return nil
+    }
+    c.getDb()[key] = &fakeItem{value : value, expire : expire}
+    return fakeStatus("OK")
+}
+
+// 执行哈希表读取/删除命令
+func (c *fakeConn) executeHash(command string, args []string, hash map[string]string) interface{} {
+    switch command {
+        case "HGET":
+            if v, ok := hash[args[1]]; ok {
+                return v
+            }
+            return nil
+        case "HEXISTS":
+            if _, ok := hash[args[1]]; ok {
+                return 1
+            }
+            return 0
+        case "HMGET":
+            replies := make([]interface{}, len(args) - 1)
+            for i, field := range args[1:] {
+                if v, ok := hash[field]; ok {
+                    replies[i] = v
+                }
+            }
+            return replies
+        case "HGETALL", "HKEYS", "HVALS":
+            fields := make([]string, 0, len(hash))
+            for field := range hash {
+                fields = append(fields, field)
+            }
+            sort.Strings(fields)
+            replies := make([]string, 0, len(hash) * 2)
+            for _, field := range fields {
+                if command != "HVALS" {
+                    replies = append(replies, field)
+                }
+                if command != "HKEYS" {
+                    replies = append(replies, hash[field])
+                }
+            }
+            return replies
+        case "HLEN":
+            return len(hash)
+        case "HDEL":
+            count := 0
+            for _, field := range args[1:] {
+                if _, ok := hash[field]; ok {
+                    delete(hash, field)
+                    count++
+                }
+            }
+            if hash != nil && len(hash) == 0 {
+                delete(c.getDb(), args[0])
+            }
+            return count
+        case "HINCRBY":
+            delta, err := strconv.ParseInt(args[2], 10, 64)
+            if err != nil {
+                return gFAKE_NOT_INT
+            }
+            if hash == nil {
+                hash = make(map[string]string)
+                c.getDb()[args[0]] = &fakeItem{value : hash}
+            }
+            n := int64(0)
+            if v, ok := hash[args[1]]; ok {
+                if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+                    return gFAKE_NOT_INT
+                }
+            }
+            n += delta
+            hash[args[1]] = strconv.FormatInt(n, 10)
+            return n
+    }
+    return nil
+}
+
+// 执行列表读取/删除命令
+func (c *fakeConn) executeList(command string, args []string, item *fakeItem, list []string) interface{} {
+    switch command {
+        case "LPOP", "RPOP":
+            if len(list) == 0 {
+                return nil
+            }
+            v := ""
+            if command == "LPOP" {
+                v, list = list[0], list[1:]
+            } else {
+                v, list = list[len(list) - 1], list[:len(list) - 1]
+            }
+            if len(list) == 0 {
+                delete(c.getDb(), args[0])
+            } else {
+                item.value = list
+            }
+            return v
+        case "LLEN":
+            return len(list)
+        case "LINDEX":
+            index, err := strconv.Atoi(args[1])
+            if err != nil {
+                return gFAKE_NOT_INT
+            }
+            if index < 0 {
+                index += len(list)
+            }
+            if index < 0 || index >= len(list) {
+                return nil
+            }
+            return list[index]
+        case "LRANGE":
+            start, err1 := strconv.Atoi(args[1])
+            stop,  err2 := strconv.Atoi(args[2])
+            if err1 != nil || err2 != nil {
+                return gFAKE_NOT_INT
+            }
+            start, stop = fakeRange(start, stop, len(list))
+            if start > stop {
+                return []string{}
+            }
+            return list[start : stop + 1]
+        case "LREM":
+            count, err := strconv.Atoi(args[1])
+            if err != nil {
+                return gFAKE_NOT_INT
+            }
+            removed := 0
+            result  := make([]string, 0, len(list))
+            for _, v := range list {
+                if v == args[2] && (count == 0 || removed < count || (count < 0 && removed < -count)) {
+                    removed++
+                    continue
+                }
+                result = append(result, v)
+            }
+            if item != nil {
+                if len(result) == 0 {
+                    delete(c.getDb(), args[0])
+                } else {
+                    item.value = result
+                }
+            }
+            return removed
+    }
+    return nil
+}
+
+// 执行有序集合读取/删除命令
+func (c *fakeConn) executeSortedSet(command string, args []string, zset map[string]float64) interface{} {
+    members := make([]string, 0, len(zset))
+    for member := range zset {
+        members = append(members, member)
+    }
+    sort.Slice(members, func(i, j int) bool {
+        if zset[members[i]] != zset[members[j]] {
+            return zset[members[i]] < zset[members[j]]
+        }
+        return members[i] < members[j]
+    })
+    withScores := strings.ToUpper(args[len(args) - 1]) == "WITHSCORES"
+    output     := func(array []string) []string {
+        if !withScores {
+            return array
+        }
+        replies := make([]string, 0, len(array) * 2)
+        for _, member := range array {
+            replies = append(replies, member, formatFakeFloat(zset[member]))
+        }
+        return replies
+    }
+    switch command {
+        case "ZREM":
+            count := 0
+            for _, member := range args[1:] {
+                if _, ok := zset[member]; ok {
+                    delete(zset, member)
+                    count++
+                }
+            }
+            if zset != nil && len(zset) == 0 {
+                delete(c.getDb(), args[0])
+            }
+            return count
+        case "ZSCORE":
+            if score, ok := zset[args[1]]; ok {
+                return formatFakeFloat(score)
+            }
+            return nil
+        case "ZCARD":
+            return len(zset)
+        case "ZRANK":
+            for i, member := range members {
+                if member == args[1] {
+                    return i
+                }
+            }
+            return nil
+        case "ZRANGE", "ZREVRANGE":
+            if command == "ZREVRANGE" {
+                for i, j := 0, len(members) - 1; i < j; i, j = i + 1, j - 1 {
+                    members[i], members[j] = members[j], members[i]
+                }
+            }
+            start, err1 := strconv.Atoi(args[1])
+            stop,  err2 := strconv.Atoi(args[2])
+            if err1 != nil || err2 != nil {
+                return gFAKE_NOT_INT
+            }
+            start, stop = fakeRange(start, stop, len(members))
+            if start > stop {
+                return []string{}
+            }
+            return output(members[start : stop + 1])
+        case "ZRANGEBYSCORE":
+            min, err1 := parseFakeScore(args[1])
+            max, err2 := parseFakeScore(args[2])
+            if err1 != nil || err2 != nil {
+                return fakeError("ERR min or max is not a float")
+            }
+            array := make([]string, 0)
+            for _, member := range members {
+                if zset[member] >= min && zset[member] <= max {
+                    array = append(array, member)
+                }
+            }
+            return output(array)
+    }
+    return nil
+}
+
+// 将Redis的范围参数(支持负数)转换为有效的切片索引
+func fakeRange(start, stop, length int) (int, int) {
+    if start < 0 {
+        start += length
+    }
+    if stop < 0 {
+        stop += length
+    }
+    if start < 0 {
+        start = 0
+    }
+    if stop >= length {
+        stop = length - 1
+    }
+    return start, stop
+}
+
+// 解析有序集合的分数范围参数
+func parseFakeScore(s string) (float64, error) {
+    switch strings.ToLower(s) {
+        case "-inf":
+            return -1 << 63, nil
+        case "+inf", "inf":
+            return 1 << 63, nil
+    }
+    return strconv.ParseFloat(s, 64)
+}
+
+// 格式化有序集合的分数
+func formatFakeFloat(f float64) string {
+    return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// 获得匹配指定模式的所有未过期键名
+func (c *fakeConn) matchKeys(pattern string) []string {
+    keys := make([]string, 0)
+    for key := range c.getDb() {
+        if fakeMatch(pattern, key) && c.getItem(key) != nil {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// Redis的glob风格模式匹配(支持*、?、[...]及\\转义)
+func fakeMatch(pattern string, s string) bool {
+    buffer := make([]byte, 0, len(pattern) + 8)
+    buffer  = append(buffer, '^')
+    for i := 0; i < len(pattern); i++ {
+        switch ch := pattern[i]; ch {
+            case '*':
+                buffer = append(buffer, ".*"...)
+            case '?':
+                buffer = append(buffer, '.')
+            case '[':
+                end := strings.IndexByte(pattern[i:], ']')
+                if end < 0 {
+                    buffer = append(buffer, `\[`...)
+                    continue
+                }
+                class := pattern[i + 1 : i + end]
+                if strings.HasPrefix(class, "^") {
+                    class = "^" + regexp.QuoteMeta(class[1:])
+                } else {
+                    class = regexp.QuoteMeta(class)
+                }
+                buffer = append(buffer, '[')
+                buffer = append(buffer, class...)
+                buffer = append(buffer, ']')
+                i += end
+            case '\\':
+                if i + 1 < len(pattern) {
+                    i++
+                    buffer = append(buffer, regexp.QuoteMeta(pattern[i : i + 1])...)
+                }
+            default:
+                buffer = append(buffer, regexp.QuoteMeta(string(ch))...)
+        }
+    }
+    buffer = append(buffer, '$')
+    re, err := regexp.Compile(string(buffer))
+    if err != nil {
+        return false
+    }
+    return re.MatchString(s)
+}
+
+// 执行EVAL/EVALSHA命令，EVALSHA只能执行通过EVAL执行过的脚本
+func (c *fakeConn) eval(command string, args []string) interface{} {
+    hash := args[0]
+    if command == "EVAL" {
+        sum := sha1.Sum([]byte(args[0]))
+        hash = hex.EncodeToString(sum[:])
+    }
+    f, ok := c.server.scripts[hash]
+    if !ok {
+        if command == "EVAL" {
+            return fakeError("ERR script is not registered in fake server")
+        }
+        return fakeError("NOSCRIPT No matching script. Please use EVAL.")
+    }
+    if command == "EVAL" {
+        c.server.loaded[hash] = struct{}{}
+    } else if _, ok := c.server.loaded[hash]; !ok {
+        return fakeError("NOSCRIPT No matching script. Please use EVAL.")
+    }
+    n, err := strconv.Atoi(args[1])
+    if err != nil || n < 0 || n > len(args) - 2 {
+        return fakeError("ERR Number of keys can't be greater than number of args")
+    }
+    call := func(command string, args...string) interface{} {
+        return c.execute(strings.ToUpper(command), args)
+    }
+    return f(call, args[2 : 2 + n], args[2 + n :])
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gredistest
+
+import (
+    "fmt"
+    "gitee.com/johng/gf/g/database/gredis"
+    "net"
+    "strconv"
+    "strings"
+)
+
+// 不包含键名的命令
+var keylessCommands = make(map[string]bool)
+
+func init() {
+    commands := `PING ECHO AUTH SELECT QUIT INFO TIME DBSIZE KEYS SCAN FLUSHDB FLUSHALL MULTI EXEC DISCARD
+                 PUBLISH SUBSCRIBE PSUBSCRIBE UNSUBSCRIBE PUNSUBSCRIBE CLUSTER SENTINEL ROLE ASKING READONLY
+                 SCRIPT CONFIG CLIENT`
+    for _, command := range strings.Fields(commands) {
+        keylessCommands[command] = true
+    }
+}
+
+// 包含多个键名的命令及每个键名占用的参数个数，集群模式下所有键名需要位于同一个槽位
+var multiKeyCommands = map[string]int {
+    "DEL"    : 1,
+    "EXISTS" : 1,
+    "MGET"   : 1,
+    "MSET"   : 2,
+}
+
+// 模拟服务端的集群槽位分配区间
+type FakeSlotRange struct {
+    Start int    // 起始槽位(包含)
+    End   int    // 结束槽位(包含)
+    Addr  string // 负责该区间的主节点地址
+}
+
+// 设置节点角色(master/slave)，用于模拟Sentinel模式下的主从切换
+func (s *FakeServer) SetRole(role string) {
+    s.mu.Lock()
+    s.role = role
+    s.mu.Unlock()
+}
+
+// 将模拟服务端作为Sentinel节点，设置主节点名称对应的主节点地址，
+// 主节点地址变更时将会向+switch-master频道发布主从切换事件。
+func (s *FakeServer) SetSentinelMaster(name string, addr string) {
+    s.mu.Lock()
+    old := s.masters[name]
+    s.masters[name] = addr
+    s.mu.Unlock()
+    if old != "" && old != addr {
+        oldHost, oldPort, _ := net.SplitHostPort(old)
+        newHost, newPort, _ := net.SplitHostPort(addr)
+        s.publish("+switch-master", strings.Join([]string{name, oldHost, oldPort, newHost, newPort}, " "))
+    }
+}
+
+// 将模拟服务端作为集群节点，设置集群的槽位分配(所有节点需要设置相同的分配)，
+// 对于不属于当前节点的槽位，键名操作将会返回MOVED重定向错误。
+func (s *FakeServer) SetClusterSlots(ranges...FakeSlotRange) {
+    s.mu.Lock()
+    s.slots = ranges
+    s.mu.Unlock()
+}
+
+// 设置正在迁移的槽位，对于该槽位中当前节点不存在的键名，键名操作将会返回ASK重定向错误，addr为空时取消迁移
+func (s *FakeServer) SetClusterAsk(slot int, addr string) {
+    s.mu.Lock()
+    if addr == "" {
+        delete(s.asks, slot)
+    } else {
+        s.asks[slot] = addr
+    }
+    s.mu.Unlock()
+}
+
+// 处理Sentinel及Cluster相关的命令
+func (c *fakeConn) handleCluster(command string, args []string) interface{} {
+    c.server.mu.Lock()
+    defer c.server.mu.Unlock()
+    switch command {
+        case "ROLE":
+            if c.server.role == "master" {
+                return []interface{}{"master", 0, []interface{}{}}
+            }
+            return []interface{}{c.server.role, "127.0.0.1", 0, "connected", 0}
+
+        case "ASKING":
+            c.asking = true
+            return fakeStatus("OK")
+
+        case "SENTINEL":
+            if len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") {
+                host, port, err := net.SplitHostPort(c.server.masters[args[1]])
+                if err != nil {
+                    return []interface{}(nil)
+                }
+                return []string{host, port}
+            }
+            return fakeError("ERR unknown sentinel subcommand")
+
+        case "CLUSTER":
+            if len(c.server.slots) == 0 {
+                return fakeError("ERR This instance has cluster support disabled")
+            }
+            if len(args) == 1 && strings.EqualFold(args[0], "SLOTS") {
+                reply := make([]interface{}, 0, len(c.server.slots))
+                for i, r := range c.server.slots {
+                    host, port, _ := net.SplitHostPort(r.Addr)
+                    p, _          := strconv.Atoi(port)
+                    reply = append(reply, []interface{}{r.Start, r.End, []interface{}{host, p, fmt.Sprintf("node%d", i)}})
+                }
+                return reply
+            }
+            return fakeError("ERR unknown cluster subcommand")
+    }
+    return nil
+}
+
+// 集群模式下检查命令键名所在的槽位是否由当前节点负责，需要重定向时返回重定向错误，否则返回nil
+func (c *fakeConn) checkSlot(command string, args []string) interface{} {
+    c.server.mu.Lock()
+    defer c.server.mu.Unlock()
+    asking  := c.asking
+    c.asking = false
+    if len(c.server.slots) == 0 {
+        return nil
+    }
+    key, ok := getCommandKey(command, args)
+    if !ok {
+        return nil
+    }
+    slot := gredis.GetSlot(key)
+    if step := multiKeyCommands[command]; step > 0 {
+        for i := step; i < len(args); i += step {
+            if gredis.GetSlot(args[i]) != slot {
+                return fakeError("CROSSSLOT Keys in request don't hash to the same slot")
+            }
+        }
+    }
+    if addr, ok := c.server.asks[slot]; ok && c.getItem(key) == nil {
+        return fakeError(fmt.Sprintf("ASK %d %s", slot, addr))
+    }
+    if asking {
+        return nil
+    }
+    for _, r := range c.server.slots {
+        if slot >= r.Start && slot <= r.End {
+            if r.Addr == c.server.Addr() {
+                return nil
+            }
+            return fakeError(fmt.Sprintf("MOVED %d %s", slot, r.Addr))
+        }
+    }
+    return fakeError(fmt.Sprintf("CLUSTERDOWN Hash slot %d not served", slot))
+}
+
+// 获得命令中的键名，不包含键名的命令返回false
+func getCommandKey(command string, args []string) (string, bool) {
+    if keylessCommands[command] || len(args) == 0 {
+        return "", false
+    }
+    switch command {
+        case "EVAL", "EVALSHA":
+            // EVAL script numkeys key [key ...] arg [arg ...]
+            if len(args) < 3 {
+                return "", false
+            }
+            if n, _ := strconv.Atoi(args[1]); n < 1 {
+                return "", false
+            }
+            return args[2], true
+    }
+    return args[0], true
+}
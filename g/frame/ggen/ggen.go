@@ -0,0 +1,239 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// Package ggen provides code generator for database models and DAO objects.
+//
+// 数据库模型代码生成器.
+// 根据数据库表结构生成实体对象(entity)、字段名称常量以及基于gdb.Model的DAO对象，
+// 可以通过gcmd命令行绑定使用，例如：gcmd.BindHandle("gen", ggen.Command)
+package ggen
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/frame/gins"
+    "gitee.com/johng/gf/g/os/gcmd"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/glog"
+    "gitee.com/johng/gf/g/util/gregex"
+    "gitee.com/johng/gf/g/util/gstr"
+    "go/format"
+    "strings"
+)
+
+const (
+    // 生成文件的标识，只有带有该标识的文件才会在重新生成时被覆盖
+    gGENERATED_MARK  = "// Code generated by ggen. DO NOT EDIT."
+    // 默认生成代码的目录
+    gDEFAULT_PATH    = "model"
+)
+
+// 代码生成配置
+type Config struct {
+    DB      gdb.DB   // 数据库对象，为nil时使用Group获取gins.Database配置的数据库对象
+    Group   string   // 数据库配置分组名称，默认为default
+    Tables  []string // 需要生成的数据表名称列表，为空时生成数据库中的所有数据表
+    Prefix  string   // 生成对象名称时需要去掉的数据表前缀，例如：gf_
+    Path    string   // 生成代码的目录，默认为当前工作目录下的model目录
+    Package string   // 生成代码的包名，默认为Path的最后一级目录名称
+}
+
+// 单个文件的生成结果
+type File struct {
+    Path    string // 文件路径
+    Skipped bool   // 是否被跳过(用户编写或者修改过的文件不会被覆盖)
+}
+
+// 命令行入口方法，命令行选项：
+// --group   数据库配置分组名称；
+// --tables  数据表名称，多个使用","分隔；
+// --prefix  需要去掉的数据表前缀；
+// --path    生成代码的目录；
+// --package 生成代码的包名；
+// 使用示例：gcmd.BindHandle("gen", ggen.Command)，随后执行：go run main.go gen --path=./app/model
+func Command() {
+    config := Config {
+        Group   : gcmd.Option.Get("group", gdb.DEFAULT_GROUP_NAME),
+        Prefix  : gcmd.Option.Get("prefix"),
+        Path    : gcmd.Option.Get("path", gDEFAULT_PATH),
+        Package : gcmd.Option.Get("package"),
+    }
+    if tables := gcmd.Option.Get("tables"); tables != "" {
+        for _, table := range strings.Split(tables, ",") {
+            if table = strings.TrimSpace(table); table != "" {
+                config.Tables = append(config.Tables, table)
+            }
+        }
+    }
+    files, err := Generate(config)
+    for _, file := range files {
+        if file.Skipped {
+            glog.Printfln("skipped: %s", file.Path)
+        } else {
+            glog.Printfln("generated: %s", file.Path)
+        }
+    }
+    if err != nil {
+        glog.Error(err)
+    }
+}
+
+// 根据配置生成代码，返回所有处理过的文件列表。
+// 每张数据表会生成三个文件(以数据表user为例)：
+// user_entity.go 实体对象及字段名称常量，每次生成时覆盖；
+// user_dao.go    DAO对象，每次生成时覆盖；
+// user_model.go  用户自定义代码文件，仅在文件不存在时生成，不会被覆盖。
+// 当生成文件已存在且不带有生成标识(即被用户改写过)时，该文件将被跳过。
+func Generate(config Config) ([]File, error) {
+    if config.Group == "" {
+        config.Group = gdb.DEFAULT_GROUP_NAME
+    }
+    if config.Path == "" {
+        config.Path = gDEFAULT_PATH
+    }
+    db := config.DB
+    if db == nil {
+        if db = gins.Database(config.Group); db == nil {
+            return nil, errors.New(fmt.Sprintf(`database initialization failed for group "%s"`, config.Group))
+        }
+    }
+    tables := config.Tables
+    if len(tables) == 0 {
+        var err error
+        if tables, err = db.Tables(); err != nil {
+            return nil, err
+        }
+    }
+    if !gfile.Exists(config.Path) {
+        if err := gfile.Mkdir(config.Path); err != nil {
+            return nil, err
+        }
+    }
+    if config.Package == "" {
+        config.Package = gfile.Basename(gfile.RealPath(config.Path))
+    }
+    files := make([]File, 0)
+    for _, table := range tables {
+        fields, err := db.TableFields(table)
+        if err != nil {
+            return files, err
+        }
+        if len(fields) == 0 {
+            return files, errors.New(fmt.Sprintf(`no fields found for table "%s"`, table))
+        }
+        t := newTable(config, table, fields)
+        for _, item := range []struct{
+            name      string
+            template  string
+            generated bool
+        }{
+            {t.FileName + "_entity.go", tplEntity, true},
+            {t.FileName + "_dao.go",    tplDao,    true},
+            {t.FileName + "_model.go",  tplUser,   false},
+        } {
+            file, err := writeFile(strings.TrimRight(config.Path, gfile.Separator) + gfile.Separator + item.name, item.template, t, item.generated)
+            if err != nil {
+                return files, err
+            }
+            files = append(files, file)
+        }
+    }
+    return files, nil
+}
+
+// 根据模板生成代码并写入文件，generated为false时表示用户文件，已存在时不会被覆盖
+func writeFile(path string, template string, t *table, generated bool) (File, error) {
+    file := File{Path : path}
+    if gfile.Exists(path) {
+        if !generated || !isGeneratedFile(path) {
+            file.Skipped = true
+            return file, nil
+        }
+    }
+    content, err := parseTemplate(template, t, generated)
+    if err != nil {
+        return file, err
+    }
+    return file, gfile.PutBinContents(path, content)
+}
+
+// 判断文件是否为代码生成器生成(用户没有去掉生成标识)的文件
+func isGeneratedFile(path string) bool {
+    content := gfile.GetContents(path)
+    if pos := strings.Index(content, "\n"); pos != -1 {
+        content = content[: pos]
+    }
+    return strings.TrimSpace(content) == gGENERATED_MARK
+}
+
+// 解析模板并格式化生成的Go代码，generated为true时在文件首行写入生成标识
+func parseTemplate(content string, t *table, generated bool) ([]byte, error) {
+    buffer := bytes.NewBuffer(nil)
+    if generated {
+        buffer.WriteString(gGENERATED_MARK + "\n")
+    }
+    if err := newTemplate(content).Execute(buffer, t); err != nil {
+        return nil, err
+    }
+    return format.Source(buffer.Bytes())
+}
+
+// 将数据表/字段名称转换为Go的对象名称(大驼峰)，例如：user_detail -> UserDetail
+func camelCase(name string) string {
+    array := strings.FieldsFunc(name, func(r rune) bool {
+        return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+    })
+    for i, v := range array {
+        array[i] = gstr.UcFirst(v)
+    }
+    name = strings.Join(array, "")
+    // 名称不能以数字开头
+    if name != "" && name[0] >= '0' && name[0] <= '9' {
+        name = "F" + name
+    }
+    return name
+}
+
+// 将数据库字段类型转换为Go的变量类型，日期时间类型使用string以便直接作为预处理参数使用
+func goType(fieldType string) string {
+    t, _     := gregex.ReplaceString(`\(.+?\)`, "", fieldType)
+    t         = strings.ToLower(strings.TrimSpace(t))
+    unsigned := strings.Contains(t, "unsigned")
+    if pos := strings.Index(t, " "); pos != -1 {
+        t = t[: pos]
+    }
+    switch {
+        case t == "interval" || t == "point":
+            return "string"
+
+        case t == "bool" || t == "boolean":
+            return "bool"
+
+        case strings.Contains(t, "bigint") || t == "int8":
+            if unsigned {
+                return "uint64"
+            }
+            return "int64"
+
+        case strings.Contains(t, "int") || t == "bit" || t == "serial":
+            if unsigned {
+                return "uint"
+            }
+            return "int"
+
+        case strings.Contains(t, "float") || strings.Contains(t, "double") || t == "decimal" ||
+            t == "numeric" || t == "number" || t == "real" || t == "money":
+            return "float64"
+
+        case strings.Contains(t, "binary") || strings.Contains(t, "blob") || t == "bytea" || t == "image":
+            return "[]byte"
+
+        default:
+            return "string"
+    }
+}
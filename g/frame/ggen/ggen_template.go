@@ -0,0 +1,218 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package ggen
+
+import (
+    "fmt"
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/util/gstr"
+    "strings"
+    "text/template"
+)
+
+// 模板解析使用的数据表对象
+type table struct {
+    Package     string   // 包名
+    Group       string   // 数据库配置分组名称
+    Name        string   // 数据表名称
+    Object      string   // 实体对象名称，例如：UserDetail
+    DaoType     string   // DAO对象类型名称，例如：userDetailDao
+    FileName    string   // 生成文件名称前缀，例如：user_detail
+    Fields      []*field // 字段列表(按照字段在表中的顺序)
+    PrimaryKeys []*field // 主键字段列表
+}
+
+// 模板解析使用的字段对象
+type field struct {
+    Name    string // 字段名称
+    Object  string // 属性名称，例如：CreateTime
+    Type    string // 属性类型
+    Tag     string // 属性标签，包含orm及json标签
+    Comment string // 字段注释
+    Auto    bool   // 是否自增字段
+}
+
+// 实体对象模板，每次生成时覆盖
+const tplEntity = `
+package {{.Package}}
+
+// {{.Object}} 为数据表{{.Name}}的实体对象
+type {{.Object}} struct {
+{{- range .Fields}}
+    {{.Object}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+
+// 数据表{{.Name}}的表名及字段名称常量
+const (
+    {{.Object}}Table = "{{.Name}}"
+{{- range .Fields}}
+    {{$.Object}}Column{{.Object}} = "{{.Name}}"
+{{- end}}
+)
+`
+
+// DAO对象模板，每次生成时覆盖
+const tplDao = `
+package {{.Package}}
+
+import (
+    "database/sql"
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/frame/gins"
+    "gitee.com/johng/gf/g/util/gconv"
+)
+
+// {{.Object}}Dao 为数据表{{.Name}}的DAO对象，封装了基于gdb.Model的常用操作
+var {{.Object}}Dao = &{{.DaoType}}{
+    group : "{{.Group}}",
+    table : {{.Object}}Table,
+}
+
+// 数据表{{.Name}}的DAO对象类型
+type {{.DaoType}} struct {
+    group string // 数据库配置分组名称
+    table string // 数据表名称
+}
+
+// 获取数据库操作对象
+func (d *{{.DaoType}}) DB() gdb.DB {
+    return gins.Database(d.group)
+}
+
+// 创建数据表{{.Name}}的链式操作对象
+func (d *{{.DaoType}}) M() *gdb.Model {
+    return d.DB().Table(d.table)
+}
+
+// 查询单条记录，记录不存在时返回nil
+func (d *{{.DaoType}}) FindOne(where interface{}, args ...interface{}) (*{{.Object}}, error) {
+    record, err := d.M().Where(where, args...).One()
+    if err != nil || record == nil {
+        return nil, err
+    }
+    entity := new({{.Object}})
+    if err := record.ToStruct(entity); err != nil {
+        return nil, err
+    }
+    return entity, nil
+}
+
+// 查询多条记录
+func (d *{{.DaoType}}) FindAll(where interface{}, args ...interface{}) ([]*{{.Object}}, error) {
+    result, err := d.M().Where(where, args...).All()
+    if err != nil {
+        return nil, err
+    }
+    entities := make([]*{{.Object}}, len(result))
+    for i, record := range result {
+        entities[i] = new({{.Object}})
+        if err := record.ToStruct(entities[i]); err != nil {
+            return nil, err
+        }
+    }
+    return entities, nil
+}
+
+// 查询记录数
+func (d *{{.DaoType}}) Count(where interface{}, args ...interface{}) (int, error) {
+    return d.M().Where(where, args...).Count()
+}
+
+// 写入记录
+func (d *{{.DaoType}}) Insert(entity *{{.Object}}) (sql.Result, error) {
+    return d.M().Data(d.data(entity)).Insert()
+}
+
+// 写入记录，主键或者唯一索引冲突时更新记录
+func (d *{{.DaoType}}) Save(entity *{{.Object}}) (sql.Result, error) {
+    return d.M().Data(d.data(entity)).Save()
+}
+{{- if .PrimaryKeys}}
+
+// 根据主键更新记录
+func (d *{{.DaoType}}) Update(entity *{{.Object}}) (sql.Result, error) {
+    return d.M().Data(d.data(entity)).Where("{{primaryWhere .PrimaryKeys}}"{{range .PrimaryKeys}}, entity.{{.Object}}{{end}}).Update()
+}
+{{- end}}
+
+// 删除记录
+func (d *{{.DaoType}}) Delete(where interface{}, args ...interface{}) (sql.Result, error) {
+    return d.M().Where(where, args...).Delete()
+}
+
+// 将实体对象转换为写入数据，值为零值的自增字段将被忽略，以便由数据库自动生成
+func (d *{{.DaoType}}) data(entity *{{.Object}}) gdb.Map {
+    data := gconv.Map(entity)
+{{- range .Fields}}{{if .Auto}}
+    if entity.{{.Object}} == 0 {
+        delete(data, {{$.Object}}Column{{.Object}})
+    }
+{{- end}}{{end}}
+    return data
+}
+`
+
+// 用户自定义代码模板，仅在文件不存在时生成
+const tplUser = `
+package {{.Package}}
+
+// 该文件仅在不存在时生成，重新生成代码时不会被覆盖，
+// 可以在这里为{{.Object}}及{{.DaoType}}添加自定义的方法。
+`
+
+// 创建模板对象
+func newTemplate(content string) *template.Template {
+    return template.Must(template.New("ggen").Funcs(template.FuncMap{
+        "primaryWhere" : func(keys []*field) string {
+            array := make([]string, len(keys))
+            for i, key := range keys {
+                array[i] = key.Name + "=?"
+            }
+            return strings.Join(array, " AND ")
+        },
+    }).Parse(content))
+}
+
+// 根据数据表结构创建模板解析使用的数据表对象
+func newTable(config Config, name string, fields []*gdb.TableField) *table {
+    trimmed := name
+    if config.Prefix != "" && strings.HasPrefix(trimmed, config.Prefix) {
+        trimmed = trimmed[len(config.Prefix):]
+    }
+    t := &table {
+        Package  : config.Package,
+        Group    : config.Group,
+        Name     : name,
+        Object   : camelCase(trimmed),
+        FileName : strings.Join(strings.FieldsFunc(strings.ToLower(trimmed), func(r rune) bool {
+            return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z')
+        }), "_"),
+        Fields   : make([]*field, len(fields)),
+    }
+    t.DaoType = gstr.LcFirst(t.Object) + "Dao"
+    for i, f := range fields {
+        tag := f.Name
+        if f.Key == "PRI" {
+            tag += ",primary"
+        }
+        t.Fields[i] = &field {
+            Name    : f.Name,
+            Object  : camelCase(f.Name),
+            Type    : goType(f.Type),
+            Tag     : fmt.Sprintf("`orm:\"%s\" json:\"%s\"`", tag, f.Name),
+            Comment : strings.Join(strings.Fields(f.Comment), " "),
+        }
+        if strings.Contains(f.Extra, "auto_increment") && strings.Contains(t.Fields[i].Type, "int") {
+            t.Fields[i].Auto = true
+        }
+        if f.Key == "PRI" {
+            t.PrimaryKeys = append(t.PrimaryKeys, t.Fields[i])
+        }
+    }
+    return t
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package ggen_test
+
+import (
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/frame/ggen"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gtest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// 用于测试的数据库对象，只实现表结构查询方法
+type testDB struct {
+    gdb.DB
+}
+
+func (db *testDB) Tables() ([]string, error) {
+    return []string{"gf_user_detail"}, nil
+}
+
+func (db *testDB) TableFields(table string) ([]*gdb.TableField, error) {
+    return []*gdb.TableField {
+        {Index : 0, Name : "id",          Type : "int(10) unsigned", Key : "PRI", Extra : "auto_increment", Comment : "用户ID"},
+        {Index : 1, Name : "nickname",    Type : "varchar(45)"},
+        {Index : 2, Name : "balance",     Type : "decimal(10,2)"},
+        {Index : 3, Name : "avatar",      Type : "blob", Null : true},
+        {Index : 4, Name : "create_time", Type : "datetime"},
+    }, nil
+}
+
+func TestGenerate(t *testing.T) {
+    path := gfile.TempDir() + gfile.Separator + "ggen_" + gconv.String(time.Now().UnixNano())
+    defer gfile.Remove(path)
+
+    config := ggen.Config {
+        DB     : &testDB{},
+        Prefix : "gf_",
+        Path   : path + gfile.Separator + "model",
+    }
+    files, err := ggen.Generate(config)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(len(files), 3)
+    for _, file := range files {
+        gtest.Assert(file.Skipped, false)
+    }
+
+    entity := gfile.GetContents(config.Path + gfile.Separator + "user_detail_entity.go")
+    gtest.Assert(strings.Contains(entity, "package model"), true)
+    gtest.Assert(strings.Contains(entity, "type UserDetail struct"), true)
+    gtest.Assert(strings.Contains(entity, "Id         uint    `orm:\"id,primary\" json:\"id\"` // 用户ID"), true)
+    gtest.Assert(strings.Contains(entity, "Balance    float64 `orm:\"balance\" json:\"balance\"`"), true)
+    gtest.Assert(strings.Contains(entity, "Avatar     []byte"), true)
+    gtest.Assert(strings.Contains(entity, "CreateTime string"), true)
+    gtest.Assert(strings.Contains(entity, `UserDetailColumnCreateTime = "create_time"`), true)
+
+    dao := gfile.GetContents(config.Path + gfile.Separator + "user_detail_dao.go")
+    gtest.Assert(strings.Contains(dao, "var UserDetailDao = &userDetailDao{"), true)
+    gtest.Assert(strings.Contains(dao, `Where("id=?", entity.Id).Update()`), true)
+    gtest.Assert(strings.Contains(dao, "delete(data, UserDetailColumnId)"), true)
+
+    // 重新生成时，用户文件以及去掉生成标识的文件不会被覆盖
+    userFile := config.Path + gfile.Separator + "user_detail_model.go"
+    daoFile  := config.Path + gfile.Separator + "user_detail_dao.go"
+    gfile.PutContentsAppend(userFile, "\nfunc (d *userDetailDao) Custom() {}\n")
+    gfile.PutContents(daoFile, strings.Replace(dao, "// Code generated by ggen. DO NOT EDIT.\n", "", 1))
+    files, err = ggen.Generate(config)
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    gtest.Assert(files[0].Skipped, false)
+    gtest.Assert(files[1].Skipped, true)
+    gtest.Assert(files[2].Skipped, true)
+    gtest.Assert(strings.Contains(gfile.GetContents(userFile), "Custom()"), true)
+}
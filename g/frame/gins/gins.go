@@ -11,18 +11,22 @@
 package gins
 
 import (
+    "errors"
     "fmt"
     "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/container/gvar"
     "gitee.com/johng/gf/g/database/gdb"
     "gitee.com/johng/gf/g/database/gredis"
     "gitee.com/johng/gf/g/internal/cmdenv"
     "gitee.com/johng/gf/g/os/gcfg"
     "gitee.com/johng/gf/g/os/gfile"
-    "gitee.com/johng/gf/g/os/gfsnotify"
     "gitee.com/johng/gf/g/os/glog"
     "gitee.com/johng/gf/g/os/gview"
     "gitee.com/johng/gf/g/util/gconv"
     "gitee.com/johng/gf/g/util/gregex"
+    "net/url"
+    "strings"
 )
 
 const (
@@ -32,8 +36,12 @@ const (
     gFRAME_CORE_COMPONENT_NAME_DATABASE   = "gf.core.component.database"
 )
 
-// 单例对象存储器
-var instances = gmap.NewStringInterfaceMap()
+var (
+    // 单例对象存储器
+    instances       = gmap.NewStringInterfaceMap()
+    // 是否已监听数据库配置项
+    databaseWatched = gtype.NewBool()
+)
 
 // 获取单例对象
 func Get(key string) interface{} {
@@ -119,60 +127,11 @@ func Database(name...string) gdb.DB {
                 glog.Error(`database init failed: "database" node not found, is config file or configuration missing?`)
                 return nil
             }
-            for group, v := range m {
-                cg := gdb.ConfigGroup{}
-                if list, ok := v.([]interface{}); ok {
-                    for _, nodev := range list {
-                        node  := gdb.ConfigNode{}
-                        nodem := nodev.(map[string]interface{})
-                        if value, ok := nodem["host"]; ok {
-                            node.Host = gconv.String(value)
-                        }
-                        if value, ok := nodem["port"]; ok {
-                            node.Port = gconv.String(value)
-                        }
-                        if value, ok := nodem["user"]; ok {
-                            node.User = gconv.String(value)
-                        }
-                        if value, ok := nodem["pass"]; ok {
-                            node.Pass = gconv.String(value)
-                        }
-                        if value, ok := nodem["name"]; ok {
-                            node.Name = gconv.String(value)
-                        }
-                        if value, ok := nodem["type"]; ok {
-                            node.Type = gconv.String(value)
-                        }
-                        if value, ok := nodem["role"]; ok {
-                            node.Role = gconv.String(value)
-                        }
-                        if value, ok := nodem["charset"]; ok {
-                            node.Charset = gconv.String(value)
-                        }
-                        if value, ok := nodem["priority"]; ok {
-                            node.Priority = gconv.Int(value)
-                        }
-                        if value, ok := nodem["linkinfo"]; ok {
-                            node.Linkinfo = gconv.String(value)
-                        }
-                        if value, ok := nodem["max-idle"]; ok {
-                            node.MaxIdleConnCount = gconv.Int(value)
-                        }
-                        if value, ok := nodem["max-open"]; ok {
-                            node.MaxOpenConnCount = gconv.Int(value)
-                        }
-                        if value, ok := nodem["max-lifetime"]; ok {
-                            node.MaxConnLifetime = gconv.Int(value)
-                        }
-                        cg = append(cg, node)
-                    }
-                }
-                gdb.AddConfigGroup(group, cg)
+            addDatabaseConfig(m)
+            // 监听数据库配置项，配置有变化时更新数据库配置并关闭缓存的数据库对象
+            if !databaseWatched.Set(true) {
+                config.Watch("database", reloadDatabaseConfig)
             }
-            // 使用gfsnotify进行文件监控，当配置文件有任何变化时，清空数据库配置缓存
-            gfsnotify.Add(config.GetFilePath(), func(event *gfsnotify.Event) {
-                instances.Remove(key)
-            })
         }
         if db, err := gdb.New(name...); err == nil {
             return db
@@ -187,6 +146,84 @@ func Database(name...string) gdb.DB {
     return nil
 }
 
+// 数据库配置项变化时更新数据库配置，删除已移除的配置分组，并移除缓存的数据库对象，下一次获取时按照新的配置重新创建；
+// 移除的数据库对象不会被关闭，已持有的数据库对象(例如: var db = g.DB())仍然可以继续使用(按照更新后的配置获取连接)，
+// 但配置分组被删除后将返回配置不存在的错误
+func reloadDatabaseConfig(old, new *gvar.Var) {
+    oldMap := gconv.Map(old.Val())
+    newMap := gconv.Map(new.Val())
+    groups := []string{gdb.DEFAULT_GROUP_NAME}
+    for group := range oldMap {
+        if _, ok := newMap[group]; !ok {
+            gdb.RemoveConfigGroup(group)
+        }
+        groups = append(groups, group)
+    }
+    if newMap != nil {
+        addDatabaseConfig(newMap)
+        for group := range newMap {
+            groups = append(groups, group)
+        }
+    }
+    for _, group := range groups {
+        instances.Remove(fmt.Sprintf("%s.%s", gFRAME_CORE_COMPONENT_NAME_DATABASE, group))
+    }
+}
+
+// 解析数据库配置项并添加到数据库配置中
+func addDatabaseConfig(m map[string]interface{}) {
+    for group, v := range m {
+        cg := gdb.ConfigGroup{}
+        if list, ok := v.([]interface{}); ok {
+            for _, nodev := range list {
+                node  := gdb.ConfigNode{}
+                nodem := nodev.(map[string]interface{})
+                if value, ok := nodem["host"]; ok {
+                    node.Host = gconv.String(value)
+                }
+                if value, ok := nodem["port"]; ok {
+                    node.Port = gconv.String(value)
+                }
+                if value, ok := nodem["user"]; ok {
+                    node.User = gconv.String(value)
+                }
+                if value, ok := nodem["pass"]; ok {
+                    node.Pass = gconv.String(value)
+                }
+                if value, ok := nodem["name"]; ok {
+                    node.Name = gconv.String(value)
+                }
+                if value, ok := nodem["type"]; ok {
+                    node.Type = gconv.String(value)
+                }
+                if value, ok := nodem["role"]; ok {
+                    node.Role = gconv.String(value)
+                }
+                if value, ok := nodem["charset"]; ok {
+                    node.Charset = gconv.String(value)
+                }
+                if value, ok := nodem["priority"]; ok {
+                    node.Priority = gconv.Int(value)
+                }
+                if value, ok := nodem["linkinfo"]; ok {
+                    node.Linkinfo = gconv.String(value)
+                }
+                if value, ok := nodem["max-idle"]; ok {
+                    node.MaxIdleConnCount = gconv.Int(value)
+                }
+                if value, ok := nodem["max-open"]; ok {
+                    node.MaxOpenConnCount = gconv.Int(value)
+                }
+                if value, ok := nodem["max-lifetime"]; ok {
+                    node.MaxConnLifetime = gconv.Int(value)
+                }
+                cg = append(cg, node)
+            }
+        }
+        gdb.AddConfigGroup(group, cg)
+    }
+}
+
 // Redis操作对象，使用了连接池
 func Redis(name...string) *gredis.Redis {
     config := Config()
@@ -197,19 +234,11 @@ func Redis(name...string) *gredis.Redis {
     key    := fmt.Sprintf("%s.%s", gFRAME_CORE_COMPONENT_NAME_REDIS, group)
     result := instances.GetOrSetFuncLock(key, func() interface{} {
         if m := config.GetMap("redis"); m != nil {
-            // host:port[,db[,pass]]
             if v, ok := m[group]; ok {
-                line     := gconv.String(v)
-                array, _ := gregex.MatchString(`(.+):(\d+),{0,1}(\d*),{0,1}(.*)`, line)
-                if len(array) > 4 {
-                    return gredis.New(gredis.Config{
-                        Host : array[1],
-                        Port : gconv.Int(array[2]),
-                        Db   : gconv.Int(array[3]),
-                        Pass : array[4],
-                    })
+                if redisConfig, err := parseRedisConfig(v); err == nil {
+                    return gredis.New(*redisConfig)
                 } else {
-                    glog.Errorfln(`invalid redis node configuration: "%s"`, line)
+                    glog.Error(err)
                 }
             } else {
                 glog.Errorfln(`configuration for redis not found for group "%s"`, group)
@@ -225,6 +254,88 @@ func Redis(name...string) *gredis.Redis {
     return nil
 }
 
+// 解析Redis分组配置，支持以下两种配置形式：
+// 1、字符串：host:port[,db[,pass]][?tls=true&skipVerify=true]，密码中可以包含?字符，例如：
+//     default = "127.0.0.1:6379,0"
+// 2、配置表(用于Sentinel及Cluster模式)，配置项：host, port, db, pass, master(Sentinel主节点名称),
+//    addrs(Sentinel或者Cluster节点地址列表), cluster, tls, skipVerify，例如：
+//     [redis.session]
+//         master = "mymaster"
+//         addrs  = ["192.168.1.1:26379", "192.168.1.2:26379"]
+//     [redis.cache]
+//         cluster = true
+//         addrs   = ["192.168.1.1:7000", "192.168.1.2:7000"]
+func parseRedisConfig(value interface{}) (*gredis.Config, error) {
+    if m, ok := value.(map[string]interface{}); ok {
+        options := make(map[string]interface{})
+        for k, v := range m {
+            options[strings.ToLower(k)] = v
+        }
+        config := &gredis.Config {
+            Host          : gconv.String(options["host"]),
+            Port          : gconv.Int(options["port"]),
+            Db            : gconv.Int(options["db"]),
+            Pass          : gconv.String(options["pass"]),
+            MasterName    : gconv.String(options["master"]),
+            Cluster       : gconv.Bool(options["cluster"]),
+            TLS           : gconv.Bool(options["tls"]),
+            TLSSkipVerify : gconv.Bool(options["skipverify"]),
+        }
+        // 节点地址列表也可以使用逗号分隔的字符串
+        if s, ok := options["addrs"].(string); ok {
+            config.Addrs = strings.Split(s, ",")
+        } else {
+            config.Addrs = gconv.Strings(options["addrs"])
+        }
+        for i, addr := range config.Addrs {
+            config.Addrs[i] = strings.TrimSpace(addr)
+        }
+        if (config.Cluster || config.MasterName != "") && len(config.Addrs) == 0 {
+            return nil, errors.New(`invalid redis configuration: "addrs" is required for sentinel or cluster mode`)
+        }
+        if !config.Cluster && config.MasterName == "" && (config.Host == "" || config.Port == 0) {
+            return nil, errors.New(`invalid redis configuration: "host" and "port" are required`)
+        }
+        return config, nil
+    }
+    line, params := splitRedisParams(gconv.String(value))
+    array, _     := gregex.MatchString(`(.+):(\d+),{0,1}(\d*),{0,1}(.*)`, line)
+    if len(array) <= 4 {
+        return nil, errors.New(fmt.Sprintf(`invalid redis node configuration: "%s"`, line))
+    }
+    config := &gredis.Config {
+        Host : array[1],
+        Port : gconv.Int(array[2]),
+        Db   : gconv.Int(array[3]),
+        Pass : array[4],
+    }
+    if params != nil {
+        config.TLS           = gconv.Bool(params.Get("tls"))
+        config.TLSSkipVerify = gconv.Bool(params.Get("skipVerify"))
+    }
+    return config, nil
+}
+
+// 拆分Redis字符串配置末尾的连接参数(?tls=true&skipVerify=true)，没有连接参数时返回nil。
+// 连接参数只能位于最后一个逗号分隔的字段中，并且参数名必须为已知的参数名，
+// 否则作为普通配置内容处理(例如密码中包含?字符的情况)。
+func splitRedisParams(line string) (string, url.Values) {
+    pos := strings.LastIndex(line, "?")
+    if pos < 0 || pos < strings.LastIndex(line, ",") {
+        return line, nil
+    }
+    params, err := url.ParseQuery(line[pos + 1 :])
+    if err != nil || len(params) == 0 {
+        return line, nil
+    }
+    for name := range params {
+        if name != "tls" && name != "skipVerify" {
+            return line, nil
+        }
+    }
+    return line[: pos], params
+}
+
 // 模板内置方法：config
 func funcConfig(pattern string, file...string) string {
     return Config().GetString(pattern, file...)
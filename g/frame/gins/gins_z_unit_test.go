@@ -0,0 +1,106 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gins
+
+import (
+    "database/sql"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/util/gtest"
+    "testing"
+)
+
+func Test_parseRedisConfig(t *testing.T) {
+    gtest.Case(t, func() {
+        cases := []struct {
+            value  interface{}
+            config gredis.Config
+        }{
+            {"127.0.0.1:6379",                        gredis.Config{Host : "127.0.0.1", Port : 6379}},
+            {"127.0.0.1:6379,1",                      gredis.Config{Host : "127.0.0.1", Port : 6379, Db : 1}},
+            {"127.0.0.1:6379,1,pass",                 gredis.Config{Host : "127.0.0.1", Port : 6379, Db : 1, Pass : "pass"}},
+            {"127.0.0.1:6379,1,pa?ss",                gredis.Config{Host : "127.0.0.1", Port : 6379, Db : 1, Pass : "pa?ss"}},
+            {"127.0.0.1:6379,1,pa?ss=1",              gredis.Config{Host : "127.0.0.1", Port : 6379, Db : 1, Pass : "pa?ss=1"}},
+            {"127.0.0.1:6379,1,pa?ss?tls=true",       gredis.Config{Host : "127.0.0.1", Port : 6379, Db : 1, Pass : "pa?ss", TLS : true}},
+            {"127.0.0.1:6379?tls=true&skipVerify=1",  gredis.Config{Host : "127.0.0.1", Port : 6379, TLS : true, TLSSkipVerify : true}},
+            {"127.0.0.1:6379,0,?tls=1",               gredis.Config{Host : "127.0.0.1", Port : 6379, TLS : true}},
+            {map[string]interface{} {
+                "Cluster" : true,
+                "addrs"   : "192.168.1.1:7000, 192.168.1.2:7000",
+                "pass"    : "p?w",
+            }, gredis.Config{Cluster : true, Addrs : []string{"192.168.1.1:7000", "192.168.1.2:7000"}, Pass : "p?w"}},
+            {map[string]interface{} {
+                "master" : "mymaster",
+                "addrs"  : []interface{}{"192.168.1.1:26379"},
+            }, gredis.Config{MasterName : "mymaster", Addrs : []string{"192.168.1.1:26379"}}},
+        }
+        for _, c := range cases {
+            config, err := parseRedisConfig(c.value)
+            gtest.Assert(err, nil)
+            gtest.Assert(*config, c.config)
+        }
+    })
+    gtest.Case(t, func() {
+        invalids := []interface{} {
+            "127.0.0.1",
+            map[string]interface{}{"host" : "127.0.0.1"},
+            map[string]interface{}{"cluster" : true},
+        }
+        for _, value := range invalids {
+            _, err := parseRedisConfig(value)
+            gtest.AssertNE(err, nil)
+        }
+    })
+}
+
+func Test_reloadDatabaseConfig(t *testing.T) {
+    gtest.Case(t, func() {
+        old := map[string]interface{} {
+            "gins_a" : []interface{}{map[string]interface{}{"type" : "mock", "name" : "gins_a"}},
+            "gins_b" : []interface{}{map[string]interface{}{"type" : "mock", "name" : "gins_b"}},
+        }
+        addDatabaseConfig(old)
+        dbs   := make(map[string]gdb.DB)
+        pools := make(map[string]*sql.DB)
+        for _, group := range []string{"gins_a", "gins_b"} {
+            db, err := gdb.New(group)
+            gtest.Assert(err, nil)
+            pool, err := db.Master()
+            gtest.Assert(err, nil)
+            dbs[group]   = db
+            pools[group] = pool
+            instances.Set(fmt.Sprintf("%s.%s", gFRAME_CORE_COMPONENT_NAME_DATABASE, group), db)
+        }
+        // 修改gins_a的配置，删除gins_b
+        new := map[string]interface{} {
+            "gins_a" : []interface{}{map[string]interface{}{"type" : "mock", "name" : "gins_a", "max-open" : 10}},
+        }
+        reloadDatabaseConfig(gvar.New(old, true), gvar.New(new, true))
+
+        gtest.Assert(gdb.GetConfig("gins_a")[0].MaxOpenConnCount, 10)
+        gtest.Assert(len(gdb.GetConfig("gins_b")), 0)
+        for group, pool := range pools {
+            gtest.Assert(instances.Contains(fmt.Sprintf("%s.%s", gFRAME_CORE_COMPONENT_NAME_DATABASE, group)), false)
+            // 已持有的数据库对象及连接池不会被关闭，仍然可以继续使用
+            gtest.Assert(pool.Ping(), nil)
+        }
+        db := dbs["gins_a"]
+        gdb.GetMock("gins_a").ExpectQuery(`SELECT 1`).WillReturnRows(gdb.List{{"v" : 1}})
+        value, err := db.GetValue("SELECT 1")
+        gtest.Assert(err, nil)
+        gtest.Assert(value.Int(), 1)
+        gtest.Assert(db.Close(), nil)
+
+        // 已删除的配置分组
+        _, err = dbs["gins_b"].Master()
+        gtest.AssertNE(err, nil)
+        _, err = gdb.New("gins_b")
+        gtest.AssertNE(err, nil)
+    })
+}
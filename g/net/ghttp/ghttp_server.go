@@ -361,6 +361,8 @@ func (s *Server) Run() error {
     <- doneChan
 
     glog.Printfln("%d: all servers shutdown", gproc.Pid())
+    // 服务关闭后刷新异步日志，防止进程退出时丢失日志
+    glog.Flush()
     return nil
 }
 
@@ -372,6 +374,8 @@ func Wait() {
     <- doneChan
 
     glog.Printfln("%d: all servers shutdown", gproc.Pid())
+    // 服务关闭后刷新异步日志，防止进程退出时丢失日志
+    glog.Flush()
 }
 
 
@@ -4,10 +4,11 @@
 // If a copy of the MIT was not distributed with this file,
 // You can obtain one at https://gitee.com/johng/gf.
 
-// Package gcache provides high performance and concurrent-safe in-memory cache for process.
+// Package gcache provides high performance and concurrent-safe in-memory cache for process,
+// and pluggable adapters for shared caches such as redis.
 // 
 // 缓存模块,
-// 并发安全的单进程高速缓存.
+// 并发安全的单进程高速缓存, 并可通过缓存适配器使用Redis等共享缓存.
 package gcache
 
 // 全局缓存管理对象
@@ -88,3 +89,18 @@ func Values() []interface{} {
 func Size() int {
     return cache.Size()
 }
+
+// (使用全局KV缓存对象)设置kv缓存键值对，并为该键名设置标签，过期时间单位为**毫秒**
+func SetWithTags(key interface{}, value interface{}, expire int, tags...string) {
+    cache.SetWithTags(key, value, expire, tags...)
+}
+
+// (使用全局KV缓存对象)删除指定标签(任意一个)对应的所有键值对
+func RemoveByTag(tags...string) {
+    cache.RemoveByTag(tags...)
+}
+
+// 获得全局KV缓存对象的统计信息
+func GetStats() Stats {
+    return cache.Stats()
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache
+
+// 缓存适配器接口，过期时间单位均为**毫秒**，expire为0表示不过期。
+// 内置的适配器实现：
+// 1、AdapterMemory  : 单进程内存缓存(默认)；
+// 2、AdapterRedis   : 基于gredis的缓存，键值通过Codec序列化后存储，多进程间共享；
+// 3、AdapterTwoLevel: 本地内存+Redis两级缓存，通过Redis发布/订阅机制通知其他进程清除本地缓存；
+type Adapter interface {
+    // 设置kv缓存键值对
+    Set(key interface{}, value interface{}, expire int)
+    // 当键名不存在时写入，并返回true；否则返回false
+    SetIfNotExist(key interface{}, value interface{}, expire int) bool
+    // 批量设置kv缓存键值对
+    BatchSet(data map[interface{}]interface{}, expire int)
+    // 获取指定键名的值，不存在时返回nil
+    Get(key interface{}) interface{}
+    // 当键名存在时返回其键值，否则写入指定的键值
+    GetOrSet(key interface{}, value interface{}, expire int) interface{}
+    // 当键名存在时返回其键值，否则写入指定的键值，键值由指定的函数生成
+    GetOrSetFunc(key interface{}, f func() interface{}, expire int) interface{}
+    // 与GetOrSetFunc不同的是，f是在写锁机制内执行
+    GetOrSetFuncLock(key interface{}, f func() interface{}, expire int) interface{}
+    // 是否存在指定的键名
+    Contains(key interface{}) bool
+    // 删除指定键值对，并返回被删除的键值
+    Remove(key interface{}) interface{}
+    // 批量删除键值对
+    BatchRemove(keys []interface{})
+    // 返回缓存的所有数据键值对(不包含已过期数据)
+    Data() map[interface{}]interface{}
+    // 获得所有的键名，组成数组返回
+    Keys() []interface{}
+    // 获得所有的键名，组成字符串数组返回
+    KeyStrings() []string
+    // 获得所有的值，组成数组返回
+    Values() []interface{}
+    // 获得缓存对象的键值对数量
+    Size() int
+    // 清空缓存中的所有数据
+    Clear()
+    // 关闭缓存对象
+    Close()
+}
+
+// 支持缓存标签的适配器接口(内置的适配器均已实现)，用于按照标签批量删除键值对，
+// 例如：将用户42相关的所有缓存设置标签"user_42"，用户信息变更时通过RemoveByTag("user_42")删除这些缓存。
+type TagAdapter interface {
+    // 设置kv缓存键值对，并为该键名设置标签
+    SetWithTags(key interface{}, value interface{}, expire int, tags []string)
+    // 删除指定标签(任意一个)对应的所有键值对
+    RemoveByTag(tags...string)
+}
+
+// 支持淘汰数量统计的适配器接口
+type evictionCounter interface {
+    getEvictions() int64
+}
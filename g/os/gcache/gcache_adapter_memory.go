@@ -0,0 +1,44 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache
+
+import (
+    "gitee.com/johng/gf/g/os/gtimer"
+    "sync/atomic"
+    "time"
+    "unsafe"
+)
+
+// 内存缓存适配器。
+// 底层只有一个缓存对象，如果需要提高并发性能，可新增缓存对象无锁哈希表，用键名做固定分区。
+type AdapterMemory struct {
+    *memCache
+}
+
+// 创建内存缓存适配器，lruCap参数用于限定缓存池大小(LRU)
+func NewAdapterMemory(lruCap...int) *AdapterMemory {
+    a := &AdapterMemory {
+        memCache : newMemCache(lruCap...),
+    }
+    gtimer.AddSingleton(time.Second, a.memCache.syncEventAndClearExpired)
+    return a
+}
+
+// 清空缓存中的所有数据
+func (a *AdapterMemory) Clear() {
+    c := newMemCache()
+    if a.cap > 0 {
+        c = newMemCache(a.cap)
+    }
+    // 淘汰统计数量在清空缓存后继续累加
+    c.evictions = a.evictions
+    gtimer.AddSingleton(time.Second, c.syncEventAndClearExpired)
+    // 使用原子操作替换缓存对象
+    old := atomic.SwapPointer((*unsafe.Pointer)(unsafe.Pointer(&a.memCache)), unsafe.Pointer(c))
+    // 关闭旧的缓存对象(旧对象的异步任务将会自动退出)
+    (*memCache)(old).Close()
+}
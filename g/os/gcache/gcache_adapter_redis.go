@@ -0,0 +1,335 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache
+
+import (
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/os/gmlock"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "strings"
+)
+
+const (
+    gREDIS_SCAN_COUNT     = 1000           // SCAN/MGET命令每次处理的键名数量
+    gREDIS_TAG_PREFIX     = "gcache:tag:"  // 标签集合的键名前缀，标签集合中存储的是带前缀的键名
+    gREDIS_DEFAULT_PREFIX = "gcache:data:" // 未指定键名前缀时使用的默认前缀
+)
+
+// 基于gredis的缓存适配器，多个进程可以通过同一Redis服务共享缓存数据。
+// 需要注意的是：
+// 1、键名统一转换为字符串并加上前缀后存储，因此Keys/Data返回的键名均为(不带前缀的)字符串；
+// 2、键值通过Codec序列化后存储，默认使用gob序列化；
+// 3、Redis操作失败时等同于缓存未命中(写入操作失败时忽略)；
+// 4、Data/Keys/Values/Size/Clear通过SCAN命令遍历前缀匹配的键名(Cluster模式下遍历所有的主节点)，
+//    键名前缀不能为空(未指定时使用默认前缀)，以避免Clear等操作影响到同一数据库中其他用途的数据；
+type AdapterRedis struct {
+    redis  *gredis.Redis // Redis客户端
+    prefix string        // 键名前缀
+    codec  Codec         // 键值序列化对象
+}
+
+// 创建Redis缓存适配器，prefix为可选的键名前缀，未指定或者为空时使用默认前缀"gcache:data:"，
+// 建议不同用途的缓存使用不同的前缀
+func NewAdapterRedis(redis *gredis.Redis, prefix...string) *AdapterRedis {
+    a := &AdapterRedis {
+        redis  : redis,
+        prefix : gREDIS_DEFAULT_PREFIX,
+        codec  : CodecGob{},
+    }
+    if len(prefix) > 0 && prefix[0] != "" {
+        a.prefix = prefix[0]
+    }
+    return a
+}
+
+// 设置键值序列化对象
+func (a *AdapterRedis) SetCodec(codec Codec) {
+    a.codec = codec
+}
+
+// 获得Redis中存储的键名
+func (a *AdapterRedis) getKey(key interface{}) string {
+    return a.prefix + gconv.String(key)
+}
+
+// 获得SCAN命令的键名匹配模式(前缀中的通配符需要转义)
+func (a *AdapterRedis) getPattern() string {
+    replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+    return replacer.Replace(a.prefix) + "*"
+}
+
+// 生成SET命令参数，expire<0时返回nil(表示直接删除)
+func (a *AdapterRedis) getSetArgs(key interface{}, value interface{}, expire int) []interface{} {
+    if expire < 0 {
+        return nil
+    }
+    data, err := a.codec.Encode(value)
+    if err != nil {
+        return nil
+    }
+    args := []interface{}{a.getKey(key), data}
+    if expire > 0 {
+        args = append(args, "PX", expire)
+    }
+    return args
+}
+
+// 反序列化Redis返回的键值，不存在或者失败时返回nil
+func (a *AdapterRedis) decode(reply interface{}) interface{} {
+    data, err := redis.Bytes(reply, nil)
+    if err != nil {
+        return nil
+    }
+    value, err := a.codec.Decode(data)
+    if err != nil {
+        return nil
+    }
+    return value
+}
+
+// 设置kv缓存键值对，过期时间单位为毫秒，expire<0表示立即过期
+func (a *AdapterRedis) Set(key interface{}, value interface{}, expire int) {
+    if args := a.getSetArgs(key, value, expire); args != nil {
+        a.redis.Do("SET", args...)
+    } else {
+        a.redis.Do("DEL", a.getKey(key))
+    }
+}
+
+// 当键名不存在时写入，并返回true；否则返回false。
+func (a *AdapterRedis) SetIfNotExist(key interface{}, value interface{}, expire int) bool {
+    if f, ok := value.(func() interface {}); ok {
+        value = f()
+    }
+    args := a.getSetArgs(key, value, expire)
+    if args == nil {
+        return false
+    }
+    reply, err := redis.String(a.redis.Do("SET", append(args, "NX")...))
+    return err == nil && reply == "OK"
+}
+
+// 批量设置，所有命令以pipeline方式执行(Cluster模式下按照节点分组执行)
+func (a *AdapterRedis) BatchSet(data map[interface{}]interface{}, expire int) {
+    pipeline := a.redis.Pipeline()
+    for k, v := range data {
+        if args := a.getSetArgs(k, v, expire); args != nil {
+            pipeline.Add("SET", args...)
+        } else {
+            pipeline.Add("DEL", a.getKey(k))
+        }
+    }
+    pipeline.Exec()
+}
+
+// 获取指定键名的值
+func (a *AdapterRedis) Get(key interface{}) interface{} {
+    reply, _ := a.redis.Do("GET", a.getKey(key))
+    return a.decode(reply)
+}
+
+// 获取指定键名的值及剩余的过期时间(毫秒，0表示不过期)，不存在时返回nil
+func (a *AdapterRedis) getWithExpire(key interface{}) (interface{}, int) {
+    replies, err := a.redis.Pipeline().Add("GET", a.getKey(key)).Add("PTTL", a.getKey(key)).Exec()
+    if err != nil || len(replies) != 2 {
+        return nil, 0
+    }
+    value := a.decode(replies[0].Val())
+    if value == nil {
+        return nil, 0
+    }
+    if ttl := replies[1].Int(); ttl > 0 {
+        return value, ttl
+    }
+    return value, 0
+}
+
+// 当键名存在时返回其键值，否则写入指定的键值
+func (a *AdapterRedis) GetOrSet(key interface{}, value interface{}, expire int) interface{} {
+    if v := a.Get(key); v != nil {
+        return v
+    }
+    if a.SetIfNotExist(key, value, expire) {
+        return value
+    }
+    return a.Get(key)
+}
+
+// 当键名存在时返回其键值，否则写入指定的键值，键值由指定的函数生成
+func (a *AdapterRedis) GetOrSetFunc(key interface{}, f func() interface{}, expire int) interface{} {
+    if v := a.Get(key); v != nil {
+        return v
+    }
+    return a.GetOrSet(key, f(), expire)
+}
+
+// 与GetOrSetFunc不同的是，f是在写锁机制内执行，需要注意的是该写锁只对当前进程有效
+func (a *AdapterRedis) GetOrSetFuncLock(key interface{}, f func() interface{}, expire int) interface{} {
+    if v := a.Get(key); v != nil {
+        return v
+    }
+    lockKey := "gcache_redis_" + a.getKey(key)
+    gmlock.Lock(lockKey)
+    defer gmlock.Unlock(lockKey)
+    return a.GetOrSetFunc(key, f, expire)
+}
+
+// 是否存在指定的键名，true表示存在，false表示不存在。
+func (a *AdapterRedis) Contains(key interface{}) bool {
+    n, err := redis.Int(a.redis.Do("EXISTS", a.getKey(key)))
+    return err == nil && n > 0
+}
+
+// 删除指定键值对，并返回被删除的键值
+func (a *AdapterRedis) Remove(key interface{}) interface{} {
+    replies, err := a.redis.Pipeline().Add("GET", a.getKey(key)).Add("DEL", a.getKey(key)).Exec()
+    if err != nil || len(replies) != 2 {
+        return nil
+    }
+    return a.decode(replies[0].Val())
+}
+
+// 批量删除键值对
+func (a *AdapterRedis) BatchRemove(keys []interface{}) {
+    args := make([]interface{}, len(keys))
+    for i, key := range keys {
+        args[i] = a.getKey(key)
+    }
+    a.removeKeys(args)
+}
+
+// 分批删除Redis中的键名(带前缀)
+func (a *AdapterRedis) removeKeys(keys []interface{}) {
+    for len(keys) > 0 {
+        n := len(keys)
+        if n > gREDIS_SCAN_COUNT {
+            n = gREDIS_SCAN_COUNT
+        }
+        a.redis.Do("DEL", keys[: n]...)
+        keys = keys[n :]
+    }
+}
+
+// 遍历前缀匹配的所有键名(带前缀)，Cluster模式下将会遍历所有的主节点
+func (a *AdapterRedis) scanKeys() []string {
+    keys, err := a.redis.Scan(a.getPattern(), gREDIS_SCAN_COUNT)
+    if err != nil {
+        return []string{}
+    }
+    return keys
+}
+
+// 返回缓存的所有数据键值对(不包含已过期数据)，键名为不带前缀的字符串
+func (a *AdapterRedis) Data() map[interface{}]interface{} {
+    m    := make(map[interface{}]interface{})
+    keys := a.scanKeys()
+    for len(keys) > 0 {
+        n := len(keys)
+        if n > gREDIS_SCAN_COUNT {
+            n = gREDIS_SCAN_COUNT
+        }
+        replies, err := redis.Values(a.redis.Do("MGET", gconv.Interfaces(keys[: n])...))
+        if err == nil {
+            for i, reply := range replies {
+                if value := a.decode(reply); value != nil {
+                    m[keys[i][len(a.prefix) :]] = value
+                }
+            }
+        }
+        keys = keys[n :]
+    }
+    return m
+}
+
+// 获得所有的键名，组成数组返回
+func (a *AdapterRedis) Keys() []interface{} {
+    keys  := a.scanKeys()
+    array := make([]interface{}, len(keys))
+    for i, key := range keys {
+        array[i] = key[len(a.prefix) :]
+    }
+    return array
+}
+
+// 获得所有的键名，组成字符串数组返回
+func (a *AdapterRedis) KeyStrings() []string {
+    return gconv.Strings(a.Keys())
+}
+
+// 获得所有的值，组成数组返回
+func (a *AdapterRedis) Values() []interface{} {
+    values := make([]interface{}, 0)
+    for _, v := range a.Data() {
+        values = append(values, v)
+    }
+    return values
+}
+
+// 获得缓存对象的键值对数量
+func (a *AdapterRedis) Size() int {
+    return len(a.scanKeys())
+}
+
+// 清空缓存中的所有数据(只删除前缀匹配的键名)
+func (a *AdapterRedis) Clear() {
+    a.removeKeys(gconv.Interfaces(a.scanKeys()))
+}
+
+// 获得标签集合在Redis中的键名
+func (a *AdapterRedis) getTagKey(tag string) string {
+    return gREDIS_TAG_PREFIX + a.prefix + tag
+}
+
+// 设置kv缓存键值对，并为该键名设置标签，标签集合存储在Redis中，因此标签对所有进程有效。
+// 标签集合的过期时间不小于其中键名的最大过期时间，已过期的键名将会在RemoveByTag时一并清理。
+func (a *AdapterRedis) SetWithTags(key interface{}, value interface{}, expire int, tags []string) {
+    a.Set(key, value, expire)
+    if len(tags) == 0 || expire < 0 {
+        return
+    }
+    for _, tag := range tags {
+        // 写入前的剩余过期时间：-2表示集合不存在，-1表示集合不过期
+        tagKey   := a.getTagKey(tag)
+        ttl, err := redis.Int(a.redis.Do("PTTL", tagKey))
+        if err != nil {
+            continue
+        }
+        a.redis.Do("SADD", tagKey, a.getKey(key))
+        if expire == 0 {
+            a.redis.Do("PERSIST", tagKey)
+        } else if ttl != -1 && ttl < expire {
+            a.redis.Do("PEXPIRE", tagKey, expire)
+        }
+    }
+}
+
+// 删除指定标签(任意一个)对应的所有键值对
+func (a *AdapterRedis) RemoveByTag(tags...string) {
+    a.removeByTag(tags)
+}
+
+// 删除指定标签对应的所有键值对，返回被删除的键名列表(不带前缀)
+func (a *AdapterRedis) removeByTag(tags []string) []string {
+    keys := make([]string, 0)
+    for _, tag := range tags {
+        array, err := redis.Strings(a.redis.Do("SMEMBERS", a.getTagKey(tag)))
+        if err != nil {
+            continue
+        }
+        a.removeKeys(append(gconv.Interfaces(array), a.getTagKey(tag)))
+        for _, key := range array {
+            keys = append(keys, key[len(a.prefix) :])
+        }
+    }
+    return keys
+}
+
+// 关闭缓存对象，Redis客户端由调用方管理，这里不做处理
+func (a *AdapterRedis) Close() {
+
+}
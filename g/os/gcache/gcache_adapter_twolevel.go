@@ -0,0 +1,297 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache
+
+import (
+    "encoding/json"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/third/github.com/gomodule/redigo/redis"
+    "os"
+    "sync"
+    "time"
+)
+
+const (
+    gTWO_LEVEL_RECONNECT_INTERVAL = time.Second // 订阅链接断开后的重连间隔
+)
+
+// 本地内存+Redis两级缓存适配器。
+// 读取时优先读取本地缓存，未命中时读取Redis并写入本地缓存(过期时间与Redis中的剩余过期时间一致)；
+// 写入/删除时同时操作本地缓存及Redis，并通过Redis的发布/订阅机制通知其他进程删除对应的本地缓存。
+// 需要注意的是：
+// 1、键名统一转换为字符串；
+// 2、订阅链接断开期间可能会错过失效通知，因此订阅链接重连时将会清空本地缓存；
+// 3、Data/Keys/Values/Size以Redis中的数据为准；
+type AdapterTwoLevel struct {
+    mu      sync.Mutex
+    local   *AdapterMemory     // 本地缓存
+    remote  *AdapterRedis      // Redis缓存
+    channel string             // 失效通知的发布/订阅频道
+    node    string             // 当前节点标识，用于忽略自身发出的失效通知
+    psc     *redis.PubSubConn  // 当前订阅链接
+    closed  *gtype.Bool        // 是否已关闭
+}
+
+// 失效通知消息
+type twoLevelMessage struct {
+    Node  string   `json:"node"`  // 发送节点标识
+    Keys  []string `json:"keys"`  // 需要删除的键名列表
+    Clear bool     `json:"clear"` // 是否清空所有本地缓存
+}
+
+// 创建两级缓存适配器，channel为失效通知的发布/订阅频道名称(使用同一Redis缓存的进程需要使用相同的频道)，
+// lruCap参数用于限定本地缓存池大小(LRU)
+func NewAdapterTwoLevel(remote *AdapterRedis, channel string, lruCap...int) *AdapterTwoLevel {
+    hostname, _ := os.Hostname()
+    a := &AdapterTwoLevel {
+        local   : NewAdapterMemory(lruCap...),
+        remote  : remote,
+        channel : channel,
+        node    : fmt.Sprintf("%s/%d/%d", hostname, os.Getpid(), time.Now().UnixNano()),
+        closed  : gtype.NewBool(),
+    }
+    go a.subscribe()
+    return a
+}
+
+// 订阅失效通知，链接断开时自动重连，直到适配器关闭
+func (a *AdapterTwoLevel) subscribe() {
+    for !a.closed.Val() {
+        conn := a.remote.redis.GetConn()
+        psc  := &redis.PubSubConn{Conn : conn}
+        if err := psc.Subscribe(a.channel); err == nil {
+            a.mu.Lock()
+            a.psc = psc
+            if a.closed.Val() {
+                psc.Unsubscribe()
+            }
+            a.mu.Unlock()
+            a.receive(psc)
+            a.mu.Lock()
+            a.psc = nil
+            a.mu.Unlock()
+        }
+        conn.Close()
+        if !a.closed.Val() {
+            // 订阅中断期间可能错过了失效通知
+            a.local.Clear()
+            time.Sleep(gTWO_LEVEL_RECONNECT_INTERVAL)
+        }
+    }
+}
+
+// 接收并处理失效通知，链接出错或者取消订阅时返回
+func (a *AdapterTwoLevel) receive(psc *redis.PubSubConn) {
+    for {
+        switch v := psc.Receive().(type) {
+            case redis.Message:
+                message := twoLevelMessage{}
+                if json.Unmarshal(v.Data, &message) != nil || message.Node == a.node {
+                    continue
+                }
+                if message.Clear {
+                    a.local.Clear()
+                } else {
+                    a.local.BatchRemove(gconv.Interfaces(message.Keys))
+                }
+            case redis.Subscription:
+                if v.Count == 0 {
+                    return
+                }
+            case error:
+                return
+        }
+    }
+}
+
+// 发布失效通知
+func (a *AdapterTwoLevel) publish(keys []string, clear bool) {
+    data, err := json.Marshal(twoLevelMessage {
+        Node  : a.node,
+        Keys  : keys,
+        Clear : clear,
+    })
+    if err == nil {
+        a.remote.redis.Do("PUBLISH", a.channel, data)
+    }
+}
+
+// 设置kv缓存键值对，过期时间单位为毫秒
+func (a *AdapterTwoLevel) Set(key interface{}, value interface{}, expire int) {
+    k := gconv.String(key)
+    a.remote.Set(k, value, expire)
+    a.local.Set(k, value, expire)
+    a.publish([]string{k}, false)
+}
+
+// 当键名不存在时写入，并返回true；否则返回false。
+func (a *AdapterTwoLevel) SetIfNotExist(key interface{}, value interface{}, expire int) bool {
+    if f, ok := value.(func() interface {}); ok {
+        value = f()
+    }
+    k := gconv.String(key)
+    if a.remote.SetIfNotExist(k, value, expire) {
+        a.local.Set(k, value, expire)
+        a.publish([]string{k}, false)
+        return true
+    }
+    return false
+}
+
+// 批量设置
+func (a *AdapterTwoLevel) BatchSet(data map[interface{}]interface{}, expire int) {
+    keys := make([]string, 0, len(data))
+    m    := make(map[interface{}]interface{}, len(data))
+    for k, v := range data {
+        key   := gconv.String(k)
+        m[key] = v
+        keys   = append(keys, key)
+    }
+    a.remote.BatchSet(m, expire)
+    a.local.BatchSet(m, expire)
+    a.publish(keys, false)
+}
+
+// 获取指定键名的值，本地缓存未命中时读取Redis
+func (a *AdapterTwoLevel) Get(key interface{}) interface{} {
+    k := gconv.String(key)
+    if v := a.local.Get(k); v != nil {
+        return v
+    }
+    v, expire := a.remote.getWithExpire(k)
+    if v != nil {
+        a.local.Set(k, v, expire)
+    }
+    return v
+}
+
+// 当键名存在时返回其键值，否则写入指定的键值
+func (a *AdapterTwoLevel) GetOrSet(key interface{}, value interface{}, expire int) interface{} {
+    if v := a.Get(key); v != nil {
+        return v
+    }
+    k := gconv.String(key)
+    v := a.remote.GetOrSet(k, value, expire)
+    if v != nil {
+        a.local.Set(k, v, expire)
+    }
+    return v
+}
+
+// 当键名存在时返回其键值，否则写入指定的键值，键值由指定的函数生成
+func (a *AdapterTwoLevel) GetOrSetFunc(key interface{}, f func() interface{}, expire int) interface{} {
+    if v := a.Get(key); v != nil {
+        return v
+    }
+    return a.GetOrSet(key, f(), expire)
+}
+
+// 与GetOrSetFunc不同的是，f是在写锁机制内执行，需要注意的是该写锁只对当前进程有效
+func (a *AdapterTwoLevel) GetOrSetFuncLock(key interface{}, f func() interface{}, expire int) interface{} {
+    if v := a.Get(key); v != nil {
+        return v
+    }
+    k := gconv.String(key)
+    v := a.remote.GetOrSetFuncLock(k, f, expire)
+    if v != nil {
+        a.local.Set(k, v, expire)
+    }
+    return v
+}
+
+// 是否存在指定的键名，true表示存在，false表示不存在。
+func (a *AdapterTwoLevel) Contains(key interface{}) bool {
+    k := gconv.String(key)
+    return a.local.Contains(k) || a.remote.Contains(k)
+}
+
+// 删除指定键值对，并返回被删除的键值
+func (a *AdapterTwoLevel) Remove(key interface{}) interface{} {
+    k := gconv.String(key)
+    v := a.local.Remove(k)
+    if r := a.remote.Remove(k); r != nil {
+        v = r
+    }
+    a.publish([]string{k}, false)
+    return v
+}
+
+// 批量删除键值对
+func (a *AdapterTwoLevel) BatchRemove(keys []interface{}) {
+    array := gconv.Strings(keys)
+    keys   = gconv.Interfaces(array)
+    a.remote.BatchRemove(keys)
+    a.local.BatchRemove(keys)
+    a.publish(array, false)
+}
+
+// 设置kv缓存键值对，并为该键名设置标签(标签存储在Redis中)
+func (a *AdapterTwoLevel) SetWithTags(key interface{}, value interface{}, expire int, tags []string) {
+    k := gconv.String(key)
+    a.remote.SetWithTags(k, value, expire, tags)
+    a.local.Set(k, value, expire)
+    a.publish([]string{k}, false)
+}
+
+// 删除指定标签(任意一个)对应的所有键值对，并通知其他进程删除对应的本地缓存
+func (a *AdapterTwoLevel) RemoveByTag(tags...string) {
+    keys := a.remote.removeByTag(tags)
+    if len(keys) > 0 {
+        a.local.BatchRemove(gconv.Interfaces(keys))
+        a.publish(keys, false)
+    }
+}
+
+// 获得本地缓存过期及LRU淘汰的键值对数量
+func (a *AdapterTwoLevel) getEvictions() int64 {
+    return a.local.getEvictions()
+}
+
+// 返回缓存的所有数据键值对(以Redis中的数据为准)
+func (a *AdapterTwoLevel) Data() map[interface{}]interface{} {
+    return a.remote.Data()
+}
+
+// 获得所有的键名，组成数组返回
+func (a *AdapterTwoLevel) Keys() []interface{} {
+    return a.remote.Keys()
+}
+
+// 获得所有的键名，组成字符串数组返回
+func (a *AdapterTwoLevel) KeyStrings() []string {
+    return a.remote.KeyStrings()
+}
+
+// 获得所有的值，组成数组返回
+func (a *AdapterTwoLevel) Values() []interface{} {
+    return a.remote.Values()
+}
+
+// 获得缓存对象的键值对数量
+func (a *AdapterTwoLevel) Size() int {
+    return a.remote.Size()
+}
+
+// 清空缓存中的所有数据，并通知其他进程清空本地缓存
+func (a *AdapterTwoLevel) Clear() {
+    a.remote.Clear()
+    a.local.Clear()
+    a.publish(nil, true)
+}
+
+// 关闭缓存对象，取消失效通知的订阅并关闭本地缓存
+func (a *AdapterTwoLevel) Close() {
+    a.mu.Lock()
+    a.closed.Set(true)
+    if a.psc != nil {
+        a.psc.Unsubscribe()
+    }
+    a.mu.Unlock()
+    a.local.Close()
+}
@@ -7,31 +7,257 @@
 package gcache
 
 import (
-    "gitee.com/johng/gf/g/os/gtimer"
-    "sync/atomic"
-    "time"
-    "unsafe"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/gtime"
+    "os"
+    "runtime/debug"
+    "sync"
 )
 
-// 缓存对象。
-// 底层只有一个缓存对象，如果需要提高并发性能，可新增缓存对象无锁哈希表，用键名做固定分区。
+// 缓存对象，具体的缓存操作由底层的缓存适配器实现，默认使用内存缓存适配器。
 type Cache struct {
-    *memCache
+    Adapter
+    hits    *gtype.Int64                 // 读取命中次数
+    misses  *gtype.Int64                 // 读取未命中次数
+    stale   *gtype.Int                   // (毫秒)键值过期后仍然可以返回旧值的时间
+    callsMu sync.Mutex
+    calls   map[interface{}]*cacheCall   // 正在加载中的键名(同一键名同时只有一个goroutine执行加载)
 }
 
-// Cache对象按照缓存键名首字母做了分组
+// 缓存统计信息
+type Stats struct {
+    Hits      int64 // 读取命中次数
+    Misses    int64 // 读取未命中次数
+    Evictions int64 // 过期及LRU淘汰的键值对数量(仅对内存缓存有效)
+    Size      int   // 当前键值对数量
+}
+
+// 正在加载中的键值
+type cacheCall struct {
+    wg    sync.WaitGroup
+    value interface{}
+}
+
+// 支持过期后返回旧值的键值，缓存适配器中的实际过期时间为: 新鲜时间 + 旧值保留时间
+type staleValue struct {
+    Value  interface{} // 键值
+    Expire int64       // (毫秒时间戳)新鲜时间截止，超过该时间后返回旧值并在后台刷新
+}
+
+// 创建使用内存缓存适配器的缓存对象，lruCap参数用于限定缓存池大小(LRU)
 func New(lruCap...int) *Cache {
-    c := &Cache {
-        memCache : newMemCache(lruCap...),
+    return NewWithAdapter(NewAdapterMemory(lruCap...))
+}
+
+// 创建使用指定缓存适配器的缓存对象，例如：gcache.NewWithAdapter(gcache.NewAdapterRedis(redis))
+func NewWithAdapter(adapter Adapter) *Cache {
+    return &Cache {
+        Adapter : adapter,
+        hits    : gtype.NewInt64(),
+        misses  : gtype.NewInt64(),
+        stale   : gtype.NewInt(),
+        calls   : make(map[interface{}]*cacheCall),
+    }
+}
+
+// 设置键值过期后仍然可以返回旧值的时间(毫秒)，默认为0表示不启用。
+// 启用后，通过GetOrSetFunc/GetOrSetFuncLock写入的键值在过期后的stale时间内，读取时直接返回旧值，
+// 同时由一个goroutine在后台执行f刷新键值(f产生panic时保留旧值)，从而避免键值过期时的读取延迟。
+func (c *Cache) SetStaleTime(stale int) {
+    c.stale.Set(stale)
+}
+
+// 获取指定键名的值
+func (c *Cache) Get(key interface{}) interface{} {
+    return c.count(unwrapStaleValue(c.Adapter.Get(key)))
+}
+
+// 当键名存在时返回其键值，否则写入指定的键值
+func (c *Cache) GetOrSet(key interface{}, value interface{}, expire int) interface{} {
+    if v := c.Get(key); v != nil {
+        return v
     }
-    gtimer.AddSingleton(time.Second, c.syncEventAndClearExpired)
-    return c
+    return unwrapStaleValue(c.Adapter.GetOrSet(key, value, expire))
 }
 
-// 清空缓存中的所有数据
-func (c *Cache) Clear() {
-    // 使用原子操作替换缓存对象
-    old := atomic.SwapPointer((*unsafe.Pointer)(unsafe.Pointer(&c.memCache)), unsafe.Pointer(newMemCache()))
-    // 关闭旧的缓存对象
-    (*memCache)(old).Close()
-}
\ No newline at end of file
+// 当键名存在时返回其键值，否则写入指定的键值，键值由指定的函数生成
+func (c *Cache) GetOrSetFunc(key interface{}, f func() interface{}, expire int) interface{} {
+    if c.stale.Val() > 0 && expire > 0 {
+        return c.getOrSetFuncStale(key, f, expire)
+    }
+    if v := c.Get(key); v != nil {
+        return v
+    }
+    return unwrapStaleValue(c.Adapter.GetOrSetFunc(key, f, expire))
+}
+
+// 与GetOrSetFunc不同的是，f是在写锁机制内执行，并且同一进程中同一键名同时只有一个goroutine执行加载，
+// 其他goroutine等待加载完成后直接使用加载结果
+func (c *Cache) GetOrSetFuncLock(key interface{}, f func() interface{}, expire int) interface{} {
+    if c.stale.Val() > 0 && expire > 0 {
+        return c.getOrSetFuncStale(key, f, expire)
+    }
+    if v := c.Get(key); v != nil {
+        return v
+    }
+    return c.doCall(key, func() interface{} {
+        return unwrapStaleValue(c.Adapter.GetOrSetFuncLock(key, f, expire))
+    })
+}
+
+// 删除指定键值对，并返回被删除的键值
+func (c *Cache) Remove(key interface{}) interface{} {
+    return unwrapStaleValue(c.Adapter.Remove(key))
+}
+
+// 返回缓存的所有数据键值对(不包含已过期数据)
+func (c *Cache) Data() map[interface{}]interface{} {
+    data := c.Adapter.Data()
+    for k, v := range data {
+        data[k] = unwrapStaleValue(v)
+    }
+    return data
+}
+
+// 获得所有的值，组成数组返回
+func (c *Cache) Values() []interface{} {
+    values := c.Adapter.Values()
+    for i, v := range values {
+        values[i] = unwrapStaleValue(v)
+    }
+    return values
+}
+
+// 设置kv缓存键值对，并为该键名设置标签，之后可以通过RemoveByTag删除指定标签对应的所有键值对。
+// 当缓存适配器不支持标签(未实现TagAdapter接口)时，标签将会被忽略。
+func (c *Cache) SetWithTags(key interface{}, value interface{}, expire int, tags...string) {
+    if adapter, ok := c.Adapter.(TagAdapter); ok {
+        adapter.SetWithTags(key, value, expire, tags)
+    } else {
+        c.Adapter.Set(key, value, expire)
+    }
+}
+
+// 删除指定标签(任意一个)对应的所有键值对
+func (c *Cache) RemoveByTag(tags...string) {
+    if adapter, ok := c.Adapter.(TagAdapter); ok {
+        adapter.RemoveByTag(tags...)
+    }
+}
+
+// 获得缓存统计信息
+func (c *Cache) Stats() Stats {
+    stats := Stats {
+        Hits   : c.hits.Val(),
+        Misses : c.misses.Val(),
+        Size   : c.Adapter.Size(),
+    }
+    if counter, ok := c.Adapter.(evictionCounter); ok {
+        stats.Evictions = counter.getEvictions()
+    }
+    return stats
+}
+
+// 记录读取命中/未命中次数，并返回给定的键值
+func (c *Cache) count(value interface{}) interface{} {
+    if value != nil {
+        c.hits.Add(1)
+    } else {
+        c.misses.Add(1)
+    }
+    return value
+}
+
+// 执行键值加载，同一键名同时只有一个goroutine执行f，其他goroutine等待并使用其结果
+func (c *Cache) doCall(key interface{}, f func() interface{}) interface{} {
+    c.callsMu.Lock()
+    if call, ok := c.calls[key]; ok {
+        c.callsMu.Unlock()
+        call.wg.Wait()
+        return call.value
+    }
+    call := &cacheCall{}
+    call.wg.Add(1)
+    c.calls[key] = call
+    c.callsMu.Unlock()
+    defer func() {
+        c.callsMu.Lock()
+        delete(c.calls, key)
+        c.callsMu.Unlock()
+        call.wg.Done()
+    }()
+    call.value = f()
+    return call.value
+}
+
+// 在后台刷新键值，同一键名已经在加载中时不做处理
+func (c *Cache) doRefresh(key interface{}, f func() interface{}, expire int) {
+    c.callsMu.Lock()
+    if _, ok := c.calls[key]; ok {
+        c.callsMu.Unlock()
+        return
+    }
+    call := &cacheCall{}
+    call.wg.Add(1)
+    c.calls[key] = call
+    c.callsMu.Unlock()
+    go func() {
+        defer func() {
+            // f产生panic时保留旧值(gcache不能依赖glog，直接输出到标准错误)
+            if e := recover(); e != nil {
+                fmt.Fprintln(os.Stderr, fmt.Sprintf("[gcache] refresh key \"%v\" panic: %v\n%s", key, e, debug.Stack()))
+            }
+            c.callsMu.Lock()
+            delete(c.calls, key)
+            c.callsMu.Unlock()
+            call.wg.Done()
+        }()
+        call.value = f()
+        c.setStaleValue(key, call.value, expire)
+    }()
+}
+
+// 写入支持过期后返回旧值的键值
+func (c *Cache) setStaleValue(key interface{}, value interface{}, expire int) {
+    c.Adapter.Set(key, staleValue {
+        Value  : value,
+        Expire : gtime.Millisecond() + int64(expire),
+    }, expire + c.stale.Val())
+}
+
+// 启用旧值返回时的GetOrSetFunc实现
+func (c *Cache) getOrSetFuncStale(key interface{}, f func() interface{}, expire int) interface{} {
+    switch v := c.Adapter.Get(key).(type) {
+        case nil:
+        case staleValue:
+            c.hits.Add(1)
+            if gtime.Millisecond() > v.Expire {
+                c.doRefresh(key, f, expire)
+            }
+            return v.Value
+        default:
+            c.hits.Add(1)
+            return v
+    }
+    c.misses.Add(1)
+    return c.doCall(key, func() interface{} {
+        // 二次检索，其他进程可能已经完成加载
+        if v, ok := c.Adapter.Get(key).(staleValue); ok && gtime.Millisecond() <= v.Expire {
+            return v.Value
+        }
+        value := f()
+        if value != nil {
+            c.setStaleValue(key, value, expire)
+        }
+        return value
+    })
+}
+
+// 如果是支持过期后返回旧值的键值，那么返回其实际的键值
+func unwrapStaleValue(value interface{}) interface{} {
+    if v, ok := value.(staleValue); ok {
+        return v.Value
+    }
+    return value
+}
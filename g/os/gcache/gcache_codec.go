@@ -0,0 +1,93 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache
+
+import (
+    "bytes"
+    "encoding/gob"
+    "encoding/json"
+    "gitee.com/johng/gf/g/util/gconv"
+)
+
+// 缓存键值序列化接口，用于需要将键值存储到外部服务(例如Redis)的缓存适配器
+type Codec interface {
+    Encode(value interface{}) ([]byte, error)
+    Decode(data []byte) (interface{}, error)
+}
+
+// gob序列化(默认)，能够保留键值的原始类型，自定义类型需要先通过gob.Register注册
+type CodecGob struct {}
+
+// json序列化，便于与其他语言的服务共享缓存数据，但是反序列化后数值类型统一为json.Number，map及struct统一为map[string]interface{}
+type CodecJson struct {}
+
+// gob序列化时使用的包装对象，使得interface{}类型的键值能够被编码
+type codecGobItem struct {
+    Value interface{}
+}
+
+const (
+    gCODEC_JSON_STALE_KEY = "__gcache_stale__" // json序列化时支持过期后返回旧值的键值的包装键名
+)
+
+// json序列化时支持过期后返回旧值的键值的包装对象，以特定的键名区分普通的map键值
+type codecJsonStaleItem struct {
+    Stale *staleValue `json:"__gcache_stale__"`
+}
+
+func init() {
+    // 注册常用的复合类型，以便作为interface{}类型的键值进行gob序列化
+    gob.Register(map[string]interface{}{})
+    gob.Register(map[string]string{})
+    gob.Register([]interface{}{})
+    gob.Register([]map[string]interface{}{})
+    gob.Register([]string{})
+    gob.Register([]int{})
+    gob.Register(staleValue{})
+}
+
+func (c CodecGob) Encode(value interface{}) ([]byte, error) {
+    buffer := bytes.NewBuffer(nil)
+    if err := gob.NewEncoder(buffer).Encode(&codecGobItem{value}); err != nil {
+        return nil, err
+    }
+    return buffer.Bytes(), nil
+}
+
+func (c CodecGob) Decode(data []byte) (interface{}, error) {
+    item := codecGobItem{}
+    if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&item); err != nil {
+        return nil, err
+    }
+    return item.Value, nil
+}
+
+func (c CodecJson) Encode(value interface{}) ([]byte, error) {
+    if v, ok := value.(staleValue); ok {
+        return json.Marshal(codecJsonStaleItem{&v})
+    }
+    return json.Marshal(value)
+}
+
+func (c CodecJson) Decode(data []byte) (interface{}, error) {
+    value   := interface{}(nil)
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.UseNumber()
+    if err := decoder.Decode(&value); err != nil {
+        return nil, err
+    }
+    // 还原支持过期后返回旧值的键值
+    if m, ok := value.(map[string]interface{}); ok && len(m) == 1 {
+        if item, ok := m[gCODEC_JSON_STALE_KEY].(map[string]interface{}); ok {
+            return staleValue {
+                Value  : item["Value"],
+                Expire : gconv.Int64(item["Expire"]),
+            }, nil
+        }
+    }
+    return value, nil
+}
@@ -33,6 +33,12 @@ type memCache struct {
     lruGetList   *glist.List                    // Get操作的LRU记录
     eventList    *glist.List                    // 异步处理队列
     closed       *gtype.Bool                    // 关闭事件通知
+    evictions    *gtype.Int64                   // 过期及LRU淘汰的键值对数量
+
+    tagMu        sync.RWMutex
+    tagCount     *gtype.Int                     // 带有标签的键名数量(为0时写入/删除操作不需要处理标签)
+    tags         map[string]*gset.Set           // 标签对应的键名集合
+    keyTags      map[interface{}][]string       // 键名对应的标签列表
 }
 
 // 缓存数据项
@@ -61,6 +67,10 @@ func newMemCache(lruCap...int) *memCache {
         expireSets  : make(map[int64]*gset.Set),
         eventList   : glist.New(),
         closed      : gtype.NewBool(),
+        evictions   : gtype.NewInt64(),
+        tagCount    : gtype.NewInt(),
+        tags        : make(map[string]*gset.Set),
+        keyTags     : make(map[interface{}][]string),
     }
     if len(lruCap) > 0 {
         c.cap = lruCap[0]
@@ -104,6 +114,7 @@ func (c *memCache) Set(key interface{}, value interface{}, expire int) {
     c.dataMu.Lock()
     c.data[key] = memCacheItem{v : value, e : expireTime}
     c.dataMu.Unlock()
+    c.unlinkTags(key)
     c.eventList.PushBack(&memCacheEvent{k : key, e : expireTime})
 }
 
@@ -121,6 +132,7 @@ func (c *memCache) doSetWithLockCheck(key interface{}, value interface{}, expire
     }
     c.data[key] = memCacheItem{v : value, e : expireTimestamp}
     c.dataMu.Unlock()
+    c.unlinkTags(key)
     c.eventList.PushBack(&memCacheEvent{k : key, e : expireTimestamp})
     return value
 }
@@ -150,6 +162,7 @@ func (c *memCache) BatchSet(data map[interface{}]interface{}, expire int) {
         c.dataMu.Lock()
         c.data[k] = memCacheItem{v: v, e: expireTime}
         c.dataMu.Unlock()
+        c.unlinkTags(k)
         c.eventList.PushBack(&memCacheEvent{k: k, e: expireTime})
     }
 }
@@ -212,6 +225,7 @@ func (c *memCache) Remove(key interface{}) (value interface{}) {
         c.dataMu.Lock()
         delete(c.data, key)
         c.dataMu.Unlock()
+        c.unlinkTags(key)
         c.eventList.PushBack(&memCacheEvent{k: key, e: gtime.Millisecond() - 1000})
     }
     return
@@ -360,10 +374,16 @@ func (c *memCache) clearByKey(key interface{}, force...bool) {
     // 删除缓存数据
     c.dataMu.Lock()
     // 删除核对，真正的过期才删除
-    if item, ok := c.data[key]; (ok && item.IsExpired()) || (len(force) > 0 && force[0]) {
+    deleted := false
+    if item, ok := c.data[key]; (ok && item.IsExpired()) || (ok && len(force) > 0 && force[0]) {
         delete(c.data, key)
+        deleted = true
     }
     c.dataMu.Unlock()
+    if deleted {
+        c.evictions.Add(1)
+        c.unlinkTags(key)
+    }
 
     // 删除异步处理数据项
     c.expireTimeMu.Lock()
@@ -0,0 +1,66 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache
+
+import "gitee.com/johng/gf/g/container/gset"
+
+// 设置kv缓存键值对，并为该键名设置标签(覆盖原有的标签)，过期时间单位为毫秒
+func (c *memCache) SetWithTags(key interface{}, value interface{}, expire int, tags []string) {
+    c.Set(key, value, expire)
+    if len(tags) == 0 {
+        return
+    }
+    c.tagMu.Lock()
+    for _, tag := range tags {
+        keys, ok := c.tags[tag]
+        if !ok {
+            keys        = gset.New(true)
+            c.tags[tag] = keys
+        }
+        keys.Add(key)
+    }
+    c.keyTags[key] = tags
+    c.tagCount.Set(len(c.keyTags))
+    c.tagMu.Unlock()
+}
+
+// 删除指定标签(任意一个)对应的所有键值对
+func (c *memCache) RemoveByTag(tags...string) {
+    keys := make([]interface{}, 0)
+    c.tagMu.RLock()
+    for _, tag := range tags {
+        if set, ok := c.tags[tag]; ok {
+            keys = append(keys, set.Slice()...)
+        }
+    }
+    c.tagMu.RUnlock()
+    c.BatchRemove(keys)
+}
+
+// 解除键名与其标签的关联，在键值对被覆盖或者删除时调用
+func (c *memCache) unlinkTags(key interface{}) {
+    if c.tagCount.Val() == 0 {
+        return
+    }
+    c.tagMu.Lock()
+    for _, tag := range c.keyTags[key] {
+        if keys, ok := c.tags[tag]; ok {
+            keys.Remove(key)
+            if keys.Size() == 0 {
+                delete(c.tags, tag)
+            }
+        }
+    }
+    delete(c.keyTags, key)
+    c.tagCount.Set(len(c.keyTags))
+    c.tagMu.Unlock()
+}
+
+// 获得过期及LRU淘汰的键值对数量
+func (c *memCache) getEvictions() int64 {
+    return c.evictions.Val()
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache_test
+
+import (
+    "encoding/json"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/g/os/gcache"
+    "gitee.com/johng/gf/g/util/gtest"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestCache_Adapter(t *testing.T) {
+    gtest.Case(t, func() {
+        cache := gcache.NewWithAdapter(gcache.NewAdapterMemory())
+        cache.Set(1, 11, 0)
+        gtest.Assert(cache.Get(1), 11)
+        gtest.Assert(cache.GetOrSetFunc(2, func() interface{} { return 22 }, 0), 22)
+        gtest.Assert(cache.Size(), 2)
+        cache.Clear()
+        gtest.Assert(cache.Size(), 0)
+        gtest.Assert(cache.Get(1), nil)
+        cache.Set(3, 33, 0)
+        gtest.Assert(cache.Get(3), 33)
+    })
+}
+
+func TestCache_CodecGob(t *testing.T) {
+    gtest.Case(t, func() {
+        codec := gcache.CodecGob{}
+        data, err := codec.Encode(map[string]interface{}{"id" : 1, "name" : "john"})
+        gtest.Assert(err, nil)
+        value, err := codec.Decode(data)
+        gtest.Assert(err, nil)
+        gtest.Assert(value.(map[string]interface{})["id"], 1)
+
+        // gvar变量支持gob序列化
+        data, err = codec.Encode([]interface{}{gvar.New(100), gvar.New(nil)})
+        gtest.Assert(err, nil)
+        value, err = codec.Decode(data)
+        gtest.Assert(err, nil)
+        gtest.Assert(value.([]interface{})[0].(*gvar.Var).Int(), 100)
+        gtest.Assert(value.([]interface{})[1].(*gvar.Var).IsNil(), true)
+    })
+}
+
+func TestCache_CodecJson(t *testing.T) {
+    gtest.Case(t, func() {
+        codec := gcache.CodecJson{}
+        data, err := codec.Encode(map[string]interface{}{"id" : 1})
+        gtest.Assert(err, nil)
+        gtest.Assert(string(data), `{"id":1}`)
+        value, err := codec.Decode(data)
+        gtest.Assert(err, nil)
+        gtest.Assert(value.(map[string]interface{})["id"], json.Number("1"))
+    })
+}
+
+func TestCache_Tags(t *testing.T) {
+    gtest.Case(t, func() {
+        cache := gcache.New()
+        cache.SetWithTags(1, 11, 0, "user_42")
+        cache.SetWithTags(2, 22, 0, "user_42", "order")
+        cache.SetWithTags(3, 33, 0, "order")
+        cache.Set(4, 44, 0)
+        cache.RemoveByTag("user_42")
+        gtest.Assert(cache.Get(1), nil)
+        gtest.Assert(cache.Get(2), nil)
+        gtest.Assert(cache.Get(3), 33)
+        gtest.Assert(cache.Get(4), 44)
+
+        // 覆盖写入后原有的标签失效
+        cache.Set(3, 333, 0)
+        cache.RemoveByTag("order")
+        gtest.Assert(cache.Get(3), 333)
+    })
+}
+
+func TestCache_Stats(t *testing.T) {
+    gtest.Case(t, func() {
+        cache := gcache.New()
+        cache.Set(1, 11, 0)
+        cache.Set(2, 22, 100)
+        cache.Get(1)
+        cache.Get(3)
+        cache.GetOrSetFunc(4, func() interface{} { return 44 }, 0)
+        stats := cache.Stats()
+        gtest.Assert(stats.Hits, 1)
+        gtest.Assert(stats.Misses, 2)
+        gtest.Assert(stats.Size, 3)
+        time.Sleep(2500*time.Millisecond)
+        stats = cache.Stats()
+        gtest.Assert(stats.Evictions, 1)
+        gtest.Assert(stats.Size, 2)
+    })
+}
+
+func TestCache_GetOrSetFuncLock_Singleflight(t *testing.T) {
+    gtest.Case(t, func() {
+        cache := gcache.New()
+        count := gtype.NewInt()
+        wg    := sync.WaitGroup{}
+        for i := 0; i < 10; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                v := cache.GetOrSetFuncLock(1, func() interface{} {
+                    count.Add(1)
+                    time.Sleep(100*time.Millisecond)
+                    return 11
+                }, 0)
+                gtest.Assert(v, 11)
+            }()
+        }
+        wg.Wait()
+        gtest.Assert(count.Val(), 1)
+    })
+}
+
+func TestCache_Stale(t *testing.T) {
+    gtest.Case(t, func() {
+        cache := gcache.New()
+        cache.SetStaleTime(5000)
+        count := gtype.NewInt()
+        f     := func() interface{} {
+            time.Sleep(100*time.Millisecond)
+            return count.Add(1)
+        }
+        gtest.Assert(cache.GetOrSetFunc(1, f, 100), 1)
+        gtest.Assert(cache.Get(1), 1)
+        time.Sleep(150*time.Millisecond)
+        // 过期后直接返回旧值，后台只有一个goroutine刷新
+        gtest.Assert(cache.GetOrSetFunc(1, f, 100), 1)
+        gtest.Assert(cache.GetOrSetFunc(1, f, 100), 1)
+        time.Sleep(150*time.Millisecond)
+        gtest.Assert(cache.GetOrSetFunc(1, f, 100), 2)
+        gtest.Assert(count.Val(), 2)
+    })
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcache_test
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/database/gredis/gredistest"
+    "gitee.com/johng/gf/g/os/gcache"
+    "gitee.com/johng/gf/g/util/gtest"
+    "testing"
+    "time"
+)
+
+func TestCache_AdapterRedis(t *testing.T) {
+    server, err := gredistest.NewFakeServer()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer server.Close()
+    redis := gredis.New(server.Config())
+    defer redis.Close()
+    gtest.Case(t, func() {
+        cache := gcache.NewWithAdapter(gcache.NewAdapterRedis(redis, "test:"))
+        cache.Set(1, 11, 0)
+        cache.Set("k", "v", 100)
+        gtest.Assert(cache.Get(1), 11)
+        gtest.Assert(cache.Get("k"), "v")
+        gtest.Assert(cache.Size(), 2)
+        gtest.Assert(cache.SetIfNotExist(1, 12, 0), false)
+
+        time.Sleep(200*time.Millisecond)
+        gtest.Assert(cache.Get("k"), nil)
+
+        gtest.Assert(cache.Remove(1), 11)
+        gtest.Assert(cache.Contains(1), false)
+    })
+    // 未指定前缀时使用默认前缀，Clear/Size/Keys不影响数据库中的其他数据
+    gtest.Case(t, func() {
+        redis.Do("SET", "foreign", "v")
+        cache := gcache.NewWithAdapter(gcache.NewAdapterRedis(redis, ""))
+        cache.Set("k", "v", 0)
+        gtest.Assert(cache.Size(), 1)
+        gtest.Assert(cache.Keys(), []interface{}{"k"})
+        cache.Clear()
+        gtest.Assert(cache.Size(), 0)
+        v, _ := redis.DoVar("GET", "foreign")
+        gtest.Assert(v.String(), "v")
+    })
+}
+
+func TestCache_AdapterRedisCluster(t *testing.T) {
+    a, err := gredistest.NewFakeServer()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer a.Close()
+    b, err := gredistest.NewFakeServer()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer b.Close()
+    split := []gredistest.FakeSlotRange {
+        {Start : 0,    End : 8191,  Addr : a.Addr()},
+        {Start : 8192, End : 16383, Addr : b.Addr()},
+    }
+    a.SetClusterSlots(split...)
+    b.SetClusterSlots(split...)
+    redis := gredis.New(gredis.Config {
+        Cluster : true,
+        Addrs   : []string{a.Addr()},
+    })
+    defer redis.Close()
+    // 键名分布在两个节点上，Size/Keys/Data/Clear需要遍历所有节点
+    gtest.Case(t, func() {
+        cache := gcache.NewWithAdapter(gcache.NewAdapterRedis(redis, "test:"))
+        data  := make(map[interface{}]interface{})
+        for i := 0; i < 20; i++ {
+            data[i] = i
+        }
+        cache.BatchSet(data, 0)
+        for _, server := range []*gredistest.FakeServer{a, b} {
+            node := gredis.New(server.Config())
+            size, _ := node.DoVar("DBSIZE")
+            gtest.AssertGT(size.Int(), 0)
+            node.Close()
+        }
+        gtest.Assert(cache.Size(), 20)
+        gtest.Assert(len(cache.Keys()), 20)
+        gtest.Assert(len(cache.Data()), 20)
+        gtest.Assert(cache.Get(3), 3)
+        gtest.Assert(cache.Remove(3), 3)
+        cache.BatchRemove([]interface{}{1, 2, 4, 5})
+        gtest.Assert(cache.Size(), 15)
+        cache.Clear()
+        gtest.Assert(cache.Size(), 0)
+    })
+}
+
+func TestCache_AdapterRedisStale(t *testing.T) {
+    server, err := gredistest.NewFakeServer()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer server.Close()
+    redis := gredis.New(server.Config())
+    defer redis.Close()
+    gtest.Case(t, func() {
+        adapter := gcache.NewAdapterRedis(redis, "stale:")
+        adapter.SetCodec(gcache.CodecJson{})
+        cache := gcache.NewWithAdapter(adapter)
+        cache.SetStaleTime(5000)
+        count := gtype.NewInt()
+        f     := func() interface{} {
+            if count.Add(1) > 1 {
+                panic("refresh failed")
+            }
+            return "v1"
+        }
+        gtest.Assert(cache.GetOrSetFunc("k", f, 100), "v1")
+        gtest.Assert(cache.Get("k"), "v1")
+        time.Sleep(150*time.Millisecond)
+        // 后台刷新产生panic时保留旧值
+        gtest.Assert(cache.GetOrSetFunc("k", f, 100), "v1")
+        time.Sleep(100*time.Millisecond)
+        gtest.Assert(cache.Get("k"), "v1")
+        gtest.Assert(count.Val(), 2)
+    })
+}
+
+func TestCache_AdapterTwoLevel(t *testing.T) {
+    server, err := gredistest.NewFakeServer()
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    defer server.Close()
+    redis := gredis.New(server.Config())
+    defer redis.Close()
+    gtest.Case(t, func() {
+        a := gcache.NewAdapterTwoLevel(gcache.NewAdapterRedis(redis), "gcache:test")
+        b := gcache.NewAdapterTwoLevel(gcache.NewAdapterRedis(redis), "gcache:test")
+        defer a.Close()
+        defer b.Close()
+        // 等待订阅完成
+        time.Sleep(100*time.Millisecond)
+
+        a.Set("k", 1, 0)
+        gtest.Assert(b.Get("k"), 1)
+        a.Set("k", 2, 0)
+        // b的本地缓存通过失效通知删除
+        time.Sleep(100*time.Millisecond)
+        gtest.Assert(b.Get("k"), 2)
+        b.Remove("k")
+        time.Sleep(100*time.Millisecond)
+        gtest.Assert(a.Get("k"), nil)
+    })
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// 配置项加密工具，输出ENC(...)格式的加密内容，可以直接写入配置文件，例如:
+//     gcfg-encrypt --key=0123456789abcdef mypassword
+//     echo "mypassword" | GF_GCFG_KEYFILE=/etc/app/config.key gcfg-encrypt
+// 密钥通过--key或者--keyfile选项指定，未指定时与gcfg相同，读取GF_GCFG_KEY或者GF_GCFG_KEYFILE环境变量；
+// 未通过参数给定需要加密的内容时，从标准输入按行读取(避免明文出现在命令历史中，内容包含"="时也需要通过标准输入给定)。
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "gitee.com/johng/gf/g/os/gcfg"
+    "gitee.com/johng/gf/g/os/gcmd"
+    "gitee.com/johng/gf/g/os/gfile"
+    "os"
+    "strings"
+)
+
+func main() {
+    key, err := getKey()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err.Error())
+        os.Exit(1)
+    }
+    values := gcmd.Value.GetAll()[1:]
+    if len(values) == 0 {
+        scanner := bufio.NewScanner(os.Stdin)
+        for scanner.Scan() {
+            if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+                values = append(values, line)
+            }
+        }
+        if err := scanner.Err(); err != nil {
+            fmt.Fprintln(os.Stderr, err.Error())
+            os.Exit(1)
+        }
+    }
+    for _, value := range values {
+        result, err := gcfg.Encrypt(value, key)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err.Error())
+            os.Exit(1)
+        }
+        fmt.Println(result)
+    }
+}
+
+// 获取加密密钥
+func getKey() ([]byte, error) {
+    if key := gcmd.Option.Get("key"); key != "" {
+        return []byte(key), nil
+    }
+    if path := gcmd.Option.Get("keyfile"); path != "" {
+        if !gfile.Exists(path) {
+            return nil, fmt.Errorf(`key file "%s" does not exist`, path)
+        }
+        return []byte(strings.TrimSpace(gfile.GetContents(path))), nil
+    }
+    return gcfg.LoadKey()
+}
@@ -7,7 +7,9 @@
 // Package gcfg provides reading, caching and managing for configuration files.
 // 
 // 配置管理,
-// 配置文件格式支持：json, xml, toml, yaml/yml
+// 配置文件格式支持：json, xml, toml, yaml/yml，
+// 配置项按照以下配置层的优先级(从低到高)检索：
+// 默认值 < 配置文件 < 环境配置文件(如config.prod.toml) < 自定义数据源 < 环境变量(a.b.c对应APP_A_B_C) < 命令行选项(--a.b.c=value)
 package gcfg
 
 import (
@@ -23,6 +25,7 @@ import (
     "gitee.com/johng/gf/g/os/gfsnotify"
     "gitee.com/johng/gf/g/os/glog"
     "gitee.com/johng/gf/g/os/gspath"
+    "gitee.com/johng/gf/g/util/gconv"
 )
 
 const (
@@ -31,10 +34,13 @@ const (
 
 // 配置管理对象
 type Config struct {
-    name   *gtype.String            // 默认配置文件名称
-    paths  *garray.StringArray      // 搜索目录路径
-    jsons  *gmap.StringInterfaceMap // 配置文件对象
-    vc     *gtype.Bool              // 层级检索是否执行分隔符冲突检测(默认为false，检测会比较影响检索效率)
+    name    *gtype.String            // 默认配置文件名称
+    paths   *garray.StringArray      // 搜索目录路径
+    jsons   *gmap.StringInterfaceMap // 配置文件对象
+    vc      *gtype.Bool              // 层级检索是否执行分隔符冲突检测(默认为false，检测会比较影响检索效率)
+    layers  *layers                  // 配置文件之外的配置层(默认值、环境变量、自定义数据源等)
+    watches *watches                 // 配置项监听回调及配置文件校验方法
+    secrets *secrets                 // 加密配置项的解密密钥及敏感配置项键名
 }
 
 // 生成一个配置管理对象
@@ -44,10 +50,13 @@ func New(path string, file...string) *Config {
         name = file[0]
     }
     c := &Config {
-        name   : gtype.NewString(name),
-        paths  : garray.NewStringArray(0, 1),
-        jsons  : gmap.NewStringInterfaceMap(),
-        vc     : gtype.NewBool(),
+        name    : gtype.NewString(name),
+        paths   : garray.NewStringArray(0, 1),
+        jsons   : gmap.NewStringInterfaceMap(),
+        vc      : gtype.NewBool(),
+        layers  : newLayers(),
+        watches : newWatches(),
+        secrets : newSecrets(),
     }
     if len(path) > 0 {
         c.SetPath(path)
@@ -57,18 +66,8 @@ func New(path string, file...string) *Config {
 
 // 判断从哪个配置文件中获取内容，返回配置文件的绝对路径
 func (c *Config) filePath(file...string) (path string) {
-    name := c.name.Val()
-    if len(file) > 0 {
-        name = file[0]
-    }
-    c.paths.RLockFunc(func(array []string) {
-        for _, v := range array {
-            if path, _ = gspath.Search(v, name); path != "" {
-                break
-            }
-        }
-    })
-    if path == "" {
+    name := c.fileName(file...)
+    if path = c.searchFile(name); path == "" {
         buffer := bytes.NewBuffer(nil)
         buffer.WriteString(fmt.Sprintf("[gcfg] cannot find config file \"%s\" in following paths:", name))
         c.paths.RLockFunc(func(array []string) {
@@ -81,6 +80,35 @@ func (c *Config) filePath(file...string) (path string) {
     return path
 }
 
+// 获取配置文件名称，未指定时返回默认的配置文件名称
+func (c *Config) fileName(file...string) string {
+    if len(file) > 0 {
+        return file[0]
+    }
+    return c.name.Val()
+}
+
+// 在搜索目录中检索配置文件，不存在时返回空字符串
+func (c *Config) searchFile(name string) (path string) {
+    c.paths.RLockFunc(func(array []string) {
+        for _, v := range array {
+            if path, _ = gspath.Search(v, name); path != "" {
+                break
+            }
+        }
+        // 已加载的配置文件被删除时(等待重新创建)，继续使用缓存的配置内容
+        if path == "" {
+            for _, v := range array {
+                if p := v + gfile.Separator + name; c.jsons.Contains(p) {
+                    path = p
+                    break
+                }
+            }
+        }
+    })
+    return
+}
+
 // 设置配置管理器的配置文件存放目录绝对路径
 func (c *Config) SetPath(path string) error {
     realPath := gfile.RealPath(path)
@@ -129,15 +157,18 @@ func (c *Config) SetFileName(name string) {
 
 // 添加配置文件到配置管理器中，第二个参数为非必须，如果不输入表示添加进入默认的配置名称中
 func (c *Config) getJson(file...string) *gjson.Json {
-    filePath := c.filePath(file...)
+    return c.loadJson(c.filePath(file...))
+}
+
+// 读取并缓存指定路径的配置文件，路径为空时返回nil
+func (c *Config) loadJson(filePath string) *gjson.Json {
     if filePath == "" {
         return nil
     }
     if r := c.jsons.Get(filePath); r != nil {
         return r.(*gjson.Json)
     }
-    if j, err := gjson.Load(filePath); err == nil {
-        j.SetViolenceCheck(c.vc.Val())
+    if j, err := c.parseFile(filePath); err == nil {
         c.addMonitor(filePath)
         c.jsons.Set(filePath, j)
         return j
@@ -147,27 +178,30 @@ func (c *Config) getJson(file...string) *gjson.Json {
     return nil
 }
 
-// 获取配置项，当不存在时返回nil
+// 获取配置项，按照配置层的优先级检索，当不存在时返回nil，ENC(...)格式的加密配置项自动解密
 func (c *Config) Get(pattern string, file...string) interface{} {
-    if j := c.getJson(file...); j != nil {
-        return j.Get(pattern)
-    }
-    return nil
+    value, _    := c.lookup(pattern, file...)
+    value, _, _  = c.decrypt(value)
+    return value
 }
 
-// 获得配置项，返回动态变量
-func (c *Config) GetVar(pattern string, file...string) gvar.VarRead {
-    if j := c.getJson(file...); j != nil {
-        return gvar.New(j.Get(pattern), true)
+// 获得配置项，返回动态变量，通过返回变量的Layer方法可以获得配置项来源的配置层，
+// 通过Error方法可以获得加密配置项的解密错误(解密失败的加密配置项为nil)
+func (c *Config) GetVar(pattern string, file...string) *Var {
+    value, layer  := c.lookup(pattern, file...)
+    value, _, err := c.decrypt(value)
+    return &Var {
+        VarRead : gvar.New(value, true),
+        layer   : layer,
+        err     : err,
     }
-    return gvar.New(nil, true)
 }
 
 // 获得一个键值对关联数组/哈希表，方便操作，不需要自己做类型转换
 // 注意，如果获取的值不存在，或者类型与json类型不匹配，那么将会返回nil
 func (c *Config) GetMap(pattern string, file...string)  map[string]interface{} {
-    if j := c.getJson(file...); j != nil {
-        return j.GetMap(pattern)
+    if r, ok := c.Get(pattern, file...).(map[string]interface{}); ok {
+        return r
     }
     return nil
 }
@@ -175,165 +209,125 @@ func (c *Config) GetMap(pattern string, file...string)  map[string]interface{} {
 // 获得一个数组[]interface{}，方便操作，不需要自己做类型转换
 // 注意，如果获取的值不存在，或者类型与json类型不匹配，那么将会返回nil
 func (c *Config) GetArray(pattern string, file...string)  []interface{} {
-    if j := c.getJson(file...); j != nil {
-        return j.GetArray(pattern)
+    if r, ok := c.Get(pattern, file...).([]interface{}); ok {
+        return r
     }
     return nil
 }
 
 // 返回指定json中的string
 func (c *Config) GetString(pattern string, file...string) string {
-    if j := c.getJson(file...); j != nil {
-        return j.GetString(pattern)
-    }
-    return ""
+    return c.GetVar(pattern, file...).String()
 }
 
 func (c *Config) GetStrings(pattern string, file...string) []string {
-    if j := c.getJson(file...); j != nil {
-        return j.GetStrings(pattern)
-    }
-    return nil
+    return c.GetVar(pattern, file...).Strings()
 }
 
 func (c *Config) GetInterfaces(pattern string, file...string) []interface{} {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInterfaces(pattern)
-    }
-    return nil
+    return c.GetVar(pattern, file...).Interfaces()
 }
 
 // 返回指定json中的bool
 func (c *Config) GetBool(pattern string, file...string) bool {
-    if j := c.getJson(file...); j != nil {
-        return j.GetBool(pattern)
-    }
-    return false
+    return c.GetVar(pattern, file...).Bool()
 }
 
 // 返回指定json中的float32
 func (c *Config) GetFloat32(pattern string, file...string) float32 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetFloat32(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Float32()
 }
 
 // 返回指定json中的float64
 func (c *Config) GetFloat64(pattern string, file...string) float64 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetFloat64(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Float64()
 }
 
 func (c *Config) GetFloats(pattern string, file...string) []float64 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetFloats(pattern)
-    }
-    return nil
+    return c.GetVar(pattern, file...).Floats()
 }
 
 // 返回指定json中的float64->int
 func (c *Config) GetInt(pattern string, file...string)  int {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInt(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Int()
 }
 
 
 func (c *Config) GetInt8(pattern string, file...string)  int8 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInt8(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Int8()
 }
 
 func (c *Config) GetInt16(pattern string, file...string)  int16 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInt16(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Int16()
 }
 
 func (c *Config) GetInt32(pattern string, file...string)  int32 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInt32(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Int32()
 }
 
 func (c *Config) GetInt64(pattern string, file...string)  int64 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInt64(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Int64()
 }
 
 func (c *Config) GetInts(pattern string, file...string) []int {
-    if j := c.getJson(file...); j != nil {
-        return j.GetInts(pattern)
-    }
-    return nil
+    return c.GetVar(pattern, file...).Ints()
 }
 
 // 返回指定json中的float64->uint
 func (c *Config) GetUint(pattern string, file...string)  uint {
-    if j := c.getJson(file...); j != nil {
-        return j.GetUint(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Uint()
 }
 
 func (c *Config) GetUint8(pattern string, file...string)  uint8 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetUint8(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Uint8()
 }
 
 func (c *Config) GetUint16(pattern string, file...string)  uint16 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetUint16(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Uint16()
 }
 
 func (c *Config) GetUint32(pattern string, file...string)  uint32 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetUint32(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Uint32()
 }
 
 func (c *Config) GetUint64(pattern string, file...string)  uint64 {
-    if j := c.getJson(file...); j != nil {
-        return j.GetUint64(pattern)
-    }
-    return 0
+    return c.GetVar(pattern, file...).Uint64()
 }
 
 func (c *Config) GetToStruct(pattern string, objPointer interface{}, file...string) error {
-    if j := c.getJson(file...); j != nil {
-        return j.GetToStruct(pattern, objPointer)
+    if value := c.Get(pattern, file...); value != nil {
+        return gconv.Struct(value, objPointer)
+    }
+    if c.getJson(file...) == nil {
+        return errors.New("config file not found")
     }
-    return errors.New("config file not found")
+    return nil
 }
 
-// 清空当前配置文件缓存，强制重新从磁盘文件读取配置文件内容
+// 强制重新从磁盘文件读取已缓存的配置文件内容，读取或者校验失败的配置文件保留最近一次有效的配置内容
 func (c *Config) Reload() {
-    c.jsons.Clear()
+    for _, path := range c.jsons.Keys() {
+        c.reloadFile(path)
+    }
 }
 
-// 添加文件监控
+// 添加文件监控，每个配置文件只添加一次
 func (c *Config) addMonitor(path string) {
     // 防止多goroutine同时调用
-    if c.jsons.Get(path) != nil {
-        return
-    }
-    gfsnotify.Add(path, func(event *gfsnotify.Event) {
-        // 删除文件内容缓存，下一次查询会自动更新
-        c.jsons.Remove(event.Path)
+    c.watches.monitors.GetOrSetFuncLock(path, func() interface{} {
+        callback, err := gfsnotify.Add(path, func(event *gfsnotify.Event) {
+            // 删除或者重命名后文件不存在时，文件监控已失效，等待文件重新创建
+            if (event.IsRemove() || event.IsRename()) && !gfile.Exists(event.Path) {
+                c.waitRecreate(event.Path)
+                return
+            }
+            // 重新加载并整体替换配置内容，失败时保留原有的配置内容
+            c.delayReload(event.Path)
+        })
+        if err != nil {
+            glog.Errorfln(`[gcfg] Monitor config file "%s" failed: %s`, path, err.Error())
+            return nil
+        }
+        return callback
     })
 }
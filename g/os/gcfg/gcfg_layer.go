@@ -0,0 +1,243 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcfg
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/g/encoding/gjson"
+    "gitee.com/johng/gf/g/internal/cmdenv"
+    "gitee.com/johng/gf/g/os/gcmd"
+    "gitee.com/johng/gf/g/os/genv"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/glog"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+const (
+    LAYER_DEFAULT = "default" // 默认值
+    LAYER_FILE    = "file"    // 配置文件
+    LAYER_PROFILE = "profile" // 环境配置文件，例如: config.prod.toml
+    LAYER_ENV     = "env"     // 环境变量
+    LAYER_CMD     = "cmd"     // 命令行选项
+
+    DEFAULT_ENV_PREFIX = "APP" // 默认的环境变量前缀
+)
+
+// 自定义配置数据源(例如远程配置中心)，优先级高于配置文件，低于环境变量及命令行选项，
+// Get方法按照层级检索规则返回配置项，不存在时返回nil。
+type Source interface {
+    Get(pattern string) interface{}
+}
+
+// 配置项动态变量，包含配置项来源的配置层信息
+type Var struct {
+    gvar.VarRead
+    layer string // 来源配置层，配置项不存在时为空
+    err   error  // 加密配置项的解密错误
+}
+
+// 配置文件之外的配置层
+type layers struct {
+    mu        sync.RWMutex
+    defaults  *gjson.Json    // 默认值
+    profile   *gtype.String  // 环境名称，例如: dev, prod
+    envPrefix *gtype.String  // 环境变量前缀
+    names     []string       // 自定义数据源名称(按照添加顺序)
+    sources   []Source       // 自定义数据源(后添加的优先级更高)
+}
+
+func newLayers() *layers {
+    return &layers {
+        defaults  : gjson.New(make(map[string]interface{})),
+        profile   : gtype.NewString(cmdenv.Get("gf.gcfg.profile").String()),
+        envPrefix : gtype.NewString(cmdenv.Get("gf.gcfg.envprefix", DEFAULT_ENV_PREFIX).String()),
+    }
+}
+
+// 获取配置项来源的配置层名称：default, file, profile, env, cmd或者自定义数据源名称，配置项不存在时返回空字符串
+func (v *Var) Layer() string {
+    return v.layer
+}
+
+// 获取加密配置项的解密错误(例如未设置解密密钥或者密钥错误)，没有错误时返回nil
+func (v *Var) Error() error {
+    return v.err
+}
+
+// 设置配置项的默认值，优先级最低
+func (c *Config) SetDefault(pattern string, value interface{}) error {
+    return c.layers.defaults.Set(pattern, value)
+}
+
+// 设置环境名称，设置后同时检索环境配置文件(例如: config.toml对应config.prod.toml)，
+// 默认通过命令行选项gf.gcfg.profile或者环境变量GF_GCFG_PROFILE设置，为空表示不使用环境配置文件
+func (c *Config) SetProfile(profile string) {
+    glog.Debug("[gcfg] SetProfile:", profile)
+    c.layers.profile.Set(profile)
+}
+
+// 获取环境名称
+func (c *Config) GetProfile() string {
+    return c.layers.profile.Val()
+}
+
+// 设置环境变量前缀，配置项a.b.c对应的环境变量为: 前缀_A_B_C，默认为APP，为空表示不检索环境变量
+func (c *Config) SetEnvPrefix(prefix string) {
+    c.layers.envPrefix.Set(prefix)
+}
+
+// 添加自定义配置数据源，name为数据源名称(GetVar返回的配置层名称)，后添加的数据源优先级更高
+func (c *Config) AddSource(name string, source Source) {
+    c.layers.mu.Lock()
+    c.layers.names   = append(c.layers.names, name)
+    c.layers.sources = append(c.layers.sources, source)
+    c.layers.mu.Unlock()
+}
+
+// 按照配置层的优先级从低到高依次检索配置项，返回配置项及来源的配置层名称，
+// 当多个配置层的配置项都为map时进行合并(高优先级的键值覆盖低优先级)，否则高优先级的配置项直接覆盖；
+// 配置项为map或者数组时，环境变量及命令行选项同时覆盖其中已存在的子配置项(例如: APP_DATABASE_DEFAULT_0_PASS)
+func (c *Config) lookup(pattern string, file...string) (value interface{}, layer string) {
+    set := func(v interface{}, name string) {
+        if v == nil {
+            return
+        }
+        if m, ok := v.(map[string]interface{}); ok {
+            if old, ok := value.(map[string]interface{}); ok {
+                v = mergeMap(old, m)
+            }
+        }
+        value, layer = v, name
+    }
+    set(c.layers.defaults.Get(pattern), LAYER_DEFAULT)
+    // 配置文件不是必须的(可以只使用环境变量等配置层)，不存在时直接跳过
+    if j := c.loadJson(c.searchFile(c.fileName(file...))); j != nil {
+        set(j.Get(pattern), LAYER_FILE)
+    }
+    if j := c.loadJson(c.profilePath(file...)); j != nil {
+        set(j.Get(pattern), LAYER_PROFILE)
+    }
+    c.layers.mu.RLock()
+    names, sources := c.layers.names, c.layers.sources
+    c.layers.mu.RUnlock()
+    for i, source := range sources {
+        set(source.Get(pattern), names[i])
+    }
+    if v, name := c.override(pattern); v != "" {
+        set(v, name)
+    } else if v, name := c.overrideChildren(value, pattern); name != "" {
+        value, layer = v, name
+    }
+    return
+}
+
+// 获取环境变量及命令行选项中的配置项(命令行选项优先)，返回配置项及来源的配置层名称，不存在时返回空字符串
+func (c *Config) override(pattern string) (value string, layer string) {
+    if pattern == "" {
+        return "", ""
+    }
+    if v := gcmd.Option.Get(pattern); v != "" {
+        return v, LAYER_CMD
+    }
+    if prefix := c.layers.envPrefix.Val(); prefix != "" {
+        if v := genv.Get(envKey(prefix, pattern)); v != "" {
+            return v, LAYER_ENV
+        }
+    }
+    return "", ""
+}
+
+// 使用环境变量及命令行选项覆盖map或者数组中已存在的子配置项，返回新的配置项(不修改缓存的配置内容)，
+// 以及覆盖来源中优先级最高的配置层名称，没有任何覆盖时原样返回配置项及空字符串
+func (c *Config) overrideChildren(value interface{}, pattern string) (result interface{}, layer string) {
+    item := func(v interface{}, key string) (interface{}, string) {
+        if pattern != "" {
+            key = pattern + "." + key
+        }
+        switch v.(type) {
+            case map[string]interface{}, []interface{}:
+                return c.overrideChildren(v, key)
+        }
+        if s, name := c.override(key); s != "" {
+            return s, name
+        }
+        return v, ""
+    }
+    merge := func(name string) {
+        if layer != LAYER_CMD {
+            layer = name
+        }
+    }
+    switch v := value.(type) {
+        case map[string]interface{}:
+            var m map[string]interface{}
+            for k, child := range v {
+                if r, name := item(child, k); name != "" {
+                    if m == nil {
+                        m = mergeMap(v, nil)
+                    }
+                    m[k] = r
+                    merge(name)
+                }
+            }
+            if m != nil {
+                return m, layer
+            }
+
+        case []interface{}:
+            var a []interface{}
+            for i, child := range v {
+                if r, name := item(child, strconv.Itoa(i)); name != "" {
+                    if a == nil {
+                        a = append([]interface{}(nil), v...)
+                    }
+                    a[i] = r
+                    merge(name)
+                }
+            }
+            if a != nil {
+                return a, layer
+            }
+    }
+    return value, ""
+}
+
+// 获取环境配置文件的绝对路径，未设置环境名称或者文件不存在时返回空字符串
+func (c *Config) profilePath(file...string) string {
+    profile := c.layers.profile.Val()
+    if profile == "" {
+        return ""
+    }
+    name := c.fileName(file...)
+    ext  := gfile.Ext(name)
+    return c.searchFile(strings.TrimSuffix(name, ext) + "." + profile + ext)
+}
+
+// 配置项名称转换为环境变量名称，例如: a.b.c 转换为 APP_A_B_C
+func envKey(prefix string, pattern string) string {
+    return strings.ToUpper(prefix + "_" + strings.Replace(pattern, ".", "_", -1))
+}
+
+// 深度合并两个map，返回新的map，不修改原有的map
+func mergeMap(dst, src map[string]interface{}) map[string]interface{} {
+    m := make(map[string]interface{}, len(dst) + len(src))
+    for k, v := range dst {
+        m[k] = v
+    }
+    for k, v := range src {
+        if sm, ok := v.(map[string]interface{}); ok {
+            if dm, ok := m[k].(map[string]interface{}); ok {
+                v = mergeMap(dm, sm)
+            }
+        }
+        m[k] = v
+    }
+    return m
+}
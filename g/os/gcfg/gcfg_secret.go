@@ -0,0 +1,247 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcfg
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/container/gset"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/crypto/gaes"
+    "gitee.com/johng/gf/g/internal/cmdenv"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/glog"
+    "gitee.com/johng/gf/g/util/gutil"
+    "strings"
+    "unicode/utf8"
+)
+
+const (
+    REDACTED_VALUE = "******" // 敏感配置项输出时的替换内容
+
+    gENCRYPTED_PREFIX = "ENC(" // 加密配置项前缀
+    gENCRYPTED_SUFFIX = ")"    // 加密配置项后缀
+    gENCRYPTED_IV_LEN = 16     // 加密配置项的随机初始化向量长度
+)
+
+var (
+    // 默认的敏感配置项键名(不区分大小写)
+    defaultSecretKeys = []string{"pass", "password", "secret", "token"}
+)
+
+// 加密配置项管理
+type secrets struct {
+    key    *gtype.Bytes        // 解密密钥，为空时通过LoadKey加载
+    keys   *gset.StringSet     // 敏感配置项键名(小写)，输出配置时替换为REDACTED_VALUE
+    failed *gmap.StringBoolMap // 解密失败的加密内容，每个加密内容只输出一次错误日志
+}
+
+func newSecrets() *secrets {
+    s := &secrets {
+        key    : gtype.NewBytes(),
+        keys   : gset.NewStringSet(),
+        failed : gmap.NewStringBoolMap(),
+    }
+    s.keys.BatchAdd(defaultSecretKeys)
+    return s
+}
+
+// 加载默认的加密密钥：优先使用命令行选项gf.gcfg.key或者环境变量GF_GCFG_KEY，
+// 其次读取命令行选项gf.gcfg.keyfile或者环境变量GF_GCFG_KEYFILE指定的密钥文件，密钥长度必须为16/24/32位
+func LoadKey() ([]byte, error) {
+    if key := cmdenv.Get("gf.gcfg.key").String(); key != "" {
+        return []byte(key), nil
+    }
+    if path := cmdenv.Get("gf.gcfg.keyfile").String(); path != "" {
+        if !gfile.Exists(path) {
+            return nil, errors.New(fmt.Sprintf(`key file "%s" does not exist`, path))
+        }
+        return []byte(strings.TrimSpace(gfile.GetContents(path))), nil
+    }
+    return nil, errors.New("encryption key not found, please set it by GF_GCFG_KEY or GF_GCFG_KEYFILE")
+}
+
+// 判断配置项是否为加密内容，格式: ENC(...)
+func IsEncrypted(value string) bool {
+    return len(value) > len(gENCRYPTED_PREFIX) + len(gENCRYPTED_SUFFIX) &&
+        strings.HasPrefix(value, gENCRYPTED_PREFIX) && strings.HasSuffix(value, gENCRYPTED_SUFFIX)
+}
+
+// 使用AES(CBC模式，随机初始化向量)加密配置项，返回ENC(...)格式的加密内容，可以直接写入配置文件
+func Encrypt(value string, key []byte) (string, error) {
+    iv := make([]byte, gENCRYPTED_IV_LEN)
+    if _, err := rand.Read(iv); err != nil {
+        return "", err
+    }
+    data, err := gaes.Encrypt([]byte(value), key, iv)
+    if err != nil {
+        return "", err
+    }
+    return gENCRYPTED_PREFIX + base64.StdEncoding.EncodeToString(append(iv, data...)) + gENCRYPTED_SUFFIX, nil
+}
+
+// 解密ENC(...)格式的加密配置项
+func Decrypt(value string, key []byte) (string, error) {
+    if !IsEncrypted(value) {
+        return "", errors.New("invalid encrypted value")
+    }
+    data, err := base64.StdEncoding.DecodeString(value[len(gENCRYPTED_PREFIX) : len(value) - len(gENCRYPTED_SUFFIX)])
+    if err != nil {
+        return "", err
+    }
+    if len(data) <= gENCRYPTED_IV_LEN {
+        return "", errors.New("invalid encrypted value")
+    }
+    plain, err := gaes.Decrypt(data[gENCRYPTED_IV_LEN:], key, data[:gENCRYPTED_IV_LEN])
+    if err != nil {
+        return "", err
+    }
+    // 填充校验无法识别所有的密钥错误，配置项为文本内容，解密结果不是有效的UTF-8文本时同样认为解密失败
+    if !utf8.Valid(plain) {
+        return "", errors.New("invalid decrypted value, the key may be wrong")
+    }
+    return string(plain), nil
+}
+
+// 设置加密配置项的解密密钥，未设置时通过LoadKey加载
+func (c *Config) SetKey(key []byte) {
+    c.secrets.key.Set(key)
+}
+
+// 添加敏感配置项键名(不区分大小写)，输出配置时这些键名的配置项替换为REDACTED_VALUE，
+// 默认包括: pass, password, secret, token，加密配置项无论键名都会被替换
+func (c *Config) AddSecretKey(names...string) {
+    for _, name := range names {
+        c.secrets.keys.Add(strings.ToLower(name))
+    }
+}
+
+// 获取配置项用于输出(例如打印或者日志)，敏感配置项及加密配置项替换为REDACTED_VALUE，加密配置项不进行解密
+func (c *Config) GetRedacted(pattern string, file...string) interface{} {
+    value, _ := c.lookup(pattern, file...)
+    name     := pattern
+    if pos := strings.LastIndexByte(pattern, '.'); pos != -1 {
+        name = pattern[pos + 1:]
+    }
+    return c.redact(value, name)
+}
+
+// 打印配置文件内容(合并所有的配置层)，敏感配置项及加密配置项替换为REDACTED_VALUE
+func (c *Config) Dump(file...string) {
+    gutil.Dump(c.GetRedacted("", file...))
+}
+
+// 获取解密密钥
+func (c *Config) getKey() ([]byte, error) {
+    if key := c.secrets.key.Val(); len(key) > 0 {
+        return key, nil
+    }
+    key, err := LoadKey()
+    if err != nil {
+        return nil, err
+    }
+    c.secrets.key.Set(key)
+    return key, nil
+}
+
+// 解密配置项中的加密内容(包括map及数组中的加密内容)，不修改缓存的配置内容，
+// 返回解密后的配置项、是否有解密的内容以及解密错误，解密失败的加密内容替换为nil(避免将加密内容当作明文使用)，
+// 每个解密失败的加密内容只输出一次错误日志
+func (c *Config) decrypt(value interface{}) (interface{}, bool, error) {
+    switch v := value.(type) {
+        case string:
+            if !IsEncrypted(v) {
+                return v, false, nil
+            }
+            key, err := c.getKey()
+            if err == nil {
+                var plain string
+                if plain, err = Decrypt(v, key); err == nil {
+                    return plain, true, nil
+                }
+            }
+            err = errors.New(fmt.Sprintf(`decrypt config value failed: %s`, err.Error()))
+            if c.secrets.failed.SetIfNotExist(v, true) {
+                glog.Error("[gcfg]", err.Error())
+            }
+            return nil, true, err
+
+        case map[string]interface{}:
+            var m   map[string]interface{}
+            var err error
+            for k, item := range v {
+                r, ok, e := c.decrypt(item)
+                if e != nil && err == nil {
+                    err = e
+                }
+                if ok {
+                    if m == nil {
+                        m = make(map[string]interface{}, len(v))
+                        for k, item := range v {
+                            m[k] = item
+                        }
+                    }
+                    m[k] = r
+                }
+            }
+            if m != nil {
+                return m, true, err
+            }
+
+        case []interface{}:
+            var a   []interface{}
+            var err error
+            for i, item := range v {
+                r, ok, e := c.decrypt(item)
+                if e != nil && err == nil {
+                    err = e
+                }
+                if ok {
+                    if a == nil {
+                        a = make([]interface{}, len(v))
+                        copy(a, v)
+                    }
+                    a[i] = r
+                }
+            }
+            if a != nil {
+                return a, true, err
+            }
+    }
+    return value, false, nil
+}
+
+// 替换配置项中的敏感内容，name为配置项的键名
+func (c *Config) redact(value interface{}, name string) interface{} {
+    if value != nil && c.secrets.keys.Contains(strings.ToLower(name)) {
+        return REDACTED_VALUE
+    }
+    switch v := value.(type) {
+        case string:
+            if IsEncrypted(v) {
+                return REDACTED_VALUE
+            }
+
+        case map[string]interface{}:
+            m := make(map[string]interface{}, len(v))
+            for k, item := range v {
+                m[k] = c.redact(item, k)
+            }
+            return m
+
+        case []interface{}:
+            a := make([]interface{}, len(v))
+            for i, item := range v {
+                a[i] = c.redact(item, "")
+            }
+            return a
+    }
+    return value
+}
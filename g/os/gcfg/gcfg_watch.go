@@ -0,0 +1,167 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcfg
+
+import (
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/g/encoding/gjson"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/gfsnotify"
+    "gitee.com/johng/gf/g/os/glog"
+    "gitee.com/johng/gf/g/os/gtimer"
+    "reflect"
+    "sync"
+    "time"
+)
+
+const (
+    gRELOAD_DELAY      = 100 // (毫秒)配置文件修改后延迟重新加载的时间，用于合并短时间内的多次文件事件
+    gRECREATE_INTERVAL = 500 // (毫秒)配置文件删除后检查文件是否重新创建的时间间隔
+)
+
+// 配置文件校验方法，path为配置文件绝对路径，config为新解析的配置内容，返回错误时拒绝该配置文件内容
+type ValidateFunc func(path string, config *gjson.Json) error
+
+// 配置项变化监听回调方法
+type WatchFunc func(old, new *gvar.Var)
+
+// 配置项监听对象
+type watcher struct {
+    pattern  string      // 监听的配置项
+    file     []string    // 监听的配置文件名称(为空时为默认配置文件)
+    value    interface{} // 最近一次回调时的配置项
+    callback WatchFunc   // 回调方法
+}
+
+// 配置文件的监听管理
+type watches struct {
+    mu         sync.Mutex               // 配置文件重新加载的互斥锁，保证配置内容替换及回调的顺序
+    wmu        sync.RWMutex             // 监听回调及校验方法的读写锁
+    watchers   []*watcher               // 配置项监听对象
+    validators []ValidateFunc           // 配置文件校验方法
+    pending    *gmap.StringInterfaceMap // 等待重新加载的配置文件
+    monitors   *gmap.StringInterfaceMap // 配置文件的监控回调(*gfsnotify.Callback)
+    removed    *gmap.StringInterfaceMap // 已删除并等待重新创建的配置文件
+}
+
+func newWatches() *watches {
+    return &watches {
+        pending  : gmap.NewStringInterfaceMap(),
+        monitors : gmap.NewStringInterfaceMap(),
+        removed  : gmap.NewStringInterfaceMap(),
+    }
+}
+
+// 监听配置项的变化，配置文件(包括环境配置文件)修改并重新加载后，配置项内容有变化时调用callback，
+// 回调在配置文件重新加载的goroutine中按顺序执行，回调中不能调用Reload方法。
+func (c *Config) Watch(pattern string, callback WatchFunc, file...string) {
+    w := &watcher {
+        pattern  : pattern,
+        file     : file,
+        callback : callback,
+    }
+    c.watches.mu.Lock()
+    w.value = c.Get(pattern, file...)
+    c.watches.wmu.Lock()
+    c.watches.watchers = append(c.watches.watchers, w)
+    c.watches.wmu.Unlock()
+    c.watches.mu.Unlock()
+}
+
+// 添加配置文件校验方法，配置文件加载(包括首次加载及修改后重新加载)时执行校验，
+// 校验失败时拒绝新的配置文件内容，并继续使用最近一次校验通过的配置内容。
+func (c *Config) AddValidator(validator ValidateFunc) {
+    c.watches.wmu.Lock()
+    c.watches.validators = append(c.watches.validators, validator)
+    c.watches.wmu.Unlock()
+}
+
+// 读取、解析并校验配置文件，返回完整的配置内容
+func (c *Config) parseFile(path string) (*gjson.Json, error) {
+    j, err := gjson.Load(path)
+    if err != nil {
+        return nil, err
+    }
+    j.SetViolenceCheck(c.vc.Val())
+    c.watches.wmu.RLock()
+    validators := c.watches.validators
+    c.watches.wmu.RUnlock()
+    for _, validator := range validators {
+        if err := validator(path, j); err != nil {
+            return nil, errors.New(fmt.Sprintf(`validation failed: %s`, err.Error()))
+        }
+    }
+    return j, nil
+}
+
+// 配置文件修改后延迟重新加载，合并短时间内的多次文件事件(例如先清空文件再写入内容)，避免读取到未写入完成的文件
+func (c *Config) delayReload(path string) {
+    if c.watches.pending.SetIfNotExist(path, struct{}{}) {
+        gtimer.AddOnce(gRELOAD_DELAY*time.Millisecond, func() {
+            c.watches.pending.Remove(path)
+            c.reloadFile(path)
+        })
+    }
+}
+
+// 配置文件删除(或者重命名)后底层的文件监控随之失效，定时检查配置文件是否重新创建(例如编辑器先删除再写入文件，
+// 或者ConfigMap的符号链接替换)，重新创建后重新添加文件监控并重新加载配置文件
+func (c *Config) waitRecreate(path string) {
+    if !c.watches.removed.SetIfNotExist(path, struct{}{}) {
+        return
+    }
+    gtimer.AddSingleton(gRECREATE_INTERVAL*time.Millisecond, func() {
+        if !gfile.Exists(path) {
+            return
+        }
+        c.watches.removed.Remove(path)
+        if v := c.watches.monitors.Remove(path); v != nil {
+            gfsnotify.RemoveCallback(v.(*gfsnotify.Callback).Id)
+        }
+        c.addMonitor(path)
+        c.reloadFile(path)
+        gtimer.Exit()
+    })
+}
+
+// 重新加载配置文件，解析及校验通过后整体替换缓存的配置内容(读取端不会读取到部分更新的内容)，
+// 失败时保留最近一次有效的配置内容，替换成功后执行配置项监听回调
+func (c *Config) reloadFile(path string) {
+    c.watches.mu.Lock()
+    defer c.watches.mu.Unlock()
+    if !gfile.Exists(path) {
+        glog.Warningfln(`[gcfg] Config file "%s" removed, keep the last good version until it is recreated`, path)
+        return
+    }
+    j, err := c.parseFile(path)
+    if err != nil {
+        glog.Errorfln(`[gcfg] Reload config file "%s" failed, keep the last good version: %s`, path, err.Error())
+        return
+    }
+    c.jsons.Set(path, j)
+    glog.Debug("[gcfg] Reload:", path)
+    c.notify()
+}
+
+// 检查所有监听的配置项，有变化时执行回调，调用端需要持有重新加载的互斥锁
+func (c *Config) notify() {
+    c.watches.wmu.RLock()
+    watchers := c.watches.watchers
+    c.watches.wmu.RUnlock()
+    for _, w := range watchers {
+        value := c.Get(w.pattern, w.file...)
+        if reflect.DeepEqual(value, w.value) {
+            continue
+        }
+        old    := w.value
+        w.value = value
+        w.callback(gvar.New(old, true), gvar.New(value, true))
+    }
+}
@@ -0,0 +1,398 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcfg_test
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/container/gvar"
+    "gitee.com/johng/gf/g/encoding/gjson"
+    "gitee.com/johng/gf/g/os/gcfg"
+    "gitee.com/johng/gf/g/os/genv"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/glog"
+    "gitee.com/johng/gf/g/os/gtime"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gtest"
+    "os"
+    "os/exec"
+    "strings"
+    "testing"
+    "time"
+)
+
+// 自定义配置数据源
+type mapSource map[string]interface{}
+
+func (s mapSource) Get(pattern string) interface{} {
+    return s[pattern]
+}
+
+// 创建临时配置目录并写入配置文件
+func newConfigDir(files map[string]string) string {
+    dir := gfile.TempDir() + gfile.Separator + fmt.Sprintf("gcfg_test_%d", gtime.Nanosecond())
+    gfile.Mkdir(dir)
+    for name, content := range files {
+        gfile.PutContents(dir + gfile.Separator + name, content)
+    }
+    return dir
+}
+
+func TestConfig_Layers(t *testing.T) {
+    dir := newConfigDir(map[string]string {
+        "config.toml"      : "[server]\nport = 8000\nhost = \"127.0.0.1\"\n[db]\nhost = \"localhost\"\n",
+        "config.prod.toml" : "[server]\nport = 80\n",
+    })
+    defer gfile.Remove(dir)
+
+    gtest.Case(t, func() {
+        c := gcfg.New(dir)
+        c.SetProfile("")
+        gtest.Assert(c.SetDefault("server.timeout", 30), nil)
+        gtest.Assert(c.SetDefault("server.port", 1), nil)
+
+        v := c.GetVar("server.timeout")
+        gtest.Assert(v.Int(), 30)
+        gtest.Assert(v.Layer(), gcfg.LAYER_DEFAULT)
+        v = c.GetVar("server.port")
+        gtest.Assert(v.Int(), 8000)
+        gtest.Assert(v.Layer(), gcfg.LAYER_FILE)
+        v = c.GetVar("server.none")
+        gtest.Assert(v.IsNil(), true)
+        gtest.Assert(v.Layer(), "")
+
+        // 环境配置文件
+        c.SetProfile("prod")
+        gtest.Assert(c.GetProfile(), "prod")
+        v = c.GetVar("server.port")
+        gtest.Assert(v.Int(), 80)
+        gtest.Assert(v.Layer(), gcfg.LAYER_PROFILE)
+        gtest.Assert(c.GetString("server.host"), "127.0.0.1")
+
+        // 自定义数据源
+        c.AddSource("remote", mapSource{"server.port" : 81, "db.host" : "db.remote"})
+        v = c.GetVar("server.port")
+        gtest.Assert(v.Int(), 81)
+        gtest.Assert(v.Layer(), "remote")
+
+        // 环境变量
+        genv.Set("APP_SERVER_PORT", "82")
+        defer genv.Remove("APP_SERVER_PORT")
+        v = c.GetVar("server.port")
+        gtest.Assert(v.Int(), 82)
+        gtest.Assert(v.Layer(), gcfg.LAYER_ENV)
+        gtest.Assert(c.GetInt("server.port"), 82)
+        c.SetEnvPrefix("")
+        gtest.Assert(c.GetVar("server.port").Layer(), "remote")
+        c.SetEnvPrefix("app")
+        gtest.Assert(c.GetVar("server.port").Layer(), gcfg.LAYER_ENV)
+
+        // 多个配置层的map合并，环境变量同时覆盖map中的子配置项
+        m := c.GetMap("server")
+        gtest.Assert(m["port"], "82")
+        gtest.Assert(m["host"], "127.0.0.1")
+        gtest.Assert(m["timeout"], 30)
+        gtest.Assert(c.GetVar("server").Layer(), gcfg.LAYER_ENV)
+        c.SetEnvPrefix("")
+        gtest.Assert(c.GetMap("server")["port"], 80)
+        gtest.Assert(c.GetVar("server").Layer(), gcfg.LAYER_PROFILE)
+        c.SetEnvPrefix("app")
+
+        s := struct {
+            Port    int
+            Host    string
+            Timeout int
+        }{}
+        gtest.Assert(c.GetToStruct("server", &s), nil)
+        gtest.Assert(s.Port, 82)
+        gtest.Assert(s.Host, "127.0.0.1")
+        gtest.Assert(s.Timeout, 30)
+    })
+}
+
+func TestConfig_EnvChildren(t *testing.T) {
+    dir := newConfigDir(map[string]string {
+        "config.toml" : "[[database.default]]\nhost = \"127.0.0.1\"\npass = \"12345678\"\n[[database.default]]\nhost = \"127.0.0.2\"\npass = \"12345678\"\n",
+    })
+    defer gfile.Remove(dir)
+
+    gtest.Case(t, func() {
+        c := gcfg.New(dir)
+        c.SetProfile("")
+        genv.Set("APP_DATABASE_DEFAULT_1_PASS", "secret")
+        defer genv.Remove("APP_DATABASE_DEFAULT_1_PASS")
+
+        // 读取整个配置段(例如gins.Database)时覆盖其中的子配置项
+        v := c.GetVar("database")
+        gtest.Assert(v.Layer(), gcfg.LAYER_ENV)
+        nodes := gconv.Map(v.Val())["default"].([]interface{})
+        gtest.Assert(gconv.Map(nodes[0])["pass"], "12345678")
+        gtest.Assert(gconv.Map(nodes[1])["pass"], "secret")
+        gtest.Assert(gconv.Map(nodes[1])["host"], "127.0.0.2")
+        gtest.Assert(gconv.Map(c.GetArray("database.default")[1])["pass"], "secret")
+        gtest.Assert(c.GetString("database.default.1.pass"), "secret")
+
+        // 不修改缓存的配置内容
+        genv.Remove("APP_DATABASE_DEFAULT_1_PASS")
+        gtest.Assert(gconv.Map(c.GetArray("database.default")[1])["pass"], "12345678")
+        gtest.Assert(c.GetVar("database").Layer(), gcfg.LAYER_FILE)
+    })
+}
+
+// 只使用环境变量等配置层时，配置文件不存在不输出错误日志
+func TestConfig_WithoutFile(t *testing.T) {
+    dir := newConfigDir(nil)
+    defer gfile.Remove(dir)
+
+    gtest.Case(t, func() {
+        buffer := bytes.NewBuffer(nil)
+        writer := glog.GetWriter()
+        glog.SetWriter(buffer)
+        defer glog.SetWriter(writer)
+
+        c := gcfg.New(dir)
+        c.SetProfile("")
+        gtest.Assert(c.SetDefault("server.port", 80), nil)
+        genv.Set("APP_SERVER_HOST", "127.0.0.1")
+        defer genv.Remove("APP_SERVER_HOST")
+        for i := 0; i < 3; i++ {
+            gtest.Assert(c.GetInt("server.port"), 80)
+            gtest.Assert(c.GetString("server.host"), "127.0.0.1")
+        }
+        gtest.Assert(strings.Contains(buffer.String(), "cannot find config file"), false)
+    })
+}
+
+// 命令行选项在进程启动时解析，因此通过子进程执行测试
+func TestConfig_CmdLayer(t *testing.T) {
+    if os.Getenv("GCFG_TEST_CMD") == "" {
+        cmd := exec.Command(os.Args[0], "-test.run=^TestConfig_CmdLayer$", "--", "--server.port=9000", "--server.host=cmd.local")
+        cmd.Env = append(os.Environ(), "GCFG_TEST_CMD=1", "APP_SERVER_PORT=82")
+        output, err := cmd.CombinedOutput()
+        if err != nil {
+            t.Fatalf("%v: %s", err, output)
+        }
+        return
+    }
+    dir := newConfigDir(map[string]string {
+        "config.toml" : "[server]\nport = 8000\n",
+    })
+    defer gfile.Remove(dir)
+
+    gtest.Case(t, func() {
+        c := gcfg.New(dir)
+        v := c.GetVar("server.port")
+        gtest.Assert(v.Int(), 9000)
+        gtest.Assert(v.Layer(), gcfg.LAYER_CMD)
+
+        // 命令行选项同样覆盖map中的子配置项
+        v = c.GetVar("server")
+        gtest.Assert(v.Layer(), gcfg.LAYER_CMD)
+        gtest.Assert(gconv.Map(v.Val())["port"], "9000")
+        gtest.Assert(c.GetMap("server")["host"], nil)
+    })
+}
+
+func TestConfig_Watch(t *testing.T) {
+    dir := newConfigDir(map[string]string {
+        "config.toml" : "[server]\nport = 8000\nname = \"gf\"\n",
+    })
+    defer gfile.Remove(dir)
+    path := dir + gfile.Separator + "config.toml"
+
+    gtest.Case(t, func() {
+        c := gcfg.New(dir)
+        c.AddValidator(func(path string, config *gjson.Json) error {
+            if config.GetInt("server.port") <= 0 {
+                return errors.New("invalid server port")
+            }
+            return nil
+        })
+        events := make(chan [2]int, 10)
+        c.Watch("server.port", func(old, new *gvar.Var) {
+            events <- [2]int{old.Int(), new.Int()}
+        })
+        count := gtype.NewInt()
+        c.Watch("server.name", func(old, new *gvar.Var) {
+            count.Add(1)
+        })
+        wait := func() [2]int {
+            select {
+                case e := <- events:
+                    return e
+                case <- time.After(2*time.Second):
+                    return [2]int{}
+            }
+        }
+
+        gfile.PutContents(path, "[server]\nport = 8001\nname = \"gf\"\n")
+        gtest.Assert(wait(), [2]int{8000, 8001})
+        gtest.Assert(c.GetInt("server.port"), 8001)
+
+        // 格式错误及校验失败时保留最近一次有效的配置内容
+        gfile.PutContents(path, "[server\nport = ")
+        time.Sleep(300*time.Millisecond)
+        gtest.Assert(c.GetInt("server.port"), 8001)
+        gfile.PutContents(path, "[server]\nport = 0\nname = \"gf\"\n")
+        time.Sleep(300*time.Millisecond)
+        gtest.Assert(c.GetInt("server.port"), 8001)
+        c.Reload()
+        gtest.Assert(c.GetInt("server.port"), 8001)
+        gtest.Assert(len(events), 0)
+
+        gfile.PutContents(path, "[server]\nport = 8002\nname = \"gf\"\n")
+        gtest.Assert(wait(), [2]int{8001, 8002})
+        gtest.Assert(c.GetString("server.name"), "gf")
+        gtest.Assert(count.Val(), 0)
+    })
+    // 首次加载校验失败
+    gtest.Case(t, func() {
+        c := gcfg.New(dir)
+        c.AddValidator(func(path string, config *gjson.Json) error {
+            return errors.New("invalid config")
+        })
+        gtest.Assert(c.GetVar("server.port").IsNil(), true)
+    })
+}
+
+func TestConfig_WatchRecreate(t *testing.T) {
+    newWatched := func(dir string, file string) (*gcfg.Config, func() [2]int) {
+        c      := gcfg.New(dir, file)
+        events := make(chan [2]int, 10)
+        c.Watch("server.port", func(old, new *gvar.Var) {
+            events <- [2]int{old.Int(), new.Int()}
+        })
+        return c, func() [2]int {
+            select {
+                case e := <- events:
+                    return e
+                case <- time.After(3*time.Second):
+                    return [2]int{}
+            }
+        }
+    }
+    // 删除后重新创建配置文件
+    gtest.Case(t, func() {
+        dir := newConfigDir(map[string]string {
+            "config.toml" : "[server]\nport = 8000\n",
+        })
+        defer gfile.Remove(dir)
+        path    := dir + gfile.Separator + "config.toml"
+        c, wait := newWatched(dir, "config.toml")
+        gtest.Assert(c.GetInt("server.port"), 8000)
+
+        // 删除期间继续使用最近一次有效的配置内容
+        gfile.Remove(path)
+        time.Sleep(300*time.Millisecond)
+        gtest.Assert(c.GetInt("server.port"), 8000)
+
+        gfile.PutContents(path, "[server]\nport = 8001\n")
+        gtest.Assert(wait(), [2]int{8000, 8001})
+        gtest.Assert(c.GetInt("server.port"), 8001)
+
+        // 重新创建后继续监控文件修改
+        gfile.PutContents(path, "[server]\nport = 8002\n")
+        gtest.Assert(wait(), [2]int{8001, 8002})
+    })
+    // 符号链接替换(例如Kubernetes ConfigMap)
+    gtest.Case(t, func() {
+        dir := newConfigDir(map[string]string {
+            "data1/config.toml" : "[server]\nport = 9000\n",
+            "data2/config.toml" : "[server]\nport = 9001\n",
+        })
+        defer gfile.Remove(dir)
+        path := dir + gfile.Separator + "config.toml"
+        gtest.Assert(os.Symlink(dir + gfile.Separator + "data1/config.toml", path), nil)
+        c, wait := newWatched(dir, "config.toml")
+        gtest.Assert(c.GetInt("server.port"), 9000)
+
+        gtest.Assert(os.Symlink(dir + gfile.Separator + "data2/config.toml", path + ".tmp"), nil)
+        gtest.Assert(os.Rename(path + ".tmp", path), nil)
+        gfile.Remove(dir + gfile.Separator + "data1")
+        gtest.Assert(wait(), [2]int{9000, 9001})
+        gtest.Assert(c.GetInt("server.port"), 9001)
+    })
+}
+
+func TestConfig_Secret(t *testing.T) {
+    key := []byte("0123456789abcdef")
+    gtest.Case(t, func() {
+        enc1, err := gcfg.Encrypt("s3cret", key)
+        gtest.Assert(err, nil)
+        enc2, err := gcfg.Encrypt("s3cret", key)
+        gtest.Assert(err, nil)
+        gtest.AssertNE(enc1, enc2)
+        gtest.Assert(gcfg.IsEncrypted(enc1), true)
+        gtest.Assert(gcfg.IsEncrypted("ENC()"), false)
+        gtest.Assert(gcfg.IsEncrypted("s3cret"), false)
+
+        plain, err := gcfg.Decrypt(enc1, key)
+        gtest.Assert(err, nil)
+        gtest.Assert(plain, "s3cret")
+        // 密钥错误时解密失败，不返回错误的明文
+        for i := 0; i < 200; i++ {
+            enc, _ := gcfg.Encrypt(fmt.Sprintf("s3cret%d", i), key)
+            _, err  = gcfg.Decrypt(enc, []byte("fedcba9876543210"))
+            gtest.AssertNE(err, nil)
+        }
+        _, err = gcfg.Decrypt("ENC(invalid)", key)
+        gtest.AssertNE(err, nil)
+        _, err = gcfg.Encrypt("s3cret", []byte("short"))
+        gtest.AssertNE(err, nil)
+    })
+
+    enc, _ := gcfg.Encrypt("s3cret", key)
+    dir    := newConfigDir(map[string]string {
+        "config.toml" : fmt.Sprintf("[app]\nname = \"gf\"\ntoken = \"abc\"\n[[database.default]]\nhost = \"127.0.0.1\"\npass = \"%s\"\n", enc),
+        "config.key"  : string(key) + "\n",
+    })
+    defer gfile.Remove(dir)
+
+    gtest.Case(t, func() {
+        c := gcfg.New(dir)
+        c.SetKey(key)
+        gtest.Assert(c.GetString("database.default.0.pass"), "s3cret")
+        gtest.Assert(gconv.Map(c.GetArray("database.default")[0])["pass"], "s3cret")
+        gtest.Assert(c.GetMap("app")["token"], "abc")
+        // 缓存的配置内容不被修改
+        gtest.Assert(c.GetRedacted("database.default.0.pass"), gcfg.REDACTED_VALUE)
+        gtest.Assert(c.GetString("database.default.0.pass"), "s3cret")
+
+        // 输出时替换敏感配置项
+        m := gconv.Map(c.GetRedacted(""))
+        gtest.Assert(gconv.Map(m["app"])["name"], "gf")
+        gtest.Assert(gconv.Map(m["app"])["token"], gcfg.REDACTED_VALUE)
+        node := gconv.Map(gconv.Interfaces(gconv.Map(m["database"])["default"])[0])
+        gtest.Assert(node["pass"], gcfg.REDACTED_VALUE)
+        gtest.Assert(node["host"], "127.0.0.1")
+        c.AddSecretKey("Host")
+        gtest.Assert(c.GetRedacted("database.default.0.host"), gcfg.REDACTED_VALUE)
+        gtest.Assert(c.GetString("database.default.0.host"), "127.0.0.1")
+    })
+    // 通过环境变量及密钥文件设置密钥
+    gtest.Case(t, func() {
+        // 未设置密钥时解密失败，返回nil并通过GetVar返回错误
+        c := gcfg.New(dir)
+        gtest.Assert(c.Get("database.default.0.pass"), nil)
+        gtest.AssertNE(c.GetVar("database.default.0.pass").Error(), nil)
+        gtest.AssertNE(c.GetVar("database").Error(), nil)
+        gtest.Assert(gconv.Map(c.GetArray("database.default")[0])["pass"], nil)
+        gtest.Assert(c.GetVar("app.name").Error(), nil)
+
+        genv.Set("GF_GCFG_KEY", string(key))
+        c = gcfg.New(dir)
+        gtest.Assert(c.GetString("database.default.0.pass"), "s3cret")
+        genv.Remove("GF_GCFG_KEY")
+
+        genv.Set("GF_GCFG_KEYFILE", dir + gfile.Separator + "config.key")
+        defer genv.Remove("GF_GCFG_KEYFILE")
+        c = gcfg.New(dir)
+        gtest.Assert(c.GetString("database.default.0.pass"), "s3cret")
+    })
+}
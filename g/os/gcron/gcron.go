@@ -34,6 +34,11 @@ func Add(pattern string, job func(), name ... string) (*Entry, error) {
     return defaultCron.Add(pattern, job, name...)
 }
 
+// 添加带有上下文及错误返回的定时任务
+func AddJob(pattern string, job JobFunc, name ... string) (*Entry, error) {
+    return defaultCron.AddJob(pattern, job, name...)
+}
+
 // 添加单例运行定时任务
 func AddSingleton(pattern string, job func(), name ... string) (*Entry, error) {
     return defaultCron.AddSingleton(pattern, job, name...)
@@ -98,3 +103,13 @@ func Start(name string) {
 func Stop(name string) {
     defaultCron.Stop(name)
 }
+
+// 设置定时任务持久化存储，用于多实例部署时保证具名定时任务的每个运行时刻只执行一次
+func SetStore(store Store) {
+    defaultCron.SetStore(store)
+}
+
+// 设置停机期间错过的任务的补偿策略
+func SetCatchup(policy int) {
+    defaultCron.SetCatchup(policy)
+}
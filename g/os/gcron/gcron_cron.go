@@ -7,12 +7,14 @@
 package gcron
 
 import (
+    "context"
     "errors"
     "fmt"
     "gitee.com/johng/gf/g/container/garray"
     "gitee.com/johng/gf/g/container/gmap"
     "gitee.com/johng/gf/g/container/gtype"
     "gitee.com/johng/gf/g/os/gtimer"
+    "sync"
     "time"
 )
 
@@ -21,6 +23,9 @@ type Cron struct {
     idgen    *gtype.Int               // 用于唯一名称生成
     status   *gtype.Int               // 定时任务状态(0: 未执行; 1: 运行中; 2: 已停止; -1:删除关闭)
     entries  *gmap.StringInterfaceMap // 所有的定时任务项
+    catchup  *gtype.Int               // 停机期间错过的任务的补偿策略
+    mu       sync.RWMutex             // 持久化存储的读写锁
+    store    Store                    // 持久化存储(可选)
 }
 
 // 创建自定义的定时任务管理对象
@@ -29,11 +34,20 @@ func New() *Cron {
         idgen    : gtype.NewInt(1000000),
         status   : gtype.NewInt(STATUS_RUNNING),
         entries  : gmap.NewStringInterfaceMap(),
+        catchup  : gtype.NewInt(CATCHUP_NONE),
     }
 }
 
 // 添加定时任务
 func (c *Cron) Add(pattern string, job func(), name ... string) (*Entry, error) {
+    return c.AddJob(pattern, func(ctx context.Context) error {
+        job()
+        return nil
+    }, name...)
+}
+
+// 添加带有上下文及错误返回的定时任务，ctx在定时任务关闭时取消，任务返回的错误及产生的panic记录到任务运行记录中
+func (c *Cron) AddJob(pattern string, job JobFunc, name ... string) (*Entry, error) {
     if len(name) > 0 {
         if c.Search(name[0]) != nil {
             return nil, errors.New(fmt.Sprintf(`cron job "%s" already exists`, name[0]))
@@ -147,9 +161,14 @@ func (c *Cron) Stop(name...string) {
     }
 }
 
-// 关闭定时任务
+// 关闭定时任务，正在执行的任务的上下文将会被取消
 func (c *Cron) Close() {
     c.status.Set(STATUS_CLOSED)
+    c.entries.RLockFunc(func(m map[string]interface{}) {
+        for _, v := range m {
+            v.(*Entry).cancel()
+        }
+    })
 }
 
 // 获取所有已注册的定时任务数量
@@ -7,6 +7,8 @@
 package gcron
 
 import (
+    "context"
+    "gitee.com/johng/gf/g/container/gtype"
     "gitee.com/johng/gf/g/os/gtimer"
     "strconv"
     "time"
@@ -14,16 +16,21 @@ import (
 
 // 定时任务项
 type Entry struct {
-    cron       *Cron         // 所属定时任务
-    entry      *gtimer.Entry // 定时器任务对象
-    schedule   *cronSchedule // 定时任务配置对象
-    Name       string        // 定时任务名称
-    Job        func()        // 注册定时任务方法
-    Time       time.Time     // 注册时间
+    cron       *Cron              // 所属定时任务
+    entry      *gtimer.Entry      // 定时器任务对象
+    schedule   *cronSchedule      // 定时任务配置对象
+    named      bool               // 是否为具名定时任务(只有具名定时任务才会使用持久化存储)
+    loaded     *gtype.Bool        // 是否已从持久化存储中加载运行状态(补偿执行错过的任务)
+    ctx        context.Context    // 任务执行的上下文，定时任务关闭时取消
+    cancel     context.CancelFunc // 取消任务执行的上下文
+    history    *runHistory        // 任务运行记录
+    Name       string             // 定时任务名称
+    Job        func()             // 定时任务方法，每次运行时调用，可以在停止(Stop)定时任务后替换；AddJob添加的任务包装为使用定时任务上下文执行的方法
+    Time       time.Time          // 注册时间
 }
 
 // 创建定时任务
-func (c *Cron) addEntry(pattern string, job func(), singleton bool, times int, name ... string) (*Entry, error) {
+func (c *Cron) addEntry(pattern string, job JobFunc, singleton bool, times int, name ... string) (*Entry, error) {
     schedule, err := newSchedule(pattern)
     if err != nil {
         return nil, err
@@ -31,9 +38,18 @@ func (c *Cron) addEntry(pattern string, job func(), singleton bool, times int, n
     entry := &Entry {
         cron      : c,
         schedule  : schedule,
-        Job       : job,
+        named     : len(name) > 0,
+        loaded    : gtype.NewBool(),
+        history   : newRunHistory(gDEFAULT_HISTORY_SIZE),
         Time      : time.Now(),
     }
+    entry.ctx, entry.cancel = context.WithCancel(context.Background())
+    entry.Job = func() {
+        // 任务返回的错误通过panic传递给execute记录
+        if err := job(entry.ctx); err != nil {
+            panic(jobError{err})
+        }
+    }
     if len(name) > 0 {
         entry.Name = name[0]
     } else {
@@ -80,21 +96,54 @@ func (entry *Entry) Stop() {
     entry.entry.Stop()
 }
 
-// 关闭定时任务
+// 关闭定时任务，正在执行的任务的上下文将会被取消
 func (entry *Entry) Close() {
     entry.cron.Remove(entry.Name)
     entry.entry.Close()
+    entry.cancel()
+}
+
+// 设置定时格式计算使用的时区
+func (entry *Entry) SetLocation(location *time.Location) {
+    entry.schedule.location.Set(location)
+}
+
+// 获取定时格式计算使用的时区
+func (entry *Entry) GetLocation() *time.Location {
+    return entry.schedule.getLocation()
+}
+
+// 获取接下来n次运行的计划时间(使用定时任务的时区)，不考虑运行次数限制及任务状态
+func (entry *Entry) Next(n int) []time.Time {
+    times := make([]time.Time, 0, n)
+    t     := time.Now()
+    for i := 0; i < n; i++ {
+        if t = entry.schedule.next(t); t.IsZero() {
+            break
+        }
+        times = append(times, t)
+    }
+    return times
 }
 
 // 定时任务检查执行
 func (entry *Entry) check() {
-    if entry.schedule.meet(time.Now()) {
+    now   := time.Now()
+    store := entry.getStore()
+    if store != nil {
+        // 设置持久化存储后的第一次检查时补偿执行错过的任务
+        if status := entry.cron.status.Val(); status != STATUS_STOPPED && status != STATUS_CLOSED && !entry.loaded.Set(true) {
+            entry.catchUp(store, now)
+        }
+    }
+    if entry.schedule.meet(now) {
         switch entry.cron.status.Val() {
             case STATUS_STOPPED:
                 return
 
             case STATUS_CLOSED:
                 entry.cron.Remove(entry.Name)
+                entry.cancel()
                 gtimer.Exit()
 
             case STATUS_READY: fallthrough
@@ -102,9 +151,14 @@ func (entry *Entry) check() {
                 defer func() {
                     if entry.entry.Status() == STATUS_CLOSED {
                         entry.cron.Remove(entry.Name)
+                        entry.cancel()
                     }
                 }()
-                entry.Job()
+                if store != nil {
+                    entry.runWithStore(store, now.Truncate(time.Second))
+                } else {
+                    entry.execute()
+                }
         }
     }
 }
@@ -0,0 +1,114 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcron
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "gitee.com/johng/gf/g/os/glog"
+    "sync"
+    "time"
+)
+
+const (
+    gDEFAULT_HISTORY_SIZE = 10 // 默认保留的任务运行记录数量
+)
+
+// 定时任务方法，ctx在定时任务关闭时取消，返回的错误将会记录到任务运行记录中
+type JobFunc = func(ctx context.Context) error
+
+// 定时任务方法返回的错误，由Job方法通过panic传递给execute
+type jobError struct {
+    err error
+}
+
+// 任务运行记录
+type RunRecord struct {
+    Start    time.Time     // 开始运行时间
+    Duration time.Duration // 运行耗时
+    Error    error         // 任务返回的错误(任务产生panic时转换为错误)
+}
+
+// 有限长度的任务运行记录
+type runHistory struct {
+    mu      sync.RWMutex
+    size    int
+    records []RunRecord
+}
+
+// 创建任务运行记录对象
+func newRunHistory(size int) *runHistory {
+    return &runHistory {
+        size    : size,
+        records : make([]RunRecord, 0, size),
+    }
+}
+
+// 添加任务运行记录，超过长度时删除最早的记录
+func (h *runHistory) add(record RunRecord) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.size <= 0 {
+        return
+    }
+    if len(h.records) >= h.size {
+        h.records = append(h.records[ : 0], h.records[len(h.records) - h.size + 1 : ]...)
+    }
+    h.records = append(h.records, record)
+}
+
+// 设置运行记录的最大数量
+func (h *runHistory) setSize(size int) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.size = size
+    if size <= 0 {
+        h.records = h.records[ : 0]
+    } else if len(h.records) > size {
+        h.records = append(h.records[ : 0], h.records[len(h.records) - size : ]...)
+    }
+}
+
+// 获取运行记录列表的拷贝
+func (h *runHistory) list() []RunRecord {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    records := make([]RunRecord, len(h.records))
+    copy(records, h.records)
+    return records
+}
+
+// 获取任务最近的运行记录(按照运行时间从早到晚排序)
+func (entry *Entry) History() []RunRecord {
+    return entry.history.list()
+}
+
+// 设置保留的任务运行记录数量，默认为10，为0时不记录
+func (entry *Entry) SetHistorySize(size int) {
+    entry.history.setSize(size)
+}
+
+// 执行定时任务，捕获任务产生的panic，任务返回错误时输出错误日志，并添加运行记录
+func (entry *Entry) execute() {
+    record := RunRecord{Start : time.Now()}
+    defer func() {
+        if e := recover(); e != nil {
+            if je, ok := e.(jobError); ok {
+                record.Error = je.err
+            } else {
+                record.Error = errors.New(fmt.Sprintf(`panic: %v`, e))
+            }
+        }
+        record.Duration = time.Since(record.Start)
+        if record.Error != nil {
+            glog.Errorfln(`cron job "%s" failed: %s`, entry.Name, record.Error.Error())
+        }
+        entry.history.add(record)
+    }()
+    entry.Job()
+}
@@ -9,6 +9,7 @@ package gcron
 import (
     "errors"
     "fmt"
+    "gitee.com/johng/gf/g/container/gtype"
     "gitee.com/johng/gf/g/util/gregex"
     "strconv"
     "strings"
@@ -17,18 +18,20 @@ import (
 
 // 运行时间管理对象
 type cronSchedule struct {
-    create  int64    // 创建时间戳(秒)
-    every   int64    // 运行时间间隔(秒)
-    pattern string   // 原始注册字符串
-    second  map[int]struct{}
-    minute  map[int]struct{}
-    hour    map[int]struct{}
-    day     map[int]struct{}
-    week    map[int]struct{}
-    month   map[int]struct{}
+    create   int64            // 创建时间戳(秒)
+    every    int64            // 运行时间间隔(秒)
+    pattern  string           // 原始注册字符串
+    location *gtype.Interface // 定时格式计算使用的时区(*time.Location)
+    second   map[int]struct{}
+    minute   map[int]struct{}
+    hour     map[int]struct{}
+    day      map[int]struct{}
+    week     map[int]struct{}
+    month    map[int]struct{}
 }
 
 const (
+    gREGEX_FOR_TZ   = `^(?:CRON_TZ|TZ)=(\S+)\s+(.+)$`
     gREGEX_FOR_CRON = `^([\-/\d\*\?,]+)\s+([\-/\d\*\?,]+)\s+([\-/\d\*\?,]+)\s+([\-/\d\*\?,]+)\s+([\-/\d\*\?,]+)\s+([\-/\d\*\?,]+)$`
 )
 
@@ -70,8 +73,38 @@ var (
     }
 )
 
-// 解析定时格式为cronSchedule对象
+// 解析定时格式为cronSchedule对象，定时格式可以使用"CRON_TZ=时区名称"前缀指定时区，
+// 例如："CRON_TZ=Asia/Shanghai 0 30 9 * * *"，未指定时使用本地时区
 func newSchedule(pattern string) (*cronSchedule, error) {
+    schedule, err := parseSchedule(pattern)
+    if err != nil {
+        return nil, err
+    }
+    schedule.pattern = pattern
+    return schedule, nil
+}
+
+// 解析定时格式(包括时区前缀)
+func parseSchedule(pattern string) (*cronSchedule, error) {
+    location := time.Local
+    if match, _ := gregex.MatchString(gREGEX_FOR_TZ, pattern); len(match) > 0 {
+        if loc, err := time.LoadLocation(match[1]); err != nil {
+            return nil, errors.New(fmt.Sprintf(`invalid time zone "%s": %s`, match[1], err.Error()))
+        } else {
+            location = loc
+            pattern  = match[2]
+        }
+    }
+    schedule, err := parseSchedulePattern(pattern)
+    if err != nil {
+        return nil, err
+    }
+    schedule.location = gtype.NewInterface(location)
+    return schedule, nil
+}
+
+// 解析不包含时区前缀的定时格式
+func parseSchedulePattern(pattern string) (*cronSchedule, error) {
     // 处理预定义的定时格式
     if match, _ := gregex.MatchString(`(@\w+)\s*(\w*)\s*`, pattern); len(match) > 0 {
         key := strings.ToLower(match[1])
@@ -213,8 +246,14 @@ func parseItemValue(value string, valueType byte) (int, error) {
     return 0, errors.New(fmt.Sprintf(`invalid pattern value: "%s"`, value))
 }
 
+// 获取定时格式计算使用的时区
+func (s *cronSchedule) getLocation() *time.Location {
+    return s.location.Val().(*time.Location)
+}
+
 // 判断给定的时间是否满足schedule
 func (s *cronSchedule) meet(t time.Time) bool {
+    t = t.In(s.getLocation())
     if s.every != 0 {
         diff := t.Unix() - s.create
         if diff > 0 {
@@ -242,4 +281,54 @@ func (s *cronSchedule) meet(t time.Time) bool {
         }
         return true
     }
-}
\ No newline at end of file
+}
+
+// 获得给定时间之后(不包含给定时间)下一次满足schedule的时间(秒级精度)，5年内不存在满足的时间时返回零值
+func (s *cronSchedule) next(t time.Time) time.Time {
+    t = t.In(s.getLocation()).Truncate(time.Second).Add(time.Second)
+    if s.every != 0 {
+        if diff := t.Unix() - s.create; diff <= 0 {
+            return time.Unix(s.create + s.every, 0).In(t.Location())
+        } else if r := diff%s.every; r != 0 {
+            t = t.Add(time.Duration(s.every - r)*time.Second)
+        }
+        return t
+    }
+    limit := t.AddDate(5, 0, 0)
+    for t.Before(limit) {
+        year, month, day := t.Date()
+        hour, min, _     := t.Clock()
+        if _, ok := s.month[int(month)]; !ok {
+            t = nextTime(t, time.Date(year, month + 1, 1, 0, 0, 0, 0, t.Location()), 24*time.Hour)
+            continue
+        }
+        _, ok1 := s.day[day]
+        _, ok2 := s.week[int(t.Weekday())]
+        if !ok1 || !ok2 {
+            t = nextTime(t, time.Date(year, month, day + 1, 0, 0, 0, 0, t.Location()), time.Hour)
+            continue
+        }
+        if _, ok := s.hour[hour]; !ok {
+            t = nextTime(t, time.Date(year, month, day, hour + 1, 0, 0, 0, t.Location()), time.Hour)
+            continue
+        }
+        if _, ok := s.minute[min]; !ok {
+            t = nextTime(t, time.Date(year, month, day, hour, min + 1, 0, 0, t.Location()), time.Minute)
+            continue
+        }
+        if _, ok := s.second[t.Second()]; !ok {
+            t = t.Add(time.Second)
+            continue
+        }
+        return t
+    }
+    return time.Time{}
+}
+
+// 夏令时切换时按照日期计算的时间可能不会增加，此时按照给定的步长增加，保证时间递增
+func nextTime(t time.Time, next time.Time, step time.Duration) time.Time {
+    if next.After(t) {
+        return next
+    }
+    return t.Add(step)
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package gcron
+
+import (
+    "gitee.com/johng/gf/g/os/glog"
+    "time"
+)
+
+const (
+    CATCHUP_NONE = iota // 不补偿执行停机期间错过的任务(默认)
+    CATCHUP_ONCE        // 停机期间错过的任务只补偿执行一次
+    CATCHUP_ALL         // 停机期间错过的每一次任务都补偿执行(最多gMAX_CATCHUP_TIMES次)
+
+    gMAX_CATCHUP_TIMES = 1000 // 补偿执行的最大次数(最近的错过时间)
+)
+
+// 定时任务运行状态
+type JobState struct {
+    Name    string    // 定时任务名称
+    LastRun time.Time // 最近一次运行的计划时间
+    NextRun time.Time // 下一次运行的计划时间
+}
+
+// 使用Unix时间戳(秒)创建定时任务运行状态，时间戳为0时对应零值时间，用于持久化存储的实现
+func NewJobState(name string, lastRun, nextRun int64) *JobState {
+    return &JobState {
+        Name    : name,
+        LastRun : unixTime(lastRun),
+        NextRun : unixTime(nextRun),
+    }
+}
+
+// 获取运行状态的Unix时间戳(秒)，零值时间对应0，用于持久化存储的实现
+func (s *JobState) Timestamps() (lastRun, nextRun int64) {
+    return unixTimestamp(s.LastRun), unixTimestamp(s.NextRun)
+}
+
+// 定时任务持久化存储，多个实例使用同一存储时，具名定时任务的每一个运行时刻只会被其中一个实例执行，
+// 并且记录任务的运行状态，以便在重启后补偿执行停机期间错过的任务。
+// 基于数据库及Redis的存储实现分别位于gcron/store/dbstore及gcron/store/redisstore包中。
+type Store interface {
+    // 获取定时任务的运行状态，不存在时返回nil
+    Get(name string) (*JobState, error)
+    // 抢占定时任务在state.LastRun时刻的执行权，成功时保存运行状态并返回true，
+    // 已经有实例执行过该时刻(或者更晚时刻)的任务时返回false，多个实例并发调用时只有一个实例返回true。
+    Claim(state *JobState) (bool, error)
+}
+
+// 设置定时任务持久化存储，设置后只有具名的定时任务(添加时给定名称)才会使用存储，名称需要在多个实例间保持一致，
+// 设置为nil时取消持久化存储。
+func (c *Cron) SetStore(store Store) {
+    c.mu.Lock()
+    c.store = store
+    c.mu.Unlock()
+}
+
+// 获取定时任务持久化存储
+func (c *Cron) GetStore() Store {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.store
+}
+
+// 设置停机期间错过的任务的补偿策略：CATCHUP_NONE/CATCHUP_ONCE/CATCHUP_ALL，
+// 定时任务在设置持久化存储后的第一次检查时，根据存储中的下一次运行时间判断是否有错过的任务。
+func (c *Cron) SetCatchup(policy int) {
+    c.catchup.Set(policy)
+}
+
+// 获取定时任务使用的持久化存储，未设置或者定时任务未命名时返回nil
+func (entry *Entry) getStore() Store {
+    if !entry.named {
+        return nil
+    }
+    return entry.cron.GetStore()
+}
+
+// 抢占执行权后执行定时任务，t为计划运行时间，存储操作失败时不执行任务(避免重复执行)
+func (entry *Entry) runWithStore(store Store, t time.Time) {
+    ok, err := store.Claim(&JobState {
+        Name    : entry.Name,
+        LastRun : t,
+        NextRun : entry.schedule.next(t),
+    })
+    if err != nil {
+        glog.Errorfln(`cron job "%s" claim failed: %s`, entry.Name, err.Error())
+        return
+    }
+    if ok {
+        entry.execute()
+    }
+}
+
+// 根据补偿策略执行停机期间错过的任务，now为当前检查时间。
+// 所有错过的运行时间通过一次抢占(最近一次错过的运行时间)获得执行权，抢占成功后在新的协程中依次补偿执行，
+// 因此补偿执行不会阻塞定时器的检查，并且多个实例中只会有一个实例执行补偿。
+func (entry *Entry) catchUp(store Store, now time.Time) {
+    policy := entry.cron.catchup.Val()
+    if policy == CATCHUP_NONE {
+        return
+    }
+    state, err := store.Get(entry.Name)
+    if err != nil {
+        glog.Errorfln(`cron job "%s" load state failed: %s`, entry.Name, err.Error())
+        return
+    }
+    if state == nil {
+        return
+    }
+    // 错过的运行时间(不包含当前时刻，当前时刻按照正常流程执行)
+    times := 0
+    last  := time.Time{}
+    now    = now.Truncate(time.Second)
+    for t := state.NextRun; !t.IsZero() && t.Before(now); t = entry.schedule.next(t) {
+        if times < gMAX_CATCHUP_TIMES {
+            times++
+        }
+        last = t
+    }
+    if times == 0 {
+        return
+    }
+    if policy == CATCHUP_ONCE {
+        times = 1
+    }
+    ok, err := store.Claim(&JobState {
+        Name    : entry.Name,
+        LastRun : last,
+        NextRun : entry.schedule.next(last),
+    })
+    if err != nil {
+        glog.Errorfln(`cron job "%s" claim failed: %s`, entry.Name, err.Error())
+        return
+    }
+    if !ok {
+        return
+    }
+    go func() {
+        for i := 0; i < times; i++ {
+            // 定时任务关闭后停止补偿执行
+            select {
+                case <- entry.ctx.Done():
+                    return
+                default:
+            }
+            entry.execute()
+        }
+    }()
+}
+
+// 将时间转换为Unix时间戳(秒)，零值时间转换为0
+func unixTimestamp(t time.Time) int64 {
+    if t.IsZero() {
+        return 0
+    }
+    return t.Unix()
+}
+
+// 将Unix时间戳(秒)转换为本地时间，0转换为零值时间
+func unixTime(timestamp int64) time.Time {
+    if timestamp <= 0 {
+        return time.Time{}
+    }
+    return time.Unix(timestamp, 0)
+}
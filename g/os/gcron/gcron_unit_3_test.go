@@ -0,0 +1,168 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+
+package gcron_test
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/gcron"
+    "gitee.com/johng/gf/g/util/gtest"
+    "sync"
+    "testing"
+    "time"
+)
+
+// 基于内存的持久化存储，记录每一次成功抢占的运行时刻
+type memStore struct {
+    mu     sync.Mutex
+    states map[string]gcron.JobState
+    claims int
+}
+
+func newMemStore() *memStore {
+    return &memStore {
+        states : make(map[string]gcron.JobState),
+    }
+}
+
+func (s *memStore) Get(name string) (*gcron.JobState, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if state, ok := s.states[name]; ok {
+        return &state, nil
+    }
+    return nil, nil
+}
+
+func (s *memStore) Claim(state *gcron.JobState) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if old, ok := s.states[state.Name]; ok && !old.LastRun.Before(state.LastRun) {
+        return false, nil
+    }
+    s.states[state.Name] = *state
+    s.claims++
+    return true, nil
+}
+
+func (s *memStore) getClaims() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.claims
+}
+
+func TestCron_JobState(t *testing.T) {
+    gtest.Case(t, func() {
+        state := gcron.NewJobState("job", 100, 0)
+        gtest.Assert(state.LastRun.Unix(), 100)
+        gtest.Assert(state.NextRun.IsZero(), true)
+        lastRun, nextRun := state.Timestamps()
+        gtest.Assert(lastRun, 100)
+        gtest.Assert(nextRun, 0)
+    })
+}
+
+func TestCron_Store_Distributed(t *testing.T) {
+    gtest.Case(t, func() {
+        runs  := gtype.NewInt()
+        store := newMemStore()
+        crons := []*gcron.Cron{gcron.New(), gcron.New()}
+        for _, cron := range crons {
+            cron.SetStore(store)
+            _, err := cron.Add("* * * * * *", func() {
+                runs.Add(1)
+            }, "job")
+            gtest.Assert(err, nil)
+        }
+        time.Sleep(3200*time.Millisecond)
+        for _, cron := range crons {
+            cron.Close()
+        }
+        time.Sleep(100*time.Millisecond)
+        // 每一个运行时刻只会被一个实例执行
+        gtest.Assert(runs.Val(), store.getClaims())
+        gtest.Assert(runs.Val() >= 2 && runs.Val() <= 4, true)
+
+        state, err := store.Get("job")
+        gtest.Assert(err, nil)
+        gtest.Assert(state.NextRun.Sub(state.LastRun), time.Second)
+        gtest.Assert(time.Since(state.LastRun) < 2*time.Second, true)
+    })
+}
+
+func TestCron_Store_Catchup(t *testing.T) {
+    year := time.Now().Year()
+    jan1 := func(year int) time.Time {
+        return time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+    }
+    for policy, expect := range map[int]int {
+        gcron.CATCHUP_NONE : 0,
+        gcron.CATCHUP_ONCE : 1,
+        gcron.CATCHUP_ALL  : 3,
+    } {
+        gtest.Case(t, func() {
+            store := newMemStore()
+            // 最近一次运行在3年前，之后错过了3次运行
+            ok, err := store.Claim(&gcron.JobState {
+                Name    : "yearly",
+                LastRun : jan1(year - 3),
+                NextRun : jan1(year - 2),
+            })
+            gtest.Assert(err, nil)
+            gtest.Assert(ok, true)
+
+            runs := gtype.NewInt()
+            cron := gcron.New()
+            cron.SetStore(store)
+            cron.SetCatchup(policy)
+            cron.Add("0 0 0 1 * 1", func() {
+                runs.Add(1)
+            }, "yearly")
+            // 未命名的定时任务不使用持久化存储
+            cron.Add("0 0 0 1 * 1", func() {
+                runs.Add(100)
+            })
+            time.Sleep(1500*time.Millisecond)
+            cron.Close()
+            gtest.Assert(runs.Val(), expect)
+
+            state, err := store.Get("yearly")
+            gtest.Assert(err, nil)
+            if policy == gcron.CATCHUP_NONE {
+                gtest.Assert(state.LastRun, jan1(year - 3))
+            } else {
+                gtest.Assert(state.LastRun, jan1(year))
+                gtest.Assert(state.NextRun, jan1(year + 1))
+                // 所有错过的运行时间只抢占一次
+                gtest.Assert(store.getClaims(), 2)
+            }
+        })
+    }
+}
+
+func TestCron_Store_CatchupLimit(t *testing.T) {
+    gtest.Case(t, func() {
+        store := newMemStore()
+        now   := time.Now().Truncate(time.Second)
+        store.Claim(&gcron.JobState {
+            Name    : "secondly",
+            LastRun : now.Add(-5001*time.Second),
+            NextRun : now.Add(-5000*time.Second),
+        })
+        runs := gtype.NewInt()
+        cron := gcron.New()
+        cron.SetStore(store)
+        cron.SetCatchup(gcron.CATCHUP_ALL)
+        cron.Add("* * * * * *", func() {
+            runs.Add(1)
+        }, "secondly")
+        time.Sleep(1500*time.Millisecond)
+        cron.Close()
+        // 补偿执行最多gMAX_CATCHUP_TIMES(1000)次，另外加上正常执行的次数
+        gtest.Assert(runs.Val() >= 1000 && runs.Val() <= 1003, true)
+    })
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+
+package gcron_test
+
+import (
+    "context"
+    "errors"
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/gcron"
+    "gitee.com/johng/gf/g/util/gtest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestCron_Entry_Next(t *testing.T) {
+    gtest.Case(t, func() {
+        cron := gcron.New()
+        defer cron.Close()
+        entry, err := cron.Add("CRON_TZ=Asia/Tokyo 0 30 9 * 1-5 *", func() {})
+        gtest.Assert(err, nil)
+        gtest.Assert(entry.GetLocation().String(), "Asia/Tokyo")
+        times := entry.Next(5)
+        gtest.Assert(len(times), 5)
+        for i, t := range times {
+            gtest.Assert(t.Location().String(), "Asia/Tokyo")
+            gtest.Assert(t.Format("15:04:05"), "09:30:00")
+            gtest.AssertNE(t.Weekday(), time.Saturday)
+            gtest.AssertNE(t.Weekday(), time.Sunday)
+            if i > 0 {
+                gtest.Assert(t.After(times[i - 1]), true)
+            } else {
+                gtest.Assert(t.After(time.Now()), true)
+            }
+        }
+        // 修改时区
+        entry.SetLocation(time.UTC)
+        times = entry.Next(1)
+        gtest.Assert(times[0].Location(), time.UTC)
+        gtest.Assert(times[0].Format("15:04:05"), "09:30:00")
+
+        entry, err = cron.Add("TZ=UTC 0 0 0 29 * 2", func() {})
+        gtest.Assert(err, nil)
+        for _, t := range entry.Next(2) {
+            gtest.Assert(t.Format("01-02 15:04:05"), "02-29 00:00:00")
+        }
+
+        entry, err = cron.Add("@every 2s", func() {})
+        gtest.Assert(err, nil)
+        times = entry.Next(3)
+        gtest.Assert(times[1].Sub(times[0]), 2*time.Second)
+        gtest.Assert(times[2].Sub(times[1]), 2*time.Second)
+
+        _, err = cron.Add("CRON_TZ=Invalid/Zone * * * * * *", func() {})
+        gtest.AssertNE(err, nil)
+    })
+}
+
+func TestCron_AddJob_History(t *testing.T) {
+    gtest.Case(t, func() {
+        cron  := gcron.New()
+        count := gtype.NewInt()
+        entry, err := cron.AddJob("* * * * * *", func(ctx context.Context) error {
+            switch count.Add(1) {
+                case 1:
+                    return errors.New("job failed")
+                case 2:
+                    panic("job panic")
+            }
+            return nil
+        })
+        gtest.Assert(err, nil)
+        entry.SetHistorySize(2)
+        time.Sleep(3200*time.Millisecond)
+        cron.Close()
+        history := entry.History()
+        gtest.Assert(len(history), 2)
+        gtest.Assert(strings.Contains(history[0].Error.Error(), "job panic"), true)
+        gtest.Assert(history[1].Error, nil)
+        gtest.Assert(history[1].Start.After(history[0].Start), true)
+        gtest.Assert(history[0].Duration >= 0, true)
+    })
+    // 返回的错误原样记录，替换Job后执行新的方法
+    gtest.Case(t, func() {
+        cron  := gcron.New()
+        count := gtype.NewInt()
+        entry, err := cron.AddJob("* * * * * *", func(ctx context.Context) error {
+            return errors.New("job failed")
+        })
+        gtest.Assert(err, nil)
+        time.Sleep(1200*time.Millisecond)
+        entry.Stop()
+        time.Sleep(100*time.Millisecond)
+        history := entry.History()
+        gtest.Assert(len(history) >= 1, true)
+        gtest.Assert(history[0].Error.Error(), "job failed")
+
+        entry.Job = func() {
+            count.Add(1)
+        }
+        entry.Start()
+        time.Sleep(1200*time.Millisecond)
+        cron.Close()
+        history = entry.History()
+        gtest.Assert(history[len(history) - 1].Error, nil)
+        gtest.Assert(count.Val() >= 1, true)
+    })
+    // 关闭定时任务时取消上下文
+    gtest.Case(t, func() {
+        cron   := gcron.New()
+        result := make(chan error, 1)
+        entry, err := cron.AddJob("* * * * * *", func(ctx context.Context) error {
+            <- ctx.Done()
+            result <- ctx.Err()
+            return ctx.Err()
+        })
+        gtest.Assert(err, nil)
+        time.Sleep(1200*time.Millisecond)
+        entry.Close()
+        select {
+            case err := <- result:
+                gtest.Assert(err, context.Canceled)
+            case <- time.After(time.Second):
+                gtest.Fatal("context not canceled")
+        }
+    })
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// Package dbstore provides a database backed job store for gcron.
+//
+// 基于数据库的定时任务持久化存储。
+package dbstore
+
+import (
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/os/gcron"
+)
+
+const (
+    gDEFAULT_TABLE = "gf_cron" // 默认的运行状态数据表名称
+)
+
+// 基于数据库的定时任务持久化存储，运行状态保存在数据表中，数据表结构(以MySQL为例)：
+// CREATE TABLE `gf_cron` (
+//   `name`     varchar(128) NOT NULL,
+//   `last_run` bigint NOT NULL DEFAULT 0,
+//   `next_run` bigint NOT NULL DEFAULT 0,
+//   PRIMARY KEY (`name`)
+// );
+// 其中last_run/next_run为Unix时间戳(秒)。每一个运行时刻通过带条件的UPDATE(last_run小于该时刻)抢占执行权，
+// 记录不存在时通过INSERT抢占(主键冲突表示已被其他实例抢占)，因此不需要额外的锁。使用示例：
+//     cron.SetStore(dbstore.New(db))
+type Store struct {
+    db    gdb.DB
+    table string
+}
+
+// 创建基于数据库的定时任务持久化存储，table为运行状态数据表名称，默认为gf_cron
+func New(db gdb.DB, table...string) *Store {
+    s := &Store {
+        db    : db,
+        table : gDEFAULT_TABLE,
+    }
+    if len(table) > 0 {
+        s.table = table[0]
+    }
+    return s
+}
+
+// 获取定时任务的运行状态，不存在时返回nil
+func (s *Store) Get(name string) (*gcron.JobState, error) {
+    record, err := s.db.Table(s.table).Where("name=?", name).One()
+    if err != nil || record == nil {
+        return nil, err
+    }
+    return gcron.NewJobState(name, record["last_run"].Int64(), record["next_run"].Int64()), nil
+}
+
+// 抢占定时任务在state.LastRun时刻的执行权，成功时保存运行状态并返回true
+func (s *Store) Claim(state *gcron.JobState) (bool, error) {
+    lastRun, nextRun := state.Timestamps()
+    result, err := s.db.Update(s.table, gdb.Map {
+        "last_run" : lastRun,
+        "next_run" : nextRun,
+    }, "name=? AND last_run<?", state.Name, lastRun)
+    if err != nil {
+        return false, err
+    }
+    if n, _ := result.RowsAffected(); n > 0 {
+        return true, nil
+    }
+    // 记录不存在或者该时刻已被抢占
+    _, err = s.db.Insert(s.table, gdb.Map {
+        "name"     : state.Name,
+        "last_run" : lastRun,
+        "next_run" : nextRun,
+    })
+    if err == nil {
+        return true, nil
+    }
+    // 插入失败时如果记录已存在，表示已被其他实例抢占
+    if old, e := s.Get(state.Name); e == nil && old != nil {
+        return false, nil
+    }
+    return false, err
+}
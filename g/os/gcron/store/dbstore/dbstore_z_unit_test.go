@@ -0,0 +1,82 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package dbstore_test
+
+import (
+    "errors"
+    "gitee.com/johng/gf/g/database/gdb"
+    "gitee.com/johng/gf/g/os/gcron"
+    "gitee.com/johng/gf/g/os/gcron/store/dbstore"
+    "gitee.com/johng/gf/g/util/gtest"
+    "testing"
+    "time"
+)
+
+func init() {
+    gdb.AddConfigNode("gcron", gdb.ConfigNode{
+        Type : "mock",
+        Name : "gcron",
+        Role : "master",
+    })
+}
+
+func TestStore(t *testing.T) {
+    mock := gdb.GetMock("gcron")
+    mdb, err := gdb.New("gcron")
+    if err != nil {
+        gtest.Fatal(err)
+    }
+    store := dbstore.New(mdb)
+    state := &gcron.JobState {
+        Name    : "job",
+        LastRun : time.Unix(100, 0),
+        NextRun : time.Unix(160, 0),
+    }
+    // 更新成功
+    gtest.Case(t, func() {
+        mock.Reset()
+        mock.ExpectExec(`^UPDATE gf_cron SET .+ WHERE name=\? AND last_run<\?$`).WillReturnResult(0, 1)
+        ok, err := store.Claim(state)
+        gtest.Assert(err, nil)
+        gtest.Assert(ok, true)
+        gtest.Assert(mock.ExpectationsWereMet(), nil)
+    })
+    // 记录不存在时插入
+    gtest.Case(t, func() {
+        mock.Reset()
+        mock.ExpectExec(`^UPDATE gf_cron`).WillReturnResult(0, 0)
+        mock.ExpectExec(`^INSERT INTO gf_cron`).WillReturnResult(0, 1)
+        ok, err := store.Claim(state)
+        gtest.Assert(err, nil)
+        gtest.Assert(ok, true)
+    })
+    // 已被其他实例抢占
+    gtest.Case(t, func() {
+        mock.Reset()
+        mock.ExpectExec(`^UPDATE gf_cron`).WillReturnResult(0, 0)
+        mock.ExpectExec(`^INSERT INTO gf_cron`).WillReturnError(errors.New("duplicate entry"))
+        mock.ExpectQuery(`^SELECT \* FROM gf_cron WHERE name=\?`).WithArgs("job").WillReturnRows(gdb.List {
+            {"name" : "job", "last_run" : 100, "next_run" : 160},
+        })
+        ok, err := store.Claim(state)
+        gtest.Assert(err, nil)
+        gtest.Assert(ok, false)
+
+        s, err := store.Get("job")
+        gtest.Assert(err, nil)
+        gtest.Assert(s.LastRun.Unix(), 100)
+        gtest.Assert(s.NextRun.Unix(), 160)
+    })
+    // 数据库错误
+    gtest.Case(t, func() {
+        mock.Reset()
+        mock.ExpectExec(`^UPDATE gf_cron`).WillReturnError(errors.New("connection refused"))
+        ok, err := store.Claim(state)
+        gtest.AssertNE(err, nil)
+        gtest.Assert(ok, false)
+    })
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// Package redisstore provides a redis backed job store for gcron.
+//
+// 基于Redis的定时任务持久化存储。
+package redisstore
+
+import (
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/os/gcron"
+    "gitee.com/johng/gf/g/util/gconv"
+)
+
+const (
+    gDEFAULT_PREFIX = "gcron:" // 键名的默认前缀
+)
+
+var (
+    // 抢占运行时刻：运行状态中的last_run小于该时刻时更新运行状态并返回1，否则返回0
+    claimScript = gredis.NewScript(`
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_run') or 0)
+if last >= tonumber(ARGV[1]) then
+    return 0
+end
+redis.call('HMSET', KEYS[1], 'last_run', ARGV[1], 'next_run', ARGV[2])
+return 1
+`)
+)
+
+// 基于Redis的定时任务持久化存储，运行状态保存在哈希表"前缀+state:名称"中，字段为last_run/next_run(Unix时间戳，秒)，
+// 每一个运行时刻通过Lua脚本原子地比较并更新运行状态(last_run小于该时刻时更新)来抢占执行权。使用示例：
+//     cron.SetStore(redisstore.New(redis))
+type Store struct {
+    redis  *gredis.Redis
+    prefix string
+}
+
+// 创建基于Redis的定时任务持久化存储，prefix为键名的前缀，默认为gcron:
+func New(redis *gredis.Redis, prefix...string) *Store {
+    s := &Store {
+        redis  : redis,
+        prefix : gDEFAULT_PREFIX,
+    }
+    if len(prefix) > 0 {
+        s.prefix = prefix[0]
+    }
+    return s
+}
+
+// 获取定时任务的运行状态，不存在时返回nil
+func (s *Store) Get(name string) (*gcron.JobState, error) {
+    v, err := s.redis.HGetAll(s.getStateKey(name))
+    if err != nil {
+        return nil, err
+    }
+    m, _ := v.Val().(map[string]interface{})
+    if len(m) == 0 {
+        return nil, nil
+    }
+    return gcron.NewJobState(name, gconv.Int64(m["last_run"]), gconv.Int64(m["next_run"])), nil
+}
+
+// 抢占定时任务在state.LastRun时刻的执行权，成功时保存运行状态并返回true
+func (s *Store) Claim(state *gcron.JobState) (bool, error) {
+    lastRun, nextRun := state.Timestamps()
+    reply, err       := s.redis.EvalScript(claimScript, []string{s.getStateKey(state.Name)}, lastRun, nextRun)
+    if err != nil {
+        return false, err
+    }
+    return gconv.Int(reply) == 1, nil
+}
+
+// 运行状态的键名
+func (s *Store) getStateKey(name string) string {
+    return s.prefix + "state:" + name
+}
@@ -0,0 +1,12 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// 导出内部变量用于外部测试包
+
+package redisstore
+
+// 抢占运行时刻使用的Lua脚本内容
+var ClaimScript = claimScript.Src()
@@ -0,0 +1,43 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package redisstore_test
+
+import (
+    "fmt"
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/util/gconv"
+    "net"
+    "os"
+    "testing"
+    "time"
+)
+
+// 使用真实的Redis服务端(环境变量GF_REDIS_ADDR，例如127.0.0.1:6379)验证Lua脚本，未设置时跳过测试
+func TestStore_Real(t *testing.T) {
+    addr := os.Getenv("GF_REDIS_ADDR")
+    if addr == "" {
+        t.Skip("GF_REDIS_ADDR is not set, skip tests against real redis server")
+    }
+    host, port, err := net.SplitHostPort(addr)
+    if err != nil {
+        t.Fatal(err)
+    }
+    redis := gredis.New(gredis.Config {
+        Host : host,
+        Port : gconv.Int(port),
+        Pass : os.Getenv("GF_REDIS_PASS"),
+    })
+    defer redis.Close()
+    // 每次测试使用不同的前缀，测试结束后删除所有相关键名
+    prefix := fmt.Sprintf("gcron:test:%d:", time.Now().UnixNano())
+    defer func() {
+        if keys, err := redis.Scan(prefix + "*", 100); err == nil && len(keys) > 0 {
+            redis.Do("DEL", gconv.Interfaces(keys)...)
+        }
+    }()
+    testStore(redis, prefix)
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package redisstore_test
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/database/gredis"
+    "gitee.com/johng/gf/g/database/gredis/gredistest"
+    "gitee.com/johng/gf/g/os/gcron"
+    "gitee.com/johng/gf/g/os/gcron/store/redisstore"
+    "gitee.com/johng/gf/g/util/gconv"
+    "gitee.com/johng/gf/g/util/gtest"
+    "sync"
+    "testing"
+    "time"
+)
+
+// 创建测试使用的Redis服务端，并注册抢占脚本的Go语言实现(测试服务端不支持Lua)
+func newFakeRedis(t *testing.T) (*gredistest.FakeServer, *gredis.Redis) {
+    server, err := gredistest.NewFakeServer()
+    if err != nil {
+        t.Fatal(err)
+    }
+    server.RegisterScript(redisstore.ClaimScript, func(call func(command string, args...string) interface{}, keys []string, args []string) interface{} {
+        if gconv.Int64(call("HGET", keys[0], "last_run")) >= gconv.Int64(args[0]) {
+            return 0
+        }
+        call("HMSET", keys[0], "last_run", args[0], "next_run", args[1])
+        return 1
+    })
+    return server, gredis.New(server.Config())
+}
+
+// 测试抢占及运行状态的读取，prefix为键名前缀
+func testStore(redis *gredis.Redis, prefix string) {
+    store := redisstore.New(redis, prefix)
+    gtest.Case(nil, func() {
+        state, err := store.Get("job")
+        gtest.Assert(err, nil)
+        gtest.Assert(state, nil)
+
+        ok, err := store.Claim(&gcron.JobState {
+            Name    : "job",
+            LastRun : time.Unix(100, 0),
+            NextRun : time.Unix(160, 0),
+        })
+        gtest.Assert(err, nil)
+        gtest.Assert(ok, true)
+
+        state, err = store.Get("job")
+        gtest.Assert(err, nil)
+        gtest.Assert(state.LastRun.Unix(), 100)
+        gtest.Assert(state.NextRun.Unix(), 160)
+
+        // 相同或者更早的运行时刻不能再次抢占
+        for _, last := range []int64{100, 40} {
+            ok, err = store.Claim(&gcron.JobState {
+                Name    : "job",
+                LastRun : time.Unix(last, 0),
+                NextRun : time.Unix(last + 60, 0),
+            })
+            gtest.Assert(err, nil)
+            gtest.Assert(ok, false)
+        }
+        state, err = store.Get("job")
+        gtest.Assert(err, nil)
+        gtest.Assert(state.LastRun.Unix(), 100)
+    })
+    // 并发抢占同一运行时刻时只有一个实例成功
+    gtest.Case(nil, func() {
+        wg   := sync.WaitGroup{}
+        wins := gtype.NewInt()
+        for i := 0; i < 10; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                ok, err := store.Claim(&gcron.JobState {
+                    Name    : "concurrent",
+                    LastRun : time.Unix(200, 0),
+                    NextRun : time.Unix(260, 0),
+                })
+                gtest.Assert(err, nil)
+                if ok {
+                    wins.Add(1)
+                }
+            }()
+        }
+        wg.Wait()
+        gtest.Assert(wins.Val(), 1)
+    })
+}
+
+func TestStore(t *testing.T) {
+    server, redis := newFakeRedis(t)
+    defer server.Close()
+    defer redis.Close()
+    testStore(redis, "gcron:")
+    gtest.Case(t, func() {
+        v, err := redis.DoVar("HGET", "gcron:state:job", "next_run")
+        gtest.Assert(err, nil)
+        gtest.Assert(v.Int64(), 160)
+    })
+}
@@ -8,7 +8,7 @@
 // Package glog implements powerful and easy-to-use levelled logging functionality.
 // 
 // 日志模块,
-// 直接文件/输出操作，没有异步逻辑，没有使用缓存或者通道
+// 默认直接文件/输出操作，可通过SetAsync开启异步缓冲写入
 package glog
 
 import (
@@ -55,6 +55,31 @@ func SetLevel(level int) {
     defaultLevel.Set(level)
 }
 
+// 设置日志文件滚动配置
+func SetRotate(config RotateConfig) {
+    logger.SetRotate(config)
+}
+
+// 开启或关闭默认日志对象的异步日志写入
+func SetAsync(enabled bool, config...AsyncConfig) {
+    logger.SetAsync(enabled, config...)
+}
+
+// 获得默认日志对象异步缓冲区满时丢弃的日志条数
+func Dropped() int64 {
+    return logger.Dropped()
+}
+
+// 添加默认日志对象的日志输出目标
+func AddSink(sink Sink) error {
+    return logger.AddSink(sink)
+}
+
+// 清除默认日志对象的所有日志输出目标
+func ClearSinks() {
+    logger.ClearSinks()
+}
+
 // 可自定义IO接口，IO可以是文件输出、标准输出、网络输出
 func SetWriter(writer io.Writer) {
     logger.SetWriter(writer)
@@ -85,6 +110,16 @@ func GetPath() string {
     return logger.GetPath()
 }
 
+// 设置结构化日志编码器，设置为nil时恢复为原有的文本日志格式
+func SetEncoder(encoder Encoder) {
+    logger.SetEncoder(encoder)
+}
+
+// 设置是否在结构化日志中记录调用方代码位置(默认开启)
+func SetCaller(enabled bool) {
+    logger.SetCaller(enabled)
+}
+
 // 打印文件调用回溯信息
 func PrintBacktrace(skip...int) {
     logger.PrintBacktrace(skip...)
@@ -134,6 +169,17 @@ func StdPrint(enabled bool) *Logger {
 func Header(enabled bool) *Logger {
     return logger.Header(enabled)
 }
+
+// 设置结构化日志编码器
+func Encoding(encoder Encoder) *Logger {
+    return logger.Encoding(encoder)
+}
+
+// 创建绑定指定字段的子Logger，fields为键值对形式的参数
+func With(fields...interface{}) *Logger {
+    return logger.With(fields...)
+}
+
 func Print(v ...interface{}) {
     logger.Print(v ...)
 }
@@ -222,6 +268,31 @@ func Criticalf(format string, v ...interface{}) {
     logger.Criticalf(format, v...)
 }
 
+// 输出结构化日志，message为日志内容，kvs为键值对形式的日志字段，例如：Infow("login", "user", 1, "cost", 10)
+func Infow(message string, kvs...interface{}) {
+    logger.Infow(message, kvs...)
+}
+
+func Debugw(message string, kvs...interface{}) {
+    logger.Debugw(message, kvs...)
+}
+
+func Noticew(message string, kvs...interface{}) {
+    logger.Noticew(message, kvs...)
+}
+
+func Warningw(message string, kvs...interface{}) {
+    logger.Warningw(message, kvs...)
+}
+
+func Errorw(message string, kvs...interface{}) {
+    logger.Errorw(message, kvs...)
+}
+
+func Criticalw(message string, kvs...interface{}) {
+    logger.Criticalw(message, kvs...)
+}
+
 func Infofln(format string, v ...interface{}) {
     logger.Infofln(format, v...)
 }
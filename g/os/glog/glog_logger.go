@@ -34,6 +34,12 @@ type Logger struct {
     btStatus     *gtype.Int          // 是否当打印错误时同时开启backtrace打印(默认-1，表示默认打印逻辑 - 错误才打印)
     printHeader  *gtype.Bool         // 是否不打印前缀信息(时间，级别等)
     alsoStdPrint *gtype.Bool         // 控制台打印开关，当输出到文件/自定义输出时也同时打印到终端
+    encoder      Encoder             // 结构化日志编码器
+    fields       []Field             // 结构化日志绑定的字段(创建后不再修改)
+    caller       *gtype.Bool         // 结构化日志是否记录调用方代码位置
+    rotate       RotateConfig        // 日志文件滚动配置
+    async        *asyncWriter        // 异步日志写入对象，为nil时同步写入
+    sinks        []*sinkHandler      // 额外的日志输出目标
 }
 
 const (
@@ -68,6 +74,7 @@ func New() *Logger {
         btStatus     : gtype.NewInt(-1),
         printHeader  : gtype.NewBool(true),
         alsoStdPrint : gtype.NewBool(true),
+        caller       : gtype.NewBool(true),
     }
 }
 
@@ -80,9 +87,15 @@ func (l *Logger) Clone() *Logger {
         file         : l.file.Clone(),
         level        : l.level.Clone(),
         btSkip       : l.btSkip.Clone(),
-        btStatus    : l.btStatus.Clone(),
+        btStatus     : l.btStatus.Clone(),
         printHeader  : l.printHeader.Clone(),
         alsoStdPrint : l.alsoStdPrint.Clone(),
+        encoder      : l.GetEncoder(),
+        fields       : l.GetFields(),
+        caller       : l.caller.Clone(),
+        rotate       : l.GetRotate(),
+        async        : l.getAsync(),
+        sinks        : l.getSinks(),
     }
 }
 
@@ -134,8 +147,24 @@ func (l *Logger) GetWriter() io.Writer {
     return r
 }
 
-// 获取默认的文件IO
-func (l *Logger) getFilePointer() *gfpool.File {
+// 设置结构化日志编码器，设置后所有日志方法均输出结构化日志，设置为nil时恢复为原有的文本日志格式
+// (绑定了字段的Logger仍然使用文本格式编码器输出结构化日志)
+func (l *Logger) SetEncoder(encoder Encoder) {
+    l.mu.Lock()
+    l.encoder = encoder
+    l.mu.Unlock()
+}
+
+// 返回结构化日志编码器，默认为nil
+func (l *Logger) GetEncoder() Encoder {
+    l.mu.RLock()
+    r := l.encoder
+    l.mu.RUnlock()
+    return r
+}
+
+// 获取当前写入的日志文件路径，未设置日志目录时返回空字符串
+func (l *Logger) getFilePath() string {
     if path := l.path.Val(); path != "" {
         // 文件名称中使用"{}"包含的内容使用gtime格式化
         file, _ := gregex.ReplaceStringFunc(`{.+?}`, l.file.Val(), func(s string) string {
@@ -145,17 +174,37 @@ func (l *Logger) getFilePointer() *gfpool.File {
         if !gfile.Exists(path) {
             if err := gfile.Mkdir(path); err != nil {
                 fmt.Fprintln(os.Stderr, fmt.Sprintf(`[glog] mkdir "%s" failed: %s`, path, err.Error()))
-                return nil
+                return ""
             }
         }
-        fpath   := path + gfile.Separator + file
-        if fp, err := gfpool.Open(fpath, gDEFAULT_FILE_POOL_FLAGS, gDEFAULT_FPOOL_PERM, gDEFAULT_FPOOL_EXPIRE); err == nil {
-            return fp
-        } else {
-            fmt.Fprintln(os.Stderr, err)
+        return path + gfile.Separator + file
+    }
+    return ""
+}
+
+// 获取默认的文件IO
+func (l *Logger) getFilePointer(fpath string) io.WriteCloser {
+    fp, err := gfpool.Open(fpath, gDEFAULT_FILE_POOL_FLAGS, gDEFAULT_FPOOL_PERM, gDEFAULT_FPOOL_EXPIRE)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return nil
+    }
+    // 开启滚动时，文件可能已被其他进程滚动(重命名)，而文件指针池中的指针仍然指向滚动后的文件，
+    // 此时直接打开新的日志文件写入，文件指针池将会在接收到文件重命名事件后重建。
+    if config := l.GetRotate(); config.Size > 0 || config.Interval > 0 {
+        fpStat, err1 := fp.Stat()
+        stat,   err2 := os.Stat(fpath)
+        if err1 == nil && err2 == nil && !os.SameFile(fpStat, stat) {
+            fp.Close()
+            if f, err := os.OpenFile(fpath, gDEFAULT_FILE_POOL_FLAGS, gDEFAULT_FPOOL_PERM); err == nil {
+                return f
+            } else {
+                fmt.Fprintln(os.Stderr, err)
+                return nil
+            }
         }
     }
-    return nil
+    return fp
 }
 
 // 设置日志文件的存储目录路径
@@ -186,25 +235,41 @@ func (l *Logger) SetStdPrint(enabled bool) {
     l.alsoStdPrint.Set(enabled)
 }
 
-// 这里的写锁保证统一时刻只会写入一行日志，防止串日志的情况
+// 输出日志内容，开启日志头信息时添加日志头信息
 func (l *Logger) print(std io.Writer, s string) {
-    // 优先使用自定义的IO输出
     if l.printHeader.Val() {
         s = l.format(s)
     }
+    l.write(std, s)
+}
+
+// 写入日志内容，开启异步写入时写入到异步缓冲区
+func (l *Logger) write(std io.Writer, s string) {
+    if w := l.getAsync(); w != nil && w.push(asyncEntry{logger : l, std : std, content : s}) {
+        return
+    }
+    l.syncWrite(std, s)
+}
+
+// 这里的写锁保证统一时刻只会写入一行日志，防止串日志的情况
+func (l *Logger) syncWrite(std io.Writer, s string) {
+    // 优先使用自定义的IO输出
     writer := l.GetWriter()
     if writer == nil {
         // 如果设置的writer为空，那么其次判断是否有文件输出设置
         // 内部使用了内存锁，保证在glog中对同一个日志文件的并发写入不会串日志(并发安全)
-        if f := l.getFilePointer(); f != nil {
-            defer f.Close()
+        if fpath := l.getFilePath(); fpath != "" {
             key := l.path.Val()
             gmlock.Lock(key)
-            _, err := io.WriteString(f, s)
-            gmlock.Unlock(key)
-            if err != nil {
-                fmt.Fprintln(os.Stderr, err.Error())
+            l.checkCleanup(fpath)
+            l.rotateFile(fpath, len(s))
+            if f := l.getFilePointer(fpath); f != nil {
+                if _, err := io.WriteString(f, s); err != nil {
+                    fmt.Fprintln(os.Stderr, err.Error())
+                }
+                f.Close()
             }
+            gmlock.Unlock(key)
         }
         // 当没有设置writer时，需要判断是否允许输出到标准输出
         if l.alsoStdPrint.Val() {
@@ -302,159 +367,162 @@ func (l *Logger) format(s string) string {
 }
 
 func (l *Logger) Print(v ...interface{}) {
-    l.stdPrint(fmt.Sprintln(v...))
+    l.printv(0, v)
 }
 
 func (l *Logger) Printf(format string, v ...interface{}) {
-    l.stdPrint(fmt.Sprintf(format, v...))
+    l.printf(0, format, v, "")
 }
 
 func (l *Logger) Println(v ...interface{}) {
-    l.stdPrint(fmt.Sprintln(v...))
+    l.printv(0, v)
 }
 
 func (l *Logger) Printfln(format string, v ...interface{}) {
-    l.stdPrint(fmt.Sprintf(format + ln, v...))
+    l.printf(0, format, v, ln)
 }
 
 func (l *Logger) Fatal(v ...interface{}) {
-    l.errPrint("[FATA] " + fmt.Sprintln(v...))
+    l.printv(gLEVEL_FATA, v)
+    l.Flush()
     os.Exit(1)
 }
 
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-    l.errPrint("[FATA] " + fmt.Sprintf(format, v...))
+    l.printf(gLEVEL_FATA, format, v, "")
+    l.Flush()
     os.Exit(1)
 }
 
 func (l *Logger) Fatalfln(format string, v ...interface{}) {
-    l.errPrint("[FATA] " + fmt.Sprintf(format + ln, v...))
+    l.printf(gLEVEL_FATA, format, v, ln)
+    l.Flush()
     os.Exit(1)
 }
 
 func (l *Logger) Panic(v ...interface{}) {
-    s := fmt.Sprintln(v...)
-    l.errPrint("[PANI] " + s)
+    s := l.printv(gLEVEL_PANI, v)
+    l.Flush()
     panic(s)
 }
 
 func (l *Logger) Panicf(format string, v ...interface{}) {
-    s := fmt.Sprintf(format, v...)
-    l.errPrint("[PANI] " + s)
+    s := l.printf(gLEVEL_PANI, format, v, "")
+    l.Flush()
     panic(s)
 }
 
 func (l *Logger) Panicfln(format string, v ...interface{}) {
-    s := fmt.Sprintf(format + ln, v...)
-    l.errPrint("[PANI] " + s)
+    s := l.printf(gLEVEL_PANI, format, v, ln)
+    l.Flush()
     panic(s)
 }
 
 func (l *Logger) Info(v ...interface{}) {
     if l.checkLevel(LEVEL_INFO) {
-        l.stdPrint("[INFO] " + fmt.Sprintln(v...))
+        l.printv(LEVEL_INFO, v)
     }
 }
 
 func (l *Logger) Infof(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_INFO) {
-        l.stdPrint("[INFO] " + fmt.Sprintf(format, v...))
+        l.printf(LEVEL_INFO, format, v, "")
     }
 }
 
 func (l *Logger) Infofln(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_INFO) {
-        l.stdPrint("[INFO] " + fmt.Sprintf(format, v...) + ln)
+        l.printf(LEVEL_INFO, format, v, ln)
     }
 }
 
 func (l *Logger) Debug(v ...interface{}) {
     if l.checkLevel(LEVEL_DEBU) {
-        l.stdPrint("[DEBU] " + fmt.Sprintln(v...))
+        l.printv(LEVEL_DEBU, v)
     }
 }
 
 func (l *Logger) Debugf(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_DEBU) {
-        l.stdPrint("[DEBU] " + fmt.Sprintf(format, v...))
+        l.printf(LEVEL_DEBU, format, v, "")
     }
 }
 
 func (l *Logger) Debugfln(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_DEBU) {
-        l.stdPrint("[DEBU] " + fmt.Sprintf(format, v...) + ln)
+        l.printf(LEVEL_DEBU, format, v, ln)
     }
 }
 
 func (l *Logger) Notice(v ...interface{}) {
     if l.checkLevel(LEVEL_NOTI) {
-        l.errPrint("[NOTI] " + fmt.Sprintln(v...))
+        l.printv(LEVEL_NOTI, v)
     }
 }
 
 func (l *Logger) Noticef(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_NOTI) {
-        l.errPrint("[NOTI] " + fmt.Sprintf(format, v...))
+        l.printf(LEVEL_NOTI, format, v, "")
     }
 }
 
 func (l *Logger) Noticefln(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_NOTI) {
-        l.errPrint("[NOTI] " + fmt.Sprintf(format, v...) + ln)
+        l.printf(LEVEL_NOTI, format, v, ln)
     }
 }
 
 func (l *Logger) Warning(v ...interface{}) {
     if l.checkLevel(LEVEL_WARN) {
-        l.errPrint("[WARN] " + fmt.Sprintln(v...))
+        l.printv(LEVEL_WARN, v)
     }
 }
 
 func (l *Logger) Warningf(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_WARN) {
-        l.errPrint("[WARN] " + fmt.Sprintf(format, v...))
+        l.printf(LEVEL_WARN, format, v, "")
     }
 }
 
 func (l *Logger) Warningfln(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_WARN) {
-        l.errPrint("[WARN] " + fmt.Sprintf(format, v...) + ln)
+        l.printf(LEVEL_WARN, format, v, ln)
     }
 }
 
 func (l *Logger) Error(v ...interface{}) {
     if l.checkLevel(LEVEL_ERRO) {
-        l.errPrint("[ERRO] " + fmt.Sprintln(v...))
+        l.printv(LEVEL_ERRO, v)
     }
 }
 
 func (l *Logger) Errorf(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_ERRO) {
-        l.errPrint("[ERRO] " + fmt.Sprintf(format, v...))
+        l.printf(LEVEL_ERRO, format, v, "")
     }
 }
 
 func (l *Logger) Errorfln(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_ERRO) {
-        l.errPrint("[ERRO] " + fmt.Sprintf(format, v...) + ln)
+        l.printf(LEVEL_ERRO, format, v, ln)
     }
 }
 
 func (l *Logger) Critical(v ...interface{}) {
     if l.checkLevel(LEVEL_CRIT) {
-        l.errPrint("[CRIT] " + fmt.Sprintln(v...))
+        l.printv(LEVEL_CRIT, v)
     }
 }
 
 func (l *Logger) Criticalf(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_CRIT) {
-        l.errPrint("[CRIT] " + fmt.Sprintf(format, v...))
+        l.printf(LEVEL_CRIT, format, v, "")
     }
 }
 
 func (l *Logger) Criticalfln(format string, v ...interface{}) {
     if l.checkLevel(LEVEL_CRIT) {
-        l.errPrint("[CRIT] " + fmt.Sprintf(format, v...) + ln)
+        l.printf(LEVEL_CRIT, format, v, ln)
     }
 }
 
@@ -0,0 +1,277 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog
+
+import (
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/container/gtype"
+    "io"
+    "os"
+    "os/signal"
+    "sync"
+    "syscall"
+    "time"
+)
+
+const (
+    OVERFLOW_BLOCK       = iota // 缓冲区满时阻塞等待写入(默认)
+    OVERFLOW_DROP_OLDEST        // 缓冲区满时丢弃最早的日志
+    OVERFLOW_DROP_NEW           // 缓冲区满时丢弃新写入的日志
+)
+
+const (
+    gDEFAULT_ASYNC_BUFFER_SIZE    = 10000       // 默认的异步日志缓冲区大小(日志条数)
+    gDEFAULT_ASYNC_FLUSH_INTERVAL = time.Second // 默认的异步日志定时刷新间隔
+)
+
+// 异步日志配置
+type AsyncConfig struct {
+    BufferSize    int           // 缓冲区大小(日志条数)，缓冲区使用超过一半时立即刷新
+    Overflow      int           // 缓冲区满时的处理策略：OVERFLOW_BLOCK/OVERFLOW_DROP_OLDEST/OVERFLOW_DROP_NEW
+    FlushInterval time.Duration // 定时刷新间隔
+}
+
+// 异步日志写入对象，日志内容写入环形缓冲区后由后台协程定时批量写入
+type asyncWriter struct {
+    config  AsyncConfig
+    mu      sync.Mutex
+    cond    *sync.Cond     // 缓冲区满时阻塞写入的条件变量
+    buffer  []asyncEntry   // 环形缓冲区
+    head    int            // 缓冲区中最早日志的位置
+    size    int            // 缓冲区中的日志条数
+    writeMu sync.Mutex     // 保证批量写入的顺序
+    dropped *gtype.Int64   // 缓冲区满时丢弃的日志条数
+    notify  chan struct{}  // 立即刷新通知
+    closed  chan struct{}  // 关闭通知
+    done    chan struct{}  // 后台协程退出通知
+    once    sync.Once
+}
+
+// 缓冲区中的日志
+type asyncEntry struct {
+    logger  *Logger
+    std     io.Writer
+    sink    *sinkHandler // 不为nil时表示写入到该输出目标
+    content string
+}
+
+var (
+    // 所有开启的异步日志写入对象，用于全局刷新
+    asyncWriters  = gmap.NewInterfaceInterfaceMap()
+    // 是否已关闭异步写入(接收到FlushOnSignal监听的信号后所有日志改为同步写入)
+    asyncDisabled = gtype.NewBool()
+    // 保证进程信号只监听一次
+    signalOnce    sync.Once
+)
+
+// 开启或关闭异步日志写入，config为可选的异步配置。Logger的链式操作对象与原Logger共享同一个异步缓冲区。
+// 关闭或者重新设置时将会先刷新原有缓冲区中的日志。
+// 进程退出前需要刷新异步日志，否则缓冲区中的日志将会丢失：ghttp服务关闭时将会自动调用Flush，
+// 其他进程(例如定时任务、消息队列消费进程)可以通过FlushOnSignal在接收到终止信号时自动刷新，或者在退出前自行调用Flush。
+func (l *Logger) SetAsync(enabled bool, config...AsyncConfig) {
+    var writer *asyncWriter
+    if enabled {
+        c := AsyncConfig{}
+        if len(config) > 0 {
+            c = config[0]
+        }
+        writer = newAsyncWriter(c)
+    }
+    l.mu.Lock()
+    old    := l.async
+    l.async = writer
+    l.mu.Unlock()
+    if old != nil {
+        old.close()
+    }
+}
+
+// 获取异步日志写入对象，未开启时返回nil
+func (l *Logger) getAsync() *asyncWriter {
+    l.mu.RLock()
+    r := l.async
+    l.mu.RUnlock()
+    return r
+}
+
+// 将异步缓冲区中的日志立即写入
+func (l *Logger) Flush() {
+    if w := l.getAsync(); w != nil {
+        w.flush()
+    }
+}
+
+// 获得异步缓冲区满时丢弃的日志条数
+func (l *Logger) Dropped() int64 {
+    if w := l.getAsync(); w != nil {
+        return w.dropped.Val()
+    }
+    return 0
+}
+
+// 刷新所有开启的异步日志缓冲区，进程退出前应当调用该方法(ghttp服务关闭时将会自动调用)
+func Flush() {
+    for _, w := range asyncWriters.Keys() {
+        w.(*asyncWriter).flush()
+    }
+}
+
+// 监听进程终止信号(默认为SIGINT/SIGTERM)，接收到信号后刷新所有的异步日志并改为同步写入，
+// 随后停止监听并重新发送该信号，使进程按照原有的方式处理该信号(默认为退出进程)，只有第一次调用有效。
+// glog默认不监听任何信号，自行处理终止信号的进程不需要调用该方法，在退出前调用Flush即可。
+func FlushOnSignal(signals...os.Signal) {
+    if len(signals) == 0 {
+        signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+    }
+    signalOnce.Do(func() {
+        ch := make(chan os.Signal, 1)
+        signal.Notify(ch, signals...)
+        go func() {
+            sig := <- ch
+            asyncDisabled.Set(true)
+            Flush()
+            signal.Stop(ch)
+            if p, err := os.FindProcess(os.Getpid()); err == nil {
+                if err := p.Signal(sig); err == nil {
+                    return
+                }
+            }
+            os.Exit(1)
+        }()
+    })
+}
+
+// 创建异步日志写入对象，并开始后台定时刷新
+func newAsyncWriter(config AsyncConfig) *asyncWriter {
+    if config.BufferSize <= 0 {
+        config.BufferSize = gDEFAULT_ASYNC_BUFFER_SIZE
+    }
+    if config.FlushInterval <= 0 {
+        config.FlushInterval = gDEFAULT_ASYNC_FLUSH_INTERVAL
+    }
+    w := &asyncWriter {
+        config  : config,
+        buffer  : make([]asyncEntry, config.BufferSize),
+        dropped : gtype.NewInt64(),
+        notify  : make(chan struct{}, 1),
+        closed  : make(chan struct{}),
+        done    : make(chan struct{}),
+    }
+    w.cond = sync.NewCond(&w.mu)
+    asyncWriters.Set(w, true)
+    go w.loop()
+    return w
+}
+
+// 写入日志到缓冲区，异步写入已关闭时返回false
+func (w *asyncWriter) push(entry asyncEntry) bool {
+    if asyncDisabled.Val() {
+        return false
+    }
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    for w.size == len(w.buffer) {
+        select {
+            case <- w.closed:
+                return false
+            default:
+        }
+        switch w.config.Overflow {
+            case OVERFLOW_DROP_NEW:
+                w.dropped.Add(1)
+                return true
+
+            case OVERFLOW_DROP_OLDEST:
+                w.buffer[w.head] = asyncEntry{}
+                w.head = (w.head + 1) % len(w.buffer)
+                w.size--
+                w.dropped.Add(1)
+
+            default:
+                w.signal()
+                w.cond.Wait()
+        }
+    }
+    w.buffer[(w.head + w.size) % len(w.buffer)] = entry
+    w.size++
+    if w.size >= (len(w.buffer) + 1)/2 {
+        w.signal()
+    }
+    return true
+}
+
+// 通知后台协程立即刷新
+func (w *asyncWriter) signal() {
+    select {
+        case w.notify <- struct{}{}:
+        default:
+    }
+}
+
+// 后台定时刷新
+func (w *asyncWriter) loop() {
+    defer close(w.done)
+    ticker := time.NewTicker(w.config.FlushInterval)
+    defer ticker.Stop()
+    for {
+        select {
+            case <- w.closed:
+                w.flush()
+                return
+            case <- w.notify:
+                w.flush()
+            case <- ticker.C:
+                w.flush()
+        }
+    }
+}
+
+// 将缓冲区中的日志批量写入，同一Logger连续写入的日志将会合并为一次写入
+func (w *asyncWriter) flush() {
+    w.writeMu.Lock()
+    defer w.writeMu.Unlock()
+    w.mu.Lock()
+    entries := make([]asyncEntry, w.size)
+    for i := 0; i < w.size; i++ {
+        index     := (w.head + i) % len(w.buffer)
+        entries[i] = w.buffer[index]
+        w.buffer[index] = asyncEntry{}
+    }
+    w.head = 0
+    w.size = 0
+    w.cond.Broadcast()
+    w.mu.Unlock()
+    for i := 0; i < len(entries); {
+        j       := i + 1
+        content := entries[i].content
+        for ; j < len(entries) && entries[j].logger == entries[i].logger && entries[j].std == entries[i].std && entries[j].sink == entries[i].sink; j++ {
+            // 自定义Writer的输出目标每一条日志调用一次Write(例如网络输出的消息边界)
+            if entries[i].sink != nil && entries[i].sink.sink.Writer != nil {
+                break
+            }
+            content += entries[j].content
+        }
+        if entries[i].sink != nil {
+            entries[i].sink.write(content)
+        } else {
+            entries[i].logger.syncWrite(entries[i].std, content)
+        }
+        i = j
+    }
+}
+
+// 关闭异步写入，刷新缓冲区中的日志后退出后台协程
+func (w *asyncWriter) close() {
+    w.once.Do(func() {
+        asyncWriters.Remove(w)
+        close(w.closed)
+        w.mu.Lock()
+        w.cond.Broadcast()
+        w.mu.Unlock()
+        <- w.done
+    })
+}
@@ -0,0 +1,191 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// 结构化日志编码器，将日志记录编码为一行日志内容(需要包含换行符)
+type Encoder interface {
+    Encode(record *Record) []byte
+}
+
+// 文本格式编码器，格式为：2006-01-02 15:04:05.000 [INFO] message key=value caller=dir/file.go:10
+type TextEncoder struct {}
+
+// logfmt格式编码器，格式为：time=2006-01-02T15:04:05.000+08:00 level=info msg=message caller=dir/file.go:10 key=value
+type LogfmtEncoder struct {}
+
+// JSON Lines格式编码器，每一条日志记录编码为一行JSON对象，
+// 固定字段为time/level/msg/caller/backtrace，自定义字段与固定字段同名时将会被覆盖。
+type JsonEncoder struct {}
+
+var (
+    // 未设置Encoder时结构化日志使用的编码器
+    defaultTextEncoder = NewTextEncoder()
+)
+
+// 创建文本格式编码器
+func NewTextEncoder() Encoder {
+    return &TextEncoder{}
+}
+
+// 创建logfmt格式编码器
+func NewLogfmtEncoder() Encoder {
+    return &LogfmtEncoder{}
+}
+
+// 创建JSON Lines格式编码器
+func NewJsonEncoder() Encoder {
+    return &JsonEncoder{}
+}
+
+// 文本格式编码
+func (e *TextEncoder) Encode(record *Record) []byte {
+    buffer := bytes.NewBuffer(nil)
+    if !record.Time.IsZero() {
+        buffer.WriteString(record.Time.Format("2006-01-02 15:04:05.000 "))
+    }
+    if prefix := record.LevelPrefix(); prefix != "" {
+        buffer.WriteString("[" + prefix + "] ")
+    }
+    buffer.WriteString(record.Message)
+    for _, field := range record.Fields {
+        writeLogfmtField(buffer, field.Key, field.Value)
+    }
+    if record.Caller != "" {
+        writeLogfmtField(buffer, "caller", record.Caller)
+    }
+    buffer.WriteString(ln)
+    if record.Backtrace != "" {
+        buffer.WriteString("Backtrace:" + ln + record.Backtrace)
+    }
+    return buffer.Bytes()
+}
+
+// logfmt格式编码
+func (e *LogfmtEncoder) Encode(record *Record) []byte {
+    buffer := bytes.NewBuffer(nil)
+    if !record.Time.IsZero() {
+        writeLogfmtField(buffer, "time", record.Time.Format("2006-01-02T15:04:05.000Z07:00"))
+    }
+    if name := record.LevelName(); name != "" {
+        writeLogfmtField(buffer, "level", name)
+    }
+    writeLogfmtField(buffer, "msg", record.Message)
+    if record.Caller != "" {
+        writeLogfmtField(buffer, "caller", record.Caller)
+    }
+    for _, field := range record.Fields {
+        writeLogfmtField(buffer, field.Key, field.Value)
+    }
+    if record.Backtrace != "" {
+        writeLogfmtField(buffer, "backtrace", record.Backtrace)
+    }
+    // 去掉第一个字段前的空格
+    b := buffer.Bytes()[1:]
+    return append(b, ln...)
+}
+
+// JSON Lines格式编码
+func (e *JsonEncoder) Encode(record *Record) []byte {
+    buffer := bytes.NewBuffer(nil)
+    buffer.WriteByte('{')
+    keys := make(map[string]bool)
+    write := func(key string, value interface{}) {
+        if keys[key] {
+            return
+        }
+        keys[key] = true
+        if len(keys) > 1 {
+            buffer.WriteByte(',')
+        }
+        buffer.Write(marshalJson(key))
+        buffer.WriteByte(':')
+        buffer.Write(marshalJson(value))
+    }
+    if !record.Time.IsZero() {
+        write("time", record.Time.Format("2006-01-02T15:04:05.000Z07:00"))
+    }
+    if name := record.LevelName(); name != "" {
+        write("level", name)
+    }
+    write("msg", record.Message)
+    if record.Caller != "" {
+        write("caller", record.Caller)
+    }
+    if record.Backtrace != "" {
+        write("backtrace", record.Backtrace)
+    }
+    for _, field := range record.Fields {
+        write(field.Key, field.Value)
+    }
+    buffer.WriteByte('}')
+    buffer.WriteString(ln)
+    return buffer.Bytes()
+}
+
+// 将日志字段值转换为JSON，error/fmt.Stringer类型转换为字符串，无法转换为JSON的值使用fmt.Sprint转换为字符串
+func marshalJson(value interface{}) []byte {
+    switch v := value.(type) {
+        case error:
+            value = v.Error()
+        case time.Duration:
+            value = v.String()
+        case fmt.Stringer:
+            value = v.String()
+    }
+    if b, err := json.Marshal(value); err == nil {
+        return b
+    }
+    b, _ := json.Marshal(fmt.Sprint(value))
+    return b
+}
+
+// 写入logfmt格式的字段(字段前带空格)
+func writeLogfmtField(buffer *bytes.Buffer, key string, value interface{}) {
+    buffer.WriteByte(' ')
+    buffer.WriteString(quoteLogfmt(key))
+    buffer.WriteByte('=')
+    buffer.WriteString(quoteLogfmt(formatValue(value)))
+}
+
+// 将日志字段值转换为字符串
+func formatValue(value interface{}) string {
+    switch v := value.(type) {
+        case nil:
+            return "nil"
+        case string:
+            return v
+        case []byte:
+            return string(v)
+        case error:
+            return v.Error()
+        case fmt.Stringer:
+            return v.String()
+    }
+    return fmt.Sprint(value)
+}
+
+// 包含空格、等号、引号或者控制字符的字符串使用双引号转义，空字符串转换为""
+func quoteLogfmt(s string) string {
+    if s == "" {
+        return `""`
+    }
+    if strings.IndexFunc(s, func(r rune) bool {
+        return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+    }) == -1 {
+        return s
+    }
+    return strconv.Quote(s)
+}
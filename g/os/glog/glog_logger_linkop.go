@@ -100,4 +100,16 @@ func (l *Logger) Header(enabled bool) *Logger {
     }
     logger.printHeader.Set(enabled)
     return logger
+}
+
+// 设置结构化日志编码器
+func (l *Logger) Encoding(encoder Encoder) *Logger {
+    logger := (*Logger)(nil)
+    if l.pr == nil {
+        logger = l.Clone()
+    } else {
+        logger = l
+    }
+    logger.SetEncoder(encoder)
+    return logger
 }
\ No newline at end of file
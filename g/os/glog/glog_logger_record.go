@@ -0,0 +1,245 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    // Fatal/Panic方法的日志级别，不受日志等级设置的影响
+    gLEVEL_FATA = LEVEL_CRIT << (iota + 1)
+    gLEVEL_PANI
+)
+
+const (
+    gMISSING_VALUE    = "(MISSING)" // 键值对参数个数为奇数时，最后一个键名对应的键值
+    gMAX_CALLER_DEPTH = 20          // 查找调用方代码位置时的最大调用层级
+)
+
+var (
+    // 日志级别名称(用于文本格式的日志前缀)
+    levelPrefixes = map[int]string {
+        LEVEL_DEBU  : "DEBU",
+        LEVEL_INFO  : "INFO",
+        LEVEL_NOTI  : "NOTI",
+        LEVEL_WARN  : "WARN",
+        LEVEL_ERRO  : "ERRO",
+        LEVEL_CRIT  : "CRIT",
+        gLEVEL_FATA : "FATA",
+        gLEVEL_PANI : "PANI",
+    }
+    // 日志级别名称(用于logfmt/JSON格式的level字段)
+    levelNames = map[int]string {
+        LEVEL_DEBU  : "debug",
+        LEVEL_INFO  : "info",
+        LEVEL_NOTI  : "notice",
+        LEVEL_WARN  : "warning",
+        LEVEL_ERRO  : "error",
+        LEVEL_CRIT  : "critical",
+        gLEVEL_FATA : "fatal",
+        gLEVEL_PANI : "panic",
+    }
+)
+
+// 结构化日志字段
+type Field struct {
+    Key   string
+    Value interface{}
+}
+
+// 结构化日志记录，由Encoder编码为写入的日志内容
+type Record struct {
+    Time      time.Time // 日志时间，关闭日志头信息时为零值
+    Level     int       // 日志级别，Print*方法输出的日志为0
+    Message   string    // 日志内容
+    Fields    []Field   // 日志字段(Logger绑定的字段在前)
+    Caller    string    // 调用方代码位置(file:line)，关闭时为空
+    Backtrace string    // 调用回溯信息，只有错误级别的日志才会记录
+}
+
+// 获得日志级别名称(debug/info/notice/warning/error/critical/fatal/panic)，Print*方法输出的日志返回空字符串
+func (r *Record) LevelName() string {
+    return levelNames[r.Level]
+}
+
+// 获得日志级别前缀名称(DEBU/INFO/NOTI/WARN/ERRO/CRIT/FATA/PANI)，Print*方法输出的日志返回空字符串
+func (r *Record) LevelPrefix() string {
+    return levelPrefixes[r.Level]
+}
+
+// 创建绑定指定字段的子Logger，fields为键值对形式的参数，例如：With("user", 1, "ip", "127.0.0.1")。
+// 子Logger将会输出结构化日志(未设置Encoder时使用文本格式)，Print/Info等日志方法的参数仍然按照位置拼接为日志内容，
+// 需要附加键值对形式的日志字段时使用Infow等*w方法，例如：Infow("login", "cost", 10)。
+// 子Logger是一个独立的Logger对象，对其的链式操作不会影响原有的Logger，对原有Logger的链式操作也不会影响子Logger。
+func (l *Logger) With(fields...interface{}) *Logger {
+    logger       := l.Clone()
+    logger.pr     = nil
+    logger.fields = append(logger.fields, parseFields(fields)...)
+    return logger
+}
+
+// 获得Logger绑定的日志字段
+func (l *Logger) GetFields() []Field {
+    return append([]Field(nil), l.fields...)
+}
+
+// 设置是否在结构化日志中记录调用方代码位置(默认开启)
+func (l *Logger) SetCaller(enabled bool) {
+    l.caller.Set(enabled)
+}
+
+// 是否输出结构化日志，设置了Encoder或者绑定了日志字段时输出结构化日志
+func (l *Logger) isStructured() bool {
+    return l.GetEncoder() != nil || len(l.fields) > 0
+}
+
+// 将键值对形式的参数转换为日志字段，非字符串类型的键名使用fmt.Sprint转换
+func parseFields(kvs []interface{}) []Field {
+    fields := make([]Field, 0, (len(kvs) + 1)/2)
+    for i := 0; i < len(kvs); i += 2 {
+        key := ""
+        if s, ok := kvs[i].(string); ok {
+            key = s
+        } else {
+            key = fmt.Sprint(kvs[i])
+        }
+        if i + 1 < len(kvs) {
+            fields = append(fields, Field{Key : key, Value : kvs[i + 1]})
+        } else {
+            fields = append(fields, Field{Key : key, Value : gMISSING_VALUE})
+        }
+    }
+    return fields
+}
+
+// 输出非格式化的日志内容，所有参数按照位置拼接为日志内容(结构化日志同样如此)，返回日志内容
+func (l *Logger) printv(level int, v []interface{}) string {
+    if l.isStructured() {
+        message := strings.TrimRight(fmt.Sprintln(v...), "\n")
+        l.printRecord(level, message, nil)
+        return message
+    }
+    s := fmt.Sprintln(v...)
+    l.printText(level, s)
+    return s
+}
+
+// 输出带有键值对字段的结构化日志(未设置Encoder时使用文本格式)
+func (l *Logger) printw(level int, message string, kvs []interface{}) {
+    if l.checkLevel(level) {
+        l.printRecord(level, message, parseFields(kvs))
+    }
+}
+
+func (l *Logger) Debugw(message string, kvs...interface{}) {
+    l.printw(LEVEL_DEBU, message, kvs)
+}
+
+func (l *Logger) Infow(message string, kvs...interface{}) {
+    l.printw(LEVEL_INFO, message, kvs)
+}
+
+func (l *Logger) Noticew(message string, kvs...interface{}) {
+    l.printw(LEVEL_NOTI, message, kvs)
+}
+
+func (l *Logger) Warningw(message string, kvs...interface{}) {
+    l.printw(LEVEL_WARN, message, kvs)
+}
+
+func (l *Logger) Errorw(message string, kvs...interface{}) {
+    l.printw(LEVEL_ERRO, message, kvs)
+}
+
+func (l *Logger) Criticalw(message string, kvs...interface{}) {
+    l.printw(LEVEL_CRIT, message, kvs)
+}
+
+// 输出格式化的日志内容，suffix为文本格式下日志内容的后缀(结构化日志忽略)，返回日志内容
+func (l *Logger) printf(level int, format string, v []interface{}, suffix string) string {
+    s := fmt.Sprintf(format, v...)
+    if l.isStructured() {
+        l.printRecord(level, s, nil)
+        return s
+    }
+    s += suffix
+    l.printText(level, s)
+    return s
+}
+
+// 输出文本格式的日志内容(原有的日志格式)
+func (l *Logger) printText(level int, s string) {
+    if level == 0 {
+        l.stdPrint(s)
+    } else if level <= LEVEL_INFO {
+        l.stdPrint("[" + levelPrefixes[level] + "] " + s)
+    } else {
+        l.errPrint("[" + levelPrefixes[level] + "] " + s)
+    }
+    if sinks := l.getSinks(); len(sinks) > 0 {
+        l.writeSinks(sinks, l.newRecord(level, strings.TrimRight(s, "\r\n"), nil))
+    }
+}
+
+// 输出结构化日志
+func (l *Logger) printRecord(level int, message string, fields []Field) {
+    record  := l.newRecord(level, message, fields)
+    encoder := l.GetEncoder()
+    if encoder == nil {
+        encoder = defaultTextEncoder
+    }
+    l.write(os.Stdout, string(encoder.Encode(record)))
+    if sinks := l.getSinks(); len(sinks) > 0 {
+        l.writeSinks(sinks, record)
+    }
+}
+
+// 创建日志记录，并添加Logger绑定的字段、调用方代码位置及错误级别日志的调用回溯信息
+func (l *Logger) newRecord(level int, message string, fields []Field) *Record {
+    record := &Record {
+        Level   : level,
+        Message : message,
+        Fields  : fields,
+    }
+    if l.printHeader.Val() {
+        record.Time = time.Now()
+    }
+    if len(l.fields) > 0 {
+        record.Fields = append(append(make([]Field, 0, len(l.fields) + len(fields)), l.fields...), fields...)
+    }
+    if l.caller.Val() {
+        record.Caller = getCaller()
+    }
+    if level > LEVEL_INFO {
+        if status := l.btStatus.Val(); status == -1 || status == 1 {
+            record.Backtrace = l.GetBacktrace()
+        }
+    }
+    return record
+}
+
+// 获得glog包外的调用方代码位置，格式为：所在目录名/文件名:行号
+func getCaller() string {
+    for i := 2; i < gMAX_CALLER_DEPTH; i++ {
+        _, file, line, ok := runtime.Caller(i)
+        if !ok {
+            break
+        }
+        if strings.Contains(file, "/g/os/glog/") && !strings.HasSuffix(file, "_test.go") {
+            continue
+        }
+        return filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file) + ":" + strconv.Itoa(line)
+    }
+    return ""
+}
@@ -0,0 +1,268 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog
+
+import (
+    "fmt"
+    "gitee.com/johng/gf/g/container/gmap"
+    "gitee.com/johng/gf/g/encoding/gcompress"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/gflock"
+    "gitee.com/johng/gf/g/util/gregex"
+    "hash/crc32"
+    "io/ioutil"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+const (
+    gROTATE_TIME_FORMAT  = "20060102150405.000000" // 滚动备份文件名称中的时间格式(去掉小数点后为20位数字)
+    gROTATE_COMPRESS_EXT = ".gz"                   // 压缩后的备份文件扩展名
+)
+
+// 日志文件滚动配置，Size与Interval均为0时不滚动，MaxBackups与MaxAge均为0时不清理日志文件。
+// 滚动后的备份文件名称为：原文件名称.时间.扩展名，例如：2019-01-02.20190102150405123456.log，
+// 清理的日志文件包括滚动备份文件以及按照文件名称格式(例如{Y-m-d}.log)生成的历史日志文件，
+// 目录下的其他文件(例如其他Logger的日志文件)不会被清理。
+type RotateConfig struct {
+    Size       int64         // 日志文件的最大大小(字节)，写入后超过该大小时滚动
+    Interval   time.Duration // 日志文件的滚动间隔，按照本地时间对齐(例如time.Hour表示每个整点滚动)
+    MaxBackups int           // 保留的历史日志文件最大数量
+    MaxAge     time.Duration // 历史日志文件的最长保留时间(按照文件修改时间计算)
+    Compress   bool          // 是否使用gzip压缩滚动后的备份文件
+}
+
+var (
+    // gtime时间格式字符对应的正则表达式，用于匹配按照文件名称格式生成的历史日志文件
+    timeFormatPatterns = map[byte]string {
+        'd' : `\d{2}`,
+        'D' : `[A-Za-z]{3}`,
+        'j' : `\d{1,2}`,
+        'l' : `[A-Za-z]+`,
+        'F' : `[A-Za-z]+`,
+        'm' : `\d{2}`,
+        'M' : `[A-Za-z]{3}`,
+        'n' : `\d{1,2}`,
+        'Y' : `\d{4}`,
+        'y' : `\d{2}`,
+        'a' : `(am|pm)`,
+        'A' : `(AM|PM)`,
+        'g' : `\d{1,2}`,
+        'G' : `\d{1,2}`,
+        'h' : `\d{2}`,
+        'H' : `\d{2}`,
+        'i' : `\d{2}`,
+        's' : `\d{2}`,
+        'u' : `\d{3}`,
+        'O' : `[+-]\d{4}`,
+        'P' : `[+-]\d{2}:\d{2}`,
+        'T' : `[A-Za-z]+`,
+        'c' : `\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{2}:\d{2}`,
+        'r' : `[A-Za-z]{3}, \d{2} [A-Za-z]{3} \d{2} \d{2}:\d{2} [A-Za-z]+`,
+    }
+    // 日志文件滚动时使用的文件锁(保证多进程间只会有一个进程执行滚动)，键名为日志文件路径
+    rotateLockers = gmap.NewStringInterfaceMap()
+    // 已执行过历史文件清理的日志文件路径，按照文件名称格式生成新的日志文件时执行一次清理
+    cleanedFiles  = gmap.NewStringBoolMap()
+)
+
+// 设置日志文件滚动配置
+func (l *Logger) SetRotate(config RotateConfig) {
+    l.mu.Lock()
+    l.rotate = config
+    l.mu.Unlock()
+}
+
+// 获取日志文件滚动配置
+func (l *Logger) GetRotate() RotateConfig {
+    l.mu.RLock()
+    r := l.rotate
+    l.mu.RUnlock()
+    return r
+}
+
+// 是否需要执行滚动，size为即将写入的内容大小
+func (c RotateConfig) needRotate(stat os.FileInfo, size int, now time.Time) bool {
+    if stat.Size() == 0 {
+        return false
+    }
+    if c.Size > 0 && stat.Size() + int64(size) > c.Size {
+        return true
+    }
+    if c.Interval > 0 && getRotateSlot(now, c.Interval) > getRotateSlot(stat.ModTime(), c.Interval) {
+        return true
+    }
+    return false
+}
+
+// 获得时间所在的滚动周期序号(按照本地时区对齐)
+func getRotateSlot(t time.Time, interval time.Duration) int64 {
+    _, offset := t.Zone()
+    return (t.UnixNano() + int64(offset) * int64(time.Second)) / int64(interval)
+}
+
+// 获得日志文件对应的文件锁
+func getRotateLocker(path string) *gflock.Locker {
+    return rotateLockers.GetOrSetFuncLock(path, func() interface{} {
+        return gflock.New(fmt.Sprintf("glog.%d.lock", crc32.ChecksumIEEE([]byte(path))))
+    }).(*gflock.Locker)
+}
+
+// 日志文件第一次写入时异步清理历史日志文件
+func (l *Logger) checkCleanup(path string) {
+    config := l.GetRotate()
+    if config.MaxBackups <= 0 && config.MaxAge <= 0 {
+        return
+    }
+    if cleanedFiles.SetIfNotExist(path, true) {
+        go l.cleanupFiles(path, config)
+    }
+}
+
+// 检查日志文件是否需要滚动，需要时将文件重命名为备份文件，调用端需要保证进程内的并发安全(gmlock)，
+// 多进程间通过文件锁保证只有一个进程执行滚动，其他进程在锁释放后检查到文件已经滚动将不再重复执行。
+func (l *Logger) rotateFile(path string, size int) {
+    config := l.GetRotate()
+    if config.Size <= 0 && config.Interval <= 0 {
+        return
+    }
+    now := time.Now()
+    if stat, err := os.Stat(path); err != nil || !config.needRotate(stat, size, now) {
+        return
+    }
+    locker := getRotateLocker(path)
+    locker.Lock()
+    defer locker.UnLock()
+    stat, err := os.Stat(path)
+    if err != nil || !config.needRotate(stat, size, now) {
+        return
+    }
+    backup := getBackupPath(path, stat.ModTime())
+    if err := os.Rename(path, backup); err != nil {
+        fmt.Fprintln(os.Stderr, fmt.Sprintf(`[glog] rotate "%s" failed: %s`, path, err.Error()))
+        return
+    }
+    go func() {
+        if config.Compress {
+            compressFile(backup)
+        }
+        l.cleanupFiles(path, config)
+    }()
+}
+
+// 获得滚动备份文件路径，文件已存在时(同一时间多次滚动)往后顺延
+func getBackupPath(path string, t time.Time) string {
+    ext  := gfile.Ext(path)
+    base := strings.TrimSuffix(path, ext)
+    for {
+        backup := base + "." + strings.Replace(t.Format(gROTATE_TIME_FORMAT), ".", "", 1) + ext
+        if !gfile.Exists(backup) && !gfile.Exists(backup + gROTATE_COMPRESS_EXT) {
+            return backup
+        }
+        t = t.Add(time.Microsecond)
+    }
+}
+
+// 使用gzip压缩备份文件，压缩后删除原文件并保留原文件的修改时间
+func compressFile(path string) {
+    stat, err := os.Stat(path)
+    if err != nil {
+        return
+    }
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, fmt.Sprintf(`[glog] compress "%s" failed: %s`, path, err.Error()))
+        return
+    }
+    data := gcompress.Gzip(content)
+    if data == nil {
+        return
+    }
+    if err := ioutil.WriteFile(path + gROTATE_COMPRESS_EXT, data, gDEFAULT_FPOOL_PERM); err != nil {
+        fmt.Fprintln(os.Stderr, fmt.Sprintf(`[glog] compress "%s" failed: %s`, path, err.Error()))
+        return
+    }
+    os.Chtimes(path + gROTATE_COMPRESS_EXT, stat.ModTime(), stat.ModTime())
+    os.Remove(path)
+}
+
+// 清理当前日志文件所在目录下的历史日志文件，path为当前写入的日志文件路径
+func (l *Logger) cleanupFiles(path string, config RotateConfig) {
+    if config.MaxBackups <= 0 && config.MaxAge <= 0 {
+        return
+    }
+    dir        := gfile.Dir(path)
+    infos, err := ioutil.ReadDir(dir)
+    if err != nil {
+        return
+    }
+    pattern := l.getFilePattern()
+    files   := make([]os.FileInfo, 0)
+    for _, info := range infos {
+        if info.IsDir() || info.Name() == gfile.Basename(path) {
+            continue
+        }
+        if gregex.IsMatchString(pattern, info.Name()) {
+            files = append(files, info)
+        }
+    }
+    sort.Slice(files, func(i, j int) bool {
+        return files[i].ModTime().After(files[j].ModTime())
+    })
+    now := time.Now()
+    for i, info := range files {
+        if (config.MaxBackups > 0 && i >= config.MaxBackups) || (config.MaxAge > 0 && now.Sub(info.ModTime()) > config.MaxAge) {
+            os.Remove(dir + gfile.Separator + info.Name())
+        }
+    }
+}
+
+// 将日志文件名称格式转换为匹配文件名称的正则表达式，只匹配当前Logger生成的日志文件及其滚动备份文件：
+// "{}"包含的时间格式按照各格式字符转换为对应的正则表达式(例如{Y-m-d}匹配\d{4}-\d{2}-\d{2})，其他内容按照原样匹配，
+// 滚动备份文件在扩展名前包含20位数字的滚动时间，并且可能包含压缩扩展名。
+func (l *Logger) getFilePattern() string {
+    file := gfile.Basename(l.file.Val())
+    ext  := gfile.Ext(file)
+    return "^" + fileFormatToPattern(strings.TrimSuffix(file, ext)) + `(\.\d{20})?` +
+        fileFormatToPattern(ext) + "(" + gregex.Quote(gROTATE_COMPRESS_EXT) + ")?$"
+}
+
+// 将文件名称格式转换为正则表达式，"{}"包含的内容作为时间格式转换，其他内容按照原样匹配
+func fileFormatToPattern(format string) string {
+    parts := strings.Split(format, "{")
+    for i, part := range parts {
+        if i > 0 {
+            if index := strings.Index(part, "}"); index >= 0 {
+                parts[i] = timeFormatToPattern(part[ : index]) + gregex.Quote(part[index + 1 : ])
+                continue
+            }
+            part = "{" + part
+        }
+        parts[i] = gregex.Quote(part)
+    }
+    return strings.Join(parts, "")
+}
+
+// 将gtime时间格式转换为正则表达式，非格式字符按照原样匹配，"\"用于转义格式字符
+func timeFormatToPattern(format string) string {
+    pattern := ""
+    for i := 0; i < len(format); i++ {
+        if format[i] == '\\' && i < len(format) - 1 {
+            i++
+            pattern += gregex.Quote(format[i : i + 1])
+            continue
+        }
+        if p, ok := timeFormatPatterns[format[i]]; ok {
+            pattern += p
+        } else {
+            pattern += gregex.Quote(format[i : i + 1])
+        }
+    }
+    return pattern
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "sync"
+)
+
+// 日志输出目标，Logger在原有输出之外，将满足等级的日志同时按照各输出目标的编码器输出，
+// Writer与Path只需要设置一项，同时设置时优先使用Writer。
+type Sink struct {
+    Level   int          // 输出的日志等级(可组合，例如LEVEL_ERRO|LEVEL_CRIT)，为0时输出所有日志(包括Print*)，Fatal/Panic日志按照LEVEL_CRIT判断
+    Encoder Encoder      // 日志编码器，为nil时使用Logger的编码器(Logger未设置时使用文本格式)
+    Writer  io.Writer    // 自定义输出(例如网络输出)，每一条日志调用一次Write
+    Path    string       // 日志文件目录
+    File    string       // 日志文件名称格式，默认为{Y-m-d}.log
+    Rotate  RotateConfig // 日志文件滚动配置
+}
+
+// 日志输出目标处理对象
+type sinkHandler struct {
+    sink   Sink
+    mu     sync.Mutex // 保证自定义Writer同一时刻只会写入一条日志
+    logger *Logger    // 文件输出时使用的Logger对象
+}
+
+// 添加日志输出目标，例如：将错误日志输出到单独的文件，将所有日志以JSON格式输出到另一个文件，
+// 将严重错误日志输出到syslog服务(NewSyslogSink)。
+func (l *Logger) AddSink(sink Sink) error {
+    handler := &sinkHandler{sink : sink}
+    if sink.Writer == nil {
+        if sink.Path == "" {
+            return errors.New("sink writer or path should be specified")
+        }
+        handler.logger = New()
+        if err := handler.logger.SetPath(sink.Path); err != nil {
+            return err
+        }
+        if sink.File != "" {
+            handler.logger.SetFile(sink.File)
+        }
+        handler.logger.SetStdPrint(false)
+        handler.logger.SetRotate(sink.Rotate)
+    }
+    l.mu.Lock()
+    l.sinks = append(l.sinks[ : len(l.sinks) : len(l.sinks)], handler)
+    l.mu.Unlock()
+    return nil
+}
+
+// 清除所有日志输出目标
+func (l *Logger) ClearSinks() {
+    l.mu.Lock()
+    l.sinks = nil
+    l.mu.Unlock()
+}
+
+// 获得日志输出目标列表
+func (l *Logger) getSinks() []*sinkHandler {
+    l.mu.RLock()
+    r := l.sinks
+    l.mu.RUnlock()
+    return r
+}
+
+// 将日志记录输出到满足等级的输出目标，开启异步写入时写入到异步缓冲区
+func (l *Logger) writeSinks(sinks []*sinkHandler, record *Record) {
+    w := l.getAsync()
+    for _, handler := range sinks {
+        if !handler.match(record.Level) {
+            continue
+        }
+        encoder := handler.sink.Encoder
+        if encoder == nil {
+            if encoder = l.GetEncoder(); encoder == nil {
+                encoder = defaultTextEncoder
+            }
+        }
+        content := string(encoder.Encode(record))
+        if w != nil && w.push(asyncEntry{logger : l, sink : handler, content : content}) {
+            continue
+        }
+        handler.write(content)
+    }
+}
+
+// 判断日志等级是否满足输出目标的等级
+func (h *sinkHandler) match(level int) bool {
+    if h.sink.Level == 0 {
+        return true
+    }
+    if level == gLEVEL_FATA || level == gLEVEL_PANI {
+        level = LEVEL_CRIT
+    }
+    return h.sink.Level & level > 0
+}
+
+// 写入日志内容到输出目标
+func (h *sinkHandler) write(content string) {
+    if h.sink.Writer != nil {
+        h.mu.Lock()
+        _, err := h.sink.Writer.Write([]byte(content))
+        h.mu.Unlock()
+        if err != nil {
+            fmt.Fprintln(os.Stderr, fmt.Sprintf(`[glog] write sink failed: %s`, err.Error()))
+        }
+    } else {
+        h.logger.syncWrite(os.Stdout, content)
+    }
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    gSYSLOG_DEFAULT_FACILITY = 1               // 默认的syslog设施(user-level messages)
+    gSYSLOG_SD_ID            = "glog@32473"    // 日志字段的结构化数据ID
+    gSYSLOG_DIAL_TIMEOUT     = 3 * time.Second // 链接syslog服务的超时时间
+    gSYSLOG_WRITE_TIMEOUT    = 3 * time.Second // 写入syslog服务的超时时间
+)
+
+var (
+    // 日志级别对应的syslog严重程度
+    syslogSeverities = map[int]int {
+        LEVEL_DEBU  : 7,
+        LEVEL_INFO  : 6,
+        LEVEL_NOTI  : 5,
+        LEVEL_WARN  : 4,
+        LEVEL_ERRO  : 3,
+        LEVEL_CRIT  : 2,
+        gLEVEL_FATA : 1,
+        gLEVEL_PANI : 1,
+    }
+)
+
+// syslog配置
+type SyslogConfig struct {
+    Facility int    // syslog设施编号(1-23)，为0时默认为1(user-level messages)，例如local0为16
+    AppName  string // 应用名称，默认为当前执行文件名称
+    Hostname string // 主机名称，默认为当前主机名称
+}
+
+// RFC 5424格式的syslog编码器，日志字段编码为结构化数据，编码结果不包含换行符(由SyslogWriter负责消息分帧)
+type SyslogEncoder struct {
+    facility int
+    appName  string
+    hostname string
+    procId   string
+}
+
+// syslog网络输出，支持udp/tcp/unix网络类型，tcp及unix流式链接使用RFC 6587的octet-counting方式分帧，
+// 链接断开时在下一次写入时自动重连。
+type SyslogWriter struct {
+    mu      sync.Mutex
+    network string
+    addr    string
+    conn    net.Conn
+    stream  bool // 是否为流式链接
+}
+
+// 创建RFC 5424格式的syslog编码器
+func NewSyslogEncoder(config...SyslogConfig) Encoder {
+    c := SyslogConfig{}
+    if len(config) > 0 {
+        c = config[0]
+    }
+    if c.Facility <= 0 || c.Facility > 23 {
+        c.Facility = gSYSLOG_DEFAULT_FACILITY
+    }
+    if c.AppName == "" {
+        c.AppName = filepath.Base(os.Args[0])
+    }
+    if c.Hostname == "" {
+        c.Hostname, _ = os.Hostname()
+    }
+    return &SyslogEncoder {
+        facility : c.Facility,
+        appName  : syslogHeaderValue(c.AppName, 48),
+        hostname : syslogHeaderValue(c.Hostname, 255),
+        procId   : strconv.Itoa(os.Getpid()),
+    }
+}
+
+// 创建syslog网络输出，network为udp/tcp/unix(unix依次尝试数据报及流式链接)，addr为服务地址或者unix socket路径，
+// 创建时不会立即链接，第一次写入时链接syslog服务。
+func NewSyslogWriter(network, addr string) (*SyslogWriter, error) {
+    switch network {
+        case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+        default:
+            return nil, errors.New(fmt.Sprintf(`unsupported syslog network "%s"`, network))
+    }
+    return &SyslogWriter {
+        network : network,
+        addr    : addr,
+    }, nil
+}
+
+// 创建syslog日志输出目标，level为输出的日志等级
+func NewSyslogSink(network, addr string, level int, config...SyslogConfig) (Sink, error) {
+    writer, err := NewSyslogWriter(network, addr)
+    if err != nil {
+        return Sink{}, err
+    }
+    return Sink {
+        Level   : level,
+        Encoder : NewSyslogEncoder(config...),
+        Writer  : writer,
+    }, nil
+}
+
+// RFC 5424格式编码：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [STRUCTURED-DATA] MSG
+func (e *SyslogEncoder) Encode(record *Record) []byte {
+    severity, ok := syslogSeverities[record.Level]
+    if !ok {
+        severity = 6
+    }
+    buffer := bytes.NewBuffer(nil)
+    buffer.WriteString("<" + strconv.Itoa(e.facility * 8 + severity) + ">1 ")
+    if record.Time.IsZero() {
+        buffer.WriteString("- ")
+    } else {
+        buffer.WriteString(record.Time.Format("2006-01-02T15:04:05.000000Z07:00") + " ")
+    }
+    buffer.WriteString(e.hostname + " " + e.appName + " " + e.procId + " - ")
+    if len(record.Fields) > 0 || record.Caller != "" {
+        buffer.WriteString("[" + gSYSLOG_SD_ID)
+        for _, field := range record.Fields {
+            buffer.WriteString(" " + syslogParamName(field.Key) + `="` + syslogParamValue(formatValue(field.Value)) + `"`)
+        }
+        if record.Caller != "" {
+            buffer.WriteString(` caller="` + syslogParamValue(record.Caller) + `"`)
+        }
+        buffer.WriteString("]")
+    } else {
+        buffer.WriteString("-")
+    }
+    if record.Message != "" {
+        buffer.WriteString(" " + record.Message)
+    }
+    return buffer.Bytes()
+}
+
+// 写入一条syslog消息，写入失败时重新链接并重试一次
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    var err error
+    for i := 0; i < 2; i++ {
+        if w.conn == nil {
+            if err = w.dial(); err != nil {
+                continue
+            }
+        }
+        w.conn.SetWriteDeadline(time.Now().Add(gSYSLOG_WRITE_TIMEOUT))
+        if w.stream {
+            _, err = w.conn.Write(append([]byte(strconv.Itoa(len(p)) + " "), p...))
+        } else {
+            _, err = w.conn.Write(p)
+        }
+        if err == nil {
+            return len(p), nil
+        }
+        w.conn.Close()
+        w.conn = nil
+    }
+    return 0, err
+}
+
+// 关闭链接
+func (w *SyslogWriter) Close() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.conn != nil {
+        err   := w.conn.Close()
+        w.conn = nil
+        return err
+    }
+    return nil
+}
+
+// 链接syslog服务
+func (w *SyslogWriter) dial() error {
+    if w.network == "unix" {
+        // unix socket可能为数据报或者流式类型
+        for _, network := range []string{"unixgram", "unix"} {
+            if conn, err := net.DialTimeout(network, w.addr, gSYSLOG_DIAL_TIMEOUT); err == nil {
+                w.conn   = conn
+                w.stream = network == "unix"
+                return nil
+            }
+        }
+        return errors.New(fmt.Sprintf(`dial syslog unix socket "%s" failed`, w.addr))
+    }
+    conn, err := net.DialTimeout(w.network, w.addr, gSYSLOG_DIAL_TIMEOUT)
+    if err != nil {
+        return err
+    }
+    w.conn   = conn
+    w.stream = strings.HasPrefix(w.network, "tcp")
+    return nil
+}
+
+// 转换syslog头部字段值，只能包含可打印的ASCII字符，为空时使用"-"
+func syslogHeaderValue(s string, max int) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 33 || r > 126 {
+            return -1
+        }
+        return r
+    }, s)
+    if len(s) > max {
+        s = s[ : max]
+    }
+    if s == "" {
+        return "-"
+    }
+    return s
+}
+
+// 转换结构化数据的参数名称，不能包含空格、等号、右中括号及双引号，最长32个字符
+func syslogParamName(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+            return '_'
+        }
+        return r
+    }, s)
+    if len(s) > 32 {
+        s = s[ : 32]
+    }
+    if s == "" {
+        return "_"
+    }
+    return s
+}
+
+// 转义结构化数据的参数值中的双引号、反斜杠及右中括号
+func syslogParamValue(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,445 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+package glog_test
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "gitee.com/johng/gf/g/encoding/gcompress"
+    "gitee.com/johng/gf/g/os/gfile"
+    "gitee.com/johng/gf/g/os/glog"
+    "gitee.com/johng/gf/g/util/gtest"
+    "io"
+    "net"
+    "os"
+    "os/exec"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "testing"
+    "time"
+)
+
+func TestLogger_Text(t *testing.T) {
+    gtest.Case(t, func() {
+        buffer := bytes.NewBuffer(nil)
+        logger := glog.New()
+        logger.SetWriter(buffer)
+        logger.Header(false).Info("hello", "world")
+        gtest.Assert(buffer.String(), "[INFO] hello world\n")
+
+        // 绑定字段后输出结构化日志
+        buffer.Reset()
+        child := logger.With("user", 1)
+        child.Header(false).Infow("login", "ip", "127.0.0.1", "cost", "1 ms")
+        s := buffer.String()
+        gtest.Assert(strings.HasPrefix(s, `[INFO] login user=1 ip=127.0.0.1 cost="1 ms" caller=glog/glog_z_unit_test.go:`), true)
+
+        // 非*w方法的参数按照位置拼接为日志内容
+        buffer.Reset()
+        child.Header(false).Backtrace(false).Error("consume error:", errors.New("timeout"), 3)
+        s = buffer.String()
+        gtest.Assert(strings.HasPrefix(s, `[ERRO] consume error: timeout 3 user=1 caller=glog/glog_z_unit_test.go:`), true)
+
+        // With不影响原有的Logger
+        buffer.Reset()
+        logger.Header(false).Infof("%d-%d", 1, 2)
+        gtest.Assert(buffer.String(), "[INFO] 1-2")
+        gtest.Assert(len(logger.GetFields()), 0)
+        gtest.Assert(len(child.GetFields()), 1)
+    })
+}
+
+func TestLogger_Logfmt(t *testing.T) {
+    gtest.Case(t, func() {
+        buffer := bytes.NewBuffer(nil)
+        logger := glog.New()
+        logger.SetWriter(buffer)
+        logger.SetEncoder(glog.NewLogfmtEncoder())
+        logger.SetCaller(false)
+        logger.Header(false).With("app", "demo").Debugw("start up", "port", 8199, "odd")
+        gtest.Assert(buffer.String(), `level=debug msg="start up" app=demo port=8199 odd=(MISSING)` + "\n")
+    })
+}
+
+func TestLogger_Json(t *testing.T) {
+    gtest.Case(t, func() {
+        buffer := bytes.NewBuffer(nil)
+        logger := glog.New()
+        logger.SetWriter(buffer)
+        logger.Encoding(glog.NewJsonEncoder()).Backtrace(false).Errorw("failed", "error", errors.New("timeout"), "retry", 3)
+        m := make(map[string]interface{})
+        gtest.Assert(json.Unmarshal(buffer.Bytes(), &m), nil)
+        gtest.Assert(m["level"], "error")
+        gtest.Assert(m["msg"], "failed")
+        gtest.Assert(m["error"], "timeout")
+        gtest.Assert(m["retry"], 3)
+        gtest.Assert(strings.HasPrefix(m["caller"].(string), "glog/glog_z_unit_test.go:"), true)
+        gtest.AssertNE(m["time"], nil)
+        gtest.Assert(m["backtrace"], nil)
+
+        // 日志等级过滤
+        buffer.Reset()
+        logger.Level(glog.LEVEL_ERRO).Encoding(glog.NewJsonEncoder()).Info("ignored")
+        gtest.Assert(buffer.Len(), 0)
+        logger.Level(glog.LEVEL_ERRO).Encoding(glog.NewJsonEncoder()).Infow("ignored", "k", "v")
+        gtest.Assert(buffer.Len(), 0)
+
+        // 非*w方法不解析键值对字段
+        buffer.Reset()
+        logger.Encoding(glog.NewJsonEncoder()).Backtrace(false).Warning("retry", 3)
+        m = make(map[string]interface{})
+        gtest.Assert(json.Unmarshal(buffer.Bytes(), &m), nil)
+        gtest.Assert(m["msg"], "retry 3")
+        gtest.Assert(m["retry"], nil)
+    })
+}
+
+func TestLogger_Rotate(t *testing.T) {
+    gtest.Case(t, func() {
+        path := gfile.TempDir() + gfile.Separator + "glog-rotate-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+        defer gfile.Remove(path)
+        logger := glog.New()
+        logger.SetPath(path)
+        logger.SetFile("test.log")
+        logger.SetStdPrint(false)
+        logger.SetRotate(glog.RotateConfig {
+            Size       : 100,
+            MaxBackups : 2,
+            Compress   : true,
+        })
+        for i := 0; i < 10; i++ {
+            logger.Header(false).Println(strings.Repeat("a", 59))
+        }
+        // 每个文件最多写入1行日志，最终保留1个当前文件以及2个压缩的备份文件
+        time.Sleep(500 * time.Millisecond)
+        files, _ := gfile.ScanDir(path, "*")
+        gtest.Assert(len(files), 3)
+        gtest.Assert(gfile.GetContents(path + gfile.Separator + "test.log"), strings.Repeat("a", 59) + "\n")
+        backups, _ := gfile.ScanDir(path, "test.*.log.gz")
+        gtest.Assert(len(backups), 2)
+        gtest.Assert(string(gcompress.UnGzip(gfile.GetBinContents(backups[0]))), strings.Repeat("a", 59) + "\n")
+    })
+}
+
+func TestLogger_RotateInterval(t *testing.T) {
+    gtest.Case(t, func() {
+        path := gfile.TempDir() + gfile.Separator + "glog-rotate-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+        defer gfile.Remove(path)
+        logger := glog.New()
+        logger.SetPath(path)
+        logger.SetFile("{Y-m-d}.log")
+        logger.SetStdPrint(false)
+        logger.SetRotate(glog.RotateConfig {
+            Interval : time.Hour,
+            MaxAge   : 24 * time.Hour,
+        })
+        // 过期的历史日志文件将会在第一次写入时清理
+        expired := path + gfile.Separator + "2000-01-01.log"
+        gfile.PutContents(expired, "expired")
+        os.Chtimes(expired, time.Now().Add(-48 * time.Hour), time.Now().Add(-48 * time.Hour))
+
+        logger.Header(false).Println("1")
+        file := path + gfile.Separator + time.Now().Format("2006-01-02") + ".log"
+        os.Chtimes(file, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+        logger.Header(false).Println("2")
+        time.Sleep(500 * time.Millisecond)
+        gtest.Assert(gfile.Exists(expired), false)
+        gtest.Assert(gfile.GetContents(file), "2\n")
+        files, _ := gfile.ScanDir(path, "*")
+        gtest.Assert(len(files), 2)
+    })
+}
+
+func TestLogger_RotateForeignFiles(t *testing.T) {
+    gtest.Case(t, func() {
+        path := gfile.TempDir() + gfile.Separator + "glog-rotate-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+        defer gfile.Remove(path)
+        logger := glog.New()
+        logger.SetPath(path)
+        logger.SetFile("{Y-m-d}.log")
+        logger.SetStdPrint(false)
+        logger.SetRotate(glog.RotateConfig {
+            Size       : 100,
+            MaxBackups : 1,
+        })
+        // 同一目录下其他用途的文件不会被清理，按照文件名称格式生成的历史日志文件将会被清理
+        foreigns := []string{"access-20190101.log", "app.2019-01-01.log", "2019-01-01.txt", "2019-1-1.log"}
+        for _, name := range append(foreigns, "2000-01-01.log") {
+            file := path + gfile.Separator + name
+            gfile.PutContents(file, name)
+            os.Chtimes(file, time.Now().Add(-48 * time.Hour), time.Now().Add(-48 * time.Hour))
+        }
+        for i := 0; i < 5; i++ {
+            logger.Header(false).Println(strings.Repeat("a", 59))
+        }
+        time.Sleep(500 * time.Millisecond)
+        for _, name := range foreigns {
+            gtest.Assert(gfile.GetContents(path + gfile.Separator + name), name)
+        }
+        gtest.Assert(gfile.Exists(path + gfile.Separator + "2000-01-01.log"), false)
+        backups, _ := gfile.ScanDir(path, time.Now().Format("2006-01-02") + ".*.log")
+        gtest.Assert(len(backups), 1)
+        files, _ := gfile.ScanDir(path, "*")
+        gtest.Assert(len(files), len(foreigns) + 2)
+    })
+}
+
+// 可阻塞的Writer，用于模拟写入缓慢的场景
+type gateWriter struct {
+    mu      sync.Mutex
+    buffer  bytes.Buffer
+    entered chan struct{}
+    gate    chan struct{}
+}
+
+func newGateWriter() *gateWriter {
+    return &gateWriter {
+        entered : make(chan struct{}, 1),
+        gate    : make(chan struct{}),
+    }
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+    select {
+        case w.entered <- struct{}{}:
+        default:
+    }
+    <- w.gate
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.buffer.Write(p)
+}
+
+func (w *gateWriter) String() string {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.buffer.String()
+}
+
+func TestLogger_Async(t *testing.T) {
+    gtest.Case(t, func() {
+        buffer := bytes.NewBuffer(nil)
+        logger := glog.New()
+        logger.SetWriter(buffer)
+        logger.SetAsync(true, glog.AsyncConfig {
+            BufferSize    : 10,
+            FlushInterval : time.Hour,
+        })
+        defer logger.SetAsync(false)
+        l := logger.Header(false)
+        l.Println(1)
+        l.Println(2)
+        l.Println(3)
+        gtest.Assert(buffer.String(), "")
+        glog.Flush()
+        gtest.Assert(buffer.String(), "1\n2\n3\n")
+    })
+    // 缓冲区满时的处理策略
+    for overflow, expect := range map[int]string {
+        glog.OVERFLOW_BLOCK       : "1\n2\n3\n4\n",
+        glog.OVERFLOW_DROP_OLDEST : "1\n3\n4\n",
+        glog.OVERFLOW_DROP_NEW    : "1\n2\n3\n",
+    } {
+        gtest.Case(t, func() {
+            writer := newGateWriter()
+            logger := glog.New()
+            logger.SetWriter(writer)
+            logger.SetAsync(true, glog.AsyncConfig {
+                BufferSize    : 2,
+                Overflow      : overflow,
+                FlushInterval : time.Hour,
+            })
+            l := logger.Header(false)
+            l.Println(1)
+            // 等待第1条日志写入阻塞，此时缓冲区可以继续写入2条日志
+            <- writer.entered
+            l.Println(2)
+            l.Println(3)
+            done := make(chan struct{})
+            go func() {
+                l.Println(4)
+                close(done)
+            }()
+            if overflow == glog.OVERFLOW_BLOCK {
+                select {
+                    case <- done:
+                        t.Error("should block")
+                    case <- time.After(100 * time.Millisecond):
+                }
+            } else {
+                <- done
+            }
+            close(writer.gate)
+            <- done
+            if overflow == glog.OVERFLOW_BLOCK {
+                gtest.Assert(logger.Dropped(), 0)
+            } else {
+                gtest.Assert(logger.Dropped(), 1)
+            }
+            logger.SetAsync(false)
+            gtest.Assert(writer.String(), expect)
+        })
+    }
+}
+
+func TestLogger_FlushOnSignal(t *testing.T) {
+    // 子进程：开启异步日志及信号监听后给自己发送SIGTERM，缓冲区中的日志应当在进程退出前写入
+    if os.Getenv("GF_GLOG_SIGNAL_TEST") != "" {
+        logger := glog.New()
+        logger.SetWriter(os.Stdout)
+        logger.SetAsync(true, glog.AsyncConfig {
+            FlushInterval : time.Hour,
+        })
+        glog.FlushOnSignal(syscall.SIGTERM)
+        logger.Header(false).Println("before signal")
+        if p, err := os.FindProcess(os.Getpid()); err == nil {
+            p.Signal(syscall.SIGTERM)
+        }
+        time.Sleep(5*time.Second)
+        os.Exit(0)
+    }
+    if runtime.GOOS == "windows" {
+        t.Skip("sending SIGTERM is not supported on windows")
+    }
+    gtest.Case(t, func() {
+        cmd := exec.Command(os.Args[0], "-test.run=^TestLogger_FlushOnSignal$")
+        cmd.Env = append(os.Environ(), "GF_GLOG_SIGNAL_TEST=1")
+        output, err := cmd.Output()
+        // 重新发送信号后进程按照默认方式被信号终止
+        gtest.AssertNE(err, nil)
+        gtest.Assert(strings.Contains(err.Error(), "signal: terminated"), true)
+        gtest.Assert(string(output), "before signal\n")
+    })
+}
+
+func TestLogger_Sink(t *testing.T) {
+    gtest.Case(t, func() {
+        all    := bytes.NewBuffer(nil)
+        errs   := bytes.NewBuffer(nil)
+        logger := glog.New()
+        logger.SetWriter(bytes.NewBuffer(nil))
+        gtest.Assert(logger.AddSink(glog.Sink{Writer : all, Encoder : glog.NewJsonEncoder()}), nil)
+        gtest.Assert(logger.AddSink(glog.Sink{Writer : errs, Level : glog.LEVEL_ERRO | glog.LEVEL_CRIT}), nil)
+        gtest.AssertNE(logger.AddSink(glog.Sink{}), nil)
+
+        l := logger.Header(false).Backtrace(false)
+        l.Info("info")
+        l.Error("error")
+        l.With("user", 1).Critical("critical")
+        lines := strings.Split(strings.TrimSpace(all.String()), "\n")
+        gtest.Assert(len(lines), 3)
+        m := make(map[string]interface{})
+        gtest.Assert(json.Unmarshal([]byte(lines[2]), &m), nil)
+        gtest.Assert(m["level"], "critical")
+        gtest.Assert(m["msg"], "critical")
+        gtest.Assert(m["user"], 1)
+
+        lines = strings.Split(strings.TrimSpace(errs.String()), "\n")
+        gtest.Assert(len(lines), 2)
+        gtest.Assert(strings.HasPrefix(lines[0], "[ERRO] error caller=glog/glog_z_unit_test.go:"), true)
+        gtest.Assert(strings.HasPrefix(lines[1], "[CRIT] critical user=1 caller="), true)
+
+        logger.ClearSinks()
+        l = logger.Header(false)
+        l.Error("error")
+        gtest.Assert(len(strings.Split(strings.TrimSpace(errs.String()), "\n")), 2)
+    })
+}
+
+func TestLogger_Syslog(t *testing.T) {
+    // UDP
+    gtest.Case(t, func() {
+        conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+        gtest.Assert(err, nil)
+        defer conn.Close()
+        sink, err := glog.NewSyslogSink("udp", conn.LocalAddr().String(), glog.LEVEL_CRIT, glog.SyslogConfig {
+            Facility : 16,
+            AppName  : "app",
+            Hostname : "host",
+        })
+        gtest.Assert(err, nil)
+        logger := glog.New()
+        logger.SetWriter(bytes.NewBuffer(nil))
+        logger.AddSink(sink)
+        logger.SetCaller(false)
+        logger.Error("ignored")
+        logger.Backtrace(false).With("id", `a"]`).Critical("disk full")
+
+        buffer := make([]byte, 1024)
+        conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+        n, _, err := conn.ReadFrom(buffer)
+        gtest.Assert(err, nil)
+        msg   := string(buffer[ : n])
+        array := strings.SplitN(msg, " ", 3)
+        gtest.Assert(array[0], "<130>1")
+        _, err = time.Parse(time.RFC3339Nano, array[1])
+        gtest.Assert(err, nil)
+        gtest.Assert(array[2], "host app " + strconv.Itoa(os.Getpid()) + ` - [glog@32473 id="a\"\]"] disk full`)
+    })
+    // TCP(octet-counting分帧)
+    gtest.Case(t, func() {
+        listener, err := net.Listen("tcp", "127.0.0.1:0")
+        gtest.Assert(err, nil)
+        defer listener.Close()
+        sink, err := glog.NewSyslogSink("tcp", listener.Addr().String(), 0, glog.SyslogConfig{AppName : "app", Hostname : "host"})
+        gtest.Assert(err, nil)
+        logger := glog.New()
+        logger.SetWriter(bytes.NewBuffer(nil))
+        logger.AddSink(sink)
+        logger.SetCaller(false)
+        l := logger.Header(false)
+        l.Info("first")
+        l.Print("second")
+
+        conn, err := listener.Accept()
+        gtest.Assert(err, nil)
+        defer conn.Close()
+        conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+        reader := bufio.NewReader(conn)
+        for _, expect := range []string {
+            "<14>1 - host app " + strconv.Itoa(os.Getpid()) + " - - first",
+            "<14>1 - host app " + strconv.Itoa(os.Getpid()) + " - - second",
+        } {
+            length, err := reader.ReadString(' ')
+            gtest.Assert(err, nil)
+            n, _ := strconv.Atoi(strings.TrimSpace(length))
+            gtest.Assert(n, len(expect))
+            data := make([]byte, n)
+            _, err = io.ReadFull(reader, data)
+            gtest.Assert(err, nil)
+            gtest.Assert(string(data), expect)
+        }
+    })
+    // Unix Socket
+    gtest.Case(t, func() {
+        path := gfile.TempDir() + gfile.Separator + "glog-syslog-" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".sock"
+        conn, err := net.ListenPacket("unixgram", path)
+        if err != nil {
+            t.Skip("unix socket not supported:", err)
+        }
+        defer os.Remove(path)
+        defer conn.Close()
+        sink, err := glog.NewSyslogSink("unix", path, glog.LEVEL_WARN)
+        gtest.Assert(err, nil)
+        logger := glog.New()
+        logger.SetWriter(bytes.NewBuffer(nil))
+        logger.AddSink(sink)
+        logger.Backtrace(false).Warning("warn")
+
+        buffer := make([]byte, 1024)
+        conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+        n, _, err := conn.ReadFrom(buffer)
+        gtest.Assert(err, nil)
+        gtest.Assert(strings.HasPrefix(string(buffer[ : n]), "<12>1 "), true)
+        gtest.Assert(strings.HasSuffix(string(buffer[ : n]), " warn"), true)
+    })
+}
@@ -7,7 +7,8 @@
 // Package gtimer implements Hierarchical Timing Wheel for interval/delayed jobs running and management.
 // 
 // 任务定时器,
-// 高性能的分层时间轮任务管理模块，用于管理间隔/延迟运行任务。
+// 高性能的分层时间轮任务管理模块，用于管理间隔/延迟运行任务，任务的添加及删除为O(1)操作，
+// 每个刻度的处理开销只与到期的任务数量相关，适用于大量任务(例如每个会话/缓存项一个任务)的场景。
 // 与gcron模块的区别是，时间轮模块只管理间隔执行任务，并且更注重执行效率(纳秒级别)。
 // 需要注意执行时间间隔的准确性问题: https://github.com/golang/go/issues/14410
 package gtimer
@@ -99,6 +100,11 @@ func DelayAddTimes(delay time.Duration, interval time.Duration, times int, job J
     defaultTimer.DelayAddTimes(delay, interval, times, job)
 }
 
+// 获取默认定时器的统计信息
+func GetStats() *Stats {
+    return defaultTimer.Stats()
+}
+
 // 在Job方法中调用，停止当前运行的任务。
 func Exit() {
     panic(gPANIC_EXIT)
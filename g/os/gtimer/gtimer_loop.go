@@ -7,72 +7,157 @@
 package gtimer
 
 import (
-    "gitee.com/johng/gf/g/container/glist"
     "time"
 )
 
-// 开始循环
-func (w *wheel) start() {
-    go func() {
-        ticker := time.NewTicker(time.Duration(w.intervalMs)*time.Millisecond)
-        for {
-           select {
-               case <- ticker.C:
-                   switch w.timer.status.Val() {
-                       case STATUS_RUNNING:
-                           w.proceed()
+// 开始循环，ticker触发时处理所有已到计划时间的刻度(ticker丢失触发时补齐刻度)，
+// 定时器停止期间刻度不转动，任务的剩余时间保持不变
+func (t *Timer) loop() {
+    // 计划时间需要在ticker创建之前计算，保证不晚于ticker的触发时间
+    next   := time.Now().Add(t.interval)
+    ticker := time.NewTicker(t.interval)
+    defer ticker.Stop()
+    for now := range ticker.C {
+        switch t.status.Val() {
+            case STATUS_RUNNING:
+                for !now.Before(next) {
+                    t.proceed()
+                    t.setLag(time.Now().Sub(next))
+                    next = next.Add(t.interval)
+                }
 
-                       case STATUS_STOPPED:
-                       case STATUS_CLOSED:
-                           ticker.Stop()
-                           return
-                   }
+            case STATUS_STOPPED:
+                for !now.Before(next) {
+                    next = next.Add(t.interval)
+                }
 
-           }
+            case STATUS_CLOSED:
+                return
         }
-    }()
+    }
 }
 
-// 执行时间轮刻度逻辑
-func (w *wheel) proceed() {
-    n      := w.ticks.Add(1)
-    l      := w.slots[int(n%w.number)]
-    length := l.Len()
-    if length > 0 {
-        go func(l *glist.List, nowTicks int64) {
-            entry := (*Entry)(nil)
-            nowMs := time.Now().UnixNano()/1e6
-            for i := length; i > 0; i-- {
-                if v := l.PopFront(); v == nil {
-                    break
-                } else {
-                    entry = v.(*Entry)
-                }
-                // 是否满足运行条件
-                runnable, addable := entry.check(nowTicks, nowMs)
-                if runnable {
-                    // 异步执行运行
-                    go func(entry *Entry) {
-                        defer func() {
-                            if err := recover(); err != nil {
-                                if err != gPANIC_EXIT {
-                                    panic(err)
-                                } else {
-                                    entry.Close()
-                                }
-                            }
-                            if entry.Status() == STATUS_RUNNING {
-                                entry.SetStatus(STATUS_READY)
-                            }
-                        }()
-                        entry.job()
-                    }(entry)
-                }
-                // 是否继续添运行, 滚动任务
-                if addable {
-                    entry.wheel.timer.doAddEntryByParent(entry.rawIntervalMs, entry)
-                }
+// 记录刻度处理的延迟
+func (t *Timer) setLag(lag time.Duration) {
+    t.lag.Set(int64(lag))
+    if int64(lag) > t.maxLag.Val() {
+        t.maxLag.Set(int64(lag))
+    }
+}
+
+// 执行时间轮刻度逻辑：级联上层时间轮的任务，执行第0层当前slot中的到期任务，并将需要继续运行的任务重新添加到定时器
+func (t *Timer) proceed() {
+    t.mu.Lock()
+    t.ticks++
+    now := t.ticks
+    // 从上往下级联，上层级联下来的任务可能在同一刻度被下层继续级联或者执行
+    for i := t.length - 1; i > 0; i-- {
+        w := t.wheels[i]
+        if now%w.span != 0 {
+            continue
+        }
+        // 超出范围的任务可能重新添加到当前slot，因此只处理级联前已存在的任务
+        l := w.slots[(now/w.span)%t.number]
+        for n := l.Len(); n > 0; n-- {
+            entry := l.Remove(l.Front()).(*Entry)
+            t.insert(entry)
+        }
+    }
+    l       := t.wheels[0].slots[now%t.number]
+    entries := t.due[:0]
+    for l.Len() > 0 {
+        entry        := l.Remove(l.Front()).(*Entry)
+        entry.bucket  = nil
+        entry.element = nil
+        entries       = append(entries, entry)
+    }
+    t.mu.Unlock()
+    t.total.Add(1)
+    if len(entries) == 0 {
+        return
+    }
+    addable := t.addable[:0]
+    for range entries {
+        addable = append(addable, false)
+    }
+    for i, entry := range entries {
+        runnable := false
+        if runnable, addable[i] = entry.check(); runnable {
+            // 异步执行运行
+            go entry.run()
+        }
+    }
+    // 是否继续添运行, 滚动任务
+    removed := int64(0)
+    t.mu.Lock()
+    for i, entry := range entries {
+        if !entry.active || entry.bucket != nil {
+            continue
+        }
+        if addable[i] && entry.status.Val() != STATUS_CLOSED {
+            entry.expire = now + entry.interval
+            t.insert(entry)
+        } else {
+            entry.active = false
+            removed++
+        }
+    }
+    t.mu.Unlock()
+    // 清空引用后保留缓冲区，避免每个刻度重复分配
+    for i := range entries {
+        entries[i] = nil
+    }
+    t.due, t.addable = entries[:0], addable[:0]
+    if removed > 0 {
+        t.entries.Add(-removed)
+    }
+}
+
+// 执行任务，任务中调用Exit时关闭任务
+func (entry *Entry) run() {
+    defer func() {
+        if err := recover(); err != nil {
+            if err != gPANIC_EXIT {
+                panic(err)
+            } else {
+                entry.Close()
             }
-        }(l, n)
+        }
+        if entry.Status() == STATUS_RUNNING {
+            entry.SetStatus(STATUS_READY)
+        }
+    }()
+    entry.job()
+}
+
+// 按照任务的到期刻度将任务添加到对应的时间轮slot，调用端需要持有定时器的互斥锁
+func (t *Timer) insert(entry *Entry) {
+    delta := entry.expire - t.ticks
+    i     := 0
+    for i < t.length - 1 && delta >= t.wheels[i].span*t.number {
+        i++
+    }
+    w     := t.wheels[i]
+    index := (entry.expire/w.span)%t.number
+    if delta >= w.span*t.number {
+        // 超出最高一层时间轮范围，添加到一圈后转到的slot，级联时重新计算位置
+        index = (t.ticks/w.span)%t.number
+    }
+    entry.bucket  = w.slots[index]
+    entry.element = entry.bucket.PushBack(entry)
+}
+
+// 从定时器中删除任务，执行期间的任务在执行后删除
+func (t *Timer) remove(entry *Entry) {
+    t.mu.Lock()
+    if !entry.active || entry.bucket == nil {
+        t.mu.Unlock()
+        return
     }
+    entry.bucket.Remove(entry.element)
+    entry.bucket  = nil
+    entry.element = nil
+    entry.active  = false
+    t.mu.Unlock()
+    t.entries.Add(-1)
 }
@@ -7,77 +7,95 @@
 package gtimer
 
 import (
-    "gitee.com/johng/gf/g/container/glist"
+    "container/list"
     "gitee.com/johng/gf/g/container/gtype"
+    "math"
+    "sync"
     "time"
 )
 
-// 定时器/分层时间轮
+// 定时器/分层时间轮：
+// 1、每一层时间轮包含相同数量的slot，第0层每个slot为1个刻度，第n层每个slot为第n-1层一圈的刻度数量；
+// 2、任务按照到期刻度(绝对值)与当前刻度的差值添加到能够容纳的最低一层，添加与删除都是O(1)操作；
+// 3、每个刻度只处理第0层当前slot中的到期任务，上层时间轮转到新的slot时，将该slot中的任务按照剩余刻度重新添加到下层(级联)，
+//    因此每个刻度的处理开销与任务总数无关，只与到期(或级联)的任务数量相关；
+// 4、超出最高一层时间轮范围的任务添加到最高一层一圈后转到的slot，级联时重新计算位置。
 type Timer struct {
-    status     *gtype.Int      // 定时器状态
-    wheels     []*wheel        // 分层时间轮对象
-    length     int             // 分层层数
-    number     int             // 每一层Slot Number
-    intervalMs int64           // 最小时间刻度(毫秒)
+    mu       sync.Mutex      // 时间轮及任务位置的互斥锁
+    status   *gtype.Int      // 定时器状态
+    wheels   []*wheel        // 分层时间轮对象
+    length   int             // 分层层数
+    number   int64           // 每一层Slot Number
+    interval time.Duration   // 最小时间刻度
+    ticks    int64           // 当前已转动的刻度数量
+    entries  *gtype.Int64    // 当前的任务数量
+    lag      *gtype.Int64    // 最近一次刻度处理的延迟(纳秒)
+    maxLag   *gtype.Int64    // 刻度处理的最大延迟(纳秒)
+    total    *gtype.Int64    // 已转动的刻度数量(用于统计)
+    due      []*Entry        // 刻度处理时的到期任务缓冲区(仅在循环goroutine中使用)
+    addable  []bool          // 到期任务是否需要继续运行的缓冲区(仅在循环goroutine中使用)
 }
 
 // 单层时间轮
 type wheel struct {
-    timer      *Timer          // 所属定时器
     level      int             // 所属分层索引号
-    slots      []*glist.List   // 所有的循环任务项, 按照Slot Number进行分组
-    number     int64           // Slot Number=len(slots)
-    ticks      *gtype.Int64    // 当前时间轮已转动的刻度数量
-    totalMs    int64           // 整个时间轮的时间长度(毫秒)=number*interval
-    createMs   int64           // 创建时间(毫秒)
-    intervalMs int64           // 时间间隔(slot时间长度, 毫秒)
+    slots      []*list.List    // 所有的任务项, 按照Slot Number进行分组
+    span       int64           // 每个slot的刻度数量
 }
 
-// 创建分层时间轮
+// 定时器统计信息
+type Stats struct {
+    Entries int64         // 当前的任务数量
+    Ticks   int64         // 已转动的刻度数量
+    Lag     time.Duration // 最近一次刻度处理的延迟(实际处理时间与计划时间的差值)
+    MaxLag  time.Duration // 刻度处理的最大延迟
+}
+
+// 创建分层时间轮，slot为每一层的slot数量，interval为最小时间刻度(每个Timer可以使用不同的精度)，level为分层层数，
+// 时间轮能够直接容纳的最大时间间隔为interval*slot^level，超出时通过级联处理。
 func New(slot int, interval time.Duration, level...int) *Timer {
     length := gDEFAULT_WHEEL_LEVEL
-    if len(level) > 0 {
+    if len(level) > 0 && level[0] > 0 {
         length = level[0]
     }
+    if slot < 2 {
+        slot = gDEFAULT_SLOT_NUMBER
+    }
+    if interval <= 0 {
+        interval = gDEFAULT_WHEEL_INTERVAL*time.Millisecond
+    }
     t := &Timer {
-        status     : gtype.NewInt(STATUS_RUNNING),
-        wheels     : make([]*wheel, length),
-        length     : length,
-        number     : slot,
-        intervalMs : interval.Nanoseconds()/1e6,
+        status   : gtype.NewInt(STATUS_RUNNING),
+        wheels   : make([]*wheel, 0, length),
+        number   : int64(slot),
+        interval : interval,
+        entries  : gtype.NewInt64(),
+        lag      : gtype.NewInt64(),
+        maxLag   : gtype.NewInt64(),
+        total    : gtype.NewInt64(),
     }
+    span := int64(1)
     for i := 0; i < length; i++ {
-        if i > 0 {
-            n          := time.Duration(t.wheels[i - 1].totalMs)*time.Millisecond
-            w          := t.newWheel(i, slot, n)
-            t.wheels[i] = w
-            t.wheels[i - 1].addEntry(n, w.proceed, false, gDEFAULT_TIMES, STATUS_READY)
-        } else {
-            t.wheels[i] = t.newWheel(i, slot, interval)
+        w := &wheel {
+            level : i,
+            slots : make([]*list.List, slot),
+            span  : span,
+        }
+        for j := range w.slots {
+            w.slots[j] = list.New()
         }
+        t.wheels = append(t.wheels, w)
+        // 避免刻度数量溢出
+        if span > math.MaxInt64/t.number/t.number {
+            break
+        }
+        span *= t.number
     }
-    t.wheels[0].start()
+    t.length = len(t.wheels)
+    go t.loop()
     return t
 }
 
-// 创建自定义的循环任务管理对象
-func (t *Timer) newWheel(level int, slot int, interval time.Duration) *wheel {
-    w := &wheel {
-        timer      : t,
-        level      : level,
-        slots      : make([]*glist.List, slot),
-        number     : int64(slot),
-        ticks      : gtype.NewInt64(),
-        totalMs    : int64(slot)*interval.Nanoseconds()/1e6,
-        createMs   : time.Now().UnixNano()/1e6,
-        intervalMs : interval.Nanoseconds()/1e6,
-    }
-    for i := int64(0); i < w.number; i++ {
-        w.slots[i] = glist.New()
-    }
-    return w
-}
-
 // 添加循环任务
 func (t *Timer) Add(interval time.Duration, job JobFunc) *Entry {
     return t.doAddEntry(interval, job, false, gDEFAULT_TIMES, STATUS_READY)
@@ -153,64 +171,45 @@ func (t *Timer) Close() {
     t.status.Set(STATUS_CLOSED)
 }
 
+// 获取定时器的最小时间刻度
+func (t *Timer) Interval() time.Duration {
+    return t.interval
+}
+
+// 获取定时器统计信息
+func (t *Timer) Stats() *Stats {
+    return &Stats {
+        Entries : t.entries.Val(),
+        Ticks   : t.total.Val(),
+        Lag     : time.Duration(t.lag.Val()),
+        MaxLag  : time.Duration(t.maxLag.Val()),
+    }
+}
+
 // 添加定时任务
 func (t *Timer) doAddEntry(interval time.Duration, job JobFunc, singleton bool, times int, status int) *Entry {
-    return t.wheels[t.getLevelByIntervalMs(interval.Nanoseconds()/1e6)].addEntry(interval, job, singleton, times, status)
-}
-
-// 添加定时任务，给定父级Entry, 间隔参数参数为毫秒数.
-func (t *Timer) doAddEntryByParent(interval int64, parent *Entry) *Entry {
-    return t.wheels[t.getLevelByIntervalMs(interval)].addEntryByParent(interval, parent)
-}
-
-// 根据intervalMs计算添加的分层索引
-func (t *Timer) getLevelByIntervalMs(intervalMs int64) int {
-    pos, cmp := t.binSearchIndex(intervalMs)
-    switch cmp {
-        // intervalMs与最后匹配值相等, 不添加到匹配得层，而是向下一层添加
-        case  0: fallthrough
-        // intervalMs比最后匹配值小
-        case -1:
-            i := pos
-            for ; i > 0; i-- {
-                if intervalMs > t.wheels[i].intervalMs && intervalMs <= t.wheels[i].totalMs {
-                    return i
-                }
-            }
-            return i
-
-        // intervalMs比最后匹配值大
-        case  1:
-            i := pos
-            for ; i < t.length - 1; i++ {
-                if intervalMs > t.wheels[i].intervalMs && intervalMs <= t.wheels[i].totalMs {
-                    return i
-                }
-            }
-            return i
+    entry := &Entry {
+        timer     : t,
+        job       : job,
+        times     : gtype.NewInt(times),
+        status    : gtype.NewInt(status),
+        singleton : gtype.NewBool(singleton),
+        interval  : t.getTicks(interval),
     }
-    return 0
-}
-
-// 二分查找当前任务可以添加的时间轮对象索引.
-func (t *Timer) binSearchIndex(n int64)(index int, result int) {
-    min := 0
-    max := t.length - 1
-    mid := 0
-    cmp := -2
-    for min <= max {
-        mid = int((min + max) / 2)
-        switch {
-            case t.wheels[mid].intervalMs == n : cmp =  0
-            case t.wheels[mid].intervalMs  > n : cmp = -1
-            case t.wheels[mid].intervalMs  < n : cmp =  1
-        }
-        switch cmp {
-            case -1 : max = mid - 1
-            case  1 : min = mid + 1
-            case  0 :
-                return mid, cmp
-        }
+    t.mu.Lock()
+    entry.expire = t.ticks + entry.interval
+    entry.active = true
+    t.insert(entry)
+    t.mu.Unlock()
+    t.entries.Add(1)
+    return entry
+}
+
+// 将时间间隔转换为刻度数量(四舍五入)，小于一个刻度时为一个刻度
+func (t *Timer) getTicks(interval time.Duration) int64 {
+    n := int64((interval + t.interval/2)/t.interval)
+    if n < 1 {
+        n = 1
     }
-    return mid, cmp
-}
\ No newline at end of file
+    return n
+}
@@ -0,0 +1,299 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// 分层时间轮与原有实现(每个刻度弹出slot中的所有任务并重新添加)的性能对比，
+// 原有实现保留在本文件中，并使用模拟时钟同步执行刻度逻辑(上层时间轮的转动同样同步执行)，以便与新的实现进行对比：
+// go test -run=none -bench=Wheel -benchmem
+
+package gtimer
+
+import (
+    "gitee.com/johng/gf/g/container/glist"
+    "gitee.com/johng/gf/g/container/gtype"
+    "testing"
+    "time"
+)
+
+const (
+    benchEntries = 100000 // 对比测试的任务数量
+)
+
+// 原有的分层时间轮
+type legacyTimer struct {
+    wheels     []*legacyWheel
+    length     int
+    number     int
+    intervalMs int64
+    nowMs      int64 // 模拟时钟(毫秒)
+}
+
+// 原有的单层时间轮
+type legacyWheel struct {
+    timer      *legacyTimer
+    level      int
+    slots      []*glist.List
+    number     int64
+    ticks      *gtype.Int64
+    totalMs    int64
+    createMs   int64
+    intervalMs int64
+}
+
+// 原有的任务项
+type legacyEntry struct {
+    wheel         *legacyWheel
+    job           JobFunc
+    times         *gtype.Int
+    create        int64
+    interval      int64
+    createMs      int64
+    intervalMs    int64
+    rawIntervalMs int64
+    driver        bool // 是否为转动上层时间轮的任务(模拟时钟下需要同步执行)
+}
+
+func newLegacyTimer(slot int, interval time.Duration, length int) *legacyTimer {
+    t := &legacyTimer {
+        wheels     : make([]*legacyWheel, length),
+        length     : length,
+        number     : slot,
+        intervalMs : interval.Nanoseconds()/1e6,
+    }
+    for i := 0; i < length; i++ {
+        if i > 0 {
+            n          := time.Duration(t.wheels[i - 1].totalMs)*time.Millisecond
+            w          := t.newWheel(i, slot, n)
+            t.wheels[i] = w
+            t.wheels[i - 1].addEntry(n, w.proceed, gDEFAULT_TIMES).driver = true
+        } else {
+            t.wheels[i] = t.newWheel(i, slot, interval)
+        }
+    }
+    return t
+}
+
+func (t *legacyTimer) newWheel(level int, slot int, interval time.Duration) *legacyWheel {
+    w := &legacyWheel {
+        timer      : t,
+        level      : level,
+        slots      : make([]*glist.List, slot),
+        number     : int64(slot),
+        ticks      : gtype.NewInt64(),
+        totalMs    : int64(slot)*interval.Nanoseconds()/1e6,
+        createMs   : t.nowMs,
+        intervalMs : interval.Nanoseconds()/1e6,
+    }
+    for i := int64(0); i < w.number; i++ {
+        w.slots[i] = glist.New()
+    }
+    return w
+}
+
+func (t *legacyTimer) add(interval time.Duration, job JobFunc) {
+    t.wheels[t.getLevelByIntervalMs(interval.Nanoseconds()/1e6)].addEntry(interval, job, gDEFAULT_TIMES)
+}
+
+// 转动一个刻度(模拟时钟前进一个刻度)
+func (t *legacyTimer) tick() {
+    t.nowMs += t.intervalMs
+    t.wheels[0].proceed()
+}
+
+func (t *legacyTimer) getLevelByIntervalMs(intervalMs int64) int {
+    pos, cmp := t.binSearchIndex(intervalMs)
+    switch cmp {
+        case  0: fallthrough
+        case -1:
+            i := pos
+            for ; i > 0; i-- {
+                if intervalMs > t.wheels[i].intervalMs && intervalMs <= t.wheels[i].totalMs {
+                    return i
+                }
+            }
+            return i
+
+        case  1:
+            i := pos
+            for ; i < t.length - 1; i++ {
+                if intervalMs > t.wheels[i].intervalMs && intervalMs <= t.wheels[i].totalMs {
+                    return i
+                }
+            }
+            return i
+    }
+    return 0
+}
+
+func (t *legacyTimer) binSearchIndex(n int64)(index int, result int) {
+    min := 0
+    max := t.length - 1
+    mid := 0
+    cmp := -2
+    for min <= max {
+        mid = int((min + max) / 2)
+        switch {
+            case t.wheels[mid].intervalMs == n : cmp =  0
+            case t.wheels[mid].intervalMs  > n : cmp = -1
+            case t.wheels[mid].intervalMs  < n : cmp =  1
+        }
+        switch cmp {
+            case -1 : max = mid - 1
+            case  1 : min = mid + 1
+            case  0 :
+                return mid, cmp
+        }
+    }
+    return mid, cmp
+}
+
+func (w *legacyWheel) addEntry(interval time.Duration, job JobFunc, times int) *legacyEntry {
+    ms  := interval.Nanoseconds()/1e6
+    num := ms/w.intervalMs
+    if num == 0 {
+        num = 1
+    }
+    ticks := w.ticks.Val()
+    entry := &legacyEntry {
+        wheel         : w,
+        job           : job,
+        times         : gtype.NewInt(times),
+        create        : ticks,
+        interval      : num,
+        createMs      : w.timer.nowMs,
+        intervalMs    : ms,
+        rawIntervalMs : ms,
+    }
+    w.slots[(ticks + num) % w.number].PushBack(entry)
+    return entry
+}
+
+func (w *legacyWheel) addEntryByParent(interval int64, parent *legacyEntry) {
+    num := interval/w.intervalMs
+    if num == 0 {
+        num = 1
+    }
+    ticks := w.ticks.Val()
+    entry := &legacyEntry {
+        wheel         : w,
+        job           : parent.job,
+        times         : parent.times,
+        create        : ticks,
+        interval      : num,
+        createMs      : w.timer.nowMs,
+        intervalMs    : interval,
+        rawIntervalMs : parent.rawIntervalMs,
+        driver        : parent.driver,
+    }
+    w.slots[(ticks + num) % w.number].PushBack(entry)
+}
+
+// 原有的刻度逻辑，slot中的任务同步处理，可运行的任务与原有实现一样异步执行(转动上层时间轮的任务除外)
+func (w *legacyWheel) proceed() {
+    n      := w.ticks.Add(1)
+    l      := w.slots[int(n%w.number)]
+    length := l.Len()
+    nowMs  := w.timer.nowMs
+    for i := length; i > 0; i-- {
+        v := l.PopFront()
+        if v == nil {
+            break
+        }
+        entry := v.(*legacyEntry)
+        runnable, addable := entry.check(n, nowMs)
+        if runnable {
+            if entry.driver {
+                entry.job()
+            } else {
+                go entry.job()
+            }
+        }
+        if addable {
+            t := entry.wheel.timer
+            t.wheels[t.getLevelByIntervalMs(entry.rawIntervalMs)].addEntryByParent(entry.rawIntervalMs, entry)
+        }
+    }
+}
+
+func (entry *legacyEntry) check(nowTicks int64, nowMs int64) (runnable, addable bool) {
+    if diff := nowTicks - entry.create; diff > 0 && diff%entry.interval == 0 {
+        if entry.wheel.level > 0 {
+            diffMs := nowMs - entry.createMs
+            switch {
+                case diffMs < entry.wheel.timer.intervalMs:
+                    entry.wheel.slots[(nowTicks+entry.interval)%entry.wheel.number].PushBack(entry)
+                    return false, false
+
+                case diffMs >= entry.wheel.timer.intervalMs:
+                    if leftMs := entry.intervalMs - diffMs; leftMs > entry.wheel.timer.intervalMs {
+                        t := entry.wheel.timer
+                        t.wheels[t.getLevelByIntervalMs(leftMs)].addEntryByParent(leftMs, entry)
+                        return false, false
+                    }
+            }
+        }
+        return true, true
+    }
+    return false, true
+}
+
+// 新的时间轮，不启动ticker，由测试代码手动转动刻度
+func newBenchTimer() *Timer {
+    t := New(gDEFAULT_SLOT_NUMBER, gDEFAULT_WHEEL_INTERVAL*time.Millisecond, gDEFAULT_WHEEL_LEVEL)
+    t.Close()
+    return t
+}
+
+// 任务的运行间隔分布在1秒到1小时之间(例如会话/缓存项过期)
+func benchInterval(i int) time.Duration {
+    return time.Duration(i%3600 + 1)*time.Second
+}
+
+func BenchmarkWheel_Add(b *testing.B) {
+    t := newBenchTimer()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        t.Add(benchInterval(i), func() {})
+    }
+}
+
+func BenchmarkWheel_LegacyAdd(b *testing.B) {
+    t := newLegacyTimer(gDEFAULT_SLOT_NUMBER, gDEFAULT_WHEEL_INTERVAL*time.Millisecond, gDEFAULT_WHEEL_LEVEL)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        t.add(benchInterval(i), func() {})
+    }
+}
+
+func BenchmarkWheel_AddClose(b *testing.B) {
+    t := newBenchTimer()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        t.Add(benchInterval(i), func() {}).Close()
+    }
+}
+
+// 大量任务时每个刻度的处理开销
+func BenchmarkWheel_Tick(b *testing.B) {
+    t := newBenchTimer()
+    for i := 0; i < benchEntries; i++ {
+        t.Add(benchInterval(i), func() {})
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        t.proceed()
+    }
+}
+
+func BenchmarkWheel_LegacyTick(b *testing.B) {
+    t := newLegacyTimer(gDEFAULT_SLOT_NUMBER, gDEFAULT_WHEEL_INTERVAL*time.Millisecond, gDEFAULT_WHEEL_LEVEL)
+    for i := 0; i < benchEntries; i++ {
+        t.add(benchInterval(i), func() {})
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        t.tick()
+    }
+}
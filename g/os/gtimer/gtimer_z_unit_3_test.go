@@ -0,0 +1,91 @@
+// Copyright 2019 gf Author(https://gitee.com/johng/gf). All Rights Reserved.
+//
+// This Source Code Form is subject to the terms of the MIT License.
+// If a copy of the MIT was not distributed with this file,
+// You can obtain one at https://gitee.com/johng/gf.
+
+// Timing wheel & Stats
+
+package gtimer_test
+
+import (
+    "gitee.com/johng/gf/g/container/gtype"
+    "gitee.com/johng/gf/g/os/gtimer"
+    "gitee.com/johng/gf/g/util/gtest"
+    "testing"
+    "time"
+)
+
+func TestTimer_Stats(t *testing.T) {
+    gtest.Case(t, func() {
+        timer   := New()
+        entries := make([]*gtimer.Entry, 0)
+        for i := 0; i < 100; i++ {
+            entries = append(entries, timer.Add(time.Hour, func() {}))
+        }
+        gtest.Assert(timer.Stats().Entries, 100)
+        for i := 0; i < 50; i++ {
+            entries[i].Close()
+            entries[i].Close()
+        }
+        gtest.Assert(timer.Stats().Entries, 50)
+
+        count := gtype.NewInt()
+        timer.AddOnce(20*time.Millisecond, func() {
+            count.Add(1)
+        })
+        timer.AddTimes(20*time.Millisecond, 2, func() {
+            count.Add(1)
+        })
+        gtest.Assert(timer.Stats().Entries, 52)
+        time.Sleep(200*time.Millisecond)
+        gtest.Assert(count.Val(), 3)
+        stats := timer.Stats()
+        gtest.Assert(stats.Entries, 50)
+        gtest.Assert(stats.Ticks >= 15, true)
+        gtest.Assert(stats.Lag >= 0, true)
+        gtest.Assert(stats.MaxLag >= stats.Lag, true)
+        gtest.Assert(timer.Interval(), 10*time.Millisecond)
+        timer.Close()
+    })
+}
+
+func TestTimer_Cascade(t *testing.T) {
+    // 两层时间轮只能直接容纳16个刻度(160毫秒)，更长的间隔通过级联处理
+    gtest.Case(t, func() {
+        timer := gtimer.New(4, 10*time.Millisecond, 2)
+        times := make([]*gtype.Int, 3)
+        for i, interval := range []time.Duration{30*time.Millisecond, 150*time.Millisecond, 500*time.Millisecond} {
+            n       := gtype.NewInt()
+            times[i] = n
+            timer.Add(interval, func() {
+                n.Add(1)
+            })
+        }
+        time.Sleep(470*time.Millisecond)
+        gtest.Assert(times[0].Val() >= 13 && times[0].Val() <= 15, true)
+        gtest.Assert(times[1].Val(), 3)
+        gtest.Assert(times[2].Val(), 0)
+        time.Sleep(100*time.Millisecond)
+        gtest.Assert(times[2].Val(), 1)
+        timer.Close()
+    })
+}
+
+func TestTimer_ManyEntries(t *testing.T) {
+    gtest.Case(t, func() {
+        timer := New()
+        count := gtype.NewInt()
+        for i := 0; i < 100000; i++ {
+            timer.AddOnce(time.Duration(50 + i%100)*time.Millisecond, func() {
+                count.Add(1)
+            })
+        }
+        for i := 0; i < 50 && count.Val() < 100000; i++ {
+            time.Sleep(100*time.Millisecond)
+        }
+        gtest.Assert(count.Val(), 100000)
+        gtest.Assert(timer.Stats().Entries, 0)
+        timer.Close()
+    })
+}
@@ -39,6 +39,13 @@ type Page struct {
     AjaxActionName string         // AJAX方法名，当该属性有值时，表示使用AJAX分页
 }
 
+// 分页数据源，例如数据库分页查询结果(gdb.Model.Paginate返回的*gdb.Pagination)
+type Paginator interface {
+    GetTotal() int // 总记录数
+    GetSize()  int // 每页记录数
+    GetPage()  int // 当前页码
+}
+
 // 创建一个分页对象，输入参数分别为：
 // 总数量、每页数量、当前页码、当前的URL(URI+QUERY)、(可选)路由规则(例如: /user/list/:page、/order/list/*page、/order/list/{page}.html)
 func New(TotalSize, perPage int,  CurrentPage interface{}, url string, router...*ghttp.Router) *Page {
@@ -67,6 +74,11 @@ func New(TotalSize, perPage int,  CurrentPage interface{}, url string, router...
     return page
 }
 
+// 使用分页数据源(例如数据库分页查询结果gdb.Model.Paginate)创建一个分页对象，其他参数同New方法
+func NewFromPagination(pagination Paginator, url string, router...*ghttp.Router) *Page {
+    return New(pagination.GetTotal(), pagination.GetSize(), pagination.GetPage(), url, router...)
+}
+
 // 启用AJAX分页
 func (page *Page) EnableAjax(actionName string) {
     page.AjaxActionName = actionName
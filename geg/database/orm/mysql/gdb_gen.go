@@ -0,0 +1,15 @@
+package main
+
+import (
+    "gitee.com/johng/gf/g/frame/ggen"
+    "gitee.com/johng/gf/g/os/gcmd"
+    "gitee.com/johng/gf/g/os/glog"
+)
+
+// 执行：go run gdb_gen.go gen --tables=user --path=./model
+func main() {
+    gcmd.BindHandle("gen", ggen.Command)
+    if err := gcmd.AutoRun(); err != nil {
+        glog.Error(err)
+    }
+}